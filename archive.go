@@ -0,0 +1,179 @@
+package mirrortransform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CrawlArchive processes the entries of the zip or tar archive at
+// archivePath (".zip", ".tar", ".tar.gz" or ".tgz") as if they were the
+// files of InputDir: entries go through the same filters and patterns as in
+// Crawl, and outputs are written to OutputDir. Matching entries are extracted
+// to a temporary directory, which is removed when the run ends, and callbacks
+// receive the extracted path as the input path. Paths in the result name
+// the entry inside the archive, such as "assets.zip/images/a.jpg", and so
+// do failures in the retry queue and QuarantineDir; RetryFailed leaves
+// them for the next CrawlArchive of the archive.
+// With WithDryRun nothing is extracted.
+func (mt *mirrorTransform) CrawlArchive(ctx context.Context, archivePath string, opts ...RunOption) (*Result, error) {
+	staging, err := os.MkdirTemp("", "mirror-archive-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	run := newRunState(false, opts...)
+	run.collectResult = true
+	run.archive = archivePath
+	result, err := mt.runFinite(ctx, "CrawlArchive", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		return mt.crawlArchive(ctx, archivePath, staging, taskChan, run)
+	})
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+		archiveResultPaths(result, staging, archivePath)
+	}
+	return result, err
+}
+
+// crawlArchive extracts every matching entry of the archive at archivePath
+// below staging and sends a task for it.
+func (mt *mirrorTransform) crawlArchive(ctx context.Context, archivePath, staging string, taskChan chan<- fileTask, run *runState) error {
+	name := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to open archive %q: %w", archivePath, err)
+		}
+		defer reader.Close()
+
+		for _, file := range reader.File {
+			info := file.FileInfo()
+			if !info.Mode().IsRegular() {
+				continue
+			}
+			err := mt.stageArchiveEntry(ctx, file.Name, info, staging, taskChan, run, func() (io.ReadCloser, error) {
+				return file.Open()
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		f, err := os.Open(archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to open archive %q: %w", archivePath, err)
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if !strings.HasSuffix(name, ".tar") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return fmt.Errorf("failed to read archive %q: %w", archivePath, err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+
+		reader := tar.NewReader(r)
+		for {
+			header, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read archive %q: %w", archivePath, err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			err = mt.stageArchiveEntry(ctx, header.Name, header.FileInfo(), staging, taskChan, run, func() (io.ReadCloser, error) {
+				return io.NopCloser(reader), nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unsupported archive format %q: expected .zip, .tar, .tar.gz or .tgz", archivePath)
+	}
+}
+
+// stageArchiveEntry extracts the archive entry name below staging with the
+// content returned by open and sends a task for it, if it matches.
+func (mt *mirrorTransform) stageArchiveEntry(ctx context.Context, name string, info fs.FileInfo, staging string, taskChan chan<- fileTask, run *runState, open func() (io.ReadCloser, error)) error {
+	// Check context cancellation
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	relPath, ok := mt.importRelPath(name, run)
+	if !ok {
+		return nil
+	}
+	path := filepath.Join(staging, relPath)
+
+	// Entries have no directories to skip, so check their parents
+	skipped, err := mt.inSkippedDir(relPath)
+	if err != nil || skipped {
+		return err
+	}
+	pattern, err := mt.entryPattern(path, relPath, newFileEntry(path, nil))
+	if err != nil || pattern == "" {
+		return err
+	}
+
+	// Dry runs only count what would be processed
+	if run.options.dryRun {
+		run.matched.Add(1)
+		run.skipped.Add(1)
+		return nil
+	}
+
+	r, err := open()
+	if err == nil {
+		err = extractEntry(path, info, r)
+		r.Close()
+	}
+	if err != nil {
+		return mt.handlePathError(path, fmt.Errorf("failed to extract %q: %w", name, err), "extract")
+	}
+
+	staged, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat extracted file %q: %w", path, err)
+	}
+	return mt.sendTask(ctx, path, relPath, staged, TaskEventImport, pattern, taskChan, run)
+}
+
+// archiveResultPaths replaces the staging directory in the paths of result
+// with archivePath.
+func archiveResultPaths(result *Result, staging, archivePath string) {
+	rename := func(path string) string {
+		if rel, err := filepath.Rel(staging, path); err == nil && filepath.IsLocal(rel) {
+			return filepath.Join(archivePath, rel)
+		}
+		return path
+	}
+	for i := range result.Errors {
+		result.Errors[i].Path = rename(result.Errors[i].Path)
+	}
+	for i := range result.Unprocessed {
+		result.Unprocessed[i] = rename(result.Unprocessed[i])
+	}
+}
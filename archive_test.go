@@ -0,0 +1,253 @@
+package mirrortransform
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testArchiveEntries are the entries of the archives written by writeTestArchive.
+var testArchiveEntries = map[string]string{
+	"a.jpg":          "a",
+	"photos/b.jpg":   "bb",
+	"photos/c.txt":   "text",
+	"skip/d.jpg":     "skipped",
+	"../escape.jpg":  "evil",
+	".hidden/e.jpg":  "hidden",
+	"photos/bad.jpg": "bad",
+}
+
+// writeTestArchive writes testArchiveEntries to a zip, tar or tar.gz file,
+// depending on the extension of path.
+func writeTestArchive(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer f.Close()
+
+	modTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if strings.HasSuffix(path, ".zip") {
+		zw := zip.NewWriter(f)
+		for name, content := range testArchiveEntries {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+			if err != nil {
+				t.Fatalf("Failed to write zip entry: %v", err)
+			}
+			if _, err := io.WriteString(w, content); err != nil {
+				t.Fatalf("Failed to write zip entry: %v", err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("Failed to close zip writer: %v", err)
+		}
+		return
+	}
+
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".tar.gz") {
+		gz := gzip.NewWriter(f)
+		defer func() {
+			if err := gz.Close(); err != nil {
+				t.Fatalf("Failed to close gzip writer: %v", err)
+			}
+		}()
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range testArchiveEntries {
+		header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: modTime}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := io.WriteString(tw, content); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+}
+
+// TestCrawlArchive tests that archive entries are processed like the files
+// of InputDir, without extracting them into InputDir.
+func TestCrawlArchive(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"assets.zip", "assets.tar", "assets.tar.gz"} {
+		name := name // capture range variable
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			outputDir := filepath.Join(testDir, "output")
+			archivePath := filepath.Join(testDir, name)
+
+			if err := os.MkdirAll(inputDir, 0755); err != nil {
+				t.Fatalf("Failed to create input directory: %v", err)
+			}
+			writeTestArchive(t, archivePath)
+
+			var mu sync.Mutex
+			var staged []string
+
+			config := Config{
+				InputDir:        inputDir,
+				OutputDir:       outputDir,
+				Patterns:        []string{"**/*.jpg"},
+				ExcludePatterns: []string{"skip"},
+				ContinueOnError: true,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					mu.Lock()
+					staged = append(staged, inputPath)
+					mu.Unlock()
+					if filepath.Base(inputPath) == "bad.jpg" {
+						return false, errors.New("corrupt")
+					}
+					return true, CopyFile(inputPath, outputPath, CopyOptions{})
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			result, err := mt.CrawlArchive(context.Background(), archivePath)
+			if err == nil {
+				t.Fatal("Expected the failure of bad.jpg to be reported")
+			}
+			if result.Processed != 2 || result.Failed != 1 {
+				t.Errorf("Unexpected counts: %+v", result)
+			}
+			if len(result.Errors) != 1 || result.Errors[0].Path != filepath.Join(archivePath, "photos", "bad.jpg") {
+				t.Errorf("Expected the error to name the archive entry, got %v", result.Errors)
+			}
+
+			for relPath, content := range map[string]string{"a.jpg": "a", "photos/b.jpg": "bb"} {
+				data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+				if err != nil || string(data) != content {
+					t.Errorf("Unexpected output for %s: %q (%v)", relPath, data, err)
+				}
+			}
+
+			entries, err := os.ReadDir(inputDir)
+			if err != nil || len(entries) != 0 {
+				t.Errorf("Expected nothing to be extracted into InputDir, got %v (%v)", entries, err)
+			}
+			for _, path := range staged {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Expected staged file %s to be removed", path)
+				}
+			}
+		})
+	}
+}
+
+// TestCrawlArchiveUnsupported tests that unknown archive formats are rejected.
+func TestCrawlArchiveUnsupported(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	config := Config{
+		InputDir:  testDir,
+		OutputDir: filepath.Join(t.TempDir(), "output"),
+		Patterns:  []string{"**/*"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	_, err = mt.CrawlArchive(context.Background(), filepath.Join(testDir, "assets.rar"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported archive format") {
+		t.Errorf("Expected unsupported format error, got %v", err)
+	}
+}
+
+// TestCrawlArchiveFailures tests that failed archive entries are recorded
+// under the archive in the retry queue and the quarantine, and released
+// once a later crawl of the archive succeeds.
+func TestCrawlArchiveFailures(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	quarantineDir := filepath.Join(testDir, "quarantine")
+	archivePath := filepath.Join(testDir, "assets.zip")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+	writeTestArchive(t, archivePath)
+
+	var broken sync.Map
+	broken.Store("bad.jpg", true)
+	stateStore := NewMemoryStateStore()
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"skip"},
+		ContinueOnError: true,
+		RetryQueue:      true,
+		StateStore:      stateStore,
+		QuarantineDir:   quarantineDir,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if _, ok := broken.Load(filepath.Base(inputPath)); ok {
+				return false, errors.New("corrupt")
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if _, err := mt.CrawlArchive(context.Background(), archivePath); err == nil {
+		t.Fatal("Expected the failure of bad.jpg to be reported")
+	}
+
+	key := filepath.ToSlash(filepath.Join(archivePath, "photos", "bad.jpg"))
+	state, ok, err := stateStore.Load(key)
+	if err != nil || !ok || !state.Retry || state.Archive != archivePath {
+		t.Errorf("Expected a retry for %s from the archive, got %+v (%v, %v)", key, state, ok, err)
+	}
+	record, err := os.ReadFile(filepath.Join(quarantineDir, "assets.zip", "photos", "bad.jpg"+QuarantineErrorSuffix))
+	if err != nil || !strings.Contains(string(record), "archive: "+archivePath) {
+		t.Errorf("Expected the quarantine to name the archive, got %q (%v)", record, err)
+	}
+
+	// RetryFailed leaves archive entries to CrawlArchive
+	if _, err := mt.RetryFailed(context.Background()); err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	if _, ok, _ := stateStore.Load(key); !ok {
+		t.Error("Expected RetryFailed to keep the archive entry queued")
+	}
+
+	broken.Delete("bad.jpg")
+	if _, err := mt.CrawlArchive(context.Background(), archivePath); err != nil {
+		t.Fatalf("CrawlArchive failed: %v", err)
+	}
+	if _, ok, _ := stateStore.Load(key); ok {
+		t.Error("Expected the retry to be cleared")
+	}
+	if _, err := os.Stat(filepath.Join(quarantineDir, "assets.zip", "photos", "bad.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected the quarantined copy to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AutoConcurrency as Config.Concurrency sizes the workers of each run
+// automatically between MinConcurrency and MaxConcurrency, which defaults
+// to four times the number of CPUs then. The pool starts at the number of
+// CPUs and is resized every second, towards more workers while that raises
+// the throughput and towards fewer while it does not, so IO-bound callbacks
+// find a good parallelism on fast and slow storage alike.
+const AutoConcurrency = -1
+
+// defaultTuneInterval is how often an automatic worker pool is resized.
+const defaultTuneInterval = time.Second
+
+// tuneThreshold is the relative change in throughput between two intervals
+// that counts as better or worse.
+const tuneThreshold = 0.05
+
+// autoConcurrency reports whether the worker pool is sized automatically.
+func (mt *mirrorTransform) autoConcurrency() bool {
+	return !mt.config.Ordered && mt.currentSettings().concurrency == AutoConcurrency
+}
+
+// concurrencyBounds returns the range of an automatic worker pool.
+func (mt *mirrorTransform) concurrencyBounds() (int, int) {
+	high := mt.currentSettings().maxConcurrency
+	if high <= 0 {
+		high = 4 * runtime.NumCPU()
+	}
+	low := max(mt.config.MinConcurrency, 1)
+	return min(low, high), high
+}
+
+// tuneConcurrency resizes pool every interval by hill climbing on the
+// throughput of run's callbacks, until ctx is done or the pool's workers
+// have all exited. It leaves the pool alone while the concurrency is fixed
+// or nothing is queued or processed.
+func (mt *mirrorTransform) tuneConcurrency(ctx context.Context, run *runState, pool *workerPool) {
+	ticker := time.NewTicker(mt.tuneInterval)
+	defer ticker.Stop()
+
+	direction := 1
+	var lastRate float64
+	lastDone, lastTime, lastTick := run.callbacks.Load(), run.callbackTime.Load(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			size := pool.size()
+			if size == 0 {
+				return
+			}
+			done, busy := run.callbacks.Load(), run.callbackTime.Load()
+			completed := done - lastDone
+			rate := float64(completed) / now.Sub(lastTick).Seconds()
+			var latency time.Duration
+			if completed > 0 {
+				latency = time.Duration((busy - lastTime) / completed)
+			}
+			lastDone, lastTime, lastTick = done, busy, now
+
+			// Measure only while there is work to do
+			backlog := run.queued.Load() > 0
+			if !mt.autoConcurrency() || (!backlog && completed == 0) {
+				lastRate = 0
+				continue
+			}
+
+			// Keep going while it helps, turn back when it hurts, and give
+			// back workers that make no difference
+			switch {
+			case lastRate == 0:
+				direction = 1
+			case rate > lastRate*(1+tuneThreshold):
+			case rate < lastRate*(1-tuneThreshold):
+				direction = -direction
+			default:
+				direction = -1
+			}
+			lastRate = rate
+			if direction > 0 && !backlog {
+				continue
+			}
+
+			low, high := mt.concurrencyBounds()
+			target := min(max(size+direction*max(size/4, 1), low), high)
+			if target == size {
+				continue
+			}
+			mt.log().Debug("resizing workers", "from", size, "to", target,
+				"filesPerSecond", rate, "latency", latency)
+			pool.resize(target)
+		}
+	}
+}
+
+// concurrencyFile is the serialized form of Concurrency: a number or "auto".
+type concurrencyFile int
+
+// UnmarshalJSON accepts a number or "auto".
+func (c *concurrencyFile) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		return c.parse(s)
+	}
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid concurrency %s: expected a number or \"auto\"", data)
+	}
+	*c = concurrencyFile(n)
+	return nil
+}
+
+// UnmarshalYAML accepts a number or "auto".
+func (c *concurrencyFile) UnmarshalYAML(node *yaml.Node) error {
+	var n int
+	if err := node.Decode(&n); err == nil {
+		*c = concurrencyFile(n)
+		return nil
+	}
+	return c.parse(node.Value)
+}
+
+// parse accepts "auto".
+func (c *concurrencyFile) parse(s string) error {
+	if s != "auto" {
+		return fmt.Errorf("invalid concurrency %q: expected a number or \"auto\"", s)
+	}
+	*c = AutoConcurrency
+	return nil
+}
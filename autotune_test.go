@@ -0,0 +1,90 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestAutoConcurrency tests that an automatic pool grows while more workers
+// raise the throughput of slow callbacks.
+func TestAutoConcurrency(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	var files []string
+	for i := 0; i < 200; i++ {
+		files = append(files, fmt.Sprintf("%03d.jpg", i))
+	}
+	createTestFiles(t, inputDir, files)
+
+	var running, peak atomic.Int32
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      filepath.Join(testDir, "output"),
+		Patterns:       []string{"**/*.jpg"},
+		Concurrency:    AutoConcurrency,
+		MaxConcurrency: runtime.NumCPU() + 8,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return true, nil
+		},
+	}
+
+	m, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := m.(*mirrorTransform)
+	mt.tuneInterval = 50 * time.Millisecond
+
+	if initial := mt.concurrency(); initial != runtime.NumCPU() {
+		t.Errorf("Expected to start with %d workers, got %d", runtime.NumCPU(), initial)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if p := int(peak.Load()); p <= runtime.NumCPU() {
+		t.Errorf("Expected the pool to grow beyond %d workers, peaked at %d", runtime.NumCPU(), p)
+	}
+}
+
+// TestConcurrencyFile tests that config files accept a number or "auto".
+func TestConcurrencyFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input string
+		want  int
+		ok    bool
+	}{
+		{"4", 4, true},
+		{`"auto"`, AutoConcurrency, true},
+		{`"fast"`, 0, false},
+	}
+	for _, tt := range tests {
+		var fromJSON, fromYAML concurrencyFile
+		errJSON := json.Unmarshal([]byte(tt.input), &fromJSON)
+		errYAML := yaml.Unmarshal([]byte(tt.input), &fromYAML)
+		if tt.ok && (errJSON != nil || errYAML != nil || int(fromJSON) != tt.want || int(fromYAML) != tt.want) {
+			t.Errorf("%s: got %d/%d, errors %v/%v", tt.input, fromJSON, fromYAML, errJSON, errYAML)
+		}
+		if !tt.ok && (errJSON == nil || errYAML == nil) {
+			t.Errorf("%s: expected errors, got %v/%v", tt.input, errJSON, errYAML)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultBackoffInitialDelay is used when FailureBackoff.InitialDelay is not set.
+	defaultBackoffInitialDelay = time.Minute
+
+	// defaultBackoffMaxDelay is used when FailureBackoff.MaxDelay is not set.
+	defaultBackoffMaxDelay = 24 * time.Hour
+)
+
+// ParkCallback is called when a path is parked after too many failures.
+// inputPath is the full path of the source file.
+type ParkCallback func(inputPath string, state PathState)
+
+// FailureBackoff configures how paths that fail repeatedly are retried.
+// After each failure the path is skipped for a delay that doubles with every
+// consecutive failure. A successful run or a change to the input clears the history.
+type FailureBackoff struct {
+	// InitialDelay is the delay after the first failure.
+	// Defaults to one minute if not set.
+	InitialDelay time.Duration
+
+	// MaxDelay caps the delay between attempts.
+	// Defaults to 24 hours if not set.
+	MaxDelay time.Duration
+
+	// MaxFailures parks a path after this many consecutive failures, so it is
+	// no longer retried until the input changes. Zero never parks.
+	MaxFailures int
+
+	// ParkCallback is called when a path is parked.
+	ParkCallback ParkCallback
+}
+
+// failureTracker applies FailureBackoff and keeps the RetryQueue using a
+// StateStore.
+type failureTracker struct {
+	store   StateStore
+	backoff *FailureBackoff
+	retry   bool
+	now     func() time.Time
+}
+
+// newFailureTracker returns a tracker for the configured backoff and retry
+// queue, or nil if both are disabled.
+func newFailureTracker(config *Config) *failureTracker {
+	if config.FailureBackoff == nil && !config.RetryQueue {
+		return nil
+	}
+
+	store := config.StateStore
+	if store == nil {
+		store = NewMemoryStateStore()
+	}
+	ft := &failureTracker{store: store, retry: config.RetryQueue, now: time.Now}
+	if config.FailureBackoff == nil {
+		return ft
+	}
+
+	backoff := *config.FailureBackoff
+	if backoff.InitialDelay <= 0 {
+		backoff.InitialDelay = defaultBackoffInitialDelay
+	}
+	if backoff.MaxDelay <= 0 {
+		backoff.MaxDelay = defaultBackoffMaxDelay
+	}
+	ft.backoff = &backoff
+	return ft
+}
+
+// allow reports whether the task may be processed now.
+func (ft *failureTracker) allow(task fileTask) (bool, error) {
+	if ft.backoff == nil {
+		return true, nil
+	}
+	state, ok, err := ft.store.Load(task.stateKey())
+	if err != nil {
+		return false, fmt.Errorf("failed to load state for %q: %w", task.InputPath, err)
+	}
+	if !ok || inputChanged(state, task) {
+		return true, nil
+	}
+	if state.Parked {
+		return false, nil
+	}
+	return !ft.now().Before(state.NextAttempt), nil
+}
+
+// recordFailure updates the failure history of the task, parks it if needed
+// and queues it for RetryFailed. It reports whether the failure is final:
+// always without backoff, otherwise when it parked the task.
+func (ft *failureTracker) recordFailure(task fileTask, failure error) (bool, error) {
+	key := task.stateKey()
+	state, ok, err := ft.store.Load(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to load state for %q: %w", task.InputPath, err)
+	}
+	if !ok || inputChanged(state, task) {
+		state = PathState{}
+	}
+
+	now := ft.now()
+	state.Failures++
+	state.LastError = failure.Error()
+	state.LastFailure = now
+	state.Retry = state.Retry || ft.retry
+	state.Archive = task.archive
+	if task.info != nil {
+		state.Size = task.info.Size()
+		state.ModTime = task.info.ModTime()
+	}
+	if ft.backoff == nil {
+		if err := ft.store.Save(key, state); err != nil {
+			return false, fmt.Errorf("failed to save state for %q: %w", task.InputPath, err)
+		}
+		return true, nil
+	}
+	state.NextAttempt = now.Add(ft.delay(state.Failures))
+
+	parked := ft.backoff.MaxFailures > 0 && state.Failures >= ft.backoff.MaxFailures && !state.Parked
+	if parked {
+		state.Parked = true
+	}
+
+	if err := ft.store.Save(key, state); err != nil {
+		return false, fmt.Errorf("failed to save state for %q: %w", task.InputPath, err)
+	}
+
+	if parked && ft.backoff.ParkCallback != nil {
+		ft.backoff.ParkCallback(task.InputPath, state)
+	}
+	return parked, nil
+}
+
+// recordDeferred queues a task that a run matched but did not process for
+// RetryFailed, keeping its failure history.
+func (ft *failureTracker) recordDeferred(task fileTask) error {
+	if !ft.retry {
+		return nil
+	}
+	key := task.stateKey()
+	state, ok, err := ft.store.Load(key)
+	if err != nil {
+		return fmt.Errorf("failed to load state for %q: %w", task.InputPath, err)
+	}
+	if !ok || inputChanged(state, task) {
+		state = PathState{}
+		if task.info != nil {
+			state.Size = task.info.Size()
+			state.ModTime = task.info.ModTime()
+		}
+	}
+	state.Retry = true
+	state.Archive = task.archive
+	if err := ft.store.Save(key, state); err != nil {
+		return fmt.Errorf("failed to save state for %q: %w", task.InputPath, err)
+	}
+	return nil
+}
+
+// recordSuccess clears the failure history of the task.
+func (ft *failureTracker) recordSuccess(task fileTask) error {
+	if err := ft.store.Delete(task.stateKey()); err != nil {
+		return fmt.Errorf("failed to clear state for %q: %w", task.InputPath, err)
+	}
+	return nil
+}
+
+// delay returns the backoff delay after the given number of failures.
+func (ft *failureTracker) delay(failures int) time.Duration {
+	delay := ft.backoff.InitialDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= ft.backoff.MaxDelay {
+			return ft.backoff.MaxDelay
+		}
+	}
+	if delay > ft.backoff.MaxDelay {
+		return ft.backoff.MaxDelay
+	}
+	return delay
+}
+
+// inputChanged reports whether the input differs from when state was recorded.
+func inputChanged(state PathState, task fileTask) bool {
+	if task.info == nil {
+		return false
+	}
+	return state.Size != task.info.Size() || !state.ModTime.Equal(task.info.ModTime())
+}
@@ -0,0 +1,105 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCrawlFailureBackoff tests that failing paths are skipped by later runs.
+func TestCrawlFailureBackoff(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"bad.jpg"})
+
+	var attempts int32
+	var parked int32
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 1,
+		FailureBackoff: &FailureBackoff{
+			InitialDelay: time.Hour,
+			MaxFailures:  2,
+			ParkCallback: func(inputPath string, state PathState) {
+				atomic.AddInt32(&parked, 1)
+			},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&attempts, 1)
+			return false, fmt.Errorf("corrupt image")
+		},
+	}
+
+	m, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := m.(*mirrorTransform)
+
+	// First run fails
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected error from failing callback")
+	}
+
+	// Second run is within the backoff delay and skips the file
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Expected backing-off path to be skipped, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+
+	// After the delay the file is retried and parked
+	mt.failures.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected error from failing callback")
+	}
+	if attempts != 2 || parked != 1 {
+		t.Errorf("Expected 2 attempts and 1 park, got %d attempts and %d parks", attempts, parked)
+	}
+
+	// Parked paths are not retried
+	mt.failures.now = func() time.Time { return time.Now().Add(100 * time.Hour) }
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Expected parked path to be skipped, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected parked path not to be retried, got %d attempts", attempts)
+	}
+
+	// Changing the input clears the history
+	if err := os.WriteFile(filepath.Join(inputDir, "bad.jpg"), []byte("replaced content"), 0644); err != nil {
+		t.Fatalf("Failed to replace file: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected error from failing callback")
+	}
+	if attempts != 3 {
+		t.Errorf("Expected changed input to be retried, got %d attempts", attempts)
+	}
+}
+
+// TestFailureBackoffDelay tests exponential delay growth.
+func TestFailureBackoffDelay(t *testing.T) {
+	t.Parallel()
+	ft := newFailureTracker(&Config{
+		FailureBackoff: &FailureBackoff{InitialDelay: time.Second, MaxDelay: 10 * time.Second},
+	})
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		if got := ft.delay(i + 1); got != want {
+			t.Errorf("delay(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+}
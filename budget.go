@@ -0,0 +1,126 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"sync"
+)
+
+// byteBudget limits the total input size of the files being processed at a
+// time to MaxInFlightBytes. Files are admitted in the order they asked, so
+// a large file is not starved by a stream of small ones, and a file larger
+// than the whole budget runs once nothing else is in flight.
+type byteBudget struct {
+	limit int64
+
+	mu      sync.Mutex
+	used    int64
+	waiters []*budgetWaiter
+}
+
+// budgetWaiter is a file waiting for its share of the budget.
+type budgetWaiter struct {
+	size  int64
+	ready chan struct{}
+}
+
+// newByteBudget returns the budget for the configured limit, or nil if it
+// is disabled.
+func newByteBudget(config *Config) *byteBudget {
+	if config.MaxInFlightBytes <= 0 {
+		return nil
+	}
+	return &byteBudget{limit: config.MaxInFlightBytes}
+}
+
+// acquire waits until size bytes fit in the budget and reports whether they
+// were taken before ctx was done. It always succeeds without a limit.
+func (b *byteBudget) acquire(ctx context.Context, size int64) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	if len(b.waiters) == 0 && b.fits(size) {
+		b.used += size
+		b.mu.Unlock()
+		return true
+	}
+	w := &budgetWaiter{size: size, ready: make(chan struct{})}
+	b.waiters = append(b.waiters, w)
+	b.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-w.ready:
+		// Granted meanwhile, so give the bytes back
+		b.used -= size
+	default:
+		for i, waiter := range b.waiters {
+			if waiter == w {
+				b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+				break
+			}
+		}
+	}
+	b.grant()
+	return false
+}
+
+// release returns size bytes taken by acquire.
+func (b *byteBudget) release(size int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= size
+	b.grant()
+}
+
+// fits reports whether size bytes can be taken now. It must be called with
+// mu held.
+func (b *byteBudget) fits(size int64) bool {
+	return b.used == 0 || b.used+size <= b.limit
+}
+
+// grant admits the waiters at the head of the queue that fit. It must be
+// called with mu held.
+func (b *byteBudget) grant() {
+	for len(b.waiters) > 0 && b.fits(b.waiters[0].size) {
+		w := b.waiters[0]
+		b.waiters = b.waiters[1:]
+		b.used += w.size
+		close(w.ready)
+	}
+}
+
+// inFlight returns the bytes currently taken.
+func (b *byteBudget) inFlight() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// taskSize returns the input size task counts against MaxInFlightBytes,
+// stat'ing the input if the scan did not. Inputs that cannot be stat'ed
+// count as empty; the callback reports the error.
+func taskSize(task fileTask) int64 {
+	if task.info != nil {
+		return task.Size
+	}
+	if info, err := os.Stat(task.InputPath); err == nil {
+		return info.Size()
+	}
+	return 0
+}
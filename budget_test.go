@@ -0,0 +1,131 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMaxInFlightBytes tests that no more input bytes are processed at a
+// time than the budget allows, and that a file larger than the budget runs
+// alone.
+func TestMaxInFlightBytes(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg", "f.jpg"})
+	if err := os.WriteFile(filepath.Join(inputDir, "huge.jpg"), make([]byte, 100), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	var mu sync.Mutex
+	var inFlight, peak int64
+	var hugeAlone atomic.Bool
+	config := Config{
+		InputDir:         inputDir,
+		OutputDir:        filepath.Join(testDir, "output"),
+		Patterns:         []string{"**/*.jpg"},
+		Concurrency:      4,
+		MaxConcurrency:   4,
+		MaxInFlightBytes: 24,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			info, err := os.Stat(inputPath)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			inFlight += info.Size()
+			if filepath.Base(inputPath) == "huge.jpg" {
+				hugeAlone.Store(inFlight == info.Size())
+			} else {
+				peak = max(peak, inFlight)
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			inFlight -= info.Size()
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	result, err := mt.CrawlWithResult(context.Background())
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if result.Processed != 7 {
+		t.Errorf("Expected 7 processed files, got %d", result.Processed)
+	}
+	if !hugeAlone.Load() {
+		t.Error("Expected the file larger than the budget to run alone")
+	}
+	if peak > 24 {
+		t.Errorf("Expected at most 24 bytes of small files in flight, got %d", peak)
+	}
+	if stats := mt.QueueStats(); stats.InFlightBytes != 0 {
+		t.Errorf("Expected no bytes in flight after the crawl, got %d", stats.InFlightBytes)
+	}
+}
+
+// TestByteBudget tests that the budget admits waiters in order and that a
+// waiter giving up releases its claim.
+func TestByteBudget(t *testing.T) {
+	t.Parallel()
+	b := newByteBudget(&Config{MaxInFlightBytes: 10})
+
+	if !b.acquire(context.Background(), 6) {
+		t.Fatal("Expected the first acquire to succeed")
+	}
+
+	// A waiter that gives up does not keep its place
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if b.acquire(ctx, 8) {
+		t.Fatal("Expected the acquire exceeding the budget to wait until cancelled")
+	}
+
+	// Small files queue behind a large one instead of overtaking it
+	large := make(chan struct{})
+	go func() {
+		b.acquire(context.Background(), 8)
+		close(large)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		b.mu.Lock()
+		waiting := len(b.waiters)
+		b.mu.Unlock()
+		if waiting == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	small := make(chan struct{})
+	go func() {
+		b.acquire(context.Background(), 1)
+		close(small)
+	}()
+
+	select {
+	case <-small:
+		t.Fatal("Expected the small file to wait behind the large one")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.release(6)
+	<-large
+	<-small
+	if used := b.inFlight(); used != 9 {
+		t.Errorf("Expected 9 bytes in flight, got %d", used)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+// fileConfig is the JSON config file format.
+type fileConfig struct {
+	Input           string   `json:"input"`
+	Output          string   `json:"output"`
+	Patterns        []string `json:"patterns"`
+	Excludes        []string `json:"excludes"`
+	Labels          []string `json:"labels"`
+	Concurrency     int      `json:"concurrency"`
+	ScanConcurrency int      `json:"scanConcurrency"`
+	MaxDepth        int      `json:"maxDepth"`
+	Exec            string   `json:"exec"`
+	IgnoreFile      string   `json:"ignoreFile"`
+	LockFile        string   `json:"lockFile"`
+	QuarantineDir   string   `json:"quarantineDir"`
+	IncludeHidden   bool     `json:"includeHidden"`
+	IgnoreCase      bool     `json:"ignoreCase"`
+	KeepGoing       bool     `json:"keepGoing"`
+	Sample          float64  `json:"sample"`
+	SamplePerDir    int      `json:"samplePerDir"`
+	MaxFiles        int      `json:"maxFiles"`
+	OutputNames     string   `json:"outputNames"`
+	Webhooks        []string `json:"webhooks"`
+}
+
+// loadFileConfig reads a JSON config file.
+func loadFileConfig(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg fileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// mirrorConfig converts the file config to a library Config without callbacks.
+func (c *fileConfig) mirrorConfig() mirrortransform.Config {
+	config := mirrortransform.Config{
+		InputDir:                c.Input,
+		OutputDir:               c.Output,
+		Patterns:                c.Patterns,
+		ExcludePatterns:         c.Excludes,
+		Concurrency:             c.Concurrency,
+		ScanConcurrency:         c.ScanConcurrency,
+		MaxDepth:                c.MaxDepth,
+		MaxFiles:                c.MaxFiles,
+		IgnoreFile:              c.IgnoreFile,
+		LockFile:                c.LockFile,
+		QuarantineDir:           c.QuarantineDir,
+		IncludeHidden:           c.IncludeHidden,
+		CaseInsensitivePatterns: c.IgnoreCase,
+		ContinueOnError:         c.KeepGoing,
+		RunLabels:               c.Labels,
+		OutputNames:             mirrortransform.NameMapping(c.OutputNames),
+	}
+	if c.Sample > 0 || c.SamplePerDir > 0 {
+		config.Sample = &mirrortransform.Sample{Fraction: c.Sample, PerDirectory: c.SamplePerDir}
+	}
+	for _, url := range c.Webhooks {
+		config.Webhooks = append(config.Webhooks, mirrortransform.Webhook{URL: url})
+	}
+	return config
+}
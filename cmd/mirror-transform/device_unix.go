@@ -0,0 +1,40 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// sameDevice reports whether a and b, or their nearest existing parents,
+// are on the same device.
+func sameDevice(a, b string) bool {
+	devA, okA := deviceOf(a)
+	devB, okB := deviceOf(b)
+	return okA && okB && devA == devB
+}
+
+// deviceOf returns the device of path, or of its nearest existing parent.
+func deviceOf(path string) (uint64, bool) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return 0, false
+	}
+	for {
+		info, err := os.Stat(path)
+		if err == nil {
+			stat, ok := info.Sys().(*syscall.Stat_t)
+			if !ok {
+				return 0, false
+			}
+			return uint64(stat.Dev), true
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, false
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+// execData is the data available to --exec templates.
+type execData struct {
+	// Input is the full path of the source file.
+	Input string
+
+	// Output is the full mirrored output path.
+	Output string
+
+	// OutputDir is the directory of Output, which is guaranteed to exist.
+	OutputDir string
+
+	// OutputBase is Output without its extension.
+	OutputBase string
+}
+
+// newTaskCallback returns a callback that runs the command template for each
+// file, or copies the file with copyOpts if the template is empty. Commands
+// are killed when the task's context is cancelled.
+func newTaskCallback(commandTemplate string, copyOpts mirrortransform.CopyOptions, stdout, stderr io.Writer) (mirrortransform.TaskCallback, error) {
+	if strings.TrimSpace(commandTemplate) == "" {
+		return func(task mirrortransform.Task) (bool, error) {
+			if err := mirrortransform.CopyFileContext(task.Context(), task.InputPath, task.OutputPath, copyOpts); err != nil {
+				return false, err
+			}
+			return true, nil
+		}, nil
+	}
+
+	words, err := splitCommand(commandTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each word is rendered separately so paths with spaces stay single arguments
+	templates := make([]*template.Template, len(words))
+	for i, word := range words {
+		tmpl, err := template.New("exec").Option("missingkey=error").Parse(word)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec template %q: %w", word, err)
+		}
+		templates[i] = tmpl
+	}
+
+	var outputMu sync.Mutex
+	return func(task mirrortransform.Task) (bool, error) {
+		data := execData{
+			Input:      task.InputPath,
+			Output:     task.OutputPath,
+			OutputDir:  filepath.Dir(task.OutputPath),
+			OutputBase: strings.TrimSuffix(task.OutputPath, filepath.Ext(task.OutputPath)),
+		}
+
+		args := make([]string, len(templates))
+		for i, tmpl := range templates {
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return false, fmt.Errorf("failed to render exec template: %w", err)
+			}
+			args[i] = buf.String()
+		}
+
+		var output bytes.Buffer
+		cmd := exec.CommandContext(task.Context(), args[0], args[1:]...)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		runErr := cmd.Run()
+
+		// Keep the output of concurrent commands from interleaving. Failing
+		// to relay it does not fail the file.
+		outputMu.Lock()
+		if runErr != nil {
+			_, _ = stderr.Write(output.Bytes())
+		} else {
+			_, _ = stdout.Write(output.Bytes())
+		}
+		outputMu.Unlock()
+
+		if runErr != nil {
+			return false, fmt.Errorf("command %q failed: %w", strings.Join(args, " "), runErr)
+		}
+		return true, nil
+	}, nil
+}
+
+// splitCommand splits a command line into words. Single and double quotes
+// group words; there is no other shell processing.
+func splitCommand(command string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in exec template %q", command)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("exec template is empty")
+	}
+	return words, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+func TestSplitCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plain", command: "cwebp -q 80 {{.Input}}", want: []string{"cwebp", "-q", "80", "{{.Input}}"}},
+		{name: "extra spaces", command: "  a   b  ", want: []string{"a", "b"}},
+		{name: "double quotes", command: `sh -c "echo {{.Input}}"`, want: []string{"sh", "-c", "echo {{.Input}}"}},
+		{name: "single quotes", command: `echo 'a "b"'`, want: []string{"echo", `a "b"`}},
+		{name: "empty quotes", command: `echo ""`, want: []string{"echo", ""}},
+		{name: "unterminated", command: `echo "a`, wantErr: true},
+		{name: "empty", command: "   ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := splitCommand(tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("splitCommand(%q) expected error, got %q", tt.command, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitCommand(%q) failed: %v", tt.command, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCrawl(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0o755); err != nil {
+		t.Fatalf("Failed to create input dir: %v", err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "c.md"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "crawl"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("run returned %d: %s", code, stderr.String())
+	}
+
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if err != nil {
+			t.Errorf("Expected %s to be copied: %v", name, err)
+			continue
+		}
+		if string(data) != name {
+			t.Errorf("Unexpected content in %s: %q", name, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "c.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected c.md not to be copied")
+	}
+}
+
+func TestRunBandwidthLimitSameDevice(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("devices are only compared on Linux and macOS")
+	}
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("Failed to create input dir: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-input", inputDir, "-output", filepath.Join(tmpDir, "output"), "-pattern", "**/*.txt", "-bwlimit", "1000000", "crawl"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("run returned %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "same device") {
+		t.Errorf("Expected a warning about the shared device, got %q", stderr.String())
+	}
+}
+
+func TestRunCrawlPaths(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0o755); err != nil {
+		t.Fatalf("Failed to create input dir: %v", err)
+	}
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/c.txt"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "crawl", "sub/b.txt", "missing"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("run returned %d: %s", code, stderr.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "sub", "b.txt")); err != nil {
+		t.Errorf("Expected sub/b.txt to be copied: %v", err)
+	}
+	for _, name := range []string{"a.txt", "sub/c.txt"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be copied", name)
+		}
+	}
+
+	code = run([]string{"-input", inputDir, "-output", outputDir, "-dry-run", "crawl", "sub"}, &stdout, &stderr)
+	if code != 2 {
+		t.Errorf("Expected exit code 2 for -dry-run with paths, got %d", code)
+	}
+}
+
+func TestExecCallback(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputPath := filepath.Join(tmpDir, "in.txt")
+	outputPath := filepath.Join(tmpDir, "out.txt")
+
+	var stdout, stderr bytes.Buffer
+	callback, err := newTaskCallback("echo {{.Input}} {{.OutputBase}}", mirrortransform.CopyOptions{}, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("newTaskCallback failed: %v", err)
+	}
+
+	task := mirrortransform.Task{FileTask: mirrortransform.FileTask{InputPath: inputPath, OutputPath: outputPath}}
+	if _, err := callback(task); err != nil {
+		t.Fatalf("callback failed: %v", err)
+	}
+	want := inputPath + " " + filepath.Join(tmpDir, "out") + "\n"
+	if stdout.String() != want {
+		t.Errorf("Unexpected command output: got %q, want %q", stdout.String(), want)
+	}
+
+	if _, err := newTaskCallback("echo {{.Unknown", mirrortransform.CopyOptions{}, &stdout, &stderr); err == nil {
+		t.Errorf("Expected error for invalid template")
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+
+	for dir, names := range map[string][]string{inputDir: {"a.txt", "b.txt"}, outputDir: {"a.txt", "c.txt"}} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "verify"}, &stdout, &stderr)
+	if code != 1 {
+		t.Fatalf("Expected exit code 1 for a drifted tree, got %d: %s", code, stderr.String())
+	}
+	want := "missing\tb.txt\t" + filepath.Join(outputDir, "b.txt") + "\nextra\t" + filepath.Join(outputDir, "c.txt") + "\n"
+	if stdout.String() != want {
+		t.Errorf("Unexpected report:\n%s\nwant:\n%s", stdout.String(), want)
+	}
+
+	// The tree matches once it has been crawled and the extra file removed
+	if err := os.Remove(filepath.Join(outputDir, "c.txt")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "crawl"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("run returned %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "verify"}, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("Expected a matching tree, got %d: %s", code, stdout.String())
+	}
+}
+
+func TestRunMaxFiles(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	inputDir := filepath.Join(tmpDir, "input")
+	outputDir := filepath.Join(tmpDir, "output")
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(name), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-input", inputDir, "-output", outputDir, "-pattern", "**/*.txt", "-concurrency", "1", "-max-files", "2", "crawl"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Expected the limit not to be an error, got %d: %s", code, stderr.String())
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 outputs, got %d", len(entries))
+	}
+}
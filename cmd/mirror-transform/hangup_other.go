@@ -0,0 +1,15 @@
+//go:build windows || js || wasip1
+
+package main
+
+import (
+	"context"
+	"io"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+// rescanOnHangup does nothing on platforms without SIGHUP.
+func rescanOnHangup(ctx context.Context, mt mirrortransform.MirrorTransform, stderr io.Writer) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !js && !wasip1
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+// rescanOnHangup calls Rescan whenever the process receives SIGHUP, until
+// the returned function is called, so operators can reconcile missed
+// events with kill -HUP.
+func rescanOnHangup(ctx context.Context, mt mirrortransform.MirrorTransform, stderr io.Writer) (stop func()) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				if err := mt.Rescan(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					fmt.Fprintf(stderr, "mirror-transform: rescan: %v\n", err)
+				}
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(hangup)
+		close(done)
+	}
+}
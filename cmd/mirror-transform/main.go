@@ -0,0 +1,350 @@
+// Command mirror-transform mirrors files matching glob patterns from an input
+// directory to an output directory, optionally running an external command
+// for each file.
+//
+// Usage:
+//
+//	mirror-transform [flags] crawl|watch|sync|schedule|import-list|import-tar
+//
+// Example converting every JPEG under images/ to WebP:
+//
+//	mirror-transform -input images -output webp -pattern '**/*.jpg' \
+//	    -exec 'cwebp -q 80 {{.Input}} -o {{.OutputBase}}.webp' crawl
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	mirrortransform "github.com/ideamans/go-mirror-transform"
+)
+
+// stringList is a flag.Value collecting repeated string flags.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the process exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("mirror-transform", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: mirror-transform [flags] crawl [path...]|watch|sync|import-list|import-tar|crawl-archive|verify\n\n")
+		fmt.Fprintf(stderr, "Commands:\n")
+		fmt.Fprintf(stderr, "  crawl          process all matching files once, or only those below the given paths\n")
+		fmt.Fprintf(stderr, "  watch          process files as they are created or modified; SIGHUP rescans the input\n")
+		fmt.Fprintf(stderr, "  sync           crawl existing files, then keep watching; SIGHUP rescans the input\n")
+		fmt.Fprintf(stderr, "  schedule       crawl repeatedly as set by -schedule, skipping unchanged files\n")
+		fmt.Fprintf(stderr, "  import-list    process the files listed one per line in -from\n")
+		fmt.Fprintf(stderr, "  import-tar     extract a tar archive from -from into the input and process it\n")
+		fmt.Fprintf(stderr, "  crawl-archive  process the entries of the zip or tar archive -from as the input tree\n")
+		fmt.Fprintf(stderr, "  verify         list missing outputs and outputs without an input, exiting with 1 if there are any\n\n")
+		fmt.Fprintf(stderr, "Flags:\n")
+		flags.PrintDefaults()
+	}
+
+	var (
+		configPath    string
+		patterns      stringList
+		excludes      stringList
+		labels        stringList
+		webhooks      stringList
+		opts          fileConfig
+		includeHidden bool
+		ignoreCase    bool
+		keepGoing     bool
+		dryRun        bool
+		subdir        string
+		force         bool
+		from          string
+		schedule      string
+		protocol      bool
+		shutdownGrace time.Duration
+		stableFor     time.Duration
+		bwLimit       int64
+		logLevel      string
+	)
+	flags.StringVar(&configPath, "config", "", "JSON config file; flags override its values")
+	flags.StringVar(&opts.Input, "input", "", "input directory")
+	flags.StringVar(&opts.Output, "output", "", "output directory")
+	flags.Var(&patterns, "pattern", "glob pattern of files to process (repeatable)")
+	flags.Var(&excludes, "exclude", "glob pattern of files or directories to skip (repeatable)")
+	flags.Var(&labels, "label", "label recorded with the run, e.g. nightly (repeatable)")
+	flags.Var(&webhooks, "webhook", "URL notified with a JSON payload when a run finishes, files fail or a watch stops unexpectedly (repeatable)")
+	flags.Func("concurrency", "number of parallel workers, or auto to adapt it to the measured throughput (default: number of CPUs)", func(value string) error {
+		if value == "auto" {
+			opts.Concurrency = mirrortransform.AutoConcurrency
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("expected a number or auto")
+		}
+		opts.Concurrency = n
+		return nil
+	})
+	flags.IntVar(&opts.ScanConcurrency, "scan-concurrency", 0, "number of directories read in parallel while scanning (default: sequential)")
+	flags.IntVar(&opts.MaxDepth, "max-depth", 0, "only process files this many levels below the input directory, 1 for the input directory itself (default: no limit)")
+	flags.StringVar(&opts.Exec, "exec", "", "command template run for each file, e.g. 'cwebp {{.Input}} -o {{.OutputBase}}.webp'; files are copied if empty")
+	flags.StringVar(&opts.OutputNames, "output-names", "", "normalize output names: lower or slug")
+	flags.StringVar(&opts.IgnoreFile, "ignore-file", "", "name of gitignore-style ignore files, e.g. .mirrorignore")
+	flags.StringVar(&opts.LockFile, "lock-file", "", "lock file, relative to the output directory, that keeps a second process from running over the same tree, e.g. .mirror-lock")
+	flags.StringVar(&opts.QuarantineDir, "quarantine-dir", "", "directory receiving a copy of every file whose command failed, with the error beside it")
+	flags.BoolVar(&includeHidden, "include-hidden", false, "also process hidden files and directories")
+	flags.BoolVar(&ignoreCase, "ignore-case", false, "match patterns and excludes without regard to case, so **/*.jpg also matches PHOTO.JPG")
+	flags.BoolVar(&keepGoing, "keep-going", false, "continue with other files when a file fails and report failures at the end")
+	flags.Float64Var(&opts.Sample, "sample", 0, "process only this fraction of matching files, e.g. 0.01")
+	flags.IntVar(&opts.SamplePerDir, "sample-per-dir", 0, "process at most this many matching files per directory")
+	flags.IntVar(&opts.MaxFiles, "max-files", 0, "process at most this many files per crawl or import, leaving the rest for the next run (default: no limit)")
+	flags.BoolVar(&dryRun, "dry-run", false, "match files without running the command or writing outputs")
+	flags.StringVar(&subdir, "subdir", "", "only process this subdirectory of the input directory, e.g. 2024/")
+	flags.BoolVar(&force, "force", false, "retry files that are backing off after failures")
+	flags.StringVar(&from, "from", "", "list file or archive read by import-list, import-tar (default: standard input) and crawl-archive")
+	flags.StringVar(&schedule, "schedule", "", "interval or cron expression of the schedule command, e.g. 5m or '*/10 * * * *'")
+	flags.DurationVar(&shutdownGrace, "shutdown-grace", 0, "on interrupt, let running commands finish for this long before killing them, e.g. 30m")
+	flags.Int64Var(&bwLimit, "bwlimit", 0, "cap the total throughput of the copies made without -exec, in bytes per second (default: no limit)")
+	flags.DurationVar(&stableFor, "stability-window", 0, "watch and sync wait until a changed file has kept its size and modification time this long, e.g. 5s for uploads still in progress")
+	flags.StringVar(&logLevel, "log-level", "", "log to standard error at this level: debug, info, warn or error (default: no logs)")
+	flags.BoolVar(&protocol, "protocol", false, "write tasks as JSON lines to standard output and read their results from standard input instead of running -exec")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() < 1 || (flags.NArg() > 1 && flags.Arg(0) != "crawl") {
+		flags.Usage()
+		return 2
+	}
+	command, paths := flags.Arg(0), flags.Args()[1:]
+
+	// Load the config file, then apply flags that were set explicitly
+	cfg := fileConfig{}
+	if configPath != "" {
+		loaded, err := loadFileConfig(configPath)
+		if err != nil {
+			fmt.Fprintf(stderr, "mirror-transform: %v\n", err)
+			return 1
+		}
+		cfg = *loaded
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "input":
+			cfg.Input = opts.Input
+		case "output":
+			cfg.Output = opts.Output
+		case "pattern":
+			cfg.Patterns = patterns
+		case "exclude":
+			cfg.Excludes = excludes
+		case "label":
+			cfg.Labels = labels
+		case "webhook":
+			cfg.Webhooks = webhooks
+		case "concurrency":
+			cfg.Concurrency = opts.Concurrency
+		case "scan-concurrency":
+			cfg.ScanConcurrency = opts.ScanConcurrency
+		case "max-depth":
+			cfg.MaxDepth = opts.MaxDepth
+		case "exec":
+			cfg.Exec = opts.Exec
+		case "output-names":
+			cfg.OutputNames = opts.OutputNames
+		case "ignore-file":
+			cfg.IgnoreFile = opts.IgnoreFile
+		case "lock-file":
+			cfg.LockFile = opts.LockFile
+		case "quarantine-dir":
+			cfg.QuarantineDir = opts.QuarantineDir
+		case "include-hidden":
+			cfg.IncludeHidden = includeHidden
+		case "ignore-case":
+			cfg.IgnoreCase = ignoreCase
+		case "keep-going":
+			cfg.KeepGoing = keepGoing
+		case "sample":
+			cfg.Sample = opts.Sample
+		case "sample-per-dir":
+			cfg.SamplePerDir = opts.SamplePerDir
+		case "max-files":
+			cfg.MaxFiles = opts.MaxFiles
+		}
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The protocol owns standard input and output
+	if protocol && cfg.Exec != "" {
+		fmt.Fprintf(stderr, "mirror-transform: -protocol cannot be combined with -exec\n")
+		return 2
+	}
+	if protocol && from == "" && (command == "import-list" || command == "import-tar") {
+		fmt.Fprintf(stderr, "mirror-transform: -protocol requires -from for %s\n", command)
+		return 2
+	}
+
+	config := cfg.mirrorConfig()
+	if protocol {
+		config.TaskCallback = mirrortransform.NewProtocol(os.Stdin, stdout).TaskCallback
+	} else {
+		// The limit is meant to protect the input device, which also takes the writes then
+		if bwLimit > 0 && cfg.Exec == "" && sameDevice(cfg.Input, cfg.Output) {
+			fmt.Fprintf(stderr, "mirror-transform: warning: the output is on the same device as the rate-limited input, which carries twice the -bwlimit throughput\n")
+		}
+		copyOpts := mirrortransform.CopyOptions{RateLimit: mirrortransform.NewRateLimiter(bwLimit)}
+		callback, err := newTaskCallback(cfg.Exec, copyOpts, stdout, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "mirror-transform: %v\n", err)
+			return 2
+		}
+		config.TaskCallback = callback
+	}
+	if logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+			fmt.Fprintf(stderr, "mirror-transform: invalid -log-level %q\n", logLevel)
+			return 2
+		}
+		config.Logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+	}
+	config.ShutdownGracePeriod = shutdownGrace
+	config.StabilityWindow = stableFor
+	config.ShutdownCallback = func(progress mirrortransform.ShutdownProgress) {
+		switch {
+		case progress.Expired:
+			fmt.Fprintf(stderr, "mirror-transform: grace period expired, stopping %d running files\n", len(progress.InFlight))
+		case len(progress.InFlight) > 0:
+			fmt.Fprintf(stderr, "mirror-transform: waiting for %d running files until %s\n", len(progress.InFlight), progress.Deadline.Format(time.TimeOnly))
+		}
+	}
+	config.ErrorCallback = func(path string, err error) (bool, error) {
+		fmt.Fprintf(stderr, "mirror-transform: %s: %v\n", path, err)
+		return false, nil
+	}
+
+	mt, err := mirrortransform.NewMirrorTransform(&config)
+	if err != nil {
+		fmt.Fprintf(stderr, "mirror-transform: %v\n", err)
+		return 2
+	}
+
+	// Options that only apply to this invocation
+	var runOpts []mirrortransform.RunOption
+	if dryRun {
+		runOpts = append(runOpts, mirrortransform.WithDryRun())
+	}
+	if subdir != "" {
+		runOpts = append(runOpts, mirrortransform.WithSubdir(subdir))
+	}
+	if force {
+		runOpts = append(runOpts, mirrortransform.WithForce())
+	}
+
+	switch command {
+	case "crawl":
+		if len(paths) == 0 {
+			err = mt.Crawl(ctx, runOpts...)
+			break
+		}
+		if len(runOpts) > 0 {
+			fmt.Fprintf(stderr, "mirror-transform: -dry-run, -subdir and -force cannot be combined with paths\n")
+			return 2
+		}
+		_, err = mt.CrawlPaths(ctx, paths...)
+	case "watch":
+		defer rescanOnHangup(ctx, mt, stderr)()
+		err = mt.Watch(ctx, runOpts...)
+	case "sync":
+		defer rescanOnHangup(ctx, mt, stderr)()
+		err = mt.Run(ctx, runOpts...)
+	case "schedule":
+		if schedule == "" {
+			fmt.Fprintf(stderr, "mirror-transform: schedule requires -schedule\n")
+			return 2
+		}
+		err = mt.Schedule(ctx, schedule, runOpts...)
+	case "import-list", "import-tar":
+		err = runImport(ctx, mt, command, from, runOpts)
+	case "crawl-archive":
+		if from == "" {
+			fmt.Fprintf(stderr, "mirror-transform: crawl-archive requires -from\n")
+			return 2
+		}
+		_, err = mt.CrawlArchive(ctx, from, runOpts...)
+	case "verify":
+		var drifted bool
+		if drifted, err = runVerify(ctx, mt, stdout); err == nil && drifted {
+			return 1
+		}
+	default:
+		fmt.Fprintf(stderr, "mirror-transform: unknown command %q\n", command)
+		flags.Usage()
+		return 2
+	}
+
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stderr, "mirror-transform: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runImport feeds the list file or tar archive at from, or standard input if
+// empty, to the import command.
+func runImport(ctx context.Context, mt mirrortransform.MirrorTransform, command, from string, runOpts []mirrortransform.RunOption) error {
+	var r io.Reader = os.Stdin
+	if from != "" {
+		f, err := os.Open(from)
+		if err != nil {
+			return fmt.Errorf("failed to open %q: %w", from, err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if command == "import-tar" {
+		_, err := mt.ImportTar(ctx, r, runOpts...)
+		return err
+	}
+	_, err := mt.ImportList(ctx, r, runOpts...)
+	return err
+}
+
+// runVerify writes the differences between the input and output trees to
+// stdout, one per line, and reports whether there were any.
+func runVerify(ctx context.Context, mt mirrortransform.MirrorTransform, stdout io.Writer) (drifted bool, err error) {
+	report, err := mt.Verify(ctx, mirrortransform.VerifyOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range report.Missing {
+		fmt.Fprintf(stdout, "missing\t%s\t%s\n", entry.RelPath, entry.OutputPath)
+	}
+	for _, path := range report.Extra {
+		fmt.Fprintf(stdout, "extra\t%s\n", path)
+	}
+	return !report.OK(), nil
+}
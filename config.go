@@ -0,0 +1,458 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// Validate checks the configuration and returns every problem found,
+// joined into one error, or nil if the configuration is usable.
+// NewMirrorTransform calls it before creating an instance.
+func (c *Config) Validate() error {
+	errs := c.validateSettings()
+	if c.FileCallback == nil && c.TaskCallback == nil && (c.VariantCallback == nil || len(c.Variants) == 0) {
+		errs = append(errs, fmt.Errorf("file callback is required"))
+	}
+	if err := c.validateDestructive(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, ok := c.StateStore.(StateLister); c.RetryQueue && c.StateStore != nil && !ok {
+		errs = append(errs, fmt.Errorf("retry queue requires a state store implementing StateLister"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateSettings checks every field except the callbacks, which cannot be
+// set from a config file.
+func (c *Config) validateSettings() []error {
+	var errs []error
+
+	// Directories
+	if c.InputDir == "" {
+		errs = append(errs, fmt.Errorf("input directory is required"))
+	}
+	if c.OutputDir == "" {
+		errs = append(errs, fmt.Errorf("output directory is required"))
+	}
+	if c.QuarantineDir != "" && c.InputDir != "" {
+		if overlap := overlappingDirs(c.InputDir, c.QuarantineDir); overlap != "" {
+			errs = append(errs, fmt.Errorf("quarantine directory must not overlap the input directory: %s", overlap))
+		}
+	}
+
+	// Patterns
+	if len(c.Patterns) == 0 && len(c.RegexPatterns) == 0 && c.Matcher == nil {
+		errs = append(errs, fmt.Errorf("at least one pattern is required"))
+	}
+	for _, pattern := range c.Patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q", pattern)))
+		}
+	}
+	for _, pattern := range c.ExcludePatterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid exclude pattern %q", pattern)))
+		}
+	}
+	for _, pattern := range c.TempFilePatterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid temp file pattern %q", pattern)))
+		}
+	}
+	for _, pattern := range c.IgnoreErrorPatterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid ignore error pattern %q", pattern)))
+		}
+	}
+	for _, filter := range c.ContentTypeFilter {
+		if !strings.Contains(filter, "/") {
+			errs = append(errs, fmt.Errorf("invalid content type filter %q: expected type/subtype", filter))
+		}
+	}
+
+	// Limits
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{name: "max concurrency", value: int64(c.MaxConcurrency)},
+		{name: "min concurrency", value: int64(c.MinConcurrency)},
+		{name: "scan concurrency", value: int64(c.ScanConcurrency)},
+		{name: "max depth", value: int64(c.MaxDepth)},
+		{name: "prefetch", value: int64(c.Prefetch)},
+		{name: "no-cache threshold", value: c.NoCacheThreshold},
+		{name: "max output bytes", value: c.MaxOutputBytes},
+		{name: "max files", value: int64(c.MaxFiles)},
+		{name: "max in-flight bytes", value: c.MaxInFlightBytes},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", limit.name, limit.value))
+		}
+	}
+	if c.Concurrency < 0 && c.Concurrency != AutoConcurrency {
+		errs = append(errs, fmt.Errorf("concurrency must not be negative except AutoConcurrency, got %d", c.Concurrency))
+	}
+	if c.MaxConcurrency > 0 && c.MinConcurrency > c.MaxConcurrency {
+		errs = append(errs, fmt.Errorf("min concurrency %d exceeds max concurrency %d", c.MinConcurrency, c.MaxConcurrency))
+	}
+	if c.PollUnwatched < 0 {
+		errs = append(errs, fmt.Errorf("poll unwatched interval must not be negative, got %v", c.PollUnwatched))
+	}
+	if c.StabilityWindow < 0 {
+		errs = append(errs, fmt.Errorf("stability window must not be negative, got %v", c.StabilityWindow))
+	}
+	if c.IdlePeriod < 0 {
+		errs = append(errs, fmt.Errorf("idle period must not be negative, got %v", c.IdlePeriod))
+	}
+	if c.IdleCallback != nil && c.IdlePeriod <= 0 {
+		errs = append(errs, fmt.Errorf("idle callback requires a positive idle period"))
+	}
+	if c.ShutdownGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("shutdown grace period must not be negative, got %v", c.ShutdownGracePeriod))
+	}
+
+	if c.Ordered && c.LatencyObjective != nil {
+		errs = append(errs, fmt.Errorf("ordered mode cannot be combined with a latency objective"))
+	}
+	if c.Ordered && len(c.ConcurrencyGroups) > 0 {
+		errs = append(errs, fmt.Errorf("ordered mode cannot be combined with concurrency groups"))
+	}
+	if c.MirrorEmptyDirs && c.Flatten {
+		errs = append(errs, fmt.Errorf("mirroring empty directories cannot be combined with flattened output"))
+	}
+	if c.MirrorDirRemovals && (c.Flatten || len(c.RewriteRules) > 0 || c.OutputPathFunc != nil) {
+		errs = append(errs, fmt.Errorf("mirroring directory removals cannot be combined with flattened output, rewrite rules or an output path function"))
+	}
+	if c.LazyOutputDirs && (c.MirrorEmptyDirs || c.RecoverPartialOutputs) {
+		errs = append(errs, fmt.Errorf("lazy output directories cannot be combined with mirroring empty directories or recovering partial outputs"))
+	}
+
+	if c.GenerationFile != "" && (filepath.Base(c.GenerationFile) != c.GenerationFile || c.GenerationFile == "." || c.GenerationFile == "..") {
+		errs = append(errs, fmt.Errorf("generation file must be a file name, got %q", c.GenerationFile))
+	}
+
+	// Backoff
+	if b := c.FailureBackoff; b != nil {
+		if b.InitialDelay < 0 || b.MaxDelay < 0 {
+			errs = append(errs, fmt.Errorf("failure backoff delays must not be negative"))
+		}
+		if b.InitialDelay > 0 && b.MaxDelay > 0 && b.InitialDelay > b.MaxDelay {
+			errs = append(errs, fmt.Errorf("failure backoff initial delay %v exceeds max delay %v", b.InitialDelay, b.MaxDelay))
+		}
+		if b.MaxFailures < 0 {
+			errs = append(errs, fmt.Errorf("failure backoff max failures must not be negative, got %d", b.MaxFailures))
+		}
+	}
+
+	if err := validateVariants(c.Variants); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateSample(c.Sample); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateShadow(c.Shadow, c); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validatePriorityHints(c.PriorityHints); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateLatencyObjective(c.LatencyObjective); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateReadOnlyOutput(c.ReadOnlyOutput); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateOverrides(c.Overrides); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateConcurrencyGroups(c.ConcurrencyGroups); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateWebhooks(c.Webhooks); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateNameMapping(c.OutputNames); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateUnicodeNormalization(c.UnicodeNormalization); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := compileRewriteRules(c.RewriteRules); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := compileRegexRules(c); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// configFile is the serialized form of Config read by LoadConfig.
+type configFile struct {
+	InputDir                string               `json:"inputDir" yaml:"inputDir"`
+	OutputDir               string               `json:"outputDir" yaml:"outputDir"`
+	Patterns                []string             `json:"patterns" yaml:"patterns"`
+	ExcludePatterns         []string             `json:"excludePatterns" yaml:"excludePatterns"`
+	RegexPatterns           []string             `json:"regexPatterns" yaml:"regexPatterns"`
+	RegexExcludes           []string             `json:"regexExcludes" yaml:"regexExcludes"`
+	CaseInsensitivePatterns bool                 `json:"caseInsensitivePatterns" yaml:"caseInsensitivePatterns"`
+	UnicodeNormalization    UnicodeNormalization `json:"unicodeNormalization" yaml:"unicodeNormalization"`
+	IncludeHidden           bool                 `json:"includeHidden" yaml:"includeHidden"`
+	IgnoreFile              string               `json:"ignoreFile" yaml:"ignoreFile"`
+	NestedIgnoreFiles       bool                 `json:"nestedIgnoreFiles" yaml:"nestedIgnoreFiles"`
+	TempFilePatterns        []string             `json:"tempFilePatterns" yaml:"tempFilePatterns"`
+	WatchTempFiles          bool                 `json:"watchTempFiles" yaml:"watchTempFiles"`
+	Concurrency             concurrencyFile      `json:"concurrency" yaml:"concurrency"`
+	MaxConcurrency          int                  `json:"maxConcurrency" yaml:"maxConcurrency"`
+	MinConcurrency          int                  `json:"minConcurrency" yaml:"minConcurrency"`
+	MaxInFlightBytes        int64                `json:"maxInFlightBytes" yaml:"maxInFlightBytes"`
+	ScanConcurrency         int                  `json:"scanConcurrency" yaml:"scanConcurrency"`
+	MaxDepth                int                  `json:"maxDepth" yaml:"maxDepth"`
+	Sample                  *Sample              `json:"sample" yaml:"sample"`
+	ContentTypeFilter       []string             `json:"contentTypeFilter" yaml:"contentTypeFilter"`
+	Prefetch                int                  `json:"prefetch" yaml:"prefetch"`
+	NoCacheThreshold        int64                `json:"noCacheThreshold" yaml:"noCacheThreshold"`
+	MaxOutputBytes          int64                `json:"maxOutputBytes" yaml:"maxOutputBytes"`
+	MaxFiles                int                  `json:"maxFiles" yaml:"maxFiles"`
+	GenerationFile          string               `json:"generationFile" yaml:"generationFile"`
+	LockFile                string               `json:"lockFile" yaml:"lockFile"`
+	Variants                []Variant            `json:"variants" yaml:"variants"`
+	ContinueOnError         bool                 `json:"continueOnError" yaml:"continueOnError"`
+	IgnoreErrorPatterns     []string             `json:"ignoreErrorPatterns" yaml:"ignoreErrorPatterns"`
+	FailureBackoff          *failureBackoffFile  `json:"failureBackoff" yaml:"failureBackoff"`
+	QuarantineDir           string               `json:"quarantineDir" yaml:"quarantineDir"`
+	RetryQueue              bool                 `json:"retryQueue" yaml:"retryQueue"`
+	StateFile               string               `json:"stateFile" yaml:"stateFile"`
+	TaskOrder               string               `json:"taskOrder" yaml:"taskOrder"`
+	Ordered                 bool                 `json:"ordered" yaml:"ordered"`
+	Flatten                 bool                 `json:"flatten" yaml:"flatten"`
+	MirrorEmptyDirs         bool                 `json:"mirrorEmptyDirs" yaml:"mirrorEmptyDirs"`
+	LazyOutputDirs          bool                 `json:"lazyOutputDirs" yaml:"lazyOutputDirs"`
+	OnlyIfStale             bool                 `json:"onlyIfStale" yaml:"onlyIfStale"`
+	OutputNames             NameMapping          `json:"outputNames" yaml:"outputNames"`
+	RunLabels               []string             `json:"runLabels" yaml:"runLabels"`
+	RewriteRules            []RewriteRule        `json:"rewriteRules" yaml:"rewriteRules"`
+	Overrides               []Override           `json:"overrides" yaml:"overrides"`
+	ConcurrencyGroups       []ConcurrencyGroup   `json:"concurrencyGroups" yaml:"concurrencyGroups"`
+	PriorityHints           *PriorityHints       `json:"priorityHints" yaml:"priorityHints"`
+	Webhooks                []webhookFile        `json:"webhooks" yaml:"webhooks"`
+	PreserveTimes           bool                 `json:"preserveTimes" yaml:"preserveTimes"`
+	PreserveMode            bool                 `json:"preserveMode" yaml:"preserveMode"`
+	TrackRenames            bool                 `json:"trackRenames" yaml:"trackRenames"`
+	AllowDestructive        bool                 `json:"allowDestructive" yaml:"allowDestructive"`
+	RecoverPartialOutputs   bool                 `json:"recoverPartialOutputs" yaml:"recoverPartialOutputs"`
+	CleanStaleTempFiles     bool                 `json:"cleanStaleTempFiles" yaml:"cleanStaleTempFiles"`
+	MirrorDirRemovals       bool                 `json:"mirrorDirRemovals" yaml:"mirrorDirRemovals"`
+	ProcessExisting         bool                 `json:"processExisting" yaml:"processExisting"`
+	RecursiveWatch          bool                 `json:"recursiveWatch" yaml:"recursiveWatch"`
+	IdempotencyKeys         bool                 `json:"idempotencyKeys" yaml:"idempotencyKeys"`
+	TransformVersion        string               `json:"transformVersion" yaml:"transformVersion"`
+	PollUnwatched           string               `json:"pollUnwatched" yaml:"pollUnwatched"`
+	ShutdownGracePeriod     string               `json:"shutdownGracePeriod" yaml:"shutdownGracePeriod"`
+	StabilityWindow         string               `json:"stabilityWindow" yaml:"stabilityWindow"`
+}
+
+// failureBackoffFile is the serialized form of FailureBackoff.
+// Delays are Go duration strings such as "30s" or "2h".
+type failureBackoffFile struct {
+	InitialDelay string `json:"initialDelay" yaml:"initialDelay"`
+	MaxDelay     string `json:"maxDelay" yaml:"maxDelay"`
+	MaxFailures  int    `json:"maxFailures" yaml:"maxFailures"`
+}
+
+// webhookFile is the serialized form of Webhook. The timeout is a Go
+// duration string such as "5s".
+type webhookFile struct {
+	Webhook `yaml:",inline"`
+	Timeout string `json:"timeout" yaml:"timeout"`
+}
+
+// taskSorters maps the taskOrder values accepted by LoadConfig to sorters.
+var taskSorters = map[string]TaskSorter{
+	"smallest-first": SmallestFirst,
+	"newest-first":   NewestFirst,
+	"path":           ByPath,
+}
+
+// LoadConfig reads a Config from a YAML (.yaml, .yml) or JSON (.json) file.
+// Keys use the lower camel case of the Config field names (e.g. "inputDir",
+// "excludePatterns"); unknown keys are rejected. Relative directories are
+// resolved against the directory of the file. "stateFile" sets a
+// NewFileStateStore, "taskOrder" accepts "smallest-first", "newest-first" or "path",
+// "concurrency" accepts "auto" for AutoConcurrency, and backoff delays and webhook timeouts are duration strings like "30s".
+//
+// Callbacks cannot be expressed in a file: set FileCallback (and optionally
+// ErrorCallback) on the returned Config before calling NewMirrorTransform.
+// Every other field is validated as by Config.Validate.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file configFile
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q: use .yaml, .yml or .json", ext)
+	}
+
+	config, err := file.config(filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+	if err := errors.Join(config.validateSettings()...); err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// config converts the file to a Config, resolving relative paths against baseDir.
+func (f *configFile) config(baseDir string) (*Config, error) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(baseDir, path)
+	}
+
+	config := &Config{
+		InputDir:                resolve(f.InputDir),
+		OutputDir:               resolve(f.OutputDir),
+		Patterns:                f.Patterns,
+		ExcludePatterns:         f.ExcludePatterns,
+		RegexPatterns:           f.RegexPatterns,
+		RegexExcludes:           f.RegexExcludes,
+		CaseInsensitivePatterns: f.CaseInsensitivePatterns,
+		UnicodeNormalization:    f.UnicodeNormalization,
+		IncludeHidden:           f.IncludeHidden,
+		IgnoreFile:              f.IgnoreFile,
+		NestedIgnoreFiles:       f.NestedIgnoreFiles,
+		TempFilePatterns:        f.TempFilePatterns,
+		WatchTempFiles:          f.WatchTempFiles,
+		Concurrency:             int(f.Concurrency),
+		MaxConcurrency:          f.MaxConcurrency,
+		MinConcurrency:          f.MinConcurrency,
+		MaxInFlightBytes:        f.MaxInFlightBytes,
+		ScanConcurrency:         f.ScanConcurrency,
+		MaxDepth:                f.MaxDepth,
+		Sample:                  f.Sample,
+		ContentTypeFilter:       f.ContentTypeFilter,
+		Prefetch:                f.Prefetch,
+		NoCacheThreshold:        f.NoCacheThreshold,
+		MaxOutputBytes:          f.MaxOutputBytes,
+		MaxFiles:                f.MaxFiles,
+		GenerationFile:          f.GenerationFile,
+		LockFile:                f.LockFile,
+		ContinueOnError:         f.ContinueOnError,
+		IgnoreErrorPatterns:     f.IgnoreErrorPatterns,
+		QuarantineDir:           resolve(f.QuarantineDir),
+		RetryQueue:              f.RetryQueue,
+		Ordered:                 f.Ordered,
+		Flatten:                 f.Flatten,
+		MirrorEmptyDirs:         f.MirrorEmptyDirs,
+		LazyOutputDirs:          f.LazyOutputDirs,
+		OnlyIfStale:             f.OnlyIfStale,
+		OutputNames:             f.OutputNames,
+		RunLabels:               f.RunLabels,
+		RewriteRules:            f.RewriteRules,
+		Overrides:               f.Overrides,
+		ConcurrencyGroups:       f.ConcurrencyGroups,
+		PriorityHints:           f.PriorityHints,
+		PreserveTimes:           f.PreserveTimes,
+		PreserveMode:            f.PreserveMode,
+		TrackRenames:            f.TrackRenames,
+		AllowDestructive:        f.AllowDestructive,
+		RecoverPartialOutputs:   f.RecoverPartialOutputs,
+		CleanStaleTempFiles:     f.CleanStaleTempFiles,
+		MirrorDirRemovals:       f.MirrorDirRemovals,
+		ProcessExisting:         f.ProcessExisting,
+		RecursiveWatch:          f.RecursiveWatch,
+		IdempotencyKeys:         f.IdempotencyKeys,
+		TransformVersion:        f.TransformVersion,
+	}
+
+	for _, v := range f.Variants {
+		v.Dir = resolve(v.Dir)
+		config.Variants = append(config.Variants, v)
+	}
+
+	if f.FailureBackoff != nil {
+		backoff := &FailureBackoff{MaxFailures: f.FailureBackoff.MaxFailures}
+		var err error
+		if backoff.InitialDelay, err = parseFileDuration(f.FailureBackoff.InitialDelay); err != nil {
+			return nil, fmt.Errorf("invalid failure backoff initial delay: %w", err)
+		}
+		if backoff.MaxDelay, err = parseFileDuration(f.FailureBackoff.MaxDelay); err != nil {
+			return nil, fmt.Errorf("invalid failure backoff max delay: %w", err)
+		}
+		config.FailureBackoff = backoff
+	}
+
+	for _, w := range f.Webhooks {
+		timeout, err := parseFileDuration(w.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout of webhook %q: %w", w.URL, err)
+		}
+		w.Webhook.Timeout = timeout
+		config.Webhooks = append(config.Webhooks, w.Webhook)
+	}
+
+	var err error
+	if config.PollUnwatched, err = parseFileDuration(f.PollUnwatched); err != nil {
+		return nil, fmt.Errorf("invalid poll unwatched interval: %w", err)
+	}
+	if config.ShutdownGracePeriod, err = parseFileDuration(f.ShutdownGracePeriod); err != nil {
+		return nil, fmt.Errorf("invalid shutdown grace period: %w", err)
+	}
+	if config.StabilityWindow, err = parseFileDuration(f.StabilityWindow); err != nil {
+		return nil, fmt.Errorf("invalid stability window: %w", err)
+	}
+
+	if f.StateFile != "" {
+		store, err := NewFileStateStore(resolve(f.StateFile))
+		if err != nil {
+			return nil, err
+		}
+		config.StateStore = store
+	}
+
+	if f.TaskOrder != "" {
+		sorter, ok := taskSorters[f.TaskOrder]
+		if !ok {
+			return nil, fmt.Errorf("unknown task order %q: use smallest-first, newest-first or path", f.TaskOrder)
+		}
+		config.TaskSorter = sorter
+	}
+
+	return config, nil
+}
+
+// parseFileDuration parses a duration string, treating "" as zero.
+func parseFileDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,229 @@
+package mirrortransform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoadConfigYAML tests loading every kind of field from YAML.
+func TestLoadConfigYAML(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "mirror.yaml")
+
+	content := `inputDir: input
+outputDir: /srv/output
+patterns: ["**/*.jpg", "**/*.png"]
+excludePatterns: ["tmp/**"]
+concurrency: 4
+sample:
+  perDirectory: 3
+variants:
+  - name: webp
+    ext: .webp
+failureBackoff:
+  initialDelay: 30s
+  maxDelay: 1h
+  maxFailures: 5
+stateFile: state.json
+taskOrder: smallest-first
+ordered: true
+rewriteRules:
+  - pattern: ^raw/
+    replacement: ""
+webhooks:
+  - url: https://hooks.example.com/mirror
+    events: [finished]
+    timeout: 5s
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if config.InputDir != filepath.Join(testDir, "input") {
+		t.Errorf("Expected InputDir relative to config file, got %q", config.InputDir)
+	}
+	if config.OutputDir != "/srv/output" {
+		t.Errorf("Expected absolute OutputDir to be kept, got %q", config.OutputDir)
+	}
+	if len(config.Patterns) != 2 || len(config.ExcludePatterns) != 1 || config.Concurrency != 4 {
+		t.Errorf("Unexpected patterns or concurrency: %+v", config)
+	}
+	if config.Sample == nil || config.Sample.PerDirectory != 3 {
+		t.Errorf("Unexpected sample: %+v", config.Sample)
+	}
+	if len(config.Variants) != 1 || config.Variants[0].Ext != ".webp" {
+		t.Errorf("Unexpected variants: %+v", config.Variants)
+	}
+	if b := config.FailureBackoff; b == nil || b.InitialDelay != 30*time.Second || b.MaxDelay != time.Hour || b.MaxFailures != 5 {
+		t.Errorf("Unexpected failure backoff: %+v", b)
+	}
+	if config.StateStore == nil || config.TaskSorter == nil || !config.Ordered {
+		t.Errorf("Expected StateStore, TaskSorter and Ordered to be set")
+	}
+	if len(config.RewriteRules) != 1 || config.RewriteRules[0].Pattern != "^raw/" {
+		t.Errorf("Unexpected rewrite rules: %+v", config.RewriteRules)
+	}
+	if len(config.Webhooks) != 1 || config.Webhooks[0].URL != "https://hooks.example.com/mirror" || config.Webhooks[0].Timeout != 5*time.Second {
+		t.Errorf("Unexpected webhooks: %+v", config.Webhooks)
+	}
+
+	// Callbacks are supplied by the caller
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected Validate to require a file callback")
+	}
+	config.FileCallback = func(inputPath, outputPath string) (bool, error) { return true, nil }
+	if _, err := NewMirrorTransform(config); err != nil {
+		t.Errorf("Failed to create MirrorTransform: %v", err)
+	}
+}
+
+// TestLoadConfigJSON tests loading from JSON.
+func TestLoadConfigJSON(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "mirror.json")
+
+	content := `{"inputDir": "/in", "outputDir": "/out", "patterns": ["**/*.txt"], "sample": {"fraction": 0.1},
+		"webhooks": [{"url": "http://localhost:9000/hook", "errorThreshold": 10, "timeout": "2s"}]}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.InputDir != "/in" || config.OutputDir != "/out" || len(config.Patterns) != 1 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if config.Sample == nil || config.Sample.Fraction != 0.1 {
+		t.Errorf("Unexpected sample: %+v", config.Sample)
+	}
+	if len(config.Webhooks) != 1 || config.Webhooks[0].ErrorThreshold != 10 || config.Webhooks[0].Timeout != 2*time.Second {
+		t.Errorf("Unexpected webhooks: %+v", config.Webhooks)
+	}
+}
+
+// TestLoadConfigErrors tests that malformed or invalid files are rejected.
+func TestLoadConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "unknown key", file: "c.yaml", content: "inputDir: /in\noutputdir: /out\n", wantErr: "outputdir"},
+		{name: "unknown json key", file: "c.json", content: `{"input": "/in"}`, wantErr: "input"},
+		{name: "bad extension", file: "c.toml", content: "", wantErr: "unsupported"},
+		{name: "missing patterns", file: "c.yaml", content: "inputDir: /in\noutputDir: /out\n", wantErr: "pattern"},
+		{name: "bad duration", file: "c.yaml", content: "inputDir: /in\noutputDir: /out\npatterns: ['*']\nfailureBackoff:\n  maxDelay: soon\n", wantErr: "max delay"},
+		{name: "bad task order", file: "c.yaml", content: "inputDir: /in\noutputDir: /out\npatterns: ['*']\ntaskOrder: random\n", wantErr: "task order"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatalf("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error mentioning %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestConfigValidate tests that Validate reports every problem at once.
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		InputDir:          "/in",
+		Patterns:          []string{"**/*.jpg", "[bad"},
+		Concurrency:       -2,
+		ContentTypeFilter: []string{"jpeg"},
+		FailureBackoff:    &FailureBackoff{InitialDelay: time.Hour, MaxDelay: time.Minute},
+	}
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatalf("Expected validation error")
+	}
+	for _, want := range []string{
+		"output directory is required",
+		`invalid pattern "[bad"`,
+		"concurrency must not be negative",
+		`invalid content type filter "jpeg"`,
+		"initial delay 1h0m0s exceeds max delay 1m0s",
+		"file callback is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+// TestLoadConfigRecoverPartialOutputs tests that a config file can enable
+// RecoverPartialOutputs together with AllowDestructive.
+func TestLoadConfigRecoverPartialOutputs(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "mirror.yaml")
+
+	content := `inputDir: /in
+outputDir: /out
+patterns: ["**/*.jpg"]
+recoverPartialOutputs: true
+allowDestructive: true
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !config.RecoverPartialOutputs || !config.AllowDestructive {
+		t.Errorf("Expected RecoverPartialOutputs and AllowDestructive to be set, got %+v", config)
+	}
+}
+
+// TestLoadConfigContinueOnError tests that a config file can enable
+// ContinueOnError.
+func TestLoadConfigContinueOnError(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	path := filepath.Join(testDir, "mirror.json")
+
+	content := `{"inputDir": "/in", "outputDir": "/out", "patterns": ["**/*.jpg"], "continueOnError": true}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !config.ContinueOnError {
+		t.Errorf("Expected ContinueOnError to be set, got %+v", config)
+	}
+}
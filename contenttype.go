@@ -0,0 +1,63 @@
+package mirrortransform
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// sniffLength is the number of bytes inspected by content type detection.
+const sniffLength = 512
+
+// detectContentType returns the media type of the file at path, detected from
+// its first bytes as in net/http.DetectContentType (e.g. "image/jpeg").
+func detectContentType(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "", err
+	}
+	return mediaType, nil
+}
+
+// matchContentType reports whether mediaType matches any of the filters.
+// Filters are media types such as "image/png" or wildcards such as "image/*".
+func matchContentType(mediaType string, filters []string) bool {
+	for _, filter := range filters {
+		filter = strings.ToLower(strings.TrimSpace(filter))
+		if filter == mediaType || filter == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(filter, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowContentType reports whether the task passes ContentTypeFilter.
+// Read errors go through the error policy; a nil error with false skips the file.
+func (mt *mirrorTransform) allowContentType(task fileTask) (bool, error) {
+	if len(mt.config.ContentTypeFilter) == 0 {
+		return true, nil
+	}
+
+	mediaType, err := detectContentType(task.InputPath)
+	if err != nil {
+		return false, mt.handlePathError(task.InputPath, err, "read")
+	}
+	return matchContentType(mediaType, mt.config.ContentTypeFilter), nil
+}
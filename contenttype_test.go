@@ -0,0 +1,102 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCrawlContentTypeFilter tests filtering by sniffed content type.
+func TestCrawlContentTypeFilter(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	contents := map[string]string{
+		"real.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"fake.png":  "\xff\xd8\xff\xe0\x00\x10JFIF\x00",
+		"error.jpg": "<!DOCTYPE html><html><body>Not Found</body></html>",
+	}
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+	for name, content := range contents {
+		if err := os.WriteFile(filepath.Join(inputDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   []string
+		expected []string
+	}{
+		{name: "Exact", filter: []string{"image/png"}, expected: []string{"real.png"}},
+		{name: "Wildcard", filter: []string{"image/*"}, expected: []string{"fake.png", "real.png"}},
+		{name: "Disabled", filter: nil, expected: []string{"error.jpg", "fake.png", "real.png"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var processed []string
+			var mu sync.Mutex
+
+			config := Config{
+				InputDir:          inputDir,
+				OutputDir:         filepath.Join(testDir, "output", tt.name),
+				Patterns:          []string{"**/*.{jpg,png}"},
+				ContentTypeFilter: tt.filter,
+				Concurrency:       2,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					mu.Lock()
+					processed = append(processed, filepath.Base(inputPath))
+					mu.Unlock()
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			sort.Strings(processed)
+			if strings.Join(processed, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, processed)
+			}
+		})
+	}
+}
+
+// TestMatchContentType tests media type filter matching.
+func TestMatchContentType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		mediaType string
+		filters   []string
+		want      bool
+	}{
+		{mediaType: "image/jpeg", filters: []string{"image/jpeg"}, want: true},
+		{mediaType: "image/jpeg", filters: []string{"IMAGE/JPEG"}, want: true},
+		{mediaType: "image/jpeg", filters: []string{"image/*"}, want: true},
+		{mediaType: "text/html", filters: []string{"image/*"}, want: false},
+		{mediaType: "imagex/foo", filters: []string{"image/*"}, want: false},
+		{mediaType: "text/html", filters: []string{"*/*"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := matchContentType(tt.mediaType, tt.filters); got != tt.want {
+			t.Errorf("matchContentType(%q, %v) = %v, want %v", tt.mediaType, tt.filters, got, tt.want)
+		}
+	}
+}
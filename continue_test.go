@@ -0,0 +1,124 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCrawlContinueOnError tests that failures are collected and returned
+// after every file has been processed.
+func TestCrawlContinueOnError(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"ok1.jpg", "bad1.jpg", "dir/ok2.jpg", "dir/bad2.jpg"})
+
+	errCorrupt := errors.New("corrupt image")
+	var processed int32
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		Concurrency:     1,
+		ContinueOnError: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&processed, 1)
+			if strings.HasPrefix(filepath.Base(inputPath), "bad") {
+				return false, errCorrupt
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background())
+	if err == nil {
+		t.Fatalf("Expected joined error")
+	}
+	if !errors.Is(err, errCorrupt) {
+		t.Errorf("Expected errors.Is to find the callback error, got %v", err)
+	}
+	var fileErr FileError
+	if !errors.As(err, &fileErr) || !strings.HasPrefix(filepath.Base(fileErr.Path), "bad") || fileErr.Op != "process" {
+		t.Errorf("Expected errors.As to find a FileError, got %v", err)
+	}
+
+	if atomic.LoadInt32(&processed) != 4 {
+		t.Errorf("Expected all 4 files to be processed, got %d", processed)
+	}
+	if result.Processed != 2 || result.Failed != 2 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+// TestWatchContinueOnError tests that Watch reports failures to ErrorCallback and keeps running.
+func TestWatchContinueOnError(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	var reported, processed int32
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ContinueOnError: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&processed, 1)
+			if strings.HasPrefix(filepath.Base(inputPath), "bad") {
+				return false, errors.New("corrupt image")
+			}
+			return true, nil
+		},
+		ErrorCallback: func(path string, err error) (bool, error) {
+			atomic.AddInt32(&reported, 1)
+			return false, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	createTestFiles(t, inputDir, []string{"bad.jpg"})
+	time.Sleep(200 * time.Millisecond)
+	createTestFiles(t, inputDir, []string{"good.jpg"})
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+
+	if err := <-watchErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if atomic.LoadInt32(&reported) == 0 {
+		t.Errorf("Expected failure to be reported to ErrorCallback")
+	}
+	if atomic.LoadInt32(&processed) < 2 {
+		t.Errorf("Expected Watch to keep processing after a failure, got %d calls", processed)
+	}
+}
@@ -0,0 +1,74 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ideamans/go-mirror-transform/transform"
+)
+
+// tempFilePrefix is the name prefix of temporary files written by the copy
+// helpers before they are renamed into place.
+const tempFilePrefix = transform.TempFilePrefix
+
+// CopyOptions controls CopyFile and CopyCallback.
+// Mode bits and modification times are always preserved.
+type CopyOptions = transform.CopyOptions
+
+// RateLimiter caps the throughput of the copy helpers in bytes per second,
+// shared across the workers it is passed to.
+type RateLimiter = transform.RateLimiter
+
+// NewRateLimiter returns a limiter allowing bytesPerSecond. Zero or less
+// means no limit.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return transform.NewRateLimiter(bytesPerSecond)
+}
+
+// CopyCallback returns a FileCallback that copies each input to its output
+// path with CopyFile, for plain mirrors that need no transformation.
+func CopyCallback(opts CopyOptions) FileCallback {
+	return FileCallback(transform.CopyCallback(opts))
+}
+
+// CopyFile copies inputPath to outputPath, preserving mode bits and
+// modification time, and ownership and extended attributes as requested.
+// The copy is written to a temporary file next to outputPath and renamed
+// into place, so readers never see a partial output.
+func CopyFile(inputPath, outputPath string, opts CopyOptions) error {
+	return transform.CopyFile(inputPath, outputPath, opts)
+}
+
+// CopyFileContext is CopyFile ending early with the error of ctx once it is
+// done while waiting for opts.RateLimit, e.g. with the context of a task.
+func CopyFileContext(ctx context.Context, inputPath, outputPath string, opts CopyOptions) error {
+	return transform.CopyFileContext(ctx, inputPath, outputPath, opts)
+}
+
+// LinkOptions controls LinkFile and LinkCallback.
+type LinkOptions = transform.LinkOptions
+
+// LinkCallback returns a FileCallback that mirrors each input to its output
+// path with LinkFile, for identity transforms that should not duplicate data.
+func LinkCallback(opts LinkOptions) FileCallback {
+	return FileCallback(transform.LinkCallback(opts))
+}
+
+// LinkFile makes outputPath a copy of inputPath that shares its data where
+// the file system allows: a reflink if supported, then a hard link if
+// opts.Hardlink is set, and otherwise a regular copy with CopyFile.
+func LinkFile(inputPath, outputPath string, opts LinkOptions) error {
+	return transform.LinkFile(inputPath, outputPath, opts)
+}
+
+// EnsureOutputDir creates the directory outputPath is written to, for
+// callbacks used with LazyOutputDirs. It is a no-op if it exists.
+func EnsureOutputDir(outputPath string) error {
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory %q: %w", outputDir, err)
+	}
+	return nil
+}
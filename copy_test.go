@@ -0,0 +1,101 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestCopyCallback tests that a crawl with CopyCallback mirrors content, mode
+// and modification time without leaving temporary files.
+func TestCopyCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"dir/script.sh"})
+	input := filepath.Join(inputDir, "dir", "script.sh")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chmod(input, 0750); err != nil {
+		t.Fatalf("Failed to chmod: %v", err)
+	}
+	if err := os.Chtimes(input, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+
+	config := Config{
+		InputDir:     inputDir,
+		OutputDir:    outputDir,
+		Patterns:     []string{"**/*"},
+		FileCallback: CopyCallback(CopyOptions{PreserveOwner: runtime.GOOS == "linux" || runtime.GOOS == "darwin"}),
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	output := filepath.Join(outputDir, "dir", "script.sh")
+	data, err := os.ReadFile(output)
+	if err != nil || string(data) != "test content" {
+		t.Fatalf("Unexpected output %q (%v)", data, err)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatalf("Failed to stat output: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0750 {
+		t.Errorf("Expected mode 0750, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Expected modification time %v, got %v", modTime, info.ModTime())
+	}
+
+	entries, err := os.ReadDir(filepath.Join(outputDir, "dir"))
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the output file, got %v", entries)
+	}
+}
+
+// TestLinkCallback tests that a crawl with LinkCallback mirrors content.
+func TestLinkCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt", "dir/b.txt"})
+
+	config := Config{
+		InputDir:     inputDir,
+		OutputDir:    outputDir,
+		Patterns:     []string{"**/*"},
+		FileCallback: LinkCallback(LinkOptions{Hardlink: true}),
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	for _, relPath := range []string{"a.txt", "dir/b.txt"} {
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(relPath)))
+		if err != nil || string(data) != "test content" {
+			t.Errorf("Unexpected output for %s: %q (%v)", relPath, data, err)
+		}
+	}
+}
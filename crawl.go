@@ -2,38 +2,113 @@ package mirrortransform
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
+	"time"
 
-	"github.com/bmatcuk/doublestar/v4"
+	"github.com/ideamans/go-mirror-transform/internal/fspath"
 )
 
-// fileTask represents a file to be processed.
-type fileTask struct {
-	inputPath  string
-	outputPath string
+// Crawl traverses the input directory and processes matching files.
+func (mt *mirrorTransform) Crawl(ctx context.Context, opts ...RunOption) error {
+	_, err := mt.crawl(ctx, newRunState(false, opts...))
+	return err
 }
 
-// Crawl traverses the input directory and processes matching files.
-func (mt *mirrorTransform) Crawl(ctx context.Context) error {
+// CrawlWithResult is like Crawl and also returns a summary of the run.
+// The result is returned even when the crawl fails, describing the work done
+// up to that point; it is nil only if the crawl could not start.
+func (mt *mirrorTransform) CrawlWithResult(ctx context.Context, opts ...RunOption) (*Result, error) {
+	run := newRunState(false, opts...)
+	run.collectResult = true
+	result, err := mt.crawl(ctx, run)
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+	}
+	return result, err
+}
+
+// crawl runs a crawl with the given run state and returns its result.
+func (mt *mirrorTransform) crawl(ctx context.Context, run *runState) (*Result, error) {
+	return mt.runFinite(ctx, "Crawl", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		return mt.scanDirectory(ctx, taskChan, run)
+	})
+}
+
+// runFinite processes the tasks sent by produce until it returns, as in a
+// crawl, and returns the result of the run. operation names the run's span.
+func (mt *mirrorTransform) runFinite(ctx context.Context, operation string, run *runState, produce func(ctx context.Context, taskChan chan<- fileTask) error) (result *Result, err error) {
+	run.collectFailures = true
+	run.operation = operation
+	run.fileLimit = int64(mt.config.MaxFiles)
+
+	// Notify webhooks once the run has ended
+	defer func() { mt.webhooks.finished(ctx, run, err) }()
+
+	// Report throttled errors when the run ends
+	defer mt.flushErrorSummaries()
+
+	// Trace the run as a whole
+	ctx, endSpan := mt.tracing.startRun(ctx, operation)
+	defer func() { endSpan(result, err) }()
+
+	if err := mt.checkRunOptions(run); err != nil {
+		return nil, err
+	}
+
 	// Check for circular references
 	if err := mt.checkCircularReference(); err != nil {
-		return err
+		return nil, err
 	}
 
-	// Determine concurrency
-	concurrency := mt.config.Concurrency
-	maxConcurrency := mt.config.MaxConcurrency
-	if maxConcurrency <= 0 {
-		maxConcurrency = runtime.NumCPU()
+	if !run.options.dryRun {
+		// Keep other processes from writing the same outputs
+		if err := mt.lock.acquire(); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if releaseErr := mt.lock.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+
+		// The whole run is one generation of the output tree
+		if err := mt.generation.acquire(); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if releaseErr := mt.generation.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+
+		// Clean up outputs left half-written by an interrupted run
+		if _, err := mt.recoverPartialOutputs(); err != nil {
+			return nil, err
+		}
 	}
-	if concurrency <= 0 || concurrency > maxConcurrency {
-		concurrency = maxConcurrency
+
+	// Summarize the run once it ends
+	defer func() {
+		if result != nil {
+			mt.log().Info("run finished", "matched", result.Matched, "processed", result.Processed,
+				"skipped", result.Skipped, "failed", result.Failed, "duration", result.Duration, "error", err)
+		}
+	}()
+
+	// Re-read ignore files on every run
+	if mt.ignore != nil {
+		mt.ignore.reset()
+	}
+	if mt.sampler != nil {
+		mt.sampler.reset()
 	}
+	mt.outputGate.reset()
 
 	// Create channels for communication
 	taskChan := make(chan fileTask, 1000) // Buffered channel for better performance
@@ -46,22 +121,28 @@ func (mt *mirrorTransform) Crawl(ctx context.Context) error {
 	processorCtx, cancelProcessors := context.WithCancel(ctx)
 	defer cancelProcessors()
 
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go mt.fileProcessor(processorCtx, taskChan, errChan, &wg)
-	}
+	// Callbacks may outlive a cancellation by the shutdown grace period
+	run.shutdown = mt.newShutdown(ctx)
+	defer run.shutdown.stop()
+
+	// Sorted crawls wait for the full scan so the whole run follows the order
+	dispatchChan := mt.dispatchChannel(processorCtx, run, taskChan, true, &wg)
 
-	// Start directory scanner
+	mt.startWorkers(processorCtx, run, dispatchChan, errChan, &wg)
+
+	// Start the producer, usually the directory scanner, which MaxFiles
+	// stops early
+	produceCtx, stopProducing := context.WithCancel(processorCtx)
+	defer stopProducing()
+	run.stopProducing = stopProducing
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		defer close(taskChan)
 
-		if err := mt.scanDirectory(ctx, taskChan, errChan); err != nil {
-			select {
-			case errChan <- err:
-			case <-ctx.Done():
-			}
+		err := produce(produceCtx, taskChan)
+		if err != nil && !(run.fileLimitReached.Load() && errors.Is(err, context.Canceled)) {
+			sendError(processorCtx, errChan, err)
 		}
 	}()
 
@@ -74,21 +155,55 @@ func (mt *mirrorTransform) Crawl(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		// Context cancelled, wait for graceful shutdown
+		// Context cancelled, let in-flight callbacks finish
 		cancelProcessors()
-		<-done
-		return ctx.Err()
+		run.shutdown.drain(done)
+		return run.result(), mt.withFailures(run, ctx.Err())
 	case err := <-errChan:
 		// Error occurred, cancel and wait for shutdown
 		cancelProcessors()
 		<-done
-		return err
+		return run.result(), mt.withFailures(run, err)
 	case <-done:
-		// All work completed successfully
-		return nil
+		// All work completed, possibly cut short by the output quota
+		if mt.quotaReached(run) {
+			return run.result(), mt.withFailures(run, mt.quotaError(run))
+		}
+		return run.result(), mt.withFailures(run, nil)
 	}
 }
 
+// concurrency returns the number of file processors to run,
+// min(Concurrency, MaxConcurrency) with MaxConcurrency defaulting to the CPU
+// count, or one in Ordered mode. With AutoConcurrency it is the initial
+// size, the CPU count within the bounds.
+func (mt *mirrorTransform) concurrency() int {
+	// Ordered runs process one file at a time
+	if mt.config.Ordered {
+		return 1
+	}
+
+	if mt.autoConcurrency() {
+		low, high := mt.concurrencyBounds()
+		return min(max(runtime.NumCPU(), low), high)
+	}
+
+	concurrency := mt.currentSettings().concurrency
+	maxConcurrency := mt.maxConcurrency()
+	if concurrency <= 0 || concurrency > maxConcurrency {
+		concurrency = maxConcurrency
+	}
+	return concurrency
+}
+
+// maxConcurrency returns MaxConcurrency, or the CPU count if it is not set.
+func (mt *mirrorTransform) maxConcurrency() int {
+	if maxConcurrency := mt.currentSettings().maxConcurrency; maxConcurrency > 0 {
+		return maxConcurrency
+	}
+	return runtime.NumCPU()
+}
+
 // checkCircularReference checks if input and output directories would create a circular reference.
 func (mt *mirrorTransform) checkCircularReference() error {
 	inputAbs, err := filepath.Abs(mt.config.InputDir)
@@ -96,31 +211,57 @@ func (mt *mirrorTransform) checkCircularReference() error {
 		return fmt.Errorf("failed to get absolute path of input directory: %w", err)
 	}
 
-	outputAbs, err := filepath.Abs(mt.config.OutputDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path of output directory: %w", err)
+	inputAbs = fspath.Normalize(filepath.Clean(inputAbs))
+
+	// Variants may write outside OutputDir
+	outputDirs := []string{mt.config.OutputDir}
+	for _, v := range mt.config.Variants {
+		if v.Dir != "" {
+			outputDirs = append(outputDirs, v.Dir)
+		}
+	}
+	if mt.config.Shadow != nil {
+		outputDirs = append(outputDirs, mt.config.Shadow.Dir)
 	}
 
-	// Normalize paths for comparison
-	inputAbs = filepath.Clean(inputAbs)
-	outputAbs = filepath.Clean(outputAbs)
+	for _, outputDir := range outputDirs {
+		outputAbs, err := filepath.Abs(outputDir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path of output directory: %w", err)
+		}
 
-	// Check if output is inside input
-	if strings.HasPrefix(outputAbs, inputAbs+string(filepath.Separator)) || outputAbs == inputAbs {
-		return fmt.Errorf("output directory %q is inside input directory %q, which would create a circular reference", outputAbs, inputAbs)
-	}
+		// Normalize paths for comparison
+		outputAbs = fspath.Normalize(filepath.Clean(outputAbs))
 
-	// Check if input is inside output (safety check)
-	if strings.HasPrefix(inputAbs, outputAbs+string(filepath.Separator)) {
-		return fmt.Errorf("input directory %q is inside output directory %q, which would create a circular reference", inputAbs, outputAbs)
+		// Check if output is inside input
+		if fspath.HasPrefix(outputAbs, inputAbs+string(filepath.Separator)) || fspath.Same(outputAbs, inputAbs) {
+			return markError(ErrCircularReference, fmt.Errorf("output directory %q is inside input directory %q, which would create a circular reference", outputAbs, inputAbs))
+		}
+
+		// Check if input is inside output (safety check)
+		if fspath.HasPrefix(inputAbs, outputAbs+string(filepath.Separator)) {
+			return markError(ErrCircularReference, fmt.Errorf("input directory %q is inside output directory %q, which would create a circular reference", inputAbs, outputAbs))
+		}
 	}
 
 	return nil
 }
 
 // scanDirectory recursively scans the directory and sends matching files to the task channel.
-func (mt *mirrorTransform) scanDirectory(ctx context.Context, taskChan chan<- fileTask, _ chan<- error) error {
-	return filepath.Walk(mt.config.InputDir, func(path string, info os.FileInfo, err error) error {
+// Matches are counted in run, which may be nil.
+// With ScanConcurrency above one, directories are read in parallel.
+func (mt *mirrorTransform) scanDirectory(ctx context.Context, taskChan chan<- fileTask, run *runState) error {
+	return mt.scanTree(ctx, mt.walkRoot(run), taskChan, run)
+}
+
+// scanTree is scanDirectory for the tree at root, which is InputDir or a
+// path inside it.
+func (mt *mirrorTransform) scanTree(ctx context.Context, root string, taskChan chan<- fileTask, run *runState) error {
+	if mt.config.ScanConcurrency > 1 {
+		return mt.scanParallel(ctx, root, taskChan, run, mt.config.ScanConcurrency)
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		// Check context cancellation
 		select {
 		case <-ctx.Done():
@@ -130,115 +271,468 @@ func (mt *mirrorTransform) scanDirectory(ctx context.Context, taskChan chan<- fi
 
 		// Handle walk error
 		if err != nil {
-			if mt.config.ErrorCallback != nil {
-				stop, retErr := mt.config.ErrorCallback(path, err)
-				if retErr != nil {
-					return fmt.Errorf("error callback failed at %q: %w", path, retErr)
-				}
-				if stop {
-					return fmt.Errorf("stopped due to error at %q: %w", path, err)
-				}
-				// Continue processing
-				return nil
-			}
-			return fmt.Errorf("failed to access %q: %w", path, err)
+			return mt.handlePathError(path, err, "access")
 		}
 
-		// Get relative path from input directory
-		relPath, err := filepath.Rel(mt.config.InputDir, path)
+		return mt.scanEntry(ctx, path, d, taskChan, run)
+	})
+}
+
+// scanEntry checks a single file or directory found while scanning and sends
+// a task for a matching file. It returns filepath.SkipDir for directories
+// that must not be descended into.
+// Entries are only stat'ed if they match and scanNeedsInfo.
+func (mt *mirrorTransform) scanEntry(ctx context.Context, path string, d fs.DirEntry, taskChan chan<- fileTask, run *runState) error {
+	// Get relative path from input directory
+	relPath, err := mt.relPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path for %q: %w", path, err)
+	}
+
+	pattern, err := mt.entryPattern(path, relPath, d)
+	if err != nil {
+		return err
+	}
+
+	// Reproduce directories in the output tree unless nothing may be written
+	if d.IsDir() {
+		if run == nil || run.options.dryRun {
+			return nil
+		}
+
+		// Let DirCallback skip the whole subtree
+		enter, err := mt.enterRunDir(run, path, relPath)
 		if err != nil {
-			return fmt.Errorf("failed to get relative path for %q: %w", path, err)
+			return err
+		}
+		if !enter {
+			return filepath.SkipDir
 		}
+		return mt.mirrorDir(relPath)
+	}
+	if pattern == "" {
+		return nil
+	}
 
-		// Check exclude patterns
-		for _, pattern := range mt.config.ExcludePatterns {
-			match, err := doublestar.Match(pattern, relPath)
-			if err != nil {
-				return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
-			}
-			if match {
-				if info.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+	var info os.FileInfo
+	if mt.scanNeedsInfo(run) {
+		if info, err = d.Info(); err != nil {
+			return mt.handlePathError(path, err, "access")
 		}
+	}
 
-		// Skip directories for pattern matching
-		if info.IsDir() {
-			return nil
+	return mt.sendTask(ctx, path, relPath, info, TaskEventScan, pattern, taskChan, run)
+}
+
+// scanNeedsInfo reports whether scanned files must be stat'ed because their
+// size or modification time is used: to order tasks, for failure backoff or
+// the retry queue, the no-cache threshold, MaxInFlightBytes or OnlyIfStale,
+// for TaskCallback and ResultCallback, or when the run compares versions.
+func (mt *mirrorTransform) scanNeedsInfo(run *runState) bool {
+	return mt.config.TaskSorter != nil ||
+		mt.config.TaskCallback != nil ||
+		mt.config.ResultCallback != nil ||
+		mt.config.FailureBackoff != nil ||
+		mt.config.RetryQueue ||
+		mt.config.NoCacheThreshold > 0 ||
+		mt.config.MaxInFlightBytes > 0 ||
+		mt.config.OnlyIfStale ||
+		(run != nil && run.statScanned)
+}
+
+// entryPattern returns the pattern a file matches, or "" if it is skipped.
+// For directories it returns filepath.SkipDir if they must not be descended
+// into and "" otherwise.
+func (mt *mirrorTransform) entryPattern(path, relPath string, d fs.DirEntry) (string, error) {
+	isDir := d.IsDir()
+
+	// Stay within MaxDepth
+	if mt.beyondMaxDepth(relPath, isDir) {
+		if isDir {
+			return "", filepath.SkipDir
 		}
+		return "", nil
+	}
 
-		// Check if file matches any pattern
-		matched := false
-		for _, pattern := range mt.config.Patterns {
-			match, err := doublestar.Match(pattern, relPath)
-			if err != nil {
-				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
-			}
-			if match {
-				matched = true
-				break
-			}
+	// Skip hidden files and directories
+	if !mt.config.IncludeHidden && isHidden(path, relPath) {
+		if isDir {
+			return "", filepath.SkipDir
 		}
+		return "", nil
+	}
 
-		if !matched {
-			return nil
+	// Check ignore files
+	ignored, err := mt.isIgnored(relPath, isDir)
+	if err != nil {
+		return "", err
+	}
+	if ignored {
+		if isDir {
+			return "", filepath.SkipDir
 		}
+		return "", nil
+	}
 
-		// Create output path
-		outputPath := filepath.Join(mt.config.OutputDir, relPath)
+	// Check exclude patterns
+	excluded, err := mt.isExcluded(relPath)
+	if err != nil {
+		return "", err
+	}
+	if excluded {
+		if isDir {
+			return "", filepath.SkipDir
+		}
+		return "", nil
+	}
 
-		// Send task to channel
-		select {
-		case taskChan <- fileTask{inputPath: path, outputPath: outputPath}:
-			return nil
-		case <-ctx.Done():
-			return ctx.Err()
+	// Skip directories for pattern matching
+	if isDir {
+		return "", nil
+	}
+
+	// Check if file matches any pattern
+	pattern, err := mt.matchPattern(relPath, d)
+	if err != nil || pattern == "" {
+		return "", err
+	}
+
+	// Skip priority sidecars and files outside the sample
+	if mt.isPrioritySidecar(relPath) || !mt.inSample(relPath) {
+		return "", nil
+	}
+	return pattern, nil
+}
+
+// sendTask sends the task for a matching file to taskChan, counting it in
+// run, which may be nil.
+func (mt *mirrorTransform) sendTask(ctx context.Context, path, relPath string, info os.FileInfo, event TaskEvent, pattern string, taskChan chan<- fileTask, run *runState) error {
+	// Create output path
+	outputPath, err := mt.outputPath(relPath)
+	if err != nil {
+		return err
+	}
+
+	task := newFileTask(path, outputPath, relPath, info, event, pattern)
+	if run != nil {
+		task.archive = run.archive
+	}
+
+	// Send task to channel
+	select {
+	case taskChan <- task:
+		if run != nil {
+			run.matched.Add(1)
+			run.addPending(path)
 		}
-	})
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// fileProcessor processes files from the task channel.
-func (mt *mirrorTransform) fileProcessor(ctx context.Context, taskChan <-chan fileTask, errChan chan<- error, wg *sync.WaitGroup) {
-	defer wg.Done()
+// fileProcessor processes files from the task channel until it is closed,
+// ctx is done, or the pool shrinks.
+func (mt *mirrorTransform) fileProcessor(ctx context.Context, run *runState, taskChan <-chan fileTask, errChan chan<- error, pool *workerPool) {
+	defer pool.done()
+
+	// Report what the worker does in QueueStats
+	worker := mt.workers.add(run)
+	defer mt.workers.remove(worker)
 
 	for {
+		if pool.retire() {
+			return
+		}
+
+		// Tasks held while the output was read-only go first
+		if task, ok := mt.outputGate.take(); ok {
+			mt.log().Debug("retrying held file", "path", task.RelPath)
+			if !mt.processAs(ctx, run, worker, task, errChan) {
+				return
+			}
+			continue
+		}
+
 		select {
 		case <-ctx.Done():
 			return
+		case <-pool.resized():
+			// Check whether to retire
+		case <-mt.outputGate.resumed():
+			// Pick up the held tasks
 		case task, ok := <-taskChan:
 			if !ok {
+				// Finish held tasks once the output is writable again
+				if mt.outputGate.waitHeld(ctx) {
+					continue
+				}
 				return
 			}
-
-			// Ensure output directory exists
-			outputDir := filepath.Dir(task.outputPath)
-			if err := os.MkdirAll(outputDir, 0o755); err != nil {
-				select {
-				case errChan <- fmt.Errorf("failed to create output directory %q: %w", outputDir, err):
-				case <-ctx.Done():
-				}
+			run.queued.Add(-1)
+			if !mt.processAs(ctx, run, worker, task, errChan) {
 				return
 			}
+		}
+	}
+}
 
-			// Call the file callback
-			continueProcessing, err := mt.config.FileCallback(task.inputPath, task.outputPath)
-			if err != nil {
-				select {
-				case errChan <- fmt.Errorf("file callback failed for %q: %w", task.inputPath, err):
-				case <-ctx.Done():
-				}
-				return
+// processTask processes a single task. It returns false if the processor
+// must stop, after reporting the reason on errChan.
+func (mt *mirrorTransform) processTask(ctx context.Context, run *runState, task fileTask, errChan chan<- error) bool {
+	// Stop dispatching once the output quota is reached
+	if mt.quotaReached(run) {
+		if err := mt.deferTask(run, task); err != nil {
+			sendError(ctx, errChan, err)
+			return false
+		}
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "output quota reached")
+		return true
+	}
+
+	// Skip paths that are backing off after repeated failures
+	if mt.failures != nil && !run.options.force {
+		allowed, err := mt.failures.allow(task)
+		if err != nil {
+			sendError(ctx, errChan, err)
+			return false
+		}
+		if !allowed {
+			run.finishPending(task.InputPath)
+			run.skipped.Add(1)
+			mt.skipTask(run, task, "backing off after failures")
+			return true
+		}
+	}
+
+	// Skip files whose content does not match the content type filter
+	allowed, err := mt.allowContentType(task)
+	if err != nil {
+		sendError(ctx, errChan, err)
+		return false
+	}
+	if !allowed {
+		run.finishPending(task.InputPath)
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "content type filtered")
+		return true
+	}
+
+	// Skip files whose outputs are newer than the input
+	fresh, err := mt.upToDate(task)
+	if err != nil {
+		if err := mt.handlePathError(task.InputPath, err, "check"); err != nil {
+			sendError(ctx, errChan, err)
+			return false
+		}
+		fresh = false
+	}
+	if fresh {
+		run.finishPending(task.InputPath)
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "output up to date")
+		return true
+	}
+
+	// Skip files a previous scheduled crawl processed in this version
+	if run.stamps.unchanged(task) {
+		run.finishPending(task.InputPath)
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "unchanged since the last crawl")
+		return true
+	}
+
+	// Dry runs stop short of producing anything
+	if run.options.dryRun {
+		run.finishPending(task.InputPath)
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "dry run")
+		return true
+	}
+
+	// Hold the task while the output is read-only
+	if mt.outputGate.hold(task) {
+		return true
+	}
+
+	// Leave the remaining files to the next run once MaxFiles is reached
+	if !mt.reserveFile(run) {
+		if err := mt.deferTask(run, task); err != nil {
+			sendError(ctx, errChan, err)
+			return false
+		}
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "file limit reached")
+		return true
+	}
+
+	// Key the task by its content for downstream deduplication
+	if err := mt.setIdempotencyKey(&task); err != nil {
+		if err := mt.handlePathError(task.InputPath, err, "hash"); err != nil {
+			sendError(ctx, errChan, err)
+			return false
+		}
+		mt.unreserveFile(run)
+		run.finishPending(task.InputPath)
+		run.skipped.Add(1)
+		mt.skipTask(run, task, "hashing failed")
+		return true
+	}
+
+	// Wait for a slot of the override for the subtree
+	override := mt.overrides.find(task.RelPath)
+	if !override.acquire(ctx) {
+		mt.unreserveFile(run)
+		if err := mt.deferTask(run, task); err != nil {
+			sendError(ctx, errChan, err)
+		}
+		return false
+	}
+	defer override.release()
+
+	// Wait for room in the memory budget
+	size := taskSize(task)
+	if !mt.budget.acquire(ctx, size) {
+		mt.unreserveFile(run)
+		if err := mt.deferTask(run, task); err != nil {
+			sendError(ctx, errChan, err)
+		}
+		return false
+	}
+	defer mt.budget.release(size)
+
+	// Ensure output directories exist, unless the callback creates them
+	outputs, err := mt.taskOutputs(task)
+	if err != nil {
+		sendError(ctx, errChan, err)
+		return false
+	}
+	if !mt.config.LazyOutputDirs {
+		if err := ensureOutputDirs(outputs); err != nil {
+			if mt.outputGate.pauseOn(ctx, err, task) {
+				mt.unreserveFile(run)
+				return true
 			}
+			sendError(ctx, errChan, err)
+			return false
+		}
+	}
 
-			if !continueProcessing {
-				select {
-				case errChan <- fmt.Errorf("processing stopped by callback at %q", task.inputPath):
-				case <-ctx.Done():
-				}
-				return
+	// Mark outputs as in progress so a crash can be recovered from
+	if err := mt.writePartialMarkers(task, outputs); err != nil {
+		if mt.outputGate.pauseOn(ctx, err, task) {
+			mt.unreserveFile(run)
+			return true
+		}
+		sendError(ctx, errChan, err)
+		return false
+	}
+
+	// Call the file callback
+	if err := mt.generation.acquire(); err != nil {
+		sendError(ctx, errChan, err)
+		return false
+	}
+	mt.log().Debug("processing file", "path", task.RelPath, "event", task.Event, "pattern", task.Pattern)
+	callbackCtx, span := mt.tracing.startFile(withMetadata(run.shutdown.ctx, run.options.metadata), task)
+	start := time.Now()
+	run.shutdown.begin(task.InputPath)
+	continueProcessing, err := mt.runCallback(callbackCtx, task, outputs)
+	run.shutdown.end(task.InputPath)
+	run.callbacks.Add(1)
+	run.callbackTime.Add(int64(time.Since(start)))
+	mt.tracing.endFile(span, time.Since(start), continueProcessing, err)
+	if releaseErr := mt.generation.release(); releaseErr != nil {
+		sendError(ctx, errChan, releaseErr)
+		return false
+	}
+	if mt.config.Shadow != nil {
+		mt.runShadow(task, time.Since(start), err)
+	}
+	if err == nil {
+		err = mt.preserveMetadata(task, outputs)
+	}
+	mt.checkLatency(task)
+	mt.evictFromCache(task, outputs)
+	if err != nil {
+		// Retry once the output is writable again, counting the file then
+		if mt.outputGate.pauseOn(ctx, err, task) {
+			mt.unreserveFile(run)
+			return true
+		}
+
+		run.finishPending(task.InputPath)
+		run.addFailure(task.InputPath, err)
+		mt.reportResult(run, task, start, TaskResult{Status: TaskFailed, Duration: time.Since(start), BytesRead: task.Size, Err: err})
+		mt.webhooks.failed(ctx, run)
+		mt.log().Warn("file callback failed", "path", task.RelPath, "error", err)
+		// Without backoff every failure is final, with it only parking
+		final := true
+		var recordErr error
+		if mt.failures != nil {
+			final, recordErr = mt.failures.recordFailure(task, err)
+		}
+		if final && recordErr == nil {
+			recordErr = mt.quarantine(task, err)
+		}
+
+		// Keep going and report the failure at the end of the run
+		if mt.config.ContinueOnError && recordErr == nil {
+			if err := mt.reportContinuedFailure(run, task, err); err != nil {
+				sendError(ctx, errChan, err)
+				return false
 			}
+			return true
+		}
+
+		err = errors.Join(fmt.Errorf("file callback failed: %w", FileError{Path: task.InputPath, Op: "process", Err: err}), recordErr)
+		sendError(ctx, errChan, err)
+		return false
+	}
+
+	// The callback succeeded, so the input is no longer unprocessed
+	run.finishPending(task.InputPath)
+	run.stamps.record(task)
+
+	// Failed outputs keep their markers so the next start cleans them up
+	if err := mt.removePartialMarkers(outputs); err != nil {
+		sendError(ctx, errChan, err)
+		return false
+	}
+
+	if mt.failures != nil {
+		if err := mt.failures.recordSuccess(task); err != nil {
+			sendError(ctx, errChan, err)
+			return false
 		}
 	}
+	if err := mt.unquarantine(task); err != nil {
+		sendError(ctx, errChan, err)
+		return false
+	}
+
+	run.processed.Add(1)
+	duration := time.Since(start)
+	mt.log().Debug("processed file", "path", task.RelPath, "duration", duration)
+	written, reached := mt.recordOutputBytes(run, outputs)
+	mt.reportResult(run, task, start, TaskResult{Status: TaskProcessed, Duration: duration, BytesRead: task.Size, BytesWritten: written})
+	if reached && run.stopOnQuota {
+		sendError(ctx, errChan, mt.quotaError(run))
+		return false
+	}
+
+	if !continueProcessing {
+		sendError(ctx, errChan, fmt.Errorf("%w at %q", ErrStoppedByCallback, task.InputPath))
+		return false
+	}
+	return true
+}
+
+// sendError reports err on errChan unless ctx is done first.
+func sendError(ctx context.Context, errChan chan<- error, err error) {
+	select {
+	case errChan <- err:
+	case <-ctx.Done():
+	}
 }
@@ -131,6 +131,72 @@ func TestCrawlExcludePatterns(t *testing.T) {
 	}
 }
 
+// TestCrawlMaxDepth tests that crawls stay within MaxDepth, with sequential
+// and parallel scans.
+func TestCrawlMaxDepth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		maxDepth        int
+		scanConcurrency int
+		expected        []string
+	}{
+		{name: "unlimited", maxDepth: 0, expected: []string{"a.jpg", "b/c.jpg", "b/d/e.jpg", "b/d/f/g.jpg"}},
+		{name: "input dir only", maxDepth: 1, expected: []string{"a.jpg"}},
+		{name: "two levels", maxDepth: 2, expected: []string{"a.jpg", "b/c.jpg"}},
+		{name: "two levels parallel", maxDepth: 2, scanConcurrency: 4, expected: []string{"a.jpg", "b/c.jpg"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			outputDir := filepath.Join(testDir, "output")
+
+			createTestFiles(t, inputDir, []string{"a.jpg", "b/c.jpg", "b/d/e.jpg", "b/d/f/g.jpg"})
+
+			var mu sync.Mutex
+			processed := make(map[string]bool)
+
+			config := Config{
+				InputDir:        inputDir,
+				OutputDir:       outputDir,
+				Patterns:        []string{"**/*.jpg"},
+				MaxDepth:        tt.maxDepth,
+				ScanConcurrency: tt.scanConcurrency,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					relPath, _ := filepath.Rel(inputDir, inputPath)
+					mu.Lock()
+					processed[filepath.ToSlash(relPath)] = true
+					mu.Unlock()
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			if len(processed) != len(tt.expected) {
+				t.Errorf("Expected %v to be processed, got %v", tt.expected, processed)
+			}
+			for _, relPath := range tt.expected {
+				if !processed[relPath] {
+					t.Errorf("Expected %s to be processed, got %v", relPath, processed)
+				}
+			}
+		})
+	}
+}
+
 // TestCrawlConcurrency tests different concurrency levels.
 func TestCrawlConcurrency(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,39 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDestructiveNotAllowed is returned by Config.Validate when a feature that
+// deletes, moves or overwrites existing outputs is enabled without
+// Config.AllowDestructive.
+var ErrDestructiveNotAllowed = errors.New("destructive features require AllowDestructive")
+
+// destructiveFeatures returns the names of the enabled features that delete,
+// move or overwrite existing outputs. Features whose effect is delegated to a
+// callback are left out: the callback decides what happens to the outputs.
+func (c *Config) destructiveFeatures() []string {
+	var features []string
+	if c.RecoverPartialOutputs {
+		features = append(features, "RecoverPartialOutputs")
+	}
+	if c.TrackRenames && c.RenameCallback == nil {
+		features = append(features, "TrackRenames")
+	}
+	if c.MirrorDirRemovals {
+		features = append(features, "MirrorDirRemovals")
+	}
+	return features
+}
+
+// validateDestructive checks that destructive features are only enabled
+// together with AllowDestructive.
+func (c *Config) validateDestructive() error {
+	features := c.destructiveFeatures()
+	if len(features) == 0 || c.AllowDestructive {
+		return nil
+	}
+	return fmt.Errorf("%w: %s would delete or replace outputs", ErrDestructiveNotAllowed, strings.Join(features, ", "))
+}
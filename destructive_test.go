@@ -0,0 +1,68 @@
+package mirrortransform
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestAllowDestructive tests that features deleting or replacing outputs
+// require the AllowDestructive master switch.
+func TestAllowDestructive(t *testing.T) {
+	t.Parallel()
+
+	renameCallback := func(oldOutputPath, newOutputPath string) error { return nil }
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{name: "none", modify: func(c *Config) {}},
+		{name: "recover partial outputs", modify: func(c *Config) { c.RecoverPartialOutputs = true }, wantErr: "RecoverPartialOutputs"},
+		{name: "track renames", modify: func(c *Config) { c.TrackRenames = true }, wantErr: "TrackRenames"},
+		{name: "rename callback", modify: func(c *Config) { c.TrackRenames = true; c.RenameCallback = renameCallback }},
+		{
+			name: "both",
+			modify: func(c *Config) {
+				c.RecoverPartialOutputs = true
+				c.TrackRenames = true
+			},
+			wantErr: "RecoverPartialOutputs, TrackRenames",
+		},
+		{
+			name: "allowed",
+			modify: func(c *Config) {
+				c.RecoverPartialOutputs = true
+				c.TrackRenames = true
+				c.AllowDestructive = true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := Config{
+				InputDir:  "/in",
+				OutputDir: "/out",
+				Patterns:  []string{"**/*"},
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					return true, nil
+				},
+			}
+			tt.modify(&config)
+
+			err := config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrDestructiveNotAllowed) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected ErrDestructiveNotAllowed naming %s, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package mirrortransform
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DirCallback is called when a directory is entered, before any file in
+// it is processed. inputDir is its full path and outputDir the full path of
+// the directory its outputs are written to. If enter is false, the
+// directory and everything below it are skipped.
+type DirCallback func(inputDir, outputDir string) (enter bool, err error)
+
+// dirVetoes remembers the directories DirCallback skipped, so watch events
+// below them are ignored.
+type dirVetoes struct {
+	mu      sync.Mutex
+	relDirs map[string]bool
+}
+
+// newDirVetoes returns an empty set, or nil without a DirCallback.
+func newDirVetoes(config *Config) *dirVetoes {
+	if config.DirCallback == nil {
+		return nil
+	}
+	return &dirVetoes{relDirs: make(map[string]bool)}
+}
+
+// set records whether the directory relDir was skipped. It is a no-op on a
+// nil set.
+func (v *dirVetoes) set(relDir string, skipped bool) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if skipped {
+		v.relDirs[relDir] = true
+	} else {
+		delete(v.relDirs, relDir)
+	}
+}
+
+// covers reports whether relPath is below a skipped directory. It is false
+// on a nil set.
+func (v *dirVetoes) covers(relPath string) bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.relDirs) == 0 {
+		return false
+	}
+	for dir := filepath.Dir(relPath); dir != "." && !strings.HasPrefix(dir, ".."); dir = filepath.Dir(dir) {
+		if v.relDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// dirAnswers remembers what DirCallback answered for the directories of a
+// run.
+type dirAnswers struct {
+	mu     sync.Mutex
+	enters map[string]bool
+}
+
+// get returns the answer for relDir, and whether there is one.
+func (a *dirAnswers) get(relDir string) (enter, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	enter, ok = a.enters[relDir]
+	return enter, ok
+}
+
+// put records the answer for relDir.
+func (a *dirAnswers) put(relDir string, enter bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.enters == nil {
+		a.enters = make(map[string]bool)
+	}
+	a.enters[relDir] = enter
+}
+
+// forget drops the answer for relDir, so it is asked again.
+func (a *dirAnswers) forget(relDir string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.enters, relDir)
+}
+
+// enterRunDir is enterDir for a directory of run. A directory asked about
+// earlier in the run, e.g. when watching it, keeps its answer.
+func (mt *mirrorTransform) enterRunDir(run *runState, path, relPath string) (bool, error) {
+	if enter, ok := run.entered.get(relPath); ok {
+		return enter, nil
+	}
+	enter, err := mt.enterDir(path, relPath)
+	if err != nil {
+		return false, err
+	}
+	run.entered.put(relPath, enter)
+	return enter, nil
+}
+
+// enterDir asks DirCallback whether to enter the directory at path, whose
+// path relative to InputDir is relPath, and remembers the answer. It is
+// true without a DirCallback and for InputDir itself. A callback error is
+// handled like other errors at path.
+func (mt *mirrorTransform) enterDir(path, relPath string) (bool, error) {
+	if mt.config.DirCallback == nil || relPath == "." {
+		return true, nil
+	}
+
+	outputDir := mt.config.OutputDir
+	if !mt.config.Flatten {
+		relOutput, err := mt.outputDirRel(relPath)
+		if err != nil {
+			return false, err
+		}
+		outputDir = filepath.Join(outputDir, relOutput)
+	}
+
+	enter, err := mt.config.DirCallback(path, outputDir)
+	if err != nil {
+		// Skip the directory when the error policy continues
+		return false, mt.handlePathError(path, err, "run directory callback for")
+	}
+	if !enter {
+		mt.log().Debug("skipping directory", "dir", path)
+	}
+	mt.dirVetoes.set(relPath, !enter)
+	return enter, nil
+}
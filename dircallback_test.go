@@ -0,0 +1,263 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestDirCallback tests that DirCallback is called for every directory a
+// crawl enters and can skip whole subtrees.
+func TestDirCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "keep/b.jpg", "keep/inner/c.jpg", "skip/d.jpg", "skip/inner/e.jpg"})
+
+	var mu sync.Mutex
+	dirs := make(map[string]string)
+	var processed []string
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		DirCallback: func(inputDir, outputDir string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dirs[inputDir] = outputDir
+			return filepath.Base(inputDir) != "skip", nil
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			processed = append(processed, filepath.Base(inputPath))
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	want := map[string]string{
+		filepath.Join(inputDir, "keep"):          filepath.Join(outputDir, "keep"),
+		filepath.Join(inputDir, "keep", "inner"): filepath.Join(outputDir, "keep", "inner"),
+		filepath.Join(inputDir, "skip"):          filepath.Join(outputDir, "skip"),
+	}
+	if len(dirs) != len(want) {
+		t.Errorf("Expected the callback for %v, got %v", want, dirs)
+	}
+	for inputDir, outputDir := range want {
+		if dirs[inputDir] != outputDir {
+			t.Errorf("Expected output directory %s for %s, got %q", outputDir, inputDir, dirs[inputDir])
+		}
+	}
+
+	sort.Strings(processed)
+	if strings.Join(processed, ",") != "a.jpg,b.jpg,c.jpg" {
+		t.Errorf("Expected the skipped subtree to be left out, got %v", processed)
+	}
+}
+
+// TestDirCallbackWatch tests that DirCallback is asked for directories
+// created while watching and that skipped ones are not processed.
+func TestDirCallbackWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	processed := make(chan string, 10)
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		DirCallback: func(inputDir, outputDir string) (bool, error) {
+			return filepath.Base(inputDir) != "skip", nil
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			processed <- filepath.Base(inputPath)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	// The skipped directory goes first, so its file would arrive first
+	for _, dir := range []string{"skip", "keep"} {
+		if err := os.Mkdir(filepath.Join(inputDir, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		createTestFiles(t, filepath.Join(inputDir, dir), []string{dir + ".jpg"})
+	}
+
+	select {
+	case name := <-processed:
+		if name != "keep.jpg" {
+			t.Errorf("Expected only keep.jpg to be processed, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for keep.jpg")
+	}
+
+	cancel()
+	<-watchErr
+	close(processed)
+	for name := range processed {
+		if name != "keep.jpg" {
+			t.Errorf("Expected only keep.jpg to be processed, got %s", name)
+		}
+	}
+}
+
+// TestDirCallbackWatchExisting tests that Watch asks DirCallback about the
+// directories that exist when it starts and ignores files in skipped ones.
+func TestDirCallbackWatchExisting(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"keep/a.jpg", "skip/b.jpg"})
+
+	var mu sync.Mutex
+	asked := make(map[string]int)
+	processed := make(chan string, 10)
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		DirCallback: func(inputDir, outputDir string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			asked[filepath.Base(inputDir)]++
+			return filepath.Base(inputDir) != "skip", nil
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			processed <- filepath.Base(inputPath)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	mu.Lock()
+	if asked["skip"] != 1 || asked["keep"] != 1 {
+		t.Errorf("Expected each existing directory to be asked about once, got %v", asked)
+	}
+	mu.Unlock()
+
+	// The skipped directory goes first, so its file would arrive first
+	createTestFiles(t, inputDir, []string{"skip/c.jpg"})
+	time.Sleep(100 * time.Millisecond)
+	createTestFiles(t, inputDir, []string{"keep/d.jpg"})
+
+	select {
+	case name := <-processed:
+		if name != "d.jpg" {
+			t.Errorf("Expected only d.jpg to be processed, got %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for d.jpg")
+	}
+
+	cancel()
+	<-watchErr
+	close(processed)
+	for name := range processed {
+		if name != "d.jpg" {
+			t.Errorf("Expected only d.jpg to be processed, got %s", name)
+		}
+	}
+}
+
+// TestDirCallbackProcessFile tests that ProcessFile refuses a file in a
+// directory DirCallback skipped.
+func TestDirCallbackProcessFile(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"keep/a.jpg", "skip/inner/b.jpg"})
+
+	var mu sync.Mutex
+	var processed []string
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		DirCallback: func(inputDir, outputDir string) (bool, error) {
+			return filepath.Base(inputDir) != "skip", nil
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			processed = append(processed, filepath.Base(inputPath))
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	err = mt.ProcessFile(context.Background(), filepath.Join(inputDir, "skip", "inner", "b.jpg"))
+	if !errors.Is(err, ErrNotMatched) {
+		t.Errorf("Expected ErrNotMatched, got %v", err)
+	}
+	if err := mt.ProcessFile(context.Background(), filepath.Join(inputDir, "keep", "a.jpg")); err != nil {
+		t.Errorf("ProcessFile failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(processed, ",") != "a.jpg,a.jpg" {
+		t.Errorf("Expected only a.jpg to be processed, got %v", processed)
+	}
+}
@@ -0,0 +1,167 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DirRemovalCallback is called before the output directory outputDir is
+// removed with all its contents because the input directory inputDir it
+// mirrors no longer exists. Returning false keeps it.
+type DirRemovalCallback func(inputDir, outputDir string) bool
+
+// outputDirRoots returns the directories that mirror the input tree: the
+// directory of every variant, or OutputDir without variants.
+func (mt *mirrorTransform) outputDirRoots() []string {
+	if len(mt.config.Variants) == 0 {
+		return []string{filepath.Clean(mt.config.OutputDir)}
+	}
+	roots := make([]string, 0, len(mt.config.Variants))
+	for _, v := range mt.config.Variants {
+		roots = append(roots, mt.variantDir(v))
+	}
+	return roots
+}
+
+// outputDirRel maps the input directory relPath to its path relative to the
+// output roots, as mirrorDir does.
+func (mt *mirrorTransform) outputDirRel(relPath string) (string, error) {
+	mapped, err := mt.mapOutputNames(relPath)
+	if err != nil {
+		return "", err
+	}
+	return mt.normalizeUnicode(mapped), nil
+}
+
+// removeOutputDir removes outputDir, which mirrors the missing input
+// directory inputDir, unless DirRemovalCallback vetoes it.
+func (mt *mirrorTransform) removeOutputDir(inputDir, outputDir string) error {
+	if mt.config.DirRemovalCallback != nil && !mt.config.DirRemovalCallback(inputDir, outputDir) {
+		mt.log().Debug("kept output directory", "path", outputDir)
+		return nil
+	}
+	if err := os.RemoveAll(outputDir); err != nil {
+		return mt.handlePathError(outputDir, err, "remove output directory")
+	}
+	mt.log().Info("removed output directory", "path", outputDir, "input", inputDir)
+	return nil
+}
+
+// mirrorDirRemoval removes the output directories of the input at inputPath
+// after a watch event reported it removed or renamed, if MirrorDirRemovals
+// is set and the input is gone.
+func (mt *mirrorTransform) mirrorDirRemoval(inputPath string) error {
+	if !mt.config.MirrorDirRemovals {
+		return nil
+	}
+	relPath, err := mt.relPath(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path for %q: %w", inputPath, err)
+	}
+	if relPath == "." {
+		return nil
+	}
+
+	// A path that exists again was recreated or only renamed in place
+	if _, err := os.Lstat(inputPath); !os.IsNotExist(err) {
+		return nil
+	}
+
+	relOutput, err := mt.outputDirRel(relPath)
+	if err != nil {
+		return err
+	}
+	for _, root := range mt.outputDirRoots() {
+		outputDir := filepath.Join(root, relOutput)
+		info, err := os.Lstat(outputDir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if err := mt.removeOutputDir(inputPath, outputDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOrphanedDirs removes the output directories whose input directory
+// is missing, for Run to clean up removals made while it was not running.
+// The library's own directories, and those of input directories that cannot
+// be read, are kept.
+func (mt *mirrorTransform) removeOrphanedDirs() error {
+	if !mt.config.MirrorDirRemovals {
+		return nil
+	}
+
+	// Map every input directory into the output tree. The outputs of a
+	// directory that cannot be read would look orphaned, so its whole
+	// subtree is kept.
+	expected := make(map[string]bool)
+	unread := make(map[string]bool)
+	err := filepath.WalkDir(mt.config.InputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil && (d == nil || path == mt.config.InputDir) {
+			return fmt.Errorf("failed to scan input directory %q: %w", path, err)
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		relPath, relErr := mt.relPath(path)
+		if relErr != nil {
+			return fmt.Errorf("failed to get relative path for %q: %w", path, relErr)
+		}
+		relOutput, mapErr := mt.outputDirRel(relPath)
+		if mapErr != nil {
+			return mapErr
+		}
+		expected[relOutput] = true
+		if err != nil {
+			mt.log().Warn("keeping output directories of unreadable input directory", "path", path, "error", err)
+			unread[relOutput] = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, root := range mt.outputDirRoots() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return fmt.Errorf("failed to scan output directory %q: %w", root, err)
+			}
+			if !d.IsDir() || path == root {
+				return nil
+			}
+			if mt.isOwnOutputFile(path, d) {
+				return filepath.SkipDir
+			}
+
+			relOutput, err := filepath.Rel(root, path)
+			if err != nil {
+				return fmt.Errorf("failed to get relative path for %q: %w", path, err)
+			}
+			if unread[relOutput] {
+				return filepath.SkipDir
+			}
+			if expected[relOutput] {
+				return nil
+			}
+
+			// Remove the whole orphaned subtree at once
+			if err := mt.removeOutputDir(filepath.Join(mt.config.InputDir, relOutput), path); err != nil {
+				return err
+			}
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
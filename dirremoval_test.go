@@ -0,0 +1,173 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMirrorDirRemovals tests that Run removes the output directories of
+// input directories removed before and during the run, unless vetoed.
+func TestMirrorDirRemovals(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"keep/a.jpg", "live/b.jpg"})
+	createTestFiles(t, outputDir, []string{"keep/a.jpg", "gone/old/c.jpg", "vetoed/d.jpg", "top.jpg"})
+
+	var mu sync.Mutex
+	var asked []string
+	config := Config{
+		InputDir:          inputDir,
+		OutputDir:         outputDir,
+		Patterns:          []string{"**/*.jpg"},
+		MirrorDirRemovals: true,
+		AllowDestructive:  true,
+		DirRemovalCallback: func(inputPath, outputPath string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			asked = append(asked, inputPath)
+			return filepath.Base(outputPath) != "vetoed"
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- mt.Run(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	// Directories removed while not running are cleaned up on start
+	if _, err := os.Stat(filepath.Join(outputDir, "gone")); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned output directory to be removed")
+	}
+	for _, name := range []string{"vetoed/d.jpg", "keep/a.jpg", "top.jpg"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+
+	// Directories removed while running are removed from the output
+	if err := os.RemoveAll(filepath.Join(inputDir, "keep")); err != nil {
+		t.Fatalf("Failed to remove directory: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(outputDir, "keep")); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the output directory of the removed input directory to be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-runErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(asked) != 3 {
+		t.Errorf("Expected the callback to be asked for gone, vetoed and keep, got %v", asked)
+	}
+}
+
+// TestValidateMirrorDirRemovals tests that MirrorDirRemovals requires
+// AllowDestructive and a mirrored directory structure.
+func TestValidateMirrorDirRemovals(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:          "/input",
+		OutputDir:         "/output",
+		Patterns:          []string{"**/*"},
+		MirrorDirRemovals: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+	if err := config.Validate(); !errors.Is(err, ErrDestructiveNotAllowed) {
+		t.Errorf("Expected ErrDestructiveNotAllowed, got %v", err)
+	}
+
+	config.AllowDestructive = true
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid configuration, got %v", err)
+	}
+	config.Flatten = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error with Flatten")
+	}
+
+	// Outputs remapped into other directories would look orphaned
+	config.Flatten = false
+	config.OutputPathFunc = func(relPath string) string {
+		return filepath.Join("by-name", filepath.Base(relPath))
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error with OutputPathFunc")
+	}
+}
+
+// TestMirrorDirRemovalsUnreadable tests that Run starts and keeps the output
+// directory of an input directory it cannot read.
+func TestMirrorDirRemovalsUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced")
+	}
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"locked/sub/a.jpg"})
+	createTestFiles(t, outputDir, []string{"locked/sub/a.jpg", "gone/b.jpg"})
+	locked := filepath.Join(inputDir, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatalf("Failed to lock directory: %v", err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	config := Config{
+		InputDir:          inputDir,
+		OutputDir:         outputDir,
+		Patterns:          []string{"**/*.jpg"},
+		MirrorDirRemovals: true,
+		AllowDestructive:  true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.(*mirrorTransform).removeOrphanedDirs(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "locked", "sub", "a.jpg")); err != nil {
+		t.Errorf("Expected the outputs of the unreadable directory to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "gone")); !os.IsNotExist(err) {
+		t.Errorf("Expected the orphaned output directory to be removed")
+	}
+}
@@ -0,0 +1,13 @@
+// Package mirrortransform mirrors files matching glob patterns from an input
+// directory to an output directory, transforming each file with a callback,
+// either once with Crawl or continuously with Watch and Run.
+//
+// The root package is the engine and the simple API most users need.
+// Building blocks that don't depend on the engine live in subpackages and are
+// re-exported here, so existing imports keep working:
+//
+//   - transform: ready-made transformers such as metadata-preserving copies
+//   - store: storage adapters for per-path processing state
+//   - watcher: the file system watch backends of Watch and Run
+//   - cmd/mirror-transform: the command line tool
+package mirrortransform
@@ -0,0 +1,109 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+var (
+	// ErrStoppedByCallback is matched by the error Crawl, Watch and Run
+	// return when a callback stopped processing: FileCallback or
+	// TaskCallback returning false, or ErrorCallback returning true.
+	ErrStoppedByCallback = errors.New("processing stopped by callback")
+
+	// ErrCircularReference is matched by the error returned when an output
+	// directory is inside InputDir or InputDir is inside an output directory.
+	ErrCircularReference = errors.New("circular reference between input and output directories")
+
+	// ErrInvalidPattern is matched by the errors of Validate and
+	// NewMirrorTransform for glob or regular expression patterns that do not
+	// parse.
+	ErrInvalidPattern = errors.New("invalid pattern")
+
+	// ErrWatcherOverflow is matched by *WatchOverflowError, reporting that
+	// the watcher lost events.
+	ErrWatcherOverflow = errors.New("watcher lost events")
+)
+
+// markedError is an error that keeps the message of err but also matches
+// sentinel with errors.Is.
+type markedError struct {
+	err      error
+	sentinel error
+}
+
+// markError returns err marked to match sentinel.
+func markError(sentinel, err error) error {
+	return &markedError{err: err, sentinel: sentinel}
+}
+
+func (e *markedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *markedError) Unwrap() []error {
+	return []error{e.err, e.sentinel}
+}
+
+// handlePathError applies the error policy to an error that occurred at path.
+// op describes the failed operation for the default error message.
+// It returns nil when processing should continue.
+func (mt *mirrorTransform) handlePathError(path string, err error, op string) error {
+	// Known noisy paths are skipped without consulting the error callback
+	if mt.isIgnoredErrorPath(path) {
+		return nil
+	}
+
+	if mt.config.ErrorCallback != nil {
+		stop, retErr := mt.callErrorCallback(path, err)
+		if retErr != nil {
+			return fmt.Errorf("error callback failed at %q: %w", path, retErr)
+		}
+		if stop {
+			return markError(ErrStoppedByCallback, fmt.Errorf("stopped due to error at %q: %w", path, err))
+		}
+		// Continue processing
+		return nil
+	}
+	return FileError{Path: path, Op: op, Err: err}
+}
+
+// isIgnoredErrorPath reports whether errors at path match IgnoreErrorPatterns.
+func (mt *mirrorTransform) isIgnoredErrorPath(path string) bool {
+	if len(mt.config.IgnoreErrorPatterns) == 0 {
+		return false
+	}
+
+	relPath, err := mt.relPath(path)
+	if err != nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range mt.config.IgnoreErrorPatterns {
+		// Patterns are validated in NewMirrorTransform
+		if match, _ := mt.globMatch(pattern, relPath); match {
+			return true
+		}
+	}
+	return false
+}
+
+// reportContinuedFailure handles a callback failure that ContinueOnError
+// lets the run move past. Crawl collects it for the final error; Watch and
+// Run pass it to ErrorCallback, if set, which may still stop the run.
+func (mt *mirrorTransform) reportContinuedFailure(run *runState, task fileTask, err error) error {
+	if run.collectFailures || mt.config.ErrorCallback == nil {
+		return nil
+	}
+
+	stop, retErr := mt.callErrorCallback(task.InputPath, err)
+	if retErr != nil {
+		return fmt.Errorf("error callback failed at %q: %w", task.InputPath, retErr)
+	}
+	if stop {
+		return markError(ErrStoppedByCallback, fmt.Errorf("stopped due to error at %q: %w", task.InputPath, err))
+	}
+	return nil
+}
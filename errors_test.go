@@ -0,0 +1,140 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestIgnoreErrorPatterns tests that errors at ignored paths skip the error callback.
+func TestIgnoreErrorPatterns(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	var callbackPaths []string
+
+	config := Config{
+		InputDir:            inputDir,
+		OutputDir:           filepath.Join(testDir, "output"),
+		Patterns:            []string{"**/*.jpg"},
+		IgnoreErrorPatterns: []string{"lost+found/**"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+		ErrorCallback: func(path string, err error) (bool, error) {
+			callbackPaths = append(callbackPaths, path)
+			return true, nil
+		},
+	}
+
+	m, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := m.(*mirrorTransform)
+
+	errAccess := errors.New("permission denied")
+
+	// Ignored directory and its contents
+	for _, rel := range []string{"lost+found", filepath.Join("lost+found", "x.jpg")} {
+		if err := mt.handlePathError(filepath.Join(inputDir, rel), errAccess, "access"); err != nil {
+			t.Errorf("Expected error at %s to be ignored, got %v", rel, err)
+		}
+	}
+	if len(callbackPaths) != 0 {
+		t.Errorf("Error callback was called for ignored paths: %v", callbackPaths)
+	}
+
+	// Other paths still go through the error callback
+	err = mt.handlePathError(filepath.Join(inputDir, "photos"), errAccess, "access")
+	if !errors.Is(err, errAccess) {
+		t.Errorf("Expected wrapped error for non-ignored path, got %v", err)
+	}
+	if len(callbackPaths) != 1 {
+		t.Errorf("Expected error callback to be called once, got %d", len(callbackPaths))
+	}
+}
+
+// TestIgnoreErrorPatternsValidation tests ignore error pattern validation.
+func TestIgnoreErrorPatternsValidation(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:            "/tmp/in",
+		OutputDir:           "/tmp/out",
+		Patterns:            []string{"*.jpg"},
+		IgnoreErrorPatterns: []string{"["},
+		FileCallback: func(in, out string) (bool, error) {
+			return true, nil
+		},
+	}
+	if _, err := NewMirrorTransform(&config); err == nil {
+		t.Error("Expected error for invalid ignore error pattern")
+	}
+}
+
+// TestSentinelErrors tests that failure modes can be told apart with
+// errors.Is and errors.As while keeping their messages.
+func TestSentinelErrors(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+
+	// Invalid patterns
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"[bad"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return false, nil
+		},
+	}
+	if err := config.Validate(); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+
+	// Callbacks stopping the crawl
+	config.Patterns = []string{"**/*.jpg"}
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	err = mt.Crawl(context.Background())
+	if !errors.Is(err, ErrStoppedByCallback) || !strings.Contains(err.Error(), "a.jpg") {
+		t.Errorf("Expected ErrStoppedByCallback naming a.jpg, got %v", err)
+	}
+
+	// Circular references
+	config.OutputDir = filepath.Join(inputDir, "out")
+	mt, err = NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); !errors.Is(err, ErrCircularReference) {
+		t.Errorf("Expected ErrCircularReference, got %v", err)
+	}
+
+	// Watcher overflows
+	if err := error(&WatchOverflowError{Dir: inputDir}); !errors.Is(err, ErrWatcherOverflow) || !errors.Is(err, fsnotify.ErrEventOverflow) {
+		t.Errorf("Expected the overflow to match ErrWatcherOverflow and fsnotify.ErrEventOverflow")
+	}
+
+	// File errors
+	errAccess := errors.New("permission denied")
+	err = (&mirrorTransform{config: config}).handlePathError(inputDir, errAccess, "access")
+	var fileErr FileError
+	if !errors.As(err, &fileErr) || fileErr.Path != inputDir || fileErr.Op != "access" || !errors.Is(err, errAccess) {
+		t.Errorf("Expected a FileError for the access, got %v", err)
+	}
+	if want := fmt.Sprintf("failed to access %q: permission denied", inputDir); err.Error() != want {
+		t.Errorf("Expected message %q, got %q", want, err.Error())
+	}
+}
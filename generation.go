@@ -0,0 +1,192 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultGenerationFile is the conventional name for Config.GenerationFile.
+const DefaultGenerationFile = ".mirror-generation"
+
+// generationPollInterval is how often ReadConsistent checks the marker while
+// the output tree is being written.
+const generationPollInterval = 100 * time.Millisecond
+
+// Generation is the content of the generation marker file, which tells
+// readers of the output tree whether it is being changed.
+type Generation struct {
+	// Number increases every time the engine starts changing the output tree.
+	Number uint64 `json:"generation"`
+
+	// Writing is true while outputs of this generation are being written.
+	Writing bool `json:"writing"`
+
+	// Time is when the marker was last written.
+	Time time.Time `json:"time"`
+
+	// Labels are the Config.RunLabels of the writing instance.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// ReadGeneration reads the generation marker at path. A missing marker is
+// reported as the zero Generation, an output tree that was never written.
+func ReadGeneration(path string) (Generation, error) {
+	var generation Generation
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return generation, nil
+		}
+		return generation, fmt.Errorf("failed to read generation marker %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &generation); err != nil {
+		return generation, fmt.Errorf("failed to parse generation marker %q: %w", path, err)
+	}
+	return generation, nil
+}
+
+// ReadConsistent calls read while the output tree marked by the generation
+// marker at path is not being written, and calls it again if the tree
+// changed before read returned, so read sees a consistent view of the tree.
+// It waits while the tree is being written, until ctx is done.
+func ReadConsistent(ctx context.Context, path string, read func() error) error {
+	for {
+		before, err := ReadGeneration(path)
+		if err != nil {
+			return err
+		}
+
+		if !before.Writing {
+			readErr := read()
+			after, err := ReadGeneration(path)
+			if err != nil {
+				return err
+			}
+			if after.Number == before.Number && !after.Writing {
+				return readErr
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(generationPollInterval):
+		}
+	}
+}
+
+// generationMarker maintains Config.GenerationFile. The marker reads as
+// writing while any holder changes the output tree; a new generation starts
+// whenever the first holder arrives.
+type generationMarker struct {
+	path   string
+	labels []string
+
+	mu      sync.Mutex
+	holders int
+	current Generation
+}
+
+// newGenerationMarker returns the marker for the configured file, or nil if
+// disabled.
+func newGenerationMarker(config *Config) *generationMarker {
+	if config.GenerationFile == "" {
+		return nil
+	}
+	return &generationMarker{
+		path:   filepath.Join(filepath.Clean(config.OutputDir), config.GenerationFile),
+		labels: config.RunLabels,
+	}
+}
+
+// acquire marks the output tree as being written until the matching release.
+// It is a no-op on a nil marker.
+func (g *generationMarker) acquire() error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.holders++
+	if g.holders > 1 {
+		return nil
+	}
+
+	// Continue from the marker on disk, which other runs may have advanced
+	current, err := ReadGeneration(g.path)
+	if err != nil {
+		g.holders--
+		return err
+	}
+	current.Number++
+	current.Writing = true
+	if err := g.write(current); err != nil {
+		g.holders--
+		return err
+	}
+	g.current = current
+	return nil
+}
+
+// release ends a hold taken by acquire, marking the tree consistent again
+// once no holder is left. It is a no-op on a nil marker.
+func (g *generationMarker) release() error {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.holders--
+	if g.holders > 0 {
+		return nil
+	}
+
+	current := g.current
+	current.Writing = false
+	if err := g.write(current); err != nil {
+		return err
+	}
+	g.current = current
+	return nil
+}
+
+// write replaces the marker atomically so readers never see a partial file.
+func (g *generationMarker) write(generation Generation) error {
+	generation.Time = time.Now()
+	generation.Labels = g.labels
+
+	data, err := json.Marshal(generation)
+	if err != nil {
+		return fmt.Errorf("failed to encode generation marker: %w", err)
+	}
+
+	dir := filepath.Dir(g.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory %q: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to write generation marker %q: %w", g.path, err)
+	}
+	_, writeErr := tmp.Write(data)
+	if err := errors.Join(writeErr, tmp.Close()); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write generation marker %q: %w", g.path, err)
+	}
+	if err := os.Rename(tmp.Name(), g.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write generation marker %q: %w", g.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestGenerationFile tests that a crawl marks the output tree as being
+// written while it runs and starts a new generation each time.
+func TestGenerationFile(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	markerPath := filepath.Join(outputDir, DefaultGenerationFile)
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/b.jpg"})
+
+	var seen []Generation
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      outputDir,
+		Patterns:       []string{"**/*.jpg"},
+		MaxConcurrency: 1,
+		GenerationFile: DefaultGenerationFile,
+		RunLabels:      []string{"nightly"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			generation, err := ReadGeneration(markerPath)
+			if err != nil {
+				return false, err
+			}
+			seen = append(seen, generation)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	for run := uint64(1); run <= 2; run++ {
+		seen = nil
+		if err := mt.Crawl(context.Background()); err != nil {
+			t.Fatalf("Crawl failed: %v", err)
+		}
+
+		for _, generation := range seen {
+			if generation.Number != run || !generation.Writing {
+				t.Errorf("Expected generation %d being written during the crawl, got %+v", run, generation)
+			}
+		}
+
+		generation, err := ReadGeneration(markerPath)
+		if err != nil {
+			t.Fatalf("ReadGeneration failed: %v", err)
+		}
+		if generation.Number != run || generation.Writing {
+			t.Errorf("Expected generation %d complete after the crawl, got %+v", run, generation)
+		}
+		if len(generation.Labels) != 1 || generation.Labels[0] != "nightly" {
+			t.Errorf("Expected run labels in the marker, got %v", generation.Labels)
+		}
+	}
+}
+
+// TestReadConsistent tests that readers wait while the tree is written and
+// read again if it changed underneath them.
+func TestReadConsistent(t *testing.T) {
+	t.Parallel()
+	outputDir := t.TempDir()
+
+	marker := &generationMarker{path: filepath.Join(outputDir, DefaultGenerationFile)}
+	if err := marker.acquire(); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+
+	// Release the first generation shortly, then write another during the first read
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		marker.release()
+	}()
+
+	reads := 0
+	err := ReadConsistent(context.Background(), marker.path, func() error {
+		reads++
+		if reads == 1 {
+			if err := marker.acquire(); err != nil {
+				return err
+			}
+			return marker.release()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadConsistent failed: %v", err)
+	}
+	if reads != 2 {
+		t.Errorf("Expected 2 reads, got %d", reads)
+	}
+
+	// A marker left writing blocks readers until the context is done
+	if err := marker.acquire(); err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := ReadConsistent(ctx, marker.path, func() error { return nil }); err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// Missing markers read as a tree that was never written
+	generation, err := ReadGeneration(filepath.Join(outputDir, "missing"))
+	if err != nil || generation.Number != 0 {
+		t.Errorf("Expected the zero generation, got %+v, %v", generation, err)
+	}
+}
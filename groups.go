@@ -0,0 +1,151 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ConcurrencyGroup gives the files matching its patterns a worker pool of
+// their own, so heavy transforms (e.g. videos) cannot starve cheap ones
+// (e.g. thumbnails) of workers.
+type ConcurrencyGroup struct {
+	// Name identifies the group in logs.
+	Name string `json:"name" yaml:"name"`
+
+	// Patterns are glob patterns matched against the path relative to
+	// InputDir (e.g. "**/*.mp4").
+	Patterns []string `json:"patterns" yaml:"patterns"`
+
+	// Concurrency is the number of workers processing the files of the
+	// group. It must be positive.
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+}
+
+// validateConcurrencyGroups checks that every group has a distinct name,
+// valid patterns and a positive concurrency.
+func validateConcurrencyGroups(groups []ConcurrencyGroup) error {
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		if g.Name == "" {
+			return fmt.Errorf("concurrency group must have a name")
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("duplicate concurrency group %q", g.Name)
+		}
+		seen[g.Name] = true
+
+		if g.Concurrency <= 0 {
+			return fmt.Errorf("concurrency of group %q must be positive, got %d", g.Name, g.Concurrency)
+		}
+		if len(g.Patterns) == 0 {
+			return fmt.Errorf("concurrency group %q must have patterns", g.Name)
+		}
+		for _, pattern := range g.Patterns {
+			if !doublestar.ValidatePattern(pattern) {
+				return markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q in concurrency group %q", pattern, g.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// concurrencyGroup returns the index of the first ConcurrencyGroup matching
+// relPath, or -1 if the file belongs to the shared workers.
+func (mt *mirrorTransform) concurrencyGroup(relPath string) (int, error) {
+	for i, g := range mt.config.ConcurrencyGroups {
+		for _, pattern := range g.Patterns {
+			match, err := mt.globMatch(pattern, relPath)
+			if err != nil {
+				return -1, markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q in concurrency group %q: %w", pattern, g.Name, err))
+			}
+			if match {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// startGroupWorkers starts the workers of every ConcurrencyGroup and a
+// classifier routing the tasks from taskChan to them. It returns the channel
+// of the tasks left for the shared workers.
+// Every group, and the shared workers, queue their tasks without limit in
+// dispatch order, so a busy group never blocks the others.
+func (mt *mirrorTransform) startGroupWorkers(ctx context.Context, run *runState, taskChan <-chan fileTask, errChan chan<- error, wg *sync.WaitGroup) <-chan fileTask {
+	queue := func() (chan<- fileTask, <-chan fileTask) {
+		in := make(chan fileTask)
+		out := make(chan fileTask)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTaskQueue(ctx, in, out, mt.taskSorter(), mt.priorityOf, mt.urgentAfter(), false, maxSortedTasks)
+		}()
+		return in, out
+	}
+
+	groupChans := make([]chan<- fileTask, len(mt.config.ConcurrencyGroups))
+	for i, g := range mt.config.ConcurrencyGroups {
+		in, out := queue()
+		groupChans[i] = in
+
+		// Group pools keep their size when UpdateConfig changes Concurrency
+		pool := &workerPool{wg: wg, owner: mt, changed: make(chan struct{})}
+		pool.start = func() {
+			wg.Add(1)
+			go mt.fileProcessor(ctx, run, out, errChan, pool)
+		}
+		pool.mu.Lock()
+		for n := g.Concurrency; n > 0; n-- {
+			pool.running++
+			pool.start()
+		}
+		pool.mu.Unlock()
+		mt.log().Debug("started concurrency group", "group", g.Name, "workers", g.Concurrency)
+	}
+
+	shared, sharedOut := queue()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(shared)
+		defer func() {
+			for _, ch := range groupChans {
+				close(ch)
+			}
+		}()
+
+		for {
+			var task fileTask
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case task, ok = <-taskChan:
+				if !ok {
+					return
+				}
+			}
+
+			// Route the task to its group, or to the shared workers
+			out := shared
+			group, err := mt.concurrencyGroup(task.RelPath)
+			if err != nil {
+				sendError(ctx, errChan, err)
+				return
+			}
+			if group >= 0 {
+				out = groupChans[group]
+			}
+
+			select {
+			case out <- task:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return sharedOut
+}
@@ -0,0 +1,133 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestConcurrencyGroups tests that files of a busy group do not hold up the
+// files processed by the shared workers.
+func TestConcurrencyGroups(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.mp4", "b.mp4", "c.jpg", "d.jpg", "e.jpg"})
+
+	imagesDone := make(chan struct{})
+	var images, videos, maxVideos atomic.Int32
+	var closeOnce sync.Once
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*"},
+		Concurrency: 1,
+		ConcurrencyGroups: []ConcurrencyGroup{
+			{Name: "video", Patterns: []string{"**/*.mp4"}, Concurrency: 1},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Ext(inputPath) == ".jpg" {
+				if images.Add(1) == 3 {
+					closeOnce.Do(func() { close(imagesDone) })
+				}
+				return true, nil
+			}
+
+			// Videos wait for every image, which needs a worker of its own
+			n := videos.Add(1)
+			defer videos.Add(-1)
+			for {
+				m := maxVideos.Load()
+				if n <= m || maxVideos.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			select {
+			case <-imagesDone:
+				return true, nil
+			case <-time.After(5 * time.Second):
+				return false, errors.New("images were starved")
+			}
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background())
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if result.Processed != 5 {
+		t.Errorf("Expected 5 processed files, got %d", result.Processed)
+	}
+	if n := maxVideos.Load(); n != 1 {
+		t.Errorf("Expected at most 1 video at a time, got %d", n)
+	}
+}
+
+// TestConcurrencyGroupsOrdered tests that concurrency groups are rejected in
+// Ordered mode.
+func TestConcurrencyGroupsOrdered(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:          t.TempDir(),
+		OutputDir:         t.TempDir(),
+		Patterns:          []string{"**/*"},
+		Ordered:           true,
+		ConcurrencyGroups: []ConcurrencyGroup{{Name: "video", Patterns: []string{"**/*.mp4"}, Concurrency: 1}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, nil, 0644)
+		},
+	}
+
+	if _, err := NewMirrorTransform(&config); err == nil || !strings.Contains(err.Error(), "concurrency groups") {
+		t.Errorf("Expected ordered mode to be rejected, got %v", err)
+	}
+}
+
+// TestValidateConcurrencyGroups tests the validation of concurrency groups.
+func TestValidateConcurrencyGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		groups  []ConcurrencyGroup
+		wantErr string
+	}{
+		{name: "valid", groups: []ConcurrencyGroup{{Name: "video", Patterns: []string{"**/*.mp4"}, Concurrency: 1}}},
+		{name: "no name", groups: []ConcurrencyGroup{{Patterns: []string{"**/*.mp4"}, Concurrency: 1}}, wantErr: "name"},
+		{name: "duplicate", groups: []ConcurrencyGroup{{Name: "a", Patterns: []string{"*"}, Concurrency: 1}, {Name: "a", Patterns: []string{"*"}, Concurrency: 1}}, wantErr: "duplicate"},
+		{name: "zero concurrency", groups: []ConcurrencyGroup{{Name: "a", Patterns: []string{"*"}}}, wantErr: "positive"},
+		{name: "no patterns", groups: []ConcurrencyGroup{{Name: "a", Concurrency: 1}}, wantErr: "patterns"},
+		{name: "bad pattern", groups: []ConcurrencyGroup{{Name: "a", Patterns: []string{"[a"}, Concurrency: 1}}, wantErr: "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateConcurrencyGroups(tt.groups)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
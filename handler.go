@@ -0,0 +1,193 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HandlerOptions configures the handler returned by Handler.
+type HandlerOptions struct {
+	// InputPaths returns the candidate inputs, relative to InputDir, for a
+	// requested output relative to OutputDir, in order of preference. The
+	// first candidate that exists and matches is transformed. For example, a
+	// callback converting JPEG and PNG to WebP maps "a.webp" to "a.jpg" and
+	// "a.png". By default the input has the same relative path as the output.
+	InputPaths func(outputRelPath string) []string
+}
+
+// onDemand limits and deduplicates the transforms started by Handler.
+type onDemand struct {
+	// once sizes slots on first use.
+	once sync.Once
+
+	// slots holds a token per transform in progress.
+	slots chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]*onDemandCall
+}
+
+// onDemandCall is a transform in progress that requests for the same output
+// wait for.
+type onDemandCall struct {
+	done chan struct{}
+	err  error
+}
+
+// newOnDemand returns the state shared by the handlers of an instance.
+func newOnDemand() *onDemand {
+	return &onDemand{inflight: make(map[string]*onDemandCall)}
+}
+
+// Handler returns an http.Handler serving the files of OutputDir. On a miss
+// it locates the input of the requested output, runs the callback on it
+// synchronously with the same filters, patterns and options as a crawl, and
+// serves the result, which makes the instance a pull-based mirror, e.g. the
+// origin of an image CDN. Concurrent requests for the same output share one
+// transform, and no more transforms run at a time than workers in a crawl.
+// Only GET and HEAD are served; hidden paths are not served unless
+// IncludeHidden is set.
+func (mt *mirrorTransform) Handler(opts HandlerOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Requests name outputs by their slash-separated path below OutputDir
+		relPath := filepath.FromSlash(strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/"))
+		if relPath == "" || !filepath.IsLocal(relPath) || strings.HasSuffix(relPath, PartialMarkerSuffix) {
+			http.NotFound(w, r)
+			return
+		}
+		outputPath := filepath.Join(mt.config.OutputDir, relPath)
+		if !mt.config.IncludeHidden && isHidden(outputPath, relPath) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if mt.serveOutput(w, r, outputPath) {
+			return
+		}
+
+		// Transform the input on a miss, then serve what it produced
+		if err := mt.transformOnDemand(r.Context(), relPath, opts); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			mt.log().Warn("on-demand transform failed", "path", relPath, "error", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if !mt.serveOutput(w, r, outputPath) {
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// serveOutput serves the regular file at outputPath and reports whether it
+// exists.
+func (mt *mirrorTransform) serveOutput(w http.ResponseWriter, r *http.Request, outputPath string) bool {
+	f, err := os.Open(outputPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	return true
+}
+
+// transformOnDemand transforms the input of the output outputRelPath, or
+// waits for a transform of it already in progress. It returns an error
+// matching fs.ErrNotExist if no candidate input exists and matches.
+func (mt *mirrorTransform) transformOnDemand(ctx context.Context, outputRelPath string, opts HandlerOptions) error {
+	d := mt.onDemand
+	d.mu.Lock()
+	if call, ok := d.inflight[outputRelPath]; ok {
+		d.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &onDemandCall{done: make(chan struct{})}
+	d.inflight[outputRelPath] = call
+	d.mu.Unlock()
+
+	// The transform outlives the request that started it, as others may wait
+	call.err = mt.transformInput(context.WithoutCancel(ctx), outputRelPath, opts)
+	d.mu.Lock()
+	delete(d.inflight, outputRelPath)
+	d.mu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// transformInput processes the first candidate input of outputRelPath that
+// exists and matches. ctx is not cancelled with the request.
+func (mt *mirrorTransform) transformInput(ctx context.Context, outputRelPath string, opts HandlerOptions) error {
+	candidates := []string{outputRelPath}
+	if opts.InputPaths != nil {
+		candidates = opts.InputPaths(outputRelPath)
+	}
+
+	for _, candidate := range candidates {
+		relPath := filepath.Clean(candidate)
+		if !filepath.IsLocal(relPath) {
+			continue
+		}
+		inputPath := filepath.Join(mt.config.InputDir, relPath)
+
+		info, err := os.Lstat(inputPath)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		// Inputs are filtered as in a crawl, including their directories
+		skipped, err := mt.inSkippedDir(relPath)
+		if err != nil {
+			return err
+		}
+		if skipped {
+			continue
+		}
+		pattern, err := mt.entryPattern(inputPath, relPath, newFileEntry(inputPath, nil))
+		if err != nil {
+			return err
+		}
+		if pattern == "" {
+			continue
+		}
+
+		outputPath, err := mt.outputPath(relPath)
+		if err != nil {
+			return err
+		}
+
+		// Share the crawl's limit on concurrent callbacks
+		d := mt.onDemand
+		d.once.Do(func() { d.slots = make(chan struct{}, mt.concurrency()) })
+		d.slots <- struct{}{}
+		mt.log().Debug("transforming on demand", "path", relPath, "output", outputRelPath)
+		err = mt.processNow(ctx, "Handler", newFileTask(inputPath, outputPath, relPath, info, TaskEventRequest, pattern))
+		<-d.slots
+		return err
+	}
+	return fs.ErrNotExist
+}
@@ -0,0 +1,157 @@
+package mirrortransform
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHandlerTestServer returns a server for a handler whose callback writes
+// the upper-cased input to the output with the extension ".webp", failing
+// for inputs named "bad.jpg".
+func newHandlerTestServer(t *testing.T, inputDir, outputDir string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"private"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			calls.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			if filepath.Base(inputPath) == "bad.jpg" {
+				return false, errors.New("corrupt")
+			}
+			data, err := os.ReadFile(inputPath)
+			if err != nil {
+				return false, err
+			}
+			outputPath = strings.TrimSuffix(outputPath, ".jpg") + ".webp"
+			return false, os.WriteFile(outputPath, []byte(strings.ToUpper(string(data))), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	server := httptest.NewServer(mt.Handler(HandlerOptions{
+		InputPaths: func(outputRelPath string) []string {
+			return []string{strings.TrimSuffix(outputRelPath, ".webp") + ".jpg"}
+		},
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fetch requests path from server and returns the status and body.
+// It is safe to call from other goroutines.
+func fetch(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Errorf("Request for %s failed: %v", path, err)
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("Failed to read response for %s: %v", path, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// TestHandler tests that missing outputs are transformed on request, served,
+// and served from OutputDir afterwards.
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	createTestFiles(t, inputDir, []string{"photos/a.jpg", "photos/bad.jpg", "private/b.jpg", "notes.txt", ".cache/c.jpg"})
+
+	var calls atomic.Int32
+	server := newHandlerTestServer(t, inputDir, outputDir, &calls)
+
+	status, body := fetch(t, server, "/photos/a.webp")
+	if status != http.StatusOK || body != "TEST CONTENT" {
+		t.Errorf("Expected the transformed output, got %d %q", status, body)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "photos", "a.webp")); err != nil {
+		t.Errorf("Expected the output to be written: %v", err)
+	}
+
+	// Outputs that exist are served without transforming again
+	status, body = fetch(t, server, "/photos/a.webp")
+	if status != http.StatusOK || body != "TEST CONTENT" {
+		t.Errorf("Expected the existing output, got %d %q", status, body)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 callback call, got %d", n)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"missing input", "/photos/missing.webp", http.StatusNotFound},
+		{"unmatched input", "/notes.webp", http.StatusNotFound},
+		{"excluded input", "/private/b.webp", http.StatusNotFound},
+		{"hidden input", "/.cache/c.webp", http.StatusNotFound},
+		{"outside output", "/../input/photos/a.jpg", http.StatusNotFound},
+		{"failing callback", "/photos/bad.webp", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if status, _ := fetch(t, server, tt.path); status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, status)
+		}
+	}
+
+	resp, err := http.Post(server.URL+"/photos/a.webp", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", resp.StatusCode)
+	}
+}
+
+// TestHandlerConcurrentRequests tests that concurrent requests for the same
+// missing output share one transform.
+func TestHandlerConcurrentRequests(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+
+	var calls atomic.Int32
+	server := newHandlerTestServer(t, inputDir, outputDir, &calls)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, body := fetch(t, server, "/a.webp"); status != http.StatusOK || body != "TEST CONTENT" {
+				t.Errorf("Expected the transformed output, got %d %q", status, body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 callback call, got %d", n)
+	}
+}
@@ -0,0 +1,21 @@
+package mirrortransform
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isHidden reports whether relPath is hidden, either because one of its
+// components starts with a dot or because the platform marks path as hidden.
+// The input root itself (relPath ".") is never hidden.
+func isHidden(path, relPath string) bool {
+	if relPath == "." {
+		return false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return hasHiddenAttribute(path)
+}
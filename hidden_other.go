@@ -0,0 +1,9 @@
+//go:build !windows
+
+package mirrortransform
+
+// hasHiddenAttribute reports whether the platform marks path as hidden.
+// Only dot-prefixed names are hidden outside Windows.
+func hasHiddenAttribute(_ string) bool {
+	return false
+}
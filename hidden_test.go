@@ -0,0 +1,156 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCrawlHiddenFiles tests the hidden file policy in Crawl.
+func TestCrawlHiddenFiles(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{
+		"file1.jpg",
+		".file2.jpg",
+		".hidden/file3.jpg",
+		"dir/.cache/file4.jpg",
+		"dir/file5.jpg",
+	})
+
+	tests := []struct {
+		name          string
+		includeHidden bool
+		expected      int32
+	}{
+		{name: "Default", includeHidden: false, expected: 2},
+		{name: "IncludeHidden", includeHidden: true, expected: 5},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var processedCount int32
+
+			config := Config{
+				InputDir:      inputDir,
+				OutputDir:     filepath.Join(testDir, "output", tt.name),
+				Patterns:      []string{"**/*.jpg"},
+				IncludeHidden: tt.includeHidden,
+				Concurrency:   1,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					atomic.AddInt32(&processedCount, 1)
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			if processedCount != tt.expected {
+				t.Errorf("Expected %d files to be processed, got %d", tt.expected, processedCount)
+			}
+		})
+	}
+}
+
+// TestWatchHiddenFiles tests that Watch skips hidden files and directories by default.
+func TestWatchHiddenFiles(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	// Create input directory with an existing hidden directory
+	if err := os.MkdirAll(filepath.Join(inputDir, ".existing"), 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	processedFiles := make(map[string]bool)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 1,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			processedFiles[filepath.Base(inputPath)] = true
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go mt.Watch(ctx)
+
+	// Give watcher time to start
+	time.Sleep(200 * time.Millisecond)
+
+	// Create a hidden directory after the watcher started
+	if err := os.MkdirAll(filepath.Join(inputDir, ".new"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	for _, file := range []string{".file1.jpg", ".existing/file2.jpg", ".new/file3.jpg", "file4.jpg"} {
+		if err := os.WriteFile(filepath.Join(inputDir, file), []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", file, err)
+		}
+	}
+
+	// Wait for processing
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Only file4.jpg should be processed
+	if len(processedFiles) != 1 || !processedFiles["file4.jpg"] {
+		t.Errorf("Expected only file4.jpg to be processed, got %v", processedFiles)
+	}
+}
+
+// TestIsHidden tests hidden path detection.
+func TestIsHidden(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		relPath string
+		want    bool
+	}{
+		{relPath: ".", want: false},
+		{relPath: "file.jpg", want: false},
+		{relPath: ".file.jpg", want: true},
+		{relPath: filepath.Join(".git", "config"), want: true},
+		{relPath: filepath.Join("a", ".b", "c.jpg"), want: true},
+		{relPath: filepath.Join("a", "b.c", "d.jpg"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isHidden(filepath.Join("/nonexistent", tt.relPath), tt.relPath); got != tt.want {
+			t.Errorf("isHidden(%q) = %v, want %v", tt.relPath, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package mirrortransform
+
+import "syscall"
+
+// hasHiddenAttribute reports whether path has the Windows hidden attribute.
+func hasHiddenAttribute(path string) bool {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	attrs, err := syscall.GetFileAttributes(p)
+	if err != nil {
+		return false
+	}
+	return attrs&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}
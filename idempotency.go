@@ -0,0 +1,55 @@
+package mirrortransform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// IdempotencyKey returns a stable key for transforming the input at relPath
+// whose content has the given hash with the given transform version.
+// The key changes when any of the three changes and is the same across
+// retries, restarts and machines, so downstream side effects such as uploads
+// or API calls can be deduplicated on it.
+func IdempotencyKey(relPath, contentHash, version string) string {
+	h := sha256.New()
+	for _, part := range []string{filepath.ToSlash(relPath), contentHash, version} {
+		// Length prefixes keep distinct parts from running into each other
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentHash returns the hex-encoded SHA-256 of the file at path.
+func contentHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setIdempotencyKey fills in the content hash and idempotency key of task
+// when IdempotencyKeys is enabled.
+func (mt *mirrorTransform) setIdempotencyKey(task *fileTask) error {
+	if !mt.config.IdempotencyKeys {
+		return nil
+	}
+
+	hash, err := contentHash(task.InputPath)
+	if err != nil {
+		return err
+	}
+	task.ContentHash = hash
+	task.IdempotencyKey = IdempotencyKey(task.RelPath, hash, mt.config.TransformVersion)
+	return nil
+}
@@ -0,0 +1,95 @@
+package mirrortransform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestIdempotencyKey tests that keys depend on every part and nothing else.
+func TestIdempotencyKey(t *testing.T) {
+	t.Parallel()
+	base := IdempotencyKey("a/b.jpg", "hash", "v1")
+
+	if IdempotencyKey("a/b.jpg", "hash", "v1") != base {
+		t.Error("Expected the same key for the same inputs")
+	}
+	if IdempotencyKey(filepath.Join("a", "b.jpg"), "hash", "v1") != base {
+		t.Error("Expected the key to be independent of the path separator")
+	}
+	for _, other := range []string{
+		IdempotencyKey("a/c.jpg", "hash", "v1"),
+		IdempotencyKey("a/b.jpg", "other", "v1"),
+		IdempotencyKey("a/b.jpg", "hash", "v2"),
+		IdempotencyKey("a/b.jpg", "hashv", "1"),
+	} {
+		if other == base {
+			t.Errorf("Expected a different key than %s", base)
+		}
+	}
+}
+
+// TestIdempotencyKeysInTask tests that TaskCallback receives the content hash
+// and idempotency key, and that they follow content changes.
+func TestIdempotencyKeysInTask(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"photo.jpg"})
+
+	var mu sync.Mutex
+	var keys, hashes []string
+
+	config := Config{
+		InputDir:         inputDir,
+		OutputDir:        outputDir,
+		Patterns:         []string{"**/*.jpg"},
+		IdempotencyKeys:  true,
+		TransformVersion: "v1",
+		TaskCallback: func(task Task) (bool, error) {
+			mu.Lock()
+			keys = append(keys, task.IdempotencyKey)
+			hashes = append(hashes, task.ContentHash)
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := mt.Crawl(context.Background()); err != nil {
+			t.Fatalf("Crawl failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "photo.jpg"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("test content"))
+	expectedHash := hex.EncodeToString(sum[:])
+	if hashes[0] != expectedHash {
+		t.Errorf("Expected content hash %s, got %s", expectedHash, hashes[0])
+	}
+	if expected := IdempotencyKey("photo.jpg", expectedHash, "v1"); keys[0] != expected {
+		t.Errorf("Expected key %s, got %s", expected, keys[0])
+	}
+	if keys[1] != keys[0] {
+		t.Errorf("Expected the same key for unchanged content, got %s and %s", keys[0], keys[1])
+	}
+	if keys[2] == keys[0] {
+		t.Error("Expected a new key after the content changed")
+	}
+}
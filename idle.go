@@ -0,0 +1,58 @@
+package mirrortransform
+
+import (
+	"context"
+	"time"
+)
+
+// IdleCallback is called when Watch or Run has been idle for
+// Config.IdlePeriod: no watch event arrived, and no file was queued or
+// processed. It is called once per idle spell, from a goroutine of its own.
+type IdleCallback func()
+
+// touch records activity of the run for IdleCallback.
+func (r *runState) touch() {
+	r.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleWait returns how long Watch and Run must see no activity before
+// IdleCallback is called: IdlePeriod, but at least twice StabilityWindow
+// so files held until they settle are released first.
+func (mt *mirrorTransform) idleWait() time.Duration {
+	return max(mt.config.IdlePeriod, 2*mt.config.StabilityWindow)
+}
+
+// watchIdle calls IdleCallback whenever run has had nothing queued or in
+// progress and no activity for idleWait, until ctx is done. It does nothing
+// without IdleCallback.
+func (mt *mirrorTransform) watchIdle(ctx context.Context, run *runState) {
+	if mt.config.IdleCallback == nil {
+		return
+	}
+
+	wait := mt.idleWait()
+	ticker := time.NewTicker(max(wait/4, minStabilityPoll))
+	defer ticker.Stop()
+
+	run.touch()
+	var notified int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if run.queued.Load() > 0 || run.busy.Load() > 0 {
+				continue
+			}
+
+			// Notify once until the next activity
+			last := run.lastActivity.Load()
+			if last == notified || now.Sub(time.Unix(0, last)) < wait {
+				continue
+			}
+			notified = last
+			mt.log().Debug("idle", "since", time.Unix(0, last))
+			mt.config.IdleCallback()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestWatchIdleCallback tests that IdleCallback is called once per idle
+// spell, and not while a file is being processed.
+func TestWatchIdleCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	idle := make(chan time.Time, 10)
+	processing := make(chan struct{})
+	var once sync.Once
+	config := Config{
+		InputDir:   inputDir,
+		OutputDir:  filepath.Join(testDir, "output"),
+		Patterns:   []string{"**/*.jpg"},
+		IdlePeriod: 100 * time.Millisecond,
+		IdleCallback: func() {
+			idle <- time.Now()
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			// Outlast the idle period
+			once.Do(func() { close(processing) })
+			time.Sleep(300 * time.Millisecond)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	// A watch without events falls idle once
+	select {
+	case <-idle:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the idle callback after the idle period")
+	}
+	select {
+	case <-idle:
+		t.Error("Expected one notification per idle spell")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Processing a file is activity, even while it outlasts the period
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+	<-processing
+	finished := time.Now().Add(250 * time.Millisecond)
+	select {
+	case at := <-idle:
+		if at.Before(finished) {
+			t.Errorf("Expected no idle callback while processing, got one %v early", finished.Sub(at))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the idle callback after processing")
+	}
+
+	cancel()
+	<-watchErr
+}
+
+// TestValidateIdle tests the validation of the idle settings.
+func TestValidateIdle(t *testing.T) {
+	t.Parallel()
+	base := Config{
+		InputDir:  "/input",
+		OutputDir: "/output",
+		Patterns:  []string{"**/*"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	config := base
+	config.IdleCallback = func() {}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for IdleCallback without IdlePeriod")
+	}
+	config.IdlePeriod = time.Second
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid configuration, got %v", err)
+	}
+	config.IdlePeriod = -time.Second
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error for a negative IdlePeriod")
+	}
+}
@@ -0,0 +1,206 @@
+package mirrortransform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultIgnoreFile is the conventional name for ignore files.
+const DefaultIgnoreFile = ".mirrorignore"
+
+// ignoreRule is a single rule parsed from an ignore file.
+type ignoreRule struct {
+	// pattern is a doublestar pattern relative to the ignore file's directory
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// match reports whether the rule matches relPath, which is relative to the
+// directory containing the ignore file.
+func (r ignoreRule) match(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	match, _ := doublestar.Match(r.pattern, relPath)
+	return match
+}
+
+// parseIgnoreRules parses gitignore-style rules.
+// Blank lines and lines starting with "#" are skipped, "!" negates a rule,
+// a trailing "/" matches only directories, and a pattern containing "/" is
+// anchored to the ignore file's directory; otherwise it matches at any depth.
+func parseIgnoreRules(r io.Reader) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns without a slash match at any depth
+		if strings.Contains(line, "/") {
+			rule.pattern = strings.TrimPrefix(line, "/")
+		} else {
+			rule.pattern = "**/" + line
+		}
+
+		// Invalid patterns are skipped, as git does
+		if !doublestar.ValidatePattern(rule.pattern) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// ignoreMatcher evaluates ignore files found under the input directory.
+// Rules are loaded lazily per directory and cached until reset.
+type ignoreMatcher struct {
+	inputDir string
+	fileName string
+	nested   bool
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // keyed by slash-separated directory relative to inputDir
+}
+
+// newIgnoreMatcher returns a matcher for the configured ignore file, or nil if disabled.
+func newIgnoreMatcher(config *Config) *ignoreMatcher {
+	if config.IgnoreFile == "" {
+		return nil
+	}
+	return &ignoreMatcher{
+		inputDir: config.InputDir,
+		fileName: config.IgnoreFile,
+		nested:   config.NestedIgnoreFiles,
+		rules:    make(map[string][]ignoreRule),
+	}
+}
+
+// reset drops all cached rules so ignore files are re-read.
+func (m *ignoreMatcher) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = make(map[string][]ignoreRule)
+}
+
+// invalidate drops the cached rules of the ignore file in dirRel.
+func (m *ignoreMatcher) invalidate(dirRel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.rules, filepath.ToSlash(dirRel))
+}
+
+// rulesFor returns the rules of the ignore file in dirRel, loading them if needed.
+func (m *ignoreMatcher) rulesFor(dirRel string) ([]ignoreRule, error) {
+	m.mu.Lock()
+	rules, ok := m.rules[dirRel]
+	m.mu.Unlock()
+	if ok {
+		return rules, nil
+	}
+
+	file, err := os.Open(filepath.Join(m.inputDir, filepath.FromSlash(dirRel), m.fileName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to open ignore file in %q: %w", dirRel, err)
+		}
+	} else {
+		rules, err = parseIgnoreRules(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ignore file in %q: %w", dirRel, err)
+		}
+	}
+
+	m.mu.Lock()
+	m.rules[dirRel] = rules
+	m.mu.Unlock()
+	return rules, nil
+}
+
+// ignored reports whether relPath or any of its parent directories is ignored.
+// Once a directory is ignored, nothing below it can be re-included.
+func (m *ignoreMatcher) ignored(relPath string, isDir bool) (bool, error) {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "." {
+		return false, nil
+	}
+
+	parts := strings.Split(relPath, "/")
+	for i := 1; i <= len(parts); i++ {
+		target := strings.Join(parts[:i], "/")
+		targetIsDir := isDir || i < len(parts)
+		ignored, err := m.matchPath(target, targetIsDir)
+		if err != nil || ignored {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
+
+// matchPath evaluates all applicable ignore files for relPath.
+// Deeper ignore files take precedence and the last matching rule wins.
+func (m *ignoreMatcher) matchPath(relPath string, isDir bool) (bool, error) {
+	dirs := []string{"."}
+	if m.nested {
+		parent := path.Dir(relPath)
+		parts := strings.Split(parent, "/")
+		for i := 1; parent != "." && i <= len(parts); i++ {
+			dirs = append(dirs, strings.Join(parts[:i], "/"))
+		}
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		rules, err := m.rulesFor(dir)
+		if err != nil {
+			return false, err
+		}
+
+		target := relPath
+		if dir != "." {
+			target = strings.TrimPrefix(relPath, dir+"/")
+		}
+		for _, rule := range rules {
+			if rule.match(target, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored, nil
+}
+
+// isIgnored reports whether relPath is excluded by ignore files.
+func (mt *mirrorTransform) isIgnored(relPath string, isDir bool) (bool, error) {
+	if mt.ignore == nil {
+		return false, nil
+	}
+	return mt.ignore.ignored(relPath, isDir)
+}
@@ -0,0 +1,134 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCrawlIgnoreFile tests gitignore-style ignore files in Crawl.
+func TestCrawlIgnoreFile(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{
+		"keep.jpg",
+		"draft.jpg",
+		"important-draft.jpg",
+		"build/out.jpg",
+		"docs/build/page.jpg",
+		"cache/a.jpg",
+		"sub/cache/b.jpg",
+		"sub/local.jpg",
+		"sub/nested/local.jpg",
+		"tmp/c.jpg",
+	})
+
+	writeIgnoreFile(t, filepath.Join(inputDir, DefaultIgnoreFile), `
+# comments and blank lines are skipped
+
+*draft.jpg
+!important-draft.jpg
+/build
+cache/
+`)
+	writeIgnoreFile(t, filepath.Join(inputDir, "sub", DefaultIgnoreFile), `
+/local.jpg
+`)
+
+	tests := []struct {
+		name     string
+		nested   bool
+		excludes []string
+		expected []string
+	}{
+		{
+			name:     "RootOnly",
+			nested:   false,
+			excludes: []string{"tmp/**"},
+			expected: []string{"docs/build/page.jpg", "important-draft.jpg", "keep.jpg", "sub/local.jpg", "sub/nested/local.jpg"},
+		},
+		{
+			name:     "Nested",
+			nested:   true,
+			excludes: []string{"tmp/**"},
+			expected: []string{"docs/build/page.jpg", "important-draft.jpg", "keep.jpg", "sub/nested/local.jpg"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var processed []string
+			var mu sync.Mutex
+
+			config := Config{
+				InputDir:          inputDir,
+				OutputDir:         filepath.Join(testDir, "output", tt.name),
+				Patterns:          []string{"**/*.jpg"},
+				ExcludePatterns:   tt.excludes,
+				IgnoreFile:        DefaultIgnoreFile,
+				NestedIgnoreFiles: tt.nested,
+				Concurrency:       2,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					relPath, _ := filepath.Rel(inputDir, inputPath)
+					mu.Lock()
+					processed = append(processed, filepath.ToSlash(relPath))
+					mu.Unlock()
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			sort.Strings(processed)
+			if strings.Join(processed, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, processed)
+			}
+		})
+	}
+}
+
+// TestParseIgnoreRules tests parsing of gitignore-style rules.
+func TestParseIgnoreRules(t *testing.T) {
+	t.Parallel()
+	rules, err := parseIgnoreRules(strings.NewReader("# comment\n\n*.log\n!keep.log\n/root.txt\nassets/raw/\n\\#hash\n"))
+	if err != nil {
+		t.Fatalf("parseIgnoreRules failed: %v", err)
+	}
+
+	expected := []ignoreRule{
+		{pattern: "**/*.log"},
+		{pattern: "**/keep.log", negate: true},
+		{pattern: "root.txt"},
+		{pattern: "assets/raw", dirOnly: true},
+		{pattern: "**/#hash"},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("Expected %d rules, got %d: %v", len(expected), len(rules), rules)
+	}
+	for i, rule := range rules {
+		if rule != expected[i] {
+			t.Errorf("Rule %d: expected %+v, got %+v", i, expected[i], rule)
+		}
+	}
+}
+
+func writeIgnoreFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write ignore file %s: %v", path, err)
+	}
+}
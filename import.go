@@ -0,0 +1,199 @@
+package mirrortransform
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ImportList processes the files listed in r, one path relative to InputDir
+// per line, instead of scanning InputDir. Blank lines and lines starting with
+// "#" are skipped. Listed files go through the same filters, mapping and
+// workers as in Crawl, so an inventory of a large tree can be processed
+// without listing its directories. Files that no longer exist are skipped.
+func (mt *mirrorTransform) ImportList(ctx context.Context, r io.Reader, opts ...RunOption) (*Result, error) {
+	run := newRunState(false, opts...)
+	run.collectResult = true
+	result, err := mt.runFinite(ctx, "ImportList", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		return mt.importList(ctx, r, taskChan, run)
+	})
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+	}
+	return result, err
+}
+
+// ImportTar extracts the matching regular files of the tar stream r into
+// InputDir and processes each one as soon as it is written, as in Crawl.
+// Entries that do not match, and entries with paths outside InputDir, are
+// skipped. With WithDryRun nothing is extracted.
+func (mt *mirrorTransform) ImportTar(ctx context.Context, r io.Reader, opts ...RunOption) (*Result, error) {
+	run := newRunState(false, opts...)
+	run.collectResult = true
+	result, err := mt.runFinite(ctx, "ImportTar", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		return mt.importTar(ctx, r, taskChan, run)
+	})
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+	}
+	return result, err
+}
+
+// importList sends a task for every matching file listed in r.
+func (mt *mirrorTransform) importList(ctx context.Context, r io.Reader, taskChan chan<- fileTask, run *runState) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		relPath, ok := mt.importRelPath(line, run)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(mt.config.InputDir, relPath)
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err := mt.handlePathError(path, err, "access"); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		pattern, err := mt.entryPattern(path, relPath, newFileEntry(path, info))
+		if err != nil {
+			return err
+		}
+		if pattern == "" {
+			continue
+		}
+		if err := mt.sendTask(ctx, path, relPath, info, TaskEventImport, pattern, taskChan, run); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read import list: %w", err)
+	}
+	return nil
+}
+
+// importTar extracts and sends a task for every matching file in r.
+func (mt *mirrorTransform) importTar(ctx context.Context, r io.Reader, taskChan chan<- fileTask, run *runState) error {
+	reader := tar.NewReader(r)
+	for {
+		// Check context cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar stream: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		relPath, ok := mt.importRelPath(header.Name, run)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(mt.config.InputDir, relPath)
+
+		pattern, err := mt.entryPattern(path, relPath, newFileEntry(path, header.FileInfo()))
+		if err != nil {
+			return err
+		}
+		if pattern == "" {
+			continue
+		}
+
+		// Dry runs only count what would be imported
+		if run.options.dryRun {
+			run.matched.Add(1)
+			run.skipped.Add(1)
+			continue
+		}
+
+		if err := extractEntry(path, header.FileInfo(), reader); err != nil {
+			if err := mt.handlePathError(path, err, "extract"); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat extracted file %q: %w", path, err)
+		}
+		if err := mt.sendTask(ctx, path, relPath, info, TaskEventImport, pattern, taskChan, run); err != nil {
+			return err
+		}
+	}
+}
+
+// importRelPath converts a path from an import source to a path relative to
+// InputDir. It reports false for paths outside InputDir or outside the
+// subdirectory selected with WithSubdir.
+func (mt *mirrorTransform) importRelPath(name string, run *runState) (string, bool) {
+	relPath := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(name, "./")))
+	if !filepath.IsLocal(relPath) {
+		return "", false
+	}
+	if subdir := run.options.subdir; subdir != "" {
+		prefix := filepath.Clean(filepath.FromSlash(subdir)) + string(filepath.Separator)
+		if !strings.HasPrefix(relPath, prefix) {
+			return "", false
+		}
+	}
+	return relPath, true
+}
+
+// extractEntry writes the content of an archive entry described by info to
+// path, replacing any existing file only once the content is complete.
+func extractEntry(path string, info fs.FileInfo, r io.Reader) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,148 @@
+package mirrortransform
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestImportList tests that listed files are processed without scanning.
+func TestImportList(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/b.jpg", "dir/c.jpg", "notes.txt"})
+
+	var mu sync.Mutex
+	var processed []string
+	var events []TaskEvent
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		TaskCallback: func(task Task) (bool, error) {
+			mu.Lock()
+			processed = append(processed, filepath.ToSlash(task.RelPath))
+			events = append(events, task.Event)
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	list := "# inventory\na.jpg\n\ndir/b.jpg\nnotes.txt\nmissing.jpg\n../outside.jpg\n"
+	result, err := mt.ImportList(context.Background(), strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("ImportList failed: %v", err)
+	}
+
+	sort.Strings(processed)
+	if strings.Join(processed, ",") != "a.jpg,dir/b.jpg" {
+		t.Errorf("Expected [a.jpg dir/b.jpg] to be processed, got %v", processed)
+	}
+	for _, event := range events {
+		if event != TaskEventImport {
+			t.Errorf("Expected event %q, got %q", TaskEventImport, event)
+		}
+	}
+	if result.Matched != 2 || result.Processed != 2 {
+		t.Errorf("Expected 2 matched and processed, got %+v", result)
+	}
+}
+
+// TestImportTar tests that matching tar entries are extracted into the
+// input directory and processed.
+func TestImportTar(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	modTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range []struct {
+		name     string
+		typeflag byte
+		content  string
+	}{
+		{"photos/", tar.TypeDir, ""},
+		{"photos/a.jpg", tar.TypeReg, "a"},
+		{"./photos/b.jpg", tar.TypeReg, "bb"},
+		{"photos/readme.txt", tar.TypeReg, "text"},
+		{"../escape.jpg", tar.TypeReg, "evil"},
+	} {
+		header := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Mode: 0644, Size: int64(len(entry.content)), ModTime: modTime}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("Failed to write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("Failed to close tar writer: %v", err)
+	}
+
+	config := Config{
+		InputDir:     inputDir,
+		OutputDir:    outputDir,
+		Patterns:     []string{"**/*.jpg"},
+		FileCallback: CopyCallback(CopyOptions{}),
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.ImportTar(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ImportTar failed: %v", err)
+	}
+	if result.Processed != 2 {
+		t.Errorf("Expected 2 processed files, got %d", result.Processed)
+	}
+
+	for name, content := range map[string]string{"a.jpg": "a", "b.jpg": "bb"} {
+		input := filepath.Join(inputDir, "photos", name)
+		info, err := os.Stat(input)
+		if err != nil {
+			t.Fatalf("Expected %s to be extracted: %v", name, err)
+		}
+		if !info.ModTime().Equal(modTime) {
+			t.Errorf("Expected modification time %v for %s, got %v", modTime, name, info.ModTime())
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, "photos", name))
+		if err != nil {
+			t.Fatalf("Expected output for %s: %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected output content %q for %s, got %q", content, name, data)
+		}
+	}
+
+	for _, path := range []string{filepath.Join(inputDir, "photos", "readme.txt"), filepath.Join(testDir, "escape.jpg")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be extracted", path)
+		}
+	}
+}
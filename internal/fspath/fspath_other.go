@@ -0,0 +1,21 @@
+//go:build !windows
+
+// Package fspath compares file system paths the way the platform does.
+package fspath
+
+import "strings"
+
+// Normalize returns path unchanged; only Windows paths have several spellings.
+func Normalize(path string) string {
+	return path
+}
+
+// Same reports whether a and b name the same path.
+func Same(a, b string) bool {
+	return a == b
+}
+
+// HasPrefix reports whether path starts with prefix.
+func HasPrefix(path, prefix string) bool {
+	return strings.HasPrefix(path, prefix)
+}
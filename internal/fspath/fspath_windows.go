@@ -0,0 +1,34 @@
+//go:build windows
+
+// Package fspath compares file system paths the way the platform does.
+package fspath
+
+import "strings"
+
+// Normalize removes the \\?\ long path prefix and upper-cases the drive
+// letter, so the different spellings of a path compare equal.
+// Long paths are still supported, as the os package adds the prefix itself.
+func Normalize(path string) string {
+	switch {
+	case strings.HasPrefix(path, `\\?\UNC\`):
+		path = `\\` + path[len(`\\?\UNC\`):]
+	case strings.HasPrefix(path, `\\?\`):
+		path = path[len(`\\?\`):]
+	}
+	if len(path) >= 2 && path[1] == ':' {
+		path = strings.ToUpper(path[:1]) + path[1:]
+	}
+	return path
+}
+
+// Same reports whether a and b name the same path, ignoring case as the
+// file system does.
+func Same(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// HasPrefix reports whether path starts with prefix, ignoring case as the
+// file system does.
+func HasPrefix(path, prefix string) bool {
+	return len(path) >= len(prefix) && strings.EqualFold(path[:len(prefix)], prefix)
+}
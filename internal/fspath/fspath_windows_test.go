@@ -0,0 +1,26 @@
+//go:build windows
+
+package fspath
+
+import "testing"
+
+// TestNormalize tests that the spellings of a Windows path are unified.
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{`C:\data\in`, `C:\data\in`},
+		{`c:\data\in`, `C:\data\in`},
+		{`\\?\c:\data\in`, `C:\data\in`},
+		{`\\?\UNC\server\share\in`, `\\server\share\in`},
+		{`\\server\share\in`, `\\server\share\in`},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.path); got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"time"
+)
+
+// LatencyObjective declares how quickly every queued file should be
+// processed, measured from the event or scan that queued it until its
+// callback returns.
+type LatencyObjective struct {
+	// Target is the maximum acceptable latency, e.g. one minute.
+	Target time.Duration
+
+	// AlertCallback is called for every file processed later than Target.
+	AlertCallback LatencyAlertCallback
+}
+
+// LatencyAlertCallback is called when a file missed the latency objective.
+type LatencyAlertCallback func(violation LatencyViolation)
+
+// LatencyViolation describes a file that was processed later than the target.
+type LatencyViolation struct {
+	// Task is the late file.
+	Task FileTask
+
+	// Latency is the time from queuing the file until its callback returned.
+	Latency time.Duration
+
+	// Target is the objective that was missed.
+	Target time.Duration
+}
+
+// validateLatencyObjective checks the latency objective settings.
+func validateLatencyObjective(objective *LatencyObjective) error {
+	if objective == nil {
+		return nil
+	}
+	if objective.Target <= 0 {
+		return fmt.Errorf("latency objective target must be positive")
+	}
+	return nil
+}
+
+// urgentAfter returns how long a task may wait in the queue before it is
+// dispatched ahead of the others, or zero without a latency objective.
+// Half the target leaves the other half for processing.
+func (mt *mirrorTransform) urgentAfter() time.Duration {
+	if mt.config.LatencyObjective == nil {
+		return 0
+	}
+	return mt.config.LatencyObjective.Target / 2
+}
+
+// checkLatency reports the task to AlertCallback if it missed the objective.
+func (mt *mirrorTransform) checkLatency(task fileTask) {
+	objective := mt.config.LatencyObjective
+	if objective == nil || objective.AlertCallback == nil || task.queuedAt.IsZero() {
+		return
+	}
+	latency := time.Since(task.queuedAt)
+	if latency <= objective.Target {
+		return
+	}
+	objective.AlertCallback(LatencyViolation{Task: task.FileTask, Latency: latency, Target: objective.Target})
+}
@@ -0,0 +1,107 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRunTaskQueueUrgent tests that tasks waiting too long are dispatched first.
+func TestRunTaskQueueUrgent(t *testing.T) {
+	t.Parallel()
+	in := make(chan fileTask, 3)
+	out := make(chan fileTask)
+
+	now := time.Now()
+	queued := map[string]time.Time{
+		"a": now.Add(-time.Second),
+		"b": now.Add(time.Hour),
+		"c": now.Add(time.Hour),
+	}
+	for _, rel := range []string{"a", "b", "c"} {
+		in <- fileTask{FileTask: FileTask{RelPath: rel}, queuedAt: queued[rel]}
+	}
+	close(in)
+
+	less := func(a, b FileTask) bool { return a.RelPath > b.RelPath }
+	go runTaskQueue(context.Background(), in, out, less, nil, 50*time.Millisecond, true, maxSortedTasks)
+
+	// Give the queue time to notice the waiting task
+	time.Sleep(100 * time.Millisecond)
+
+	var order []string
+	for task := range out {
+		order = append(order, task.RelPath)
+	}
+
+	if strings.Join(order, ",") != "a,c,b" {
+		t.Errorf("Expected order [a c b], got %v", order)
+	}
+}
+
+// TestLatencyObjectiveAlert tests that late files are reported.
+func TestLatencyObjectiveAlert(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"fast.jpg", "slow.jpg"})
+
+	var violations []LatencyViolation
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		// Process both at once so only the slow file is late
+		Concurrency:    2,
+		MaxConcurrency: 2,
+		LatencyObjective: &LatencyObjective{
+			Target: 500 * time.Millisecond,
+			AlertCallback: func(violation LatencyViolation) {
+				mu.Lock()
+				violations = append(violations, violation)
+				mu.Unlock()
+			},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Base(inputPath) == "slow.jpg" {
+				time.Sleep(600 * time.Millisecond)
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %v", violations)
+	}
+	v := violations[0]
+	if v.Task.RelPath != "slow.jpg" || v.Target != 500*time.Millisecond || v.Latency <= v.Target {
+		t.Errorf("Unexpected violation %+v", v)
+	}
+}
+
+// TestValidateLatencyObjective tests latency objective validation.
+func TestValidateLatencyObjective(t *testing.T) {
+	t.Parallel()
+	if err := validateLatencyObjective(&LatencyObjective{}); err == nil {
+		t.Error("Expected error for missing target")
+	}
+	if err := validateLatencyObjective(&LatencyObjective{Target: time.Minute}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// DefaultLockFile is the conventional name for Config.LockFile.
+const DefaultLockFile = ".mirror-lock"
+
+// ErrLocked is returned, wrapped with the path of the lock file, when
+// another process holds Config.LockFile.
+var ErrLocked = errors.New("lock file is held by another process")
+
+// instanceLock maintains Config.LockFile. The advisory lock is taken when
+// the first run of the instance starts and released when the last one ends.
+type instanceLock struct {
+	path string
+
+	mu      sync.Mutex
+	holders int
+	file    *os.File
+}
+
+// newInstanceLock returns the lock for the configured file, or nil if
+// disabled. Relative paths are resolved against OutputDir.
+func newInstanceLock(config *Config) *instanceLock {
+	if config.LockFile == "" {
+		return nil
+	}
+	path := config.LockFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Clean(config.OutputDir), path)
+	}
+	return &instanceLock{path: path}
+}
+
+// acquire takes the lock until the matching release. It fails with an error
+// wrapping ErrLocked if another process holds it. It is a no-op on a nil lock.
+func (l *instanceLock) acquire() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.holders > 0 {
+		l.holders++
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for lock file %q: %w", l.path, err)
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file %q: %w", l.path, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, ErrLocked) {
+			return fmt.Errorf("%w: %q", ErrLocked, l.path)
+		}
+		return fmt.Errorf("failed to lock %q: %w", l.path, err)
+	}
+
+	// Record the holder to help whoever finds the lock taken. The PID is
+	// only informational, so failing to write it does not fail the lock.
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	l.file = f
+	l.holders = 1
+	return nil
+}
+
+// release ends a hold taken by acquire, unlocking the file once no holder
+// is left. The file itself is kept. It is a no-op on a nil lock.
+func (l *instanceLock) release() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.holders--
+	if l.holders > 0 {
+		return nil
+	}
+
+	// Closing the file releases the lock
+	err := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to release lock file %q: %w", l.path, err)
+	}
+	return nil
+}
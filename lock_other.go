@@ -0,0 +1,13 @@
+//go:build !unix && !windows
+
+package mirrortransform
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile is not supported on this platform.
+func lockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,69 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestLockFile tests that a second instance over the same tree cannot run
+// while the first holds the lock, and that runs of one instance share it.
+func TestLockFile(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "plan9" {
+		t.Skip("lock files are not supported on Plan 9")
+	}
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.txt"})
+
+	newInstance := func() MirrorTransform {
+		t.Helper()
+		config := Config{
+			InputDir:  inputDir,
+			OutputDir: filepath.Join(testDir, "output"),
+			Patterns:  []string{"**/*.txt"},
+			LockFile:  DefaultLockFile,
+			FileCallback: func(inputPath, outputPath string) (bool, error) {
+				return true, nil
+			},
+		}
+		mt, err := NewMirrorTransform(&config)
+		if err != nil {
+			t.Fatalf("Failed to create MirrorTransform: %v", err)
+		}
+		return mt
+	}
+	first, second := newInstance(), newInstance()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- first.Watch(ctx) }()
+	time.Sleep(200 * time.Millisecond)
+
+	// Runs of the instance holding the lock share it
+	if err := first.Crawl(context.Background()); err != nil {
+		t.Errorf("Crawl of the same instance failed: %v", err)
+	}
+
+	err := second.Crawl(context.Background())
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+
+	// Dry runs write nothing, so they need no lock
+	if err := second.Crawl(context.Background(), WithDryRun()); err != nil {
+		t.Errorf("Dry run failed: %v", err)
+	}
+
+	cancel()
+	if err := <-done; err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if err := second.Crawl(context.Background()); err != nil {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package mirrortransform
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a slog.Handler that drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// discardLogger is used when Config.Logger is not set.
+var discardLogger = slog.New(discardHandler{})
+
+// configLogger returns config.Logger, or a logger that discards everything.
+func configLogger(config *Config) *slog.Logger {
+	if config.Logger != nil {
+		return config.Logger
+	}
+	return discardLogger
+}
+
+// log returns the logger of the instance.
+func (mt *mirrorTransform) log() *slog.Logger {
+	return configLogger(&mt.config)
+}
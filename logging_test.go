@@ -0,0 +1,166 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records decodes the JSON log records written so far.
+func (b *syncBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to decode log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// findRecord returns the first record with msg, or nil.
+func findRecord(records []map[string]any, msg string) map[string]any {
+	for _, record := range records {
+		if record["msg"] == msg {
+			return record
+		}
+	}
+	return nil
+}
+
+// TestLoggerCrawl tests that crawls log dispatched, skipped and failed files
+// and a summary.
+func TestLoggerCrawl(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "bad.jpg"})
+
+	var logs syncBuffer
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ContinueOnError: true,
+		Logger:          slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if strings.Contains(inputPath, "bad") {
+				return true, errors.New("broken")
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected the failure to be reported")
+	}
+	if err := mt.Crawl(context.Background(), WithDryRun()); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+
+	records := logs.records(t)
+	if record := findRecord(records, "processed file"); record == nil || record["path"] != "a.jpg" {
+		t.Errorf("Expected a processed file record for a.jpg, got %v", record)
+	}
+	if record := findRecord(records, "file callback failed"); record == nil || record["path"] != "bad.jpg" || record["level"] != "WARN" {
+		t.Errorf("Expected a warning for bad.jpg, got %v", record)
+	}
+	if record := findRecord(records, "skipped file"); record == nil || record["reason"] != "dry run" {
+		t.Errorf("Expected a dry run skip, got %v", record)
+	}
+	if record := findRecord(records, "run finished"); record == nil || record["processed"] != float64(1) || record["failed"] != float64(1) {
+		t.Errorf("Expected a run summary, got %v", record)
+	}
+}
+
+// TestLoggerWatch tests that Watch logs watch registration and shutdown.
+func TestLoggerWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"dir/a.txt"})
+
+	var logs syncBuffer
+	config := Config{
+		InputDir:            inputDir,
+		OutputDir:           filepath.Join(testDir, "output"),
+		Patterns:            []string{"**/*.jpg"},
+		ShutdownGracePeriod: time.Second,
+		Logger:              slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	select {
+	case <-ready:
+	case err := <-watchErr:
+		t.Fatalf("Watch ended early: %v", err)
+	}
+	cancel()
+	if err := <-watchErr; err != context.Canceled {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	records := logs.records(t)
+	watched := 0
+	for _, record := range records {
+		if record["msg"] == "watching directory" || record["msg"] == "watching tree" {
+			watched++
+		}
+	}
+	if watched == 0 {
+		t.Error("Expected watch registrations to be logged")
+	}
+	if record := findRecord(records, "watch started"); record == nil || record["dir"] != inputDir {
+		t.Errorf("Expected a watch started record, got %v", record)
+	}
+	if record := findRecord(records, "shutting down"); record == nil || record["in_flight"] != float64(0) {
+		t.Errorf("Expected a shutdown record, got %v", record)
+	}
+}
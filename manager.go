@@ -0,0 +1,225 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MirrorError reports that the mirror of a Manager named Name failed.
+type MirrorError struct {
+	// Name is the name the mirror was added with.
+	Name string
+
+	// Err is the error returned by the mirror.
+	Err error
+}
+
+func (e *MirrorError) Error() string {
+	return fmt.Sprintf("mirror %q: %v", e.Name, e.Err)
+}
+
+func (e *MirrorError) Unwrap() error {
+	return e.Err
+}
+
+// ManagerStats combines the QueueStats of the mirrors of a Manager.
+type ManagerStats struct {
+	// Total sums Pending, InFlight and InFlightBytes over all mirrors and
+	// lists all of their workers. Worker IDs are only unique within a mirror.
+	Total QueueStats `json:"total"`
+
+	// Mirrors holds the QueueStats of every mirror by name.
+	Mirrors map[string]QueueStats `json:"mirrors"`
+}
+
+// Manager runs several mirrors, e.g. one per asset root, in one process
+// under one context. Crawl, Watch and Run start the method of the same name
+// on every mirror at once and return when all have returned, joining their
+// errors as *MirrorError. Add every mirror before starting them.
+type Manager struct {
+	mu      sync.Mutex
+	names   []string
+	mirrors map[string]MirrorTransform
+}
+
+// NewManager returns a Manager without mirrors.
+func NewManager() *Manager {
+	return &Manager{mirrors: make(map[string]MirrorTransform)}
+}
+
+// Add registers mt under name, which must be unique and not empty.
+func (m *Manager) Add(name string, mt MirrorTransform) error {
+	if name == "" {
+		return fmt.Errorf("mirror name is required")
+	}
+	if mt == nil {
+		return fmt.Errorf("mirror %q is nil", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mirrors[name]; ok {
+		return fmt.Errorf("mirror %q already exists", name)
+	}
+	m.names = append(m.names, name)
+	m.mirrors[name] = mt
+	return nil
+}
+
+// Mirror returns the mirror added under name, or nil.
+func (m *Manager) Mirror(name string) MirrorTransform {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mirrors[name]
+}
+
+// Names returns the names of the mirrors in the order they were added.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.names...)
+}
+
+// snapshot returns the names of the mirrors in the order they were added
+// and the mirrors under them.
+func (m *Manager) snapshot() ([]string, []MirrorTransform) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mirrors := make([]MirrorTransform, len(m.names))
+	for i, name := range m.names {
+		mirrors[i] = m.mirrors[name]
+	}
+	return append([]string(nil), m.names...), mirrors
+}
+
+// each calls fn for every mirror concurrently and returns the errors fn
+// returned, in the order the mirrors were added.
+func (m *Manager) each(fn func(name string, mt MirrorTransform) error) []error {
+	names, mirrors := m.snapshot()
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(names[i], mirrors[i])
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Crawl crawls every mirror at once and returns their results by name once
+// all have finished. A failing mirror does not stop the others; the errors
+// of all failed mirrors are joined.
+func (m *Manager) Crawl(ctx context.Context, opts ...RunOption) (map[string]*Result, error) {
+	var mu sync.Mutex
+	results := make(map[string]*Result)
+	errs := m.each(func(name string, mt MirrorTransform) error {
+		result, err := mt.CrawlWithResult(ctx, opts...)
+		if result != nil {
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}
+		if err != nil {
+			return &MirrorError{Name: name, Err: err}
+		}
+		return nil
+	})
+	return results, errors.Join(errs...)
+}
+
+// Watch watches every mirror until ctx is cancelled. When one mirror stops
+// with an error, the others are stopped too, and the error is returned;
+// mirrors stopped that way do not add theirs. It returns ctx.Err() if ctx
+// was cancelled without a failure.
+func (m *Manager) Watch(ctx context.Context, opts ...RunOption) error {
+	return m.runAll(ctx, func(ctx context.Context, mt MirrorTransform) error {
+		return mt.Watch(ctx, opts...)
+	})
+}
+
+// Run is like Watch, calling Run on every mirror.
+func (m *Manager) Run(ctx context.Context, opts ...RunOption) error {
+	return m.runAll(ctx, func(ctx context.Context, mt MirrorTransform) error {
+		return mt.Run(ctx, opts...)
+	})
+}
+
+// runAll calls fn for every mirror with a shared context that is cancelled
+// as soon as one of them fails.
+func (m *Manager) runAll(ctx context.Context, fn func(ctx context.Context, mt MirrorTransform) error) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := m.each(func(name string, mt MirrorTransform) error {
+		err := fn(runCtx, mt)
+		if err == nil || (errors.Is(err, context.Canceled) && runCtx.Err() != nil) {
+			return nil
+		}
+
+		// Stop the other mirrors
+		cancel()
+		return &MirrorError{Name: name, Err: err}
+	})
+
+	// Keep only the errors of the mirrors that failed on their own
+	var failures []error
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+	return ctx.Err()
+}
+
+// QueueStats returns the QueueStats of every mirror and their total.
+func (m *Manager) QueueStats() ManagerStats {
+	stats := ManagerStats{
+		Total:   QueueStats{Workers: []WorkerState{}},
+		Mirrors: make(map[string]QueueStats),
+	}
+	names, mirrors := m.snapshot()
+	for i, mt := range mirrors {
+		s := mt.QueueStats()
+		stats.Mirrors[names[i]] = s
+		stats.Total.Pending += s.Pending
+		stats.Total.InFlight += s.InFlight
+		stats.Total.InFlightBytes += s.InFlightBytes
+		stats.Total.Workers = append(stats.Total.Workers, s.Workers...)
+	}
+	return stats
+}
+
+// Pause pauses every mirror.
+func (m *Manager) Pause() {
+	_, mirrors := m.snapshot()
+	for _, mt := range mirrors {
+		mt.Pause()
+	}
+}
+
+// Resume resumes every mirror.
+func (m *Manager) Resume() {
+	_, mirrors := m.snapshot()
+	for _, mt := range mirrors {
+		mt.Resume()
+	}
+}
+
+// Rescan rescans the runs in progress of every mirror and joins the errors
+// as *MirrorError.
+func (m *Manager) Rescan(ctx context.Context) error {
+	return errors.Join(m.each(func(name string, mt MirrorTransform) error {
+		if err := mt.Rescan(ctx); err != nil {
+			return &MirrorError{Name: name, Err: err}
+		}
+		return nil
+	})...)
+}
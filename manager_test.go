@@ -0,0 +1,108 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newManagerMirror returns a mirror of the jpg files of a new input tree
+// holding files, calling callback for each.
+func newManagerMirror(t *testing.T, files []string, callback FileCallback) MirrorTransform {
+	t.Helper()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, files)
+
+	mt, err := NewMirrorTransform(&Config{
+		InputDir:     inputDir,
+		OutputDir:    filepath.Join(testDir, "output"),
+		Patterns:     []string{"**/*.jpg"},
+		FileCallback: callback,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	return mt
+}
+
+// TestManagerCrawl tests that Manager crawls every mirror and joins the
+// errors of the failed ones.
+func TestManagerCrawl(t *testing.T) {
+	t.Parallel()
+	succeed := func(inputPath, outputPath string) (bool, error) { return true, nil }
+	failure := errors.New("transform failed")
+	fail := func(inputPath, outputPath string) (bool, error) { return false, failure }
+
+	m := NewManager()
+	if err := m.Add("photos", newManagerMirror(t, []string{"a.jpg", "b.jpg"}, succeed)); err != nil {
+		t.Fatalf("Failed to add mirror: %v", err)
+	}
+	if err := m.Add("banners", newManagerMirror(t, []string{"c.jpg"}, fail)); err != nil {
+		t.Fatalf("Failed to add mirror: %v", err)
+	}
+	if err := m.Add("photos", newManagerMirror(t, nil, succeed)); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+	if names := m.Names(); len(names) != 2 || names[0] != "photos" || names[1] != "banners" {
+		t.Errorf("Expected the mirrors in the order added, got %v", names)
+	}
+
+	results, err := m.Crawl(context.Background())
+	var mirrorErr *MirrorError
+	if !errors.As(err, &mirrorErr) || mirrorErr.Name != "banners" || !errors.Is(err, failure) {
+		t.Errorf("Expected the failure of banners, got %v", err)
+	}
+	if results["photos"] == nil || results["photos"].Processed != 2 {
+		t.Errorf("Expected photos to be crawled despite the failure, got %+v", results["photos"])
+	}
+}
+
+// TestManagerRun tests that a failing mirror stops the others and only its
+// error is returned.
+func TestManagerRun(t *testing.T) {
+	t.Parallel()
+	failure := errors.New("transform failed")
+	m := NewManager()
+	m.Add("healthy", newManagerMirror(t, []string{"a.jpg"}, func(inputPath, outputPath string) (bool, error) {
+		return true, nil
+	}))
+	m.Add("broken", newManagerMirror(t, []string{"b.jpg"}, func(inputPath, outputPath string) (bool, error) {
+		return false, failure
+	}))
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- m.Run(context.Background())
+	}()
+
+	select {
+	case err := <-errChan:
+		var mirrorErr *MirrorError
+		if !errors.As(err, &mirrorErr) || mirrorErr.Name != "broken" || errors.Is(err, context.Canceled) {
+			t.Errorf("Expected only the failure of broken, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the failure to stop every mirror")
+	}
+
+	// Cancelling the context stops all mirrors without an error of their own
+	ctx, cancel := context.WithCancel(context.Background())
+	m = NewManager()
+	m.Add("healthy", newManagerMirror(t, []string{"a.jpg"}, func(inputPath, outputPath string) (bool, error) {
+		return true, nil
+	}))
+	go func() {
+		errChan <- m.Watch(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if stats := m.QueueStats(); len(stats.Mirrors) != 1 {
+		t.Errorf("Expected the stats of one mirror, got %+v", stats)
+	}
+	cancel()
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/ideamans/go-mirror-transform/internal/fspath"
+)
+
+// globMatch reports whether the relative path matches the glob pattern.
+// Paths are matched with forward slashes on every platform, and without
+// regard to case if CaseInsensitivePatterns is set.
+func (mt *mirrorTransform) globMatch(pattern, relPath string) (bool, error) {
+	relPath = filepath.ToSlash(relPath)
+	if mt.config.CaseInsensitivePatterns {
+		pattern = strings.ToLower(pattern)
+		relPath = strings.ToLower(relPath)
+	}
+	return doublestar.Match(pattern, relPath)
+}
+
+// matchPattern returns the first entry of Patterns or RegexPatterns
+// matching relPath, matcherPattern if Matcher selects it, or an empty string
+// if none matches or Filter skips the file. d describes the file for
+// Matcher and Filter. Below an Override with Patterns, those are matched
+// against the path relative to its subtree instead.
+func (mt *mirrorTransform) matchPattern(relPath string, d fs.DirEntry) (string, error) {
+	return mt.matchPatternIn(mt.currentSettings(), relPath, d)
+}
+
+// matchPatternIn is like matchPattern with the Patterns of settings.
+// relPath is matched in the form of UnicodeNormalization, and matching files
+// are then passed to Filter.
+func (mt *mirrorTransform) matchPatternIn(settings *runtimeSettings, relPath string, d fs.DirEntry) (string, error) {
+	relPath = mt.normalizeUnicode(relPath)
+	pattern := mt.selectPattern(settings, relPath, d)
+	if pattern == "" || mt.config.Filter == nil {
+		return pattern, nil
+	}
+
+	// Files that cannot be stat'ed are left to the callback to report
+	info, err := d.Info()
+	if err == nil && !mt.config.Filter(filepath.ToSlash(relPath), info) {
+		return "", nil
+	}
+	return pattern, nil
+}
+
+// selectPattern returns the pattern of settings, RegexPatterns or Matcher
+// selecting relPath, or an empty string if none does.
+func (mt *mirrorTransform) selectPattern(settings *runtimeSettings, relPath string, d fs.DirEntry) string {
+	if override := mt.overrides.find(relPath); override != nil && override.globs != nil {
+		return override.globs.Pattern(override.relPath(relPath))
+	}
+
+	if mt.config.Matcher != nil {
+		if mt.config.Matcher.Match(filepath.ToSlash(relPath), d) {
+			return matcherPattern
+		}
+		return ""
+	}
+	if pattern := settings.globs.Pattern(relPath); pattern != "" {
+		return pattern
+	}
+	return mt.regexes.match(relPath)
+}
+
+// isExcluded reports whether relPath matches any of ExcludePatterns or
+// RegexExcludes, or the ExcludePatterns of the Override for its subtree.
+func (mt *mirrorTransform) isExcluded(relPath string) (bool, error) {
+	relPath = mt.normalizeUnicode(relPath)
+	if mt.regexes.excluded(relPath) {
+		return true, nil
+	}
+	excluded, err := mt.matchExcludes(mt.currentSettings().excludePatterns, relPath)
+	if err != nil || excluded {
+		return excluded, err
+	}
+
+	if override := mt.overrides.find(relPath); override != nil {
+		return mt.matchExcludes(override.ExcludePatterns, override.relPath(relPath))
+	}
+	return false, nil
+}
+
+// beyondMaxDepth reports whether relPath lies deeper below InputDir than
+// MaxDepth allows. A directory is beyond the limit when its files would be.
+func (mt *mirrorTransform) beyondMaxDepth(relPath string, isDir bool) bool {
+	if mt.config.MaxDepth <= 0 || relPath == "." {
+		return false
+	}
+	depth := strings.Count(filepath.ToSlash(relPath), "/") + 1
+	if isDir {
+		return depth >= mt.config.MaxDepth
+	}
+	return depth > mt.config.MaxDepth
+}
+
+// excludedIn reports whether relPath or one of its parent directories
+// matches the exclude patterns of settings.
+func (mt *mirrorTransform) excludedIn(settings *runtimeSettings, relPath string) (bool, error) {
+	relPath = mt.normalizeUnicode(relPath)
+	for dir := relPath; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		excluded, err := mt.matchExcludes(settings.excludePatterns, dir)
+		if err != nil || excluded {
+			return excluded, err
+		}
+	}
+	return false, nil
+}
+
+// matchExcludes reports whether relPath matches any of patterns.
+func (mt *mirrorTransform) matchExcludes(patterns []string, relPath string) (bool, error) {
+	for _, pattern := range patterns {
+		match, err := mt.globMatch(pattern, relPath)
+		if err != nil {
+			return false, markError(ErrInvalidPattern, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err))
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// relPath returns path relative to InputDir. Both are normalized first, so
+// long path prefixes and drive letter case don't prevent a match on Windows.
+func (mt *mirrorTransform) relPath(path string) (string, error) {
+	return filepath.Rel(fspath.Normalize(mt.config.InputDir), fspath.Normalize(path))
+}
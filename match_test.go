@@ -0,0 +1,114 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestCaseInsensitivePatterns tests matching without regard to case.
+func TestCaseInsensitivePatterns(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "B.JPG", "Tmp/c.jpg", "d.png"})
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		expected        []string
+	}{
+		{"Sensitive", false, []string{"Tmp/c.jpg", "a.jpg"}},
+		{"Insensitive", true, []string{"B.JPG", "a.jpg"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var processed []string
+			var mu sync.Mutex
+
+			config := Config{
+				InputDir:                inputDir,
+				OutputDir:               filepath.Join(testDir, "output", tt.name),
+				Patterns:                []string{"**/*.jpg"},
+				ExcludePatterns:         []string{"tmp/**"},
+				CaseInsensitivePatterns: tt.caseInsensitive,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					rel, err := filepath.Rel(inputDir, inputPath)
+					if err != nil {
+						return false, err
+					}
+					mu.Lock()
+					processed = append(processed, filepath.ToSlash(rel))
+					mu.Unlock()
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			sort.Strings(processed)
+			if strings.Join(processed, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, processed)
+			}
+		})
+	}
+}
+
+// TestCaseInsensitiveWatchEvents tests that watch events are matched without
+// regard to case like crawled files.
+func TestCaseInsensitiveWatchEvents(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"DCIM/PHOTO.JPG", "Tmp/c.jpg", "d.png"})
+
+	config := Config{
+		InputDir:                inputDir,
+		OutputDir:               filepath.Join(testDir, "output"),
+		Patterns:                []string{"**/*.jpg"},
+		ExcludePatterns:         []string{"tmp/**"},
+		CaseInsensitivePatterns: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	taskChan := make(chan fileTask, 10)
+	for _, rel := range []string{"DCIM/PHOTO.JPG", "Tmp/c.jpg", "d.png"} {
+		event := fsnotify.Event{Name: filepath.Join(inputDir, filepath.FromSlash(rel)), Op: fsnotify.Write}
+		if err := mt.processWatchEvent(context.Background(), nil, nil, event, taskChan); err != nil {
+			t.Fatalf("processWatchEvent failed for %q: %v", rel, err)
+		}
+	}
+	close(taskChan)
+
+	var relPaths []string
+	for task := range taskChan {
+		relPaths = append(relPaths, filepath.ToSlash(task.RelPath))
+	}
+	if len(relPaths) != 1 || relPaths[0] != "DCIM/PHOTO.JPG" {
+		t.Errorf("Expected only DCIM/PHOTO.JPG to be queued, got %v", relPaths)
+	}
+}
@@ -0,0 +1,128 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// matcherPattern is the FileTask.Pattern of files selected by Config.Matcher.
+const matcherPattern = "(matcher)"
+
+// Matcher decides which files are processed. Set as Config.Matcher, it
+// replaces Patterns and RegexPatterns, while hidden files, ignore files,
+// exclusions and Overrides with Patterns still apply before it is asked.
+// It is called from several goroutines at once.
+type Matcher interface {
+	// Match reports whether the file at relPath, relative to InputDir with
+	// forward slashes, is processed. d describes the file; its Info is
+	// stat'ed on demand and fails for files that no longer exist.
+	Match(relPath string, d fs.DirEntry) bool
+}
+
+// FilterFunc decides whether a file selected by the patterns is processed,
+// given the relative path with forward slashes and its stat info.
+type FilterFunc func(relPath string, info fs.FileInfo) bool
+
+// MatcherFunc adapts an ordinary function to Matcher.
+type MatcherFunc func(relPath string, d fs.DirEntry) bool
+
+// Match calls f(relPath, d).
+func (f MatcherFunc) Match(relPath string, d fs.DirEntry) bool {
+	return f(relPath, d)
+}
+
+// GlobMatcher is the Matcher for glob patterns used for Patterns. Patterns
+// are prepared once rather than for every file.
+type GlobMatcher struct {
+	patterns        []string
+	prepared        []string
+	caseInsensitive bool
+}
+
+// NewGlobMatcher returns a Matcher for files matching any of patterns,
+// without regard to case if caseInsensitive is set.
+func NewGlobMatcher(patterns []string, caseInsensitive bool) (*GlobMatcher, error) {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q", pattern))
+		}
+	}
+	return newGlobMatcher(patterns, caseInsensitive), nil
+}
+
+// newGlobMatcher is NewGlobMatcher for validated patterns.
+func newGlobMatcher(patterns []string, caseInsensitive bool) *GlobMatcher {
+	m := &GlobMatcher{patterns: patterns, prepared: patterns, caseInsensitive: caseInsensitive}
+	if caseInsensitive {
+		m.prepared = make([]string, len(patterns))
+		for i, pattern := range patterns {
+			m.prepared[i] = strings.ToLower(pattern)
+		}
+	}
+	return m
+}
+
+// Match reports whether relPath matches any of the patterns.
+func (m *GlobMatcher) Match(relPath string, d fs.DirEntry) bool {
+	return m.Pattern(relPath) != ""
+}
+
+// Pattern returns the first pattern matching relPath, or an empty string
+// if none matches.
+func (m *GlobMatcher) Pattern(relPath string) string {
+	if m == nil {
+		return ""
+	}
+	relPath = filepath.ToSlash(relPath)
+	if m.caseInsensitive {
+		relPath = strings.ToLower(relPath)
+	}
+	for i, pattern := range m.prepared {
+		// Patterns are validated, so matching cannot fail
+		if match, _ := doublestar.Match(pattern, relPath); match {
+			return m.patterns[i]
+		}
+	}
+	return ""
+}
+
+// fileEntry is the fs.DirEntry of a file outside a directory listing, such
+// as one reported by the watcher. It is stat'ed when its Info is first
+// asked for unless the info is already known.
+type fileEntry struct {
+	path string
+	info fs.FileInfo
+	err  error
+}
+
+// newFileEntry returns the entry of the file at path, with info if known.
+func newFileEntry(path string, info fs.FileInfo) *fileEntry {
+	return &fileEntry{path: path, info: info}
+}
+
+func (e *fileEntry) Name() string {
+	return filepath.Base(e.path)
+}
+
+func (e *fileEntry) IsDir() bool {
+	return e.info != nil && e.info.IsDir()
+}
+
+func (e *fileEntry) Type() fs.FileMode {
+	if e.info == nil {
+		return 0
+	}
+	return e.info.Mode().Type()
+}
+
+func (e *fileEntry) Info() (fs.FileInfo, error) {
+	if e.info == nil && e.err == nil {
+		e.info, e.err = os.Stat(e.path)
+	}
+	return e.info, e.err
+}
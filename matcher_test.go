@@ -0,0 +1,171 @@
+package mirrortransform
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestGlobMatcher tests matching prepared glob patterns.
+func TestGlobMatcher(t *testing.T) {
+	t.Parallel()
+	if _, err := NewGlobMatcher([]string{"[unclosed"}, false); err == nil {
+		t.Error("Expected an invalid pattern to be rejected")
+	}
+
+	tests := []struct {
+		name            string
+		caseInsensitive bool
+		relPath         string
+		expected        string
+	}{
+		{"First match", false, "a/b.jpg", "**/*.jpg"},
+		{"Second pattern", false, "docs/c.png", "docs/*.png"},
+		{"No match", false, "c.png", ""},
+		{"Case", false, "a/B.JPG", ""},
+		{"Case ignored", true, "a/B.JPG", "**/*.jpg"},
+		{"Backslashes", false, filepath.Join("docs", "c.png"), "docs/*.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewGlobMatcher([]string{"**/*.jpg", "docs/*.png"}, tt.caseInsensitive)
+			if err != nil {
+				t.Fatalf("NewGlobMatcher failed: %v", err)
+			}
+			if got := m.Pattern(tt.relPath); got != tt.expected {
+				t.Errorf("Pattern(%q) = %q, expected %q", tt.relPath, got, tt.expected)
+			}
+			if got := m.Match(tt.relPath, nil); got != (tt.expected != "") {
+				t.Errorf("Match(%q) = %v", tt.relPath, got)
+			}
+		})
+	}
+}
+
+// TestMatcher tests that a custom Matcher replaces Patterns, is given the
+// file's entry, and leaves exclusions in place.
+func TestMatcher(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"allowed/a.jpg", "allowed/b.txt", "other/c.jpg", "allowed/skip/d.jpg"})
+
+	allowlist := map[string]bool{"allowed/a.jpg": true, "allowed/b.txt": true, "allowed/skip/d.jpg": true}
+	var mu sync.Mutex
+	var processed []string
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		ExcludePatterns: []string{"**/skip"},
+		Matcher: MatcherFunc(func(relPath string, d fs.DirEntry) bool {
+			info, err := d.Info()
+			if err != nil || info.Size() != int64(len("test content")) {
+				t.Errorf("Expected the entry of %s to describe the file, got %v", relPath, err)
+			}
+			return allowlist[relPath]
+		}),
+		TaskCallback: func(task Task) (bool, error) {
+			if task.Pattern != matcherPattern {
+				t.Errorf("Expected the matcher as the pattern, got %q", task.Pattern)
+			}
+			mu.Lock()
+			processed = append(processed, filepath.ToSlash(task.RelPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sort.Strings(processed)
+	if strings.Join(processed, ",") != "allowed/a.jpg,allowed/b.txt" {
+		t.Errorf("Expected only the allowed files, got %v", processed)
+	}
+}
+
+// TestFilter tests that Filter skips matching files by their stat info
+// before they are queued, in crawls and for watch events.
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"sub/small.jpg", "large.jpg", "c.png"})
+	if err := os.WriteFile(filepath.Join(inputDir, "large.jpg"), make([]byte, 1024), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	var mu sync.Mutex
+	var filtered, processed []string
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		Filter: func(relPath string, info fs.FileInfo) bool {
+			mu.Lock()
+			filtered = append(filtered, relPath)
+			mu.Unlock()
+			return info.Size() < 1024
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			rel, err := filepath.Rel(inputDir, inputPath)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			processed = append(processed, filepath.ToSlash(rel))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := instance.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sort.Strings(filtered)
+	if strings.Join(filtered, ",") != "large.jpg,sub/small.jpg" {
+		t.Errorf("Expected only matching files to be filtered, got %v", filtered)
+	}
+	if strings.Join(processed, ",") != "sub/small.jpg" {
+		t.Errorf("Expected only the small file to be processed, got %v", processed)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "output", "large.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected no output for the skipped file")
+	}
+
+	// Watch events are filtered alike
+	mt := instance.(*mirrorTransform)
+	taskChan := make(chan fileTask, 10)
+	for _, name := range []string{"large.jpg", "sub/small.jpg"} {
+		event := fsnotify.Event{Name: filepath.Join(inputDir, filepath.FromSlash(name)), Op: fsnotify.Write}
+		if err := mt.processWatchEvent(context.Background(), nil, nil, event, taskChan); err != nil {
+			t.Fatalf("processWatchEvent failed for %q: %v", name, err)
+		}
+	}
+	close(taskChan)
+	var queued []string
+	for task := range taskChan {
+		queued = append(queued, filepath.ToSlash(task.RelPath))
+	}
+	if strings.Join(queued, ",") != "sub/small.jpg" {
+		t.Errorf("Expected only the small file to be queued, got %v", queued)
+	}
+}
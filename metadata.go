@@ -0,0 +1,53 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"os"
+)
+
+// preserveMetadata applies the input's modification time and permission bits
+// to the outputs the callback produced, as configured by PreserveTimes and
+// PreserveMode. Outputs the callback did not write are left alone.
+func (mt *mirrorTransform) preserveMetadata(task fileTask, outputs []taskOutput) error {
+	if !mt.config.PreserveTimes && !mt.config.PreserveMode {
+		return nil
+	}
+
+	info, err := os.Stat(task.InputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", task.InputPath, err)
+	}
+
+	for _, output := range outputs {
+		outputInfo, err := os.Stat(output.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stat output %q: %w", output.path, err)
+		}
+		if !outputInfo.Mode().IsRegular() {
+			continue
+		}
+		if err := applyModeAndTimes(task.InputPath, output.path, info, mt.config.PreserveMode, mt.config.PreserveTimes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyModeAndTimes sets the permission bits and modification time of path
+// to those of the input described by info.
+func applyModeAndTimes(inputPath, path string, info os.FileInfo, mode, times bool) error {
+	if mode {
+		if err := os.Chmod(path, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return fmt.Errorf("failed to preserve mode of %q: %w", inputPath, err)
+		}
+	}
+	if times {
+		if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to preserve modification time of %q: %w", inputPath, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestPreserveMetadata tests that outputs receive the input's mtime and mode.
+func TestPreserveMetadata(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		preserveTimes bool
+		preserveMode  bool
+	}{
+		{"Times", true, false},
+		{"Mode", false, true},
+		{"Both", true, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			outputDir := filepath.Join(testDir, "output")
+
+			createTestFiles(t, inputDir, []string{"a.jpg", "skipped.jpg"})
+			inputPath := filepath.Join(inputDir, "a.jpg")
+			mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := os.Chtimes(inputPath, mtime, mtime); err != nil {
+				t.Fatalf("Failed to set mtime: %v", err)
+			}
+			if err := os.Chmod(inputPath, 0600); err != nil {
+				t.Fatalf("Failed to set mode: %v", err)
+			}
+
+			config := Config{
+				InputDir:      inputDir,
+				OutputDir:     outputDir,
+				Patterns:      []string{"**/*.jpg"},
+				PreserveTimes: tt.preserveTimes,
+				PreserveMode:  tt.preserveMode,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					// Outputs that are not written must be tolerated
+					if filepath.Base(inputPath) == "skipped.jpg" {
+						return true, nil
+					}
+					return true, os.WriteFile(outputPath, []byte("out"), 0644)
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			info, err := os.Stat(filepath.Join(outputDir, "a.jpg"))
+			if err != nil {
+				t.Fatalf("Failed to stat output: %v", err)
+			}
+			if got := info.ModTime().Equal(mtime); got != tt.preserveTimes {
+				t.Errorf("Expected mtime preserved: %v, got mtime %v", tt.preserveTimes, info.ModTime())
+			}
+			if got := info.Mode().Perm() == 0600; got != tt.preserveMode {
+				t.Errorf("Expected mode preserved: %v, got mode %v", tt.preserveMode, info.Mode().Perm())
+			}
+		})
+	}
+}
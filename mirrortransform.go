@@ -2,14 +2,22 @@ package mirrortransform
 
 import (
 	"context"
-	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // FileCallback is called for each file that matches the pattern.
 // inputPath is the full path of the source file.
 // outputPath is the full path where the output should be written.
-// The directory for outputPath is guaranteed to exist.
+// The directory for outputPath is guaranteed to exist, unless
+// LazyOutputDirs leaves creating it to the callback.
 // If continueProcessing is false, the crawl will stop.
 type FileCallback func(inputPath, outputPath string) (continueProcessing bool, err error)
 
@@ -19,6 +27,25 @@ type FileCallback func(inputPath, outputPath string) (continueProcessing bool, e
 // If err is non-nil, it will be wrapped and returned from Crawl.
 type ErrorCallback func(path string, err error) (stop bool, retErr error)
 
+// TaskInfo describes a matched file waiting in the task queue.
+//
+// Deprecated: Use FileTask.
+type TaskInfo = FileTask
+
+// RewriteRule is a regular expression find/replace applied to relative paths.
+type RewriteRule struct {
+	// Pattern is a regular expression matched against the slash-separated
+	// relative path (e.g. "^raw/").
+	Pattern string `json:"pattern" yaml:"pattern"`
+
+	// Replacement is the replacement text. It may reference capture groups
+	// with $1 or ${name} as in regexp.Regexp.ReplaceAllString.
+	Replacement string `json:"replacement" yaml:"replacement"`
+}
+
+// TaskSorter reports whether task a should be processed before task b.
+type TaskSorter func(a, b FileTask) bool
+
 // Config holds the configuration for MirrorTransform.
 type Config struct {
 	// InputDir is the root directory to scan for files.
@@ -31,63 +58,668 @@ type Config struct {
 	// Example: []string{"**/*.jpg", "**/*.png"}
 	Patterns []string
 
+	// CaseInsensitivePatterns matches Patterns, ExcludePatterns,
+	// RegexPatterns, RegexExcludes and IgnoreErrorPatterns without regard to
+	// case, so "**/*.jpg" also matches "PHOTO.JPG" as it would on
+	// case-insensitive file systems.
+	CaseInsensitivePatterns bool
+
+	// UnicodeNormalization converts relative paths to a Unicode
+	// normalization form before they are matched and mapped to output paths,
+	// so names synced from macOS in NFD match patterns written in NFC and
+	// map to the same outputs. Inputs are still read by their names on disk.
+	UnicodeNormalization UnicodeNormalization
+
 	// ExcludePatterns are glob patterns for files/directories to exclude.
 	ExcludePatterns []string
 
+	// RegexPatterns are regular expressions matched against the relative
+	// path, with forward slashes, of files to process in addition to
+	// Patterns, for rules globs cannot express such as `^20\d{2}/\d{2}/`.
+	// Expressions are unanchored unless they use ^ and $.
+	RegexPatterns []string
+
+	// RegexExcludes are regular expressions for files/directories to exclude,
+	// matched like RegexPatterns. A directory is matched by its relative path
+	// without a trailing slash, and excluding it skips its whole tree.
+	RegexExcludes []string
+
+	// Matcher, if set, decides which files are processed in place of
+	// Patterns and RegexPatterns, e.g. with a database lookup or an
+	// allowlist. Exclusions and Overrides with Patterns still apply.
+	Matcher Matcher
+
+	// Filter, if set, is called with the stat info of every file the
+	// patterns or Matcher select, and skips the file if it returns false,
+	// e.g. to select files by owner or permission bits. Files are filtered
+	// before they are queued, so skipped files cost no callback or output
+	// directory. It is called from several goroutines at once.
+	Filter FilterFunc
+
+	// Overrides replace Patterns, add exclusions, limit concurrency and set
+	// transform parameters for the files below specific subtrees.
+	Overrides []Override
+
+	// MaxDepth limits how many levels below InputDir files are processed:
+	// 1 processes only the files directly in InputDir, 2 also those one
+	// directory down, and so on. Deeper directories are neither scanned nor
+	// watched. Zero means no limit.
+	MaxDepth int
+
+	// IncludeHidden processes hidden files and descends into hidden directories.
+	// By default, names starting with a dot (and, on Windows, files with the
+	// hidden attribute) are skipped in both Crawl and Watch.
+	IncludeHidden bool
+
+	// IgnoreFile is the name of a gitignore-style file in InputDir whose rules
+	// are merged with ExcludePatterns (e.g. DefaultIgnoreFile).
+	// Rules support "#" comments, "!" negation, trailing "/" for directories,
+	// and patterns containing "/" are anchored to the file's directory.
+	// Empty disables ignore files.
+	IgnoreFile string
+
+	// NestedIgnoreFiles also loads IgnoreFile from subdirectories.
+	// Rules in deeper files apply relative to their directory and take precedence.
+	NestedIgnoreFiles bool
+
+	// TempFilePatterns are glob patterns matched, without regard to case,
+	// against the base names of temporary files, such as partial uploads,
+	// editor backups and office lock files, whose watch events Watch and Run
+	// ignore. Empty uses DefaultTempFilePatterns.
+	TempFilePatterns []string
+
+	// WatchTempFiles processes the watch events of temporary files like any
+	// other, disabling TempFilePatterns.
+	WatchTempFiles bool
+
 	// Concurrency is the desired number of parallel file processors.
 	// The actual concurrency will be min(Concurrency, MaxConcurrency).
+	// AutoConcurrency adapts it to the measured throughput instead.
 	Concurrency int
 
 	// MaxConcurrency is the maximum allowed concurrency.
-	// Defaults to runtime.NumCPU() if not set.
+	// Defaults to runtime.NumCPU() if not set, or four times that with
+	// AutoConcurrency.
 	MaxConcurrency int
 
+	// MinConcurrency is the lower bound of AutoConcurrency, one if zero.
+	MinConcurrency int
+
+	// MaxInFlightBytes caps the total input size of the files being
+	// processed at a time, so a burst of huge files cannot exhaust memory
+	// while small files still run on every worker. Workers wait for room in
+	// the budget; a file larger than the budget runs alone. Zero disables
+	// the limit.
+	MaxInFlightBytes int64
+
+	// ConcurrencyGroups give the files matching their patterns workers of
+	// their own, in addition to the Concurrency workers shared by the other
+	// files, so slow transforms cannot starve fast ones. A file belongs to
+	// the first group it matches. They cannot be combined with Ordered.
+	ConcurrencyGroups []ConcurrencyGroup
+
+	// ScanConcurrency is the number of directories Crawl and Run read in
+	// parallel while scanning, which helps when listing directories is slow,
+	// e.g. on NFS. Zero or one scans sequentially in walk order.
+	ScanConcurrency int
+
+	// Sample processes only a subset of the matching files, e.g. 1% of
+	// them or the first few in each directory. Nil processes every match.
+	Sample *Sample
+
+	// ContentTypeFilter restricts processing to files whose content, sniffed
+	// from the first 512 bytes, has one of these media types (e.g. "image/jpeg"
+	// or "image/*"). It is applied after Patterns. Empty disables sniffing.
+	ContentTypeFilter []string
+
+	// Prefetch is the number of queued inputs to read ahead into the page cache
+	// while workers are busy, which improves throughput on high-latency disks.
+	// It uses posix_fadvise and has no effect on platforms other than Linux.
+	// Zero disables prefetching.
+	Prefetch int
+
+	// NoCacheThreshold evicts inputs of at least this many bytes, and their
+	// outputs, from the page cache after processing, so bulk runs over huge
+	// files don't push out the hot cache of other services on the machine.
+	// Only effective on Linux. Zero disables eviction.
+	NoCacheThreshold int64
+
+	// MaxOutputBytes caps the total size of outputs written per run.
+	// Once reached, no new tasks are dispatched, in-flight tasks finish, and
+	// Crawl returns a *QuotaExceededError listing the unprocessed inputs.
+	// Watch returns the error as soon as the quota is reached.
+	// Zero disables the quota.
+	MaxOutputBytes int64
+
+	// MaxFiles caps the number of files whose callback is started per
+	// Crawl, import or other finite run, e.g. to split a large backfill into
+	// bounded batches. Once reached, the scan stops, the matches already
+	// queued are skipped and listed in Result.Unprocessed, and the run ends
+	// without an error with Result.LimitReached set. Combine it with
+	// OnlyIfStale so the next crawl continues where this one stopped. Watch
+	// and Run ignore it. Zero disables the limit.
+	MaxFiles int
+
+	// AllowDestructive is the master switch for features that delete, move
+	// or overwrite existing outputs (RecoverPartialOutputs, MirrorDirRemovals,
+	// and TrackRenames without a RenameCallback). Validate fails with ErrDestructiveNotAllowed
+	// if one of them is enabled without it, so a copied configuration cannot
+	// silently enable deleting outputs.
+	AllowDestructive bool
+
+	// RecoverPartialOutputs writes a marker file (output path plus
+	// PartialMarkerSuffix) while each output is being produced; it is removed
+	// when the callback succeeds. On start, Crawl, Watch and Run delete outputs
+	// whose marker was left behind by a crash or failure, together with the
+	// marker, and process their inputs again. Requires AllowDestructive.
+	RecoverPartialOutputs bool
+
+	// MirrorDirRemovals removes the output directory of an input directory,
+	// with all its contents, when Watch or Run sees the input directory
+	// removed or renamed away; Run also removes those of input directories
+	// removed while it was not running when it starts. DirRemovalCallback
+	// can veto each removal. It cannot be combined with Flatten,
+	// RewriteRules or OutputPathFunc. Requires AllowDestructive.
+	MirrorDirRemovals bool
+
+	// DirRemovalCallback, if set, is asked before MirrorDirRemovals removes
+	// an output directory.
+	DirRemovalCallback DirRemovalCallback
+
+	// CleanStaleTempFiles removes the temporary files (named with the
+	// ".mirrortmp-" prefix) that writes interrupted by a crash left in the
+	// output directories, when Crawl, Watch or Run start. Files changed
+	// within the last minute are kept, as another process may still be
+	// writing them. Only the library's own temporary files are removed, so
+	// AllowDestructive is not required.
+	CleanStaleTempFiles bool
+
+	// StaleTempFileCallback, if set, is called with the path of every
+	// temporary file removed by CleanStaleTempFiles.
+	StaleTempFileCallback func(path string)
+
+	// GenerationFile is the name of a marker file in OutputDir (e.g.
+	// DefaultGenerationFile) that tells readers whether the output tree is
+	// being changed; see Generation and ReadConsistent. A crawl holds one
+	// generation from start to end; Watch and Run start one whenever a
+	// callback runs after a quiet period. Empty disables the marker.
+	GenerationFile string
+
+	// LockFile, if set, is an advisory lock file (e.g. DefaultLockFile) held
+	// while Crawl, Watch, Run, Replay or an import runs, so that a second
+	// process over the same tree, such as an overlapping cron crawl, fails
+	// with ErrLocked instead of processing files twice. Relative paths are
+	// resolved against OutputDir. Dry runs do not take the lock.
+	LockFile string
+
 	// FileCallback is called for each matching file.
+	// With Variants and no VariantCallback, it is called once per variant.
 	FileCallback FileCallback
 
+	// TaskCallback, if set, is called instead of FileCallback with a Task
+	// describing the file, including its FileInfo and the event that queued it.
+	TaskCallback TaskCallback
+
+	// DirCallback, if set, is called once per run for every directory below
+	// InputDir that a scan of Crawl, Run or a rescan enters or a watch
+	// starts watching, and every directory created while watching, unless
+	// it is hidden, ignored or excluded. It can prepare the output
+	// directory, e.g. write a per-folder manifest, or skip the directory
+	// with everything below it by returning false; watch events below
+	// skipped directories are ignored and ProcessFile refuses their files.
+	// It is not called in dry runs.
+	DirCallback DirCallback
+
+	// IdempotencyKeys hashes the content of every input before its callback
+	// runs and exposes the hash and an idempotency key derived from the
+	// relative path, the content and TransformVersion on Task, so side effects
+	// can be made exactly-once across retries and restarts.
+	IdempotencyKeys bool
+
+	// TransformVersion identifies the version of the transform logic. Change
+	// it when outputs should be produced again; it is part of idempotency keys.
+	TransformVersion string
+
+	// PreserveTimes sets the modification time of every output to the
+	// input's after the callback succeeds, so tools such as rsync don't see
+	// every output as newly modified.
+	PreserveTimes bool
+
+	// PreserveMode sets the permission bits of every output to the input's
+	// after the callback succeeds.
+	PreserveMode bool
+
+	// TrackRenames moves the outputs of a file renamed within InputDir to
+	// match its new name, instead of processing it again and leaving the old
+	// outputs behind. A move is recognized when the watcher reports the old
+	// name immediately followed by the new one. Without a RenameCallback,
+	// an output already at the new name is replaced, so this requires
+	// AllowDestructive.
+	TrackRenames bool
+
+	// RenameCallback, if set, is called to move each output of a renamed
+	// file instead of renaming it directly. Setting it enables TrackRenames.
+	RenameCallback RenameCallback
+
+	// ProcessExisting makes Watch queue the files already in InputDir once it
+	// watches, before handling events, so it catches up and then follows
+	// changes without a separate Crawl. Files already queued, e.g. recovered
+	// ones, are not queued twice. Combine it with OnlyIfStale to skip the
+	// files whose outputs are up to date.
+	ProcessExisting bool
+
+	// StabilityWindow, if positive, holds the files reported by the watcher
+	// until their size and modification time have not changed for this long,
+	// so files still being uploaded, e.g. over FTP or rsync, are not
+	// processed truncated. Files are checked every quarter of the window.
+	// Zero processes files on their first event.
+	StabilityWindow time.Duration
+
+	// IdlePeriod is how long Watch and Run must have received no watch
+	// event and had no file queued or in progress before IdleCallback is
+	// called, e.g. to flush or publish a batch. It is raised to twice
+	// StabilityWindow if that is longer.
+	IdlePeriod time.Duration
+
+	// IdleCallback, if set, is called once IdlePeriod passed without
+	// activity, and again after each later idle spell. Requires IdlePeriod.
+	IdleCallback IdleCallback
+
+	// RecursiveWatch watches the whole input tree with a native recursive
+	// backend instead of adding every directory separately. Only Windows
+	// has one (ReadDirectoryChangesW); macOS, Linux and other platforms fall
+	// back to per-directory watching.
+	RecursiveWatch bool
+
+	// PollUnwatched, if positive, is how often directory trees that cannot be
+	// watched because the platform watch limit is reached (e.g. inotify's
+	// max_user_watches) are polled for changes. The limit is reported to
+	// ErrorCallback. Zero makes Watch and Run fail with a *WatchLimitError.
+	// On platforms without a native watcher it is the interval the whole
+	// tree is polled at, two seconds if zero.
+	PollUnwatched time.Duration
+
+	// Variants produce several outputs per input (e.g. thumbnails and WebP
+	// copies). Each variant mirrors the tree under its own root instead of
+	// the plain output path.
+	Variants []Variant
+
+	// VariantCallback, if set, is called once per input with all variant
+	// output paths instead of calling FileCallback once per variant.
+	VariantCallback VariantCallback
+
+	// Shadow runs a candidate callback on every file and reports how its
+	// output compares to FileCallback's. Nil disables shadow mode.
+	Shadow *Shadow
+
+	// RunLabels tag every run of this instance (e.g. "nightly" or
+	// "backfill-2024-06"). They are copied into each Result, RecordedEvent
+	// and Tombstone so histories can be filtered per purpose when several
+	// job types share one configuration.
+	RunLabels []string
+
+	// EventLog, if set, receives every watch event as a JSON line
+	// (see RecordedEvent) so the stream can be replayed later with Replay,
+	// e.g. to reproduce a problem seen in production.
+	EventLog io.Writer
+
+	// TombstoneLog, if set, receives a JSON line (see Tombstone) for every
+	// matching input that Watch or Run sees removed or renamed away, so
+	// downstream systems can purge what disappeared. Outputs are left in place.
+	TombstoneLog io.Writer
+
+	// Webhooks notify HTTP endpoints when a crawl finishes, when failures in
+	// a run reach a threshold, or when Watch or Run stops unexpectedly.
+	Webhooks []Webhook
+
 	// ErrorCallback is called when errors occur during traversal.
 	// If nil, errors will cause Crawl to return immediately.
 	ErrorCallback ErrorCallback
+
+	// OverflowCallback, if set, is told when the watcher of Watch or Run
+	// lost events, e.g. because the kernel's event queue overflowed. The
+	// affected tree is rescanned either way, so no file is missed for good.
+	OverflowCallback OverflowCallback
+
+	// ResultCallback, if set, is called after each matched file with whether
+	// it was processed, skipped or failed, how long the callback took and how
+	// many bytes were read and written.
+	ResultCallback ResultCallback
+
+	// ErrorThrottle collapses repeated identical errors into periodic
+	// summaries instead of calling ErrorCallback for each. Nil disables it.
+	ErrorThrottle *ErrorThrottle
+
+	// Logger receives structured logs: watch registration and task dispatch
+	// at debug level, skipped and retried files, run summaries and shutdown
+	// progress. Nil disables logging.
+	Logger *slog.Logger
+
+	// TracerProvider, if set, traces runs with OpenTelemetry: Crawl,
+	// CrawlWithResult, CrawlPaths, RetryFailed, ImportList, ImportTar and
+	// CrawlArchive start a span per run, and every file whose callback runs
+	// gets a child span (of the run, or of the span in the context passed to
+	// Watch and Run) that is also in Task.Context. Nil disables tracing.
+	TracerProvider trace.TracerProvider
+
+	// ContinueOnError keeps processing other files when a callback fails.
+	// Crawl and Replay return the failures at the end as a joined error of
+	// FileError values, usable with errors.Is and errors.As. Watch and Run
+	// pass each failure to ErrorCallback instead, if set.
+	ContinueOnError bool
+
+	// IgnoreErrorPatterns are glob patterns for paths whose errors are always
+	// skipped without calling ErrorCallback (e.g. "lost+found/**"), so known
+	// noise doesn't mask new errors.
+	IgnoreErrorPatterns []string
+
+	// FailureBackoff skips paths that failed recently, doubling the delay after
+	// each consecutive failure, and optionally parks them for good.
+	// Failure history is kept in StateStore. Nil disables backoff.
+	FailureBackoff *FailureBackoff
+
+	// QuarantineDir receives a copy of every input whose callback failed for
+	// good, at its relative path, with the error recorded beside it in a file
+	// named after the input plus QuarantineErrorSuffix. A failure is final
+	// when FailureBackoff parks the path, or at once without FailureBackoff.
+	// A later success removes both files. Empty disables quarantine.
+	QuarantineDir string
+
+	// RetryQueue records in StateStore every file whose callback failed, and
+	// every matched file a run deferred, such as those skipped once
+	// MaxOutputBytes was reached. RetryFailed processes them again, and so
+	// does Watch when it starts, until they succeed. The store must
+	// implement StateLister.
+	RetryQueue bool
+
+	// StateStore records per-path processing state across runs.
+	// Defaults to an in-memory store; use NewFileStateStore to persist state
+	// across process restarts.
+	StateStore StateStore
+
+	// TaskSorter orders queued tasks instead of processing them in walk order.
+	// Crawl starts dispatching once the scan is complete so the whole run
+	// follows the order; Watch reorders whatever tasks are pending, up to
+	// 10,000 at a time.
+	// Use SmallestFirst, NewestFirst or a custom comparator.
+	TaskSorter TaskSorter
+
+	// Ordered makes runs deterministic for reproducible builds: files are
+	// processed one at a time in TaskSorter order, or sorted by relative
+	// path (ByPath) if no TaskSorter is set, so callbacks start and complete
+	// in a stable order. Concurrency is ignored. It cannot be combined with
+	// LatencyObjective, which reorders files by how long they waited.
+	Ordered bool
+
+	// PriorityHints dispatch files marked by a sidecar file or a name prefix
+	// before other pending files. Nil disables priority hints.
+	PriorityHints *PriorityHints
+
+	// LatencyObjective declares how soon every queued file should be
+	// processed. Files waiting for half the target are dispatched ahead of
+	// others, and late files are reported. Nil disables it.
+	LatencyObjective *LatencyObjective
+
+	// ReadOnlyOutput pauses processing while the output file system is
+	// read-only and resumes once it is writable again. Nil fails tasks instead.
+	ReadOnlyOutput *ReadOnlyOutput
+
+	// ShutdownGracePeriod is how long callbacks still running when the
+	// context of Crawl, Watch or Run is cancelled may continue before
+	// Task.Context is cancelled. No new files are started meanwhile, and the
+	// call returns once every callback has returned. Zero cancels
+	// Task.Context immediately.
+	ShutdownGracePeriod time.Duration
+
+	// ShutdownCallback, if set, reports the callbacks still running during
+	// the shutdown grace period.
+	ShutdownCallback ShutdownCallback
+
+	// Flatten places every output directly in OutputDir instead of mirroring
+	// the directory structure. Each name gets a hash suffix derived from the
+	// relative path (e.g. "photo-3f2a9c1b7d04.jpg") so that files with the
+	// same name in different directories never collide.
+	Flatten bool
+
+	// MirrorEmptyDirs creates every directory scanned by Crawl and Run, and
+	// every directory created while watching, in OutputDir even if it holds
+	// no matching files, for tools that expect the complete tree. OutputNames
+	// apply to directory names; RewriteRules do not. It cannot be combined
+	// with Flatten.
+	MirrorEmptyDirs bool
+
+	// LazyOutputDirs leaves creating the directory of each output to the
+	// callback instead of creating it before the callback is called, so
+	// callbacks that skip files leave no empty directories behind. Callbacks
+	// call EnsureOutputDir before writing; the built-in copy and link helpers
+	// create it themselves. It cannot be combined with MirrorEmptyDirs or
+	// RecoverPartialOutputs, whose markers need the directory.
+	LazyOutputDirs bool
+
+	// OutputNames normalizes the file and directory names of outputs, e.g.
+	// NameMappingLower for destinations where names differing only in case
+	// cause trouble. Names that would collide with another entry of the same
+	// input directory get a hash suffix. It is applied before RewriteRules.
+	// NameMappingNone keeps names unchanged.
+	OutputNames NameMapping
+
+	// RewriteRules are applied in order to the relative path before it is
+	// mapped into OutputDir. Matching still uses the original relative path.
+	// Example: []RewriteRule{{Pattern: "^raw/", Replacement: ""}}
+	RewriteRules []RewriteRule
+
+	// OutputPathFunc, if set, maps the relative path, after OutputNames and
+	// RewriteRules, to the path of the output relative to OutputDir, e.g.
+	// "photo.jpg" to "photo.webp", so callbacks receive the final name.
+	// Flatten applies to the result, which must stay inside OutputDir.
+	OutputPathFunc func(relPath string) string
+
+	// OnlyIfStale skips files whose outputs all exist and are at least as
+	// new as the input, like make, without a state store. Combined with
+	// OutputPathFunc it compares against the mapped outputs.
+	OnlyIfStale bool
 }
 
 // MirrorTransform provides functionality to mirror files from one directory
 // to another while maintaining the directory structure.
 type MirrorTransform interface {
 	// Crawl traverses the input directory and processes matching files.
-	// It respects the context for cancellation.
-	Crawl(ctx context.Context) error
+	// It respects the context for cancellation. Options such as WithDryRun
+	// or WithSubdir apply to this call only.
+	Crawl(ctx context.Context, opts ...RunOption) error
+
+	// CrawlWithResult is like Crawl and also returns a summary of what happened.
+	CrawlWithResult(ctx context.Context, opts ...RunOption) (*Result, error)
 
 	// Watch monitors the input directory for changes and processes new/modified files.
 	// This method blocks until the context is cancelled.
-	Watch(ctx context.Context) error
+	Watch(ctx context.Context, opts ...RunOption) error
+
+	// Run crawls the existing files and then watches for changes without a gap
+	// in between, processing each version of a file once.
+	// This method blocks until the context is cancelled.
+	Run(ctx context.Context, opts ...RunOption) error
+
+	// Schedule crawls the input repeatedly, at an interval such as "5m" or
+	// the times of a cron expression, skipping files unchanged since the
+	// previous crawl. This method blocks until the context is cancelled.
+	Schedule(ctx context.Context, spec string, opts ...RunOption) error
+
+	// ImportList processes the files listed in r, one path relative to
+	// InputDir per line, instead of scanning InputDir.
+	ImportList(ctx context.Context, r io.Reader, opts ...RunOption) (*Result, error)
+
+	// ImportTar extracts the matching files of a tar stream into InputDir and
+	// processes them as they are extracted.
+	ImportTar(ctx context.Context, r io.Reader, opts ...RunOption) (*Result, error)
+
+	// CrawlPaths processes only the given files and directory trees below
+	// InputDir, mapping them to their usual outputs.
+	CrawlPaths(ctx context.Context, relPaths ...string) (*Result, error)
+
+	// RetryFailed processes the files in the retry queue of RetryQueue
+	// again, past any failure backoff. Archive entries are left to
+	// CrawlArchive.
+	RetryFailed(ctx context.Context, opts ...RunOption) (*Result, error)
+
+	// CrawlArchive processes the entries of a zip or tar archive as if they
+	// were the files of InputDir, writing outputs to OutputDir.
+	CrawlArchive(ctx context.Context, archivePath string, opts ...RunOption) (*Result, error)
+
+	// ProcessFile runs the pipeline of a crawl for a single file inside
+	// InputDir and returns once its callback has finished.
+	ProcessFile(ctx context.Context, inputPath string) error
+
+	// Handler returns an http.Handler serving OutputDir that transforms the
+	// input of a missing output on request before serving it.
+	Handler(opts HandlerOptions) http.Handler
+
+	// Replay processes the watch events recorded with Config.EventLog as if
+	// they had just happened, then returns.
+	Replay(ctx context.Context, r io.Reader, opts ReplayOptions) error
+
+	// UpdateConfig changes patterns, exclusions and concurrency of the
+	// instance, including runs in progress. Watch and Run start watching
+	// directories that are no longer excluded.
+	UpdateConfig(update ConfigUpdate) error
+
+	// Reconfigure replaces the configuration while runs are in progress.
+	// Only patterns, exclusions and concurrency may differ; running watchers
+	// process the existing files the new configuration includes.
+	Reconfigure(newConfig Config) error
+
+	// Rescan makes every Watch and Run in progress scan its tree again
+	// without stopping, to pick up changes the watcher missed.
+	Rescan(ctx context.Context) error
+
+	// QueueStats returns the number of pending and in-flight tasks and the
+	// state of every worker, e.g. to see whether a watcher keeps up.
+	QueueStats() QueueStats
+
+	// Pause stops dispatching tasks, e.g. during a maintenance window of a
+	// downstream service. Running callbacks finish, and the watcher keeps
+	// queueing changed files until Resume is called.
+	Pause()
+
+	// Resume dispatches the tasks queued while paused and continues normally.
+	Resume()
+
+	// SelfTest verifies that the input is readable, the output is writable,
+	// the FileCallback works on a sample input, and the watcher delivers events.
+	// It is suitable as a readiness probe before starting a long-running Watch.
+	SelfTest(ctx context.Context) error
+
+	// Verify compares the input and output trees without changing them and
+	// reports inputs with missing outputs, outputs without an input and,
+	// with VerifyOptions.Hash, outputs whose content differs.
+	Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error)
+
+	// Orphans lists the output files that no matching input maps to,
+	// without deleting them, e.g. to review before enabling a destructive
+	// feature.
+	Orphans(ctx context.Context) ([]string, error)
+
+	// UnwatchedDirs returns the directories Watch and Run could not watch,
+	// for lack of permission or a transient error, and missed changes in.
+	UnwatchedDirs() []string
 }
 
 // mirrorTransform is the concrete implementation of MirrorTransform.
 type mirrorTransform struct {
-	config Config
+	config       Config
+	rewriteRules []compiledRewriteRule
+	regexes      *regexRules
+	ignore       *ignoreMatcher
+	failures     *failureTracker
+	sampler      *sampler
+	recorder     *eventRecorder
+	throttler    *errorThrottler
+	renames      *renameTracker
+	dirVetoes    *dirVetoes
+	outputGate   *outputGate
+	tombstones   *tombstoneLog
+	generation   *generationMarker
+	lock         *instanceLock
+	pause        *pauseControl
+	names        *nameIndex
+	overrides    *overrideSet
+	tracing      *tracing
+	webhooks     *webhookNotifier
+	onDemand     *onDemand
+	workers      *workerRegistry
+	budget       *byteBudget
+	unwatched    *unwatchedDirs
+
+	// tuneInterval is how often AutoConcurrency resizes worker pools.
+	tuneInterval time.Duration
+
+	// settings are the settings UpdateConfig can change.
+	settings atomic.Pointer[runtimeSettings]
+
+	// updateMu guards pools, updateSubs and rescanSubs and serializes
+	// updates.
+	updateMu   sync.Mutex
+	pools      map[*workerPool]struct{}
+	updateSubs map[chan *settingsChange]struct{}
+	rescanSubs map[*rescanSub]struct{}
 }
 
 // NewMirrorTransform creates a new MirrorTransform instance with the given configuration.
 func NewMirrorTransform(config *Config) (MirrorTransform, error) {
 	// Validate configuration
-	if config.InputDir == "" {
-		return nil, fmt.Errorf("input directory is required")
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
-	if config.OutputDir == "" {
-		return nil, fmt.Errorf("output directory is required")
+
+	// Compile rewrite rules and regular expression patterns once
+	rewriteRules, err := compileRewriteRules(config.RewriteRules)
+	if err != nil {
+		return nil, err
 	}
-	if len(config.Patterns) == 0 {
-		return nil, fmt.Errorf("at least one pattern is required")
+	regexes, err := compileRegexRules(config)
+	if err != nil {
+		return nil, err
 	}
-	if config.FileCallback == nil {
-		return nil, fmt.Errorf("file callback is required")
+
+	// Parse webhook templates once
+	webhooks, err := newWebhookNotifier(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Clean paths to ensure consistent handling
 	config.InputDir = filepath.Clean(config.InputDir)
 	config.OutputDir = filepath.Clean(config.OutputDir)
 
-	return &mirrorTransform{
-		config: *config,
-	}, nil
+	mt := &mirrorTransform{
+		config:       *config,
+		rewriteRules: rewriteRules,
+		regexes:      regexes,
+		ignore:       newIgnoreMatcher(config),
+		failures:     newFailureTracker(config),
+		sampler:      newSampler(config),
+		recorder:     newEventRecorder(config),
+		throttler:    newErrorThrottler(config),
+		renames:      newRenameTracker(config),
+		dirVetoes:    newDirVetoes(config),
+		outputGate:   newOutputGate(config),
+		tombstones:   newTombstoneLog(config),
+		generation:   newGenerationMarker(config),
+		lock:         newInstanceLock(config),
+		pause:        newPauseControl(),
+		names:        newNameIndex(config),
+		overrides:    newOverrideSet(config),
+		tracing:      newTracing(config),
+		webhooks:     webhooks,
+		onDemand:     newOnDemand(),
+		tuneInterval: defaultTuneInterval,
+		workers:      newWorkerRegistry(),
+		budget:       newByteBudget(config),
+		unwatched:    newUnwatchedDirs(),
+		pools:        make(map[*workerPool]struct{}),
+		updateSubs:   make(map[chan *settingsChange]struct{}),
+		rescanSubs:   make(map[*rescanSub]struct{}),
+	}
+	mt.settings.Store(newRuntimeSettings(config))
+	return mt, nil
 }
@@ -0,0 +1,194 @@
+package mirrortransform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NameMapping is a policy for rewriting the file and directory names of
+// outputs, set with Config.OutputNames.
+type NameMapping string
+
+const (
+	// NameMappingNone keeps names as they are.
+	NameMappingNone NameMapping = ""
+
+	// NameMappingLower lowercases names, e.g. "Photo.JPG" becomes "photo.jpg".
+	NameMappingLower NameMapping = "lower"
+
+	// NameMappingSlug lowercases names and replaces every run of characters
+	// other than ASCII letters, digits, ".", "_" and "-" with "-", e.g.
+	// "My Photo (1).JPG" becomes "my-photo-1.jpg".
+	NameMappingSlug NameMapping = "slug"
+)
+
+// nameHashLength is the number of hex characters of the suffix added to
+// mapped names that would collide.
+const nameHashLength = 8
+
+// validateNameMapping checks the output name policy.
+func validateNameMapping(mapping NameMapping) error {
+	switch mapping {
+	case NameMappingNone, NameMappingLower, NameMappingSlug:
+		return nil
+	}
+	return fmt.Errorf("unknown output name mapping %q: use %q or %q", mapping, NameMappingLower, NameMappingSlug)
+}
+
+// mapName applies mapping to a single file or directory name.
+func mapName(name string, mapping NameMapping) string {
+	switch mapping {
+	case NameMappingLower:
+		return strings.ToLower(name)
+	case NameMappingSlug:
+		return slugName(name)
+	}
+	return name
+}
+
+// slugName lowercases name and replaces unsafe characters with "-".
+// The extension is kept apart so "a (1).jpg" does not end in "-.jpg".
+func slugName(name string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	stem := slugPart(strings.TrimSuffix(name, ext))
+	ext = slugPart(ext)
+
+	// Names made only of unsafe characters still need a stable stem
+	if stem == "" {
+		stem = nameHash(name)
+	}
+	return stem + ext
+}
+
+// slugPart lowercases s, replaces runs of unsafe characters with "-" and
+// trims "-" from both ends.
+func slugPart(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-' {
+			b.WriteRune(r)
+			dash = r == '-'
+			continue
+		}
+		if !dash {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// nameHash returns a short hash of an original name.
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:nameHashLength]
+}
+
+// suffixName adds a hash of the original name to a mapped name, before its
+// extension.
+func suffixName(mapped, original string) string {
+	ext := filepath.Ext(mapped)
+	if ext == mapped {
+		ext = ""
+	}
+	return strings.TrimSuffix(mapped, ext) + "-" + nameHash(original) + ext
+}
+
+// nameIndex caches, per input directory, how many entries map to each name
+// under Config.OutputNames. Entries are refreshed when the directory's
+// modification time changes.
+type nameIndex struct {
+	mapping NameMapping
+
+	mu   sync.Mutex
+	dirs map[string]nameIndexEntry
+}
+
+// nameIndexEntry is the cached index of one directory.
+type nameIndexEntry struct {
+	modTime time.Time
+	counts  map[string]int
+}
+
+// newNameIndex returns an index if OutputNames is set, or nil.
+func newNameIndex(config *Config) *nameIndex {
+	if config.OutputNames == NameMappingNone {
+		return nil
+	}
+	return &nameIndex{mapping: config.OutputNames, dirs: make(map[string]nameIndexEntry)}
+}
+
+// collides reports whether an entry of dir other than name maps to mapped.
+func (x *nameIndex) collides(dir, name, mapped string) (bool, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to stat %q: %w", dir, err)
+	}
+
+	x.mu.Lock()
+	entry, ok := x.dirs[dir]
+	x.mu.Unlock()
+
+	if !ok || !entry.modTime.Equal(info.ModTime()) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false, fmt.Errorf("failed to read directory %q: %w", dir, err)
+		}
+		entry = nameIndexEntry{modTime: info.ModTime(), counts: make(map[string]int, len(entries))}
+		for _, e := range entries {
+			entry.counts[mapName(e.Name(), x.mapping)]++
+		}
+
+		x.mu.Lock()
+		x.dirs[dir] = entry
+		x.mu.Unlock()
+	}
+
+	// name itself counts once if it is still present
+	others := entry.counts[mapped]
+	if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+		others--
+	}
+	return others > 0, nil
+}
+
+// mapOutputNames applies Config.OutputNames to every component of relPath.
+// A name whose mapping would collide with that of another entry in the same
+// input directory gets a hash suffix; names the mapping leaves unchanged
+// keep them.
+func (mt *mirrorTransform) mapOutputNames(relPath string) (string, error) {
+	if mt.names == nil {
+		return relPath, nil
+	}
+
+	parts := strings.Split(relPath, string(filepath.Separator))
+	dir := mt.config.InputDir
+	for i, part := range parts {
+		mapped := mapName(part, mt.names.mapping)
+		if mapped != part {
+			collides, err := mt.names.collides(dir, part, mapped)
+			if err != nil {
+				return "", err
+			}
+			if collides {
+				mapped = suffixName(mapped, part)
+			}
+		}
+		dir = filepath.Join(dir, part)
+		parts[i] = mapped
+	}
+	return filepath.Join(parts...), nil
+}
@@ -0,0 +1,92 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestMapName tests the output name policies.
+func TestMapName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		mapping  NameMapping
+		input    string
+		expected string
+	}{
+		{name: "None", mapping: NameMappingNone, input: "Photo.JPG", expected: "Photo.JPG"},
+		{name: "Lower", mapping: NameMappingLower, input: "My Photo.JPG", expected: "my photo.jpg"},
+		{name: "Slug", mapping: NameMappingSlug, input: "My Photo (1).JPG", expected: "my-photo-1.jpg"},
+		{name: "SlugDirectory", mapping: NameMappingSlug, input: "Summer 2024", expected: "summer-2024"},
+		{name: "SlugKeepsSafeCharacters", mapping: NameMappingSlug, input: "a_b-c.tar.gz", expected: "a_b-c.tar.gz"},
+		{name: "SlugUnsafeOnly", mapping: NameMappingSlug, input: "写真.jpg", expected: nameHash("写真.jpg") + ".jpg"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := mapName(tt.input, tt.mapping); got != tt.expected {
+				t.Errorf("mapName(%q, %q) = %q, want %q", tt.input, tt.mapping, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestOutputNames tests that crawled outputs get mapped names and that
+// colliding names get distinct suffixes.
+func TestOutputNames(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"photo.jpg", "Photo.jpg", "Summer Trip/IMG 01.JPG"})
+	entries, err := os.ReadDir(inputDir)
+	if err != nil {
+		t.Fatalf("Failed to read input directory: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Skip("file system is not case-sensitive")
+	}
+
+	var mu sync.Mutex
+	var outputs []string
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg", "**/*.JPG"},
+		OutputNames: NameMappingSlug,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, err := filepath.Rel(outputDir, outputPath)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			outputs = append(outputs, filepath.ToSlash(relPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sort.Strings(outputs)
+	expected := []string{"photo-" + nameHash("Photo.jpg") + ".jpg", "photo.jpg", "summer-trip/img-01.jpg"}
+	if strings.Join(outputs, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected outputs %v, got %v", expected, outputs)
+	}
+}
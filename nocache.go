@@ -0,0 +1,15 @@
+package mirrortransform
+
+// evictFromCache drops the task's input and outputs from the page cache when
+// the input is at least NoCacheThreshold bytes. Eviction is best effort.
+func (mt *mirrorTransform) evictFromCache(task fileTask, outputs []taskOutput) {
+	if mt.config.NoCacheThreshold <= 0 || task.info == nil || task.info.Size() < mt.config.NoCacheThreshold {
+		return
+	}
+
+	dropFromCache(task.InputPath, false)
+	// Outputs must be written back before their pages can be dropped
+	for _, output := range outputs {
+		dropFromCache(output.path, true)
+	}
+}
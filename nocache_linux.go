@@ -0,0 +1,25 @@
+//go:build linux
+
+package mirrortransform
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropFromCache asks the kernel to evict path from the page cache.
+// If flush is true, dirty pages are written back first so they can be dropped.
+func dropFromCache(path string, flush bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fd := int(file.Fd())
+	if flush {
+		_ = unix.Fdatasync(fd)
+	}
+	_ = unix.Fadvise(fd, 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package mirrortransform
+
+// dropFromCache is a no-op on platforms without posix_fadvise.
+func dropFromCache(_ string, _ bool) {}
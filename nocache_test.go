@@ -0,0 +1,53 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCrawlNoCacheThreshold tests that cache eviction leaves outputs intact.
+func TestCrawlNoCacheThreshold(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"small.jpg", "dir/large.jpg"})
+	if err := os.WriteFile(filepath.Join(inputDir, "dir", "large.jpg"), make([]byte, 64*1024), 0644); err != nil {
+		t.Fatalf("Failed to create large file: %v", err)
+	}
+
+	config := Config{
+		InputDir:         inputDir,
+		OutputDir:        outputDir,
+		Patterns:         []string{"**/*.jpg"},
+		Concurrency:      2,
+		NoCacheThreshold: 1024,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			data, err := os.ReadFile(inputPath)
+			if err != nil {
+				return false, err
+			}
+			return true, os.WriteFile(outputPath, data, 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(outputDir, "dir", "large.jpg"))
+	if err != nil {
+		t.Fatalf("Large output missing: %v", err)
+	}
+	if info.Size() != 64*1024 {
+		t.Errorf("Expected large output of %d bytes, got %d", 64*1024, info.Size())
+	}
+}
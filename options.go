@@ -0,0 +1,130 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RunOption adjusts a single call of Crawl, CrawlWithResult, Watch or Run
+// without changing the instance's Config.
+type RunOption func(*runOptions)
+
+// runOptions holds the settings made by RunOptions for one run.
+type runOptions struct {
+	dryRun bool
+	subdir string
+	force  bool
+	ready  chan<- struct{}
+
+	// metadata is attached to every task with WithMetadata.
+	metadata map[string]any
+}
+
+// WithDryRun matches files as usual but calls no callback and writes
+// nothing, not even the cleanup of partial outputs. Matched files are
+// counted as skipped in the Result.
+func WithDryRun() RunOption {
+	return func(o *runOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithSubdir limits the run to the subtree dir, given relative to InputDir
+// (e.g. "2024/"). Relative paths and output paths are unchanged.
+func WithSubdir(dir string) RunOption {
+	return func(o *runOptions) {
+		o.subdir = dir
+	}
+}
+
+// WithForce processes files that FailureBackoff would skip because they
+// failed recently or were parked.
+func WithForce() RunOption {
+	return func(o *runOptions) {
+		o.force = true
+	}
+}
+
+// WithReady makes Watch and Run close ready once every initial directory
+// watch is registered, so files created afterwards are seen. The channel is
+// not closed if the call fails before that; other calls ignore it.
+func WithReady(ready chan<- struct{}) RunOption {
+	return func(o *runOptions) {
+		o.ready = ready
+	}
+}
+
+// WithMetadata attaches value under key to every task of the run, e.g. a
+// request ID, tenant ID or feature flag. TaskCallback reads it from
+// Task.Metadata; code that only has the task's context uses MetadataValue.
+// The option may be given several times.
+func WithMetadata(key string, value any) RunOption {
+	return func(o *runOptions) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]any)
+		}
+		o.metadata[key] = value
+	}
+}
+
+// metadataKey is the context key of the metadata of a run.
+type metadataKey struct{}
+
+// withMetadata returns ctx carrying metadata, or ctx itself if there is none.
+func withMetadata(ctx context.Context, metadata map[string]any) context.Context {
+	if len(metadata) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// contextMetadata returns the metadata carried by ctx, or nil.
+func contextMetadata(ctx context.Context) map[string]any {
+	metadata, _ := ctx.Value(metadataKey{}).(map[string]any)
+	return metadata
+}
+
+// MetadataValue returns the value attached with WithMetadata under key to
+// the run whose task has the context ctx, e.g. Task.Context().
+func MetadataValue(ctx context.Context, key string) (any, bool) {
+	value, ok := contextMetadata(ctx)[key]
+	return value, ok
+}
+
+// signalReady closes the channel set with WithReady, if any.
+func (r *runState) signalReady() {
+	if r.options.ready != nil {
+		close(r.options.ready)
+	}
+}
+
+// walkRoot returns the directory a run scans and watches: InputDir, or the
+// subtree selected with WithSubdir. run may be nil.
+func (mt *mirrorTransform) walkRoot(run *runState) string {
+	if run == nil || run.options.subdir == "" {
+		return mt.config.InputDir
+	}
+	return filepath.Join(mt.config.InputDir, filepath.FromSlash(run.options.subdir))
+}
+
+// checkRunOptions validates the options of a run before it starts.
+func (mt *mirrorTransform) checkRunOptions(run *runState) error {
+	subdir := run.options.subdir
+	if subdir == "" {
+		return nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(subdir)) {
+		return fmt.Errorf("subdirectory %q must be relative to the input directory", subdir)
+	}
+
+	info, err := os.Stat(mt.walkRoot(run))
+	if err != nil {
+		return fmt.Errorf("failed to access subdirectory %q: %w", subdir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("subdirectory %q is not a directory", subdir)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCrawlWithDryRun tests that dry runs match files without producing outputs.
+func TestCrawlWithDryRun(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/b.jpg"})
+
+	var calls int32
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&calls, 1)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background(), WithDryRun())
+	if err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if calls != 0 {
+		t.Errorf("Expected no callback calls, got %d", calls)
+	}
+	if result.Matched != 2 || result.Skipped != 2 || result.Processed != 0 {
+		t.Errorf("Expected 2 matched and skipped, got %+v", result)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Errorf("Expected no output directory, got %v", err)
+	}
+
+	// The instance itself is unchanged
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 callback calls, got %d", calls)
+	}
+}
+
+// TestCrawlWithSubdir tests that runs can be limited to a subtree.
+func TestCrawlWithSubdir(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "2023/b.jpg", "2024/c.jpg", "2024/05/d.jpg"})
+
+	var outputs []string
+	var mu sync.Mutex
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			rel, err := filepath.Rel(outputDir, outputPath)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			outputs = append(outputs, filepath.ToSlash(rel))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background(), WithSubdir("2024/")); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sort.Strings(outputs)
+	if strings.Join(outputs, ",") != "2024/05/d.jpg,2024/c.jpg" {
+		t.Errorf("Expected outputs [2024/05/d.jpg 2024/c.jpg], got %v", outputs)
+	}
+
+	// Invalid subdirectories are rejected
+	for _, subdir := range []string{"../input", "/abs", "missing", "a.jpg"} {
+		if err := mt.Crawl(context.Background(), WithSubdir(subdir)); err == nil {
+			t.Errorf("Expected error for subdirectory %q", subdir)
+		}
+	}
+}
+
+// TestCrawlWithForce tests that forced runs retry parked paths.
+func TestCrawlWithForce(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"bad.jpg"})
+
+	var attempts int32
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FailureBackoff: &FailureBackoff{
+			InitialDelay: time.Hour,
+			MaxFailures:  1,
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&attempts, 1)
+			return false, fmt.Errorf("corrupt image")
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected first crawl to fail")
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Expected parked path to be skipped, got %v", err)
+	}
+	if err := mt.Crawl(context.Background(), WithForce()); err == nil {
+		t.Fatal("Expected forced crawl to retry the parked path")
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+// TestWatchWithReady tests that Watch and Run signal readiness once the
+// initial watches are registered, so files created right after are seen.
+func TestWatchWithReady(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		run  func(mt MirrorTransform, ctx context.Context, opts ...RunOption) error
+	}{
+		{"Watch", MirrorTransform.Watch},
+		{"Run", MirrorTransform.Run},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+
+			createTestFiles(t, inputDir, []string{"dir/existing.txt"})
+
+			processed := make(chan string, 10)
+			config := Config{
+				InputDir:  inputDir,
+				OutputDir: filepath.Join(testDir, "output"),
+				Patterns:  []string{"**/*.jpg"},
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					processed <- inputPath
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ready := make(chan struct{})
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- tt.run(mt, ctx, WithReady(ready))
+			}()
+
+			select {
+			case <-ready:
+			case err := <-runErr:
+				t.Fatalf("Run ended before it was ready: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for readiness")
+			}
+
+			// No sleep: the watch on dir is registered already
+			createTestFiles(t, inputDir, []string{"dir/new.jpg"})
+
+			select {
+			case inputPath := <-processed:
+				if inputPath != filepath.Join(inputDir, "dir", "new.jpg") {
+					t.Errorf("Expected dir/new.jpg to be processed, got %s", inputPath)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the new file")
+			}
+
+			cancel()
+			if err := <-runErr; err != context.Canceled {
+				t.Errorf("Expected context.Canceled, got %v", err)
+			}
+		})
+	}
+}
+
+// TestCrawlWithMetadata tests that metadata attached to a run and the values
+// of its context reach every task.
+func TestCrawlWithMetadata(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "sub/b.jpg"})
+
+	type requestKey struct{}
+	var mu sync.Mutex
+	var tenants, requests, results []any
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		TaskCallback: func(task Task) (bool, error) {
+			tenant, _ := MetadataValue(task.Context(), "tenant")
+			mu.Lock()
+			defer mu.Unlock()
+			if task.Metadata["tenant"] != tenant {
+				t.Errorf("Expected the same tenant in Metadata and the context, got %v and %v", task.Metadata["tenant"], tenant)
+			}
+			tenants = append(tenants, tenant)
+			requests = append(requests, task.Context().Value(requestKey{}))
+			return true, nil
+		},
+		ResultCallback: func(task Task, result TaskResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, task.Metadata["tenant"])
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx := context.WithValue(context.Background(), requestKey{}, "req-1")
+	if err := mt.Crawl(ctx, WithMetadata("tenant", "acme"), WithMetadata("beta", true)); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(tenants) != 2 || tenants[0] != "acme" || tenants[1] != "acme" {
+		t.Errorf("Expected the tenant in every task, got %v", tenants)
+	}
+	if len(requests) != 2 || requests[0] != "req-1" || requests[1] != "req-1" {
+		t.Errorf("Expected the context values in every task, got %v", requests)
+	}
+	if len(results) != 2 || results[0] != "acme" {
+		t.Errorf("Expected the tenant in every result, got %v", results)
+	}
+
+	// Runs without metadata have none
+	tenants = nil
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(tenants) != 2 || tenants[0] != nil {
+		t.Errorf("Expected no tenant without metadata, got %v", tenants)
+	}
+}
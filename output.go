@@ -0,0 +1,118 @@
+package mirrortransform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// flattenHashLength is the number of hex characters used for flatten suffixes.
+const flattenHashLength = 12
+
+// compiledRewriteRule is a RewriteRule with its pattern compiled.
+type compiledRewriteRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// compileRewriteRules compiles the configured rewrite rules.
+func compileRewriteRules(rules []RewriteRule) ([]compiledRewriteRule, error) {
+	compiled := make([]compiledRewriteRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, markError(ErrInvalidPattern, fmt.Errorf("invalid rewrite pattern %q: %w", rule.Pattern, err))
+		}
+		compiled = append(compiled, compiledRewriteRule{re: re, replacement: rule.Replacement})
+	}
+	return compiled, nil
+}
+
+// outputPath maps a path relative to InputDir to its full output path.
+func (mt *mirrorTransform) outputPath(relPath string) (string, error) {
+	relPath, err := mt.mapOutputNames(relPath)
+	if err != nil {
+		return "", err
+	}
+	relPath = mt.normalizeUnicode(relPath)
+	relPath, err = mt.rewritePath(relPath)
+	if err != nil {
+		return "", err
+	}
+	relPath, err = mt.mapOutputPath(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	if mt.config.Flatten {
+		return filepath.Join(mt.config.OutputDir, flattenName(relPath)), nil
+	}
+	return filepath.Join(mt.config.OutputDir, relPath), nil
+}
+
+// mirrorDir creates the output directory for the input directory relPath if
+// MirrorEmptyDirs is set. OutputNames apply, rewrite rules do not.
+func (mt *mirrorTransform) mirrorDir(relPath string) error {
+	if !mt.config.MirrorEmptyDirs || relPath == "." {
+		return nil
+	}
+
+	mapped, err := mt.mapOutputNames(relPath)
+	if err != nil {
+		return err
+	}
+	outputDir := filepath.Join(mt.config.OutputDir, mt.normalizeUnicode(mapped))
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return mt.handlePathError(outputDir, err, "create output directory")
+	}
+	return nil
+}
+
+// rewritePath applies the rewrite rules to relPath.
+// Rewritten paths must stay inside OutputDir.
+func (mt *mirrorTransform) rewritePath(relPath string) (string, error) {
+	if len(mt.rewriteRules) == 0 {
+		return relPath, nil
+	}
+
+	rewritten := filepath.ToSlash(relPath)
+	for _, rule := range mt.rewriteRules {
+		rewritten = rule.re.ReplaceAllString(rewritten, rule.replacement)
+	}
+	rewritten = filepath.Clean(filepath.FromSlash(strings.TrimPrefix(rewritten, "/")))
+
+	if rewritten == "." || rewritten == ".." || strings.HasPrefix(rewritten, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("rewrite rules map %q to %q, which is outside the output directory", relPath, rewritten)
+	}
+	return rewritten, nil
+}
+
+// mapOutputPath applies OutputPathFunc to relPath.
+// Mapped paths must stay inside OutputDir.
+func (mt *mirrorTransform) mapOutputPath(relPath string) (string, error) {
+	if mt.config.OutputPathFunc == nil {
+		return relPath, nil
+	}
+
+	mapped := filepath.Clean(mt.config.OutputPathFunc(relPath))
+	if !filepath.IsLocal(mapped) {
+		return "", fmt.Errorf("output path func maps %q to %q, which is outside the output directory", relPath, mapped)
+	}
+	return mapped, nil
+}
+
+// flattenName returns a collision-safe file name for relPath.
+// The hash is computed over the slash-separated path so that names are
+// identical across platforms.
+func flattenName(relPath string) string {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(relPath)))
+	suffix := hex.EncodeToString(sum[:])[:flattenHashLength]
+
+	base := filepath.Base(relPath)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + suffix + ext
+}
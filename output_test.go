@@ -0,0 +1,311 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCrawlFlatten tests that flatten mode writes every output into OutputDir.
+func TestCrawlFlatten(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{
+		"photo.jpg",
+		"a/photo.jpg",
+		"a/b/photo.jpg",
+	})
+
+	outputs := make(map[string]bool)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 2,
+		Flatten:     true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			outputs[outputPath] = true
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(outputs) != 3 {
+		t.Fatalf("Expected 3 distinct output paths, got %d: %v", len(outputs), outputs)
+	}
+
+	for outputPath := range outputs {
+		if filepath.Dir(outputPath) != outputDir {
+			t.Errorf("Output %s is not directly inside %s", outputPath, outputDir)
+		}
+		name := filepath.Base(outputPath)
+		if !strings.HasPrefix(name, "photo-") || !strings.HasSuffix(name, ".jpg") {
+			t.Errorf("Unexpected flattened name %s", name)
+		}
+	}
+}
+
+// TestFlattenName tests that flattened names are deterministic and distinct.
+func TestFlattenName(t *testing.T) {
+	t.Parallel()
+	first := flattenName(filepath.Join("a", "photo.jpg"))
+	if first != flattenName(filepath.Join("a", "photo.jpg")) {
+		t.Error("flattenName is not deterministic")
+	}
+	if first == flattenName(filepath.Join("b", "photo.jpg")) {
+		t.Error("flattenName produced the same name for different directories")
+	}
+	if got := flattenName("archive.tar.gz"); !strings.HasSuffix(got, ".gz") || !strings.HasPrefix(got, "archive.tar-") {
+		t.Errorf("Unexpected flattened name %s", got)
+	}
+}
+
+// TestCrawlRewriteRules tests that rewrite rules restructure output paths.
+func TestCrawlRewriteRules(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{
+		"raw/img/a.jpg",
+		"raw/b.jpg",
+		"c.jpg",
+	})
+
+	outputs := make(map[string]string)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 2,
+		RewriteRules: []RewriteRule{
+			{Pattern: "^raw/", Replacement: ""},
+			{Pattern: "(^|/)img/", Replacement: "${1}images/"},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			outputs[inputPath] = outputPath
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"raw/img/a.jpg": "images/a.jpg",
+		"raw/b.jpg":     "b.jpg",
+		"c.jpg":         "c.jpg",
+	}
+	for in, out := range expected {
+		inputPath := filepath.Join(inputDir, filepath.FromSlash(in))
+		outputPath := filepath.Join(outputDir, filepath.FromSlash(out))
+		if got := outputs[inputPath]; got != outputPath {
+			t.Errorf("File %s: expected output path %s, got %s", in, outputPath, got)
+		}
+	}
+}
+
+// TestRewriteRulesValidation tests rewrite rule validation.
+func TestRewriteRulesValidation(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:     "/tmp/in",
+		OutputDir:    "/tmp/out",
+		Patterns:     []string{"*.jpg"},
+		RewriteRules: []RewriteRule{{Pattern: "(", Replacement: ""}},
+		FileCallback: func(in, out string) (bool, error) {
+			return true, nil
+		},
+	}
+	if _, err := NewMirrorTransform(&config); err == nil {
+		t.Error("Expected error for invalid rewrite pattern")
+	}
+
+	config.RewriteRules = []RewriteRule{{Pattern: "^", Replacement: "../"}}
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if _, err := mt.(*mirrorTransform).outputPath("a.jpg"); err == nil {
+		t.Error("Expected error for rewrite escaping the output directory")
+	}
+}
+
+// TestMirrorEmptyDirs tests that directories without matching files are
+// reproduced in the output tree by crawls and watches, but not dry runs.
+func TestMirrorEmptyDirs(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a/b/notes.txt", "skip/c/notes.txt", ".hidden/notes.txt"})
+	if err := os.MkdirAll(filepath.Join(inputDir, "empty"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"skip"},
+		MirrorEmptyDirs: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Dry runs write nothing
+	if err := mt.Crawl(context.Background(), WithDryRun()); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "empty")); !os.IsNotExist(err) {
+		t.Errorf("Expected dry run not to create directories, got %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	for _, dir := range []string{"a", "a/b", "empty"} {
+		if info, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(dir))); err != nil || !info.IsDir() {
+			t.Errorf("Expected output directory %s, got %v", dir, err)
+		}
+	}
+	for _, dir := range []string{"skip", ".hidden"} {
+		if _, err := os.Stat(filepath.Join(outputDir, dir)); !os.IsNotExist(err) {
+			t.Errorf("Expected skipped directory %s not to be mirrored, got %v", dir, err)
+		}
+	}
+
+	// Directories created while watching are mirrored too
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	if err := os.MkdirAll(filepath.Join(inputDir, "new"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	if info, err := os.Stat(filepath.Join(outputDir, "new")); err != nil || !info.IsDir() {
+		t.Errorf("Expected new directory to be mirrored, got %v", err)
+	}
+}
+
+// TestMirrorEmptyDirsFlatten tests that flattened output cannot mirror
+// directories.
+func TestMirrorEmptyDirsFlatten(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:        t.TempDir(),
+		OutputDir:       t.TempDir(),
+		Patterns:        []string{"**/*.jpg"},
+		Flatten:         true,
+		MirrorEmptyDirs: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	if _, err := NewMirrorTransform(&config); err == nil || !strings.Contains(err.Error(), "flattened") {
+		t.Errorf("Expected MirrorEmptyDirs with Flatten to be rejected, got %v", err)
+	}
+}
+
+// TestLazyOutputDirs tests that output directories are only created by the
+// callbacks that write to them.
+func TestLazyOutputDirs(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"skip/a.jpg", "copy/b.jpg", "write/c.jpg"})
+
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      outputDir,
+		Patterns:       []string{"**/*.jpg"},
+		LazyOutputDirs: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			switch {
+			case strings.Contains(inputPath, "skip"):
+				return true, nil
+			case strings.Contains(inputPath, "copy"):
+				return true, CopyFile(inputPath, outputPath, CopyOptions{})
+			}
+			if err := EnsureOutputDir(outputPath); err != nil {
+				return false, err
+			}
+			return true, os.WriteFile(outputPath, []byte("written"), 0o644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "skip")); !os.IsNotExist(err) {
+		t.Errorf("Expected no output directory for the skipped file, got %v", err)
+	}
+	for _, name := range []string{"copy/b.jpg", "write/c.jpg"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	// Markers and empty directories need the directories up front
+	config.RecoverPartialOutputs = true
+	config.AllowDestructive = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error with RecoverPartialOutputs")
+	}
+}
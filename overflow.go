@@ -0,0 +1,63 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ideamans/go-mirror-transform/watcher"
+)
+
+// WatchOverflowError reports that the watcher of Watch or Run lost events,
+// e.g. because the kernel's event queue overflowed during a burst of
+// changes. It matches ErrWatcherOverflow and fsnotify.ErrEventOverflow with
+// errors.Is.
+type WatchOverflowError struct {
+	// Dir is the directory tree whose events were lost, the watched root if
+	// the platform cannot tell.
+	Dir string
+}
+
+func (e *WatchOverflowError) Error() string {
+	return fmt.Sprintf("watcher lost events below %q", e.Dir)
+}
+
+func (e *WatchOverflowError) Unwrap() []error {
+	return []error{ErrWatcherOverflow, fsnotify.ErrEventOverflow}
+}
+
+// OverflowCallback is called when the watcher of Watch or Run lost events,
+// before the tree whose events were lost is rescanned.
+type OverflowCallback func(overflow *WatchOverflowError)
+
+// watchOverflow returns the overflow reported by err, a watcher error, or
+// nil if err is not an overflow. Watchers that cannot tell where events
+// were lost report root.
+func watchOverflow(err error, root string) *WatchOverflowError {
+	var overflow *WatchOverflowError
+	if errors.As(err, &overflow) {
+		return overflow
+	}
+	var lost *watcher.OverflowError
+	if errors.As(err, &lost) {
+		return &WatchOverflowError{Dir: lost.Dir}
+	}
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		return &WatchOverflowError{Dir: root}
+	}
+	return nil
+}
+
+// recoverOverflow reports overflow to OverflowCallback and rescans the tree
+// whose events were lost, so no change is missed for good.
+func (mt *mirrorTransform) recoverOverflow(ctx context.Context, run *runState, overflow *WatchOverflowError, taskChan chan<- fileTask) error {
+	mt.log().Warn("watcher lost events, rescanning", "dir", overflow.Dir)
+	if mt.config.OverflowCallback != nil {
+		mt.config.OverflowCallback(overflow)
+	}
+	if err := mt.rescan(ctx, run, overflow.Dir, TaskEventRescan, taskChan); err != nil {
+		return fmt.Errorf("failed to recover from lost watch events: %w", err)
+	}
+	return nil
+}
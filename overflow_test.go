@@ -0,0 +1,109 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// erringWatcher is a fileWatcher whose errors are sent by the test.
+type erringWatcher struct {
+	errors chan error
+}
+
+func (w *erringWatcher) Add(path string) error         { return nil }
+func (w *erringWatcher) Close() error                  { return nil }
+func (w *erringWatcher) Events() <-chan fsnotify.Event { return nil }
+func (w *erringWatcher) Errors() <-chan error          { return w.errors }
+func (w *erringWatcher) Recursive() bool               { return true }
+
+// TestWatchOverflow tests that lost watch events are reported to
+// OverflowCallback and the affected tree is rescanned instead of failing.
+func TestWatchOverflow(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "sub/b.jpg", "sub/c.txt", "other/d.jpg"})
+
+	overflows := make(chan *WatchOverflowError, 2)
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+		OverflowCallback: func(overflow *WatchOverflowError) {
+			overflows <- overflow
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher := &erringWatcher{errors: make(chan error)}
+	rescans := mt.subscribeRescans()
+	defer mt.unsubscribeRescans(rescans)
+	taskChan := make(chan fileTask, 10)
+	errChan := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mt.handleWatchEvents(ctx, newRunState(true), watcher, inputDir, nil, rescans, taskChan, errChan)
+	}()
+
+	// receive returns the relative paths of the n tasks queued next
+	receive := func(n int) []string {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(taskChan) < n && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		var relPaths []string
+		for len(taskChan) > 0 {
+			task := <-taskChan
+			if task.Event != TaskEventRescan {
+				t.Errorf("Expected the rescan event, got %q", task.Event)
+			}
+			relPaths = append(relPaths, filepath.ToSlash(task.RelPath))
+		}
+		sort.Strings(relPaths)
+		return relPaths
+	}
+
+	// Watchers that cannot tell where events were lost rescan the root
+	watcher.errors <- fsnotify.ErrEventOverflow
+	overflow := <-overflows
+	if overflow.Dir != inputDir || !errors.Is(overflow, fsnotify.ErrEventOverflow) {
+		t.Errorf("Expected an overflow of the root, got %v", overflow)
+	}
+	if relPaths := receive(3); len(relPaths) != 3 || relPaths[0] != "a.jpg" || relPaths[1] != "other/d.jpg" || relPaths[2] != "sub/b.jpg" {
+		t.Errorf("Expected every matching file to be queued, got %v", relPaths)
+	}
+
+	// Others rescan only the tree that lost events
+	watcher.errors <- &WatchOverflowError{Dir: filepath.Join(inputDir, "sub")}
+	<-overflows
+	if relPaths := receive(1); len(relPaths) != 1 || relPaths[0] != "sub/b.jpg" {
+		t.Errorf("Expected only sub/b.jpg to be queued, got %v", relPaths)
+	}
+	cancel()
+	<-done
+
+	select {
+	case err := <-errChan:
+		t.Errorf("Expected the watch to go on, got %v", err)
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Override replaces settings for the files below one subtree of InputDir,
+// so heterogeneous trees (e.g. "videos" and "images") can be served by one
+// instance. When overrides are nested, the deepest one applies.
+type Override struct {
+	// Dir is the subtree, relative to InputDir (e.g. "videos" or "media/raw").
+	Dir string `json:"dir" yaml:"dir"`
+
+	// Patterns, if set, replace Patterns for files below Dir. They are
+	// matched against the path relative to Dir (e.g. "**/*.mp4").
+	Patterns []string `json:"patterns,omitempty" yaml:"patterns,omitempty"`
+
+	// ExcludePatterns exclude files and directories below Dir in addition to
+	// ExcludePatterns, matched against the path relative to Dir.
+	ExcludePatterns []string `json:"excludePatterns,omitempty" yaml:"excludePatterns,omitempty"`
+
+	// Concurrency, if positive, limits how many files below Dir are
+	// processed at once, within the overall concurrency.
+	Concurrency int `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+
+	// Params are transform parameters passed to TaskCallback in Task.Params
+	// for files below Dir.
+	Params map[string]string `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// validateOverrides checks that every override names a distinct subtree
+// and has valid patterns.
+func validateOverrides(overrides []Override) error {
+	seen := make(map[string]bool)
+	for _, o := range overrides {
+		dir := overrideDir(o.Dir)
+		if o.Dir == "" || dir == "." || !filepath.IsLocal(filepath.FromSlash(dir)) {
+			return fmt.Errorf("override directory %q must be a subdirectory of the input directory", o.Dir)
+		}
+		if seen[dir] {
+			return fmt.Errorf("duplicate override for %q", o.Dir)
+		}
+		seen[dir] = true
+
+		if o.Concurrency < 0 {
+			return fmt.Errorf("override concurrency for %q must not be negative, got %d", o.Dir, o.Concurrency)
+		}
+		for _, pattern := range append(append([]string(nil), o.Patterns...), o.ExcludePatterns...) {
+			if !doublestar.ValidatePattern(pattern) {
+				return markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q in override for %q", pattern, o.Dir))
+			}
+		}
+	}
+	return nil
+}
+
+// overrideDir returns dir cleaned and with forward slashes.
+func overrideDir(dir string) string {
+	return path.Clean(filepath.ToSlash(dir))
+}
+
+// subtreeOverride is an Override ready for matching.
+type subtreeOverride struct {
+	Override
+
+	// prefix is the cleaned Dir followed by a slash.
+	prefix string
+
+	// globs matches Patterns; nil if the override does not replace them.
+	globs *GlobMatcher
+
+	// slots holds a token per file being processed; nil without a limit.
+	slots chan struct{}
+}
+
+// overrideSet finds the override applying to a path.
+// A nil *overrideSet has no overrides.
+type overrideSet struct {
+	overrides []*subtreeOverride
+}
+
+// newOverrideSet returns the set for the configured overrides, or nil if
+// there are none.
+func newOverrideSet(config *Config) *overrideSet {
+	if len(config.Overrides) == 0 {
+		return nil
+	}
+
+	s := &overrideSet{}
+	for _, o := range config.Overrides {
+		override := &subtreeOverride{Override: o, prefix: overrideDir(o.Dir) + "/"}
+		if len(o.Patterns) > 0 {
+			override.globs = newGlobMatcher(o.Patterns, config.CaseInsensitivePatterns)
+		}
+		if o.Concurrency > 0 {
+			override.slots = make(chan struct{}, o.Concurrency)
+		}
+		s.overrides = append(s.overrides, override)
+	}
+	return s
+}
+
+// find returns the deepest override whose subtree contains relPath, or nil.
+func (s *overrideSet) find(relPath string) *subtreeOverride {
+	if s == nil {
+		return nil
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	var found *subtreeOverride
+	for _, o := range s.overrides {
+		if strings.HasPrefix(relPath, o.prefix) && (found == nil || len(o.prefix) > len(found.prefix)) {
+			found = o
+		}
+	}
+	return found
+}
+
+// relPath returns relPath relative to the subtree of o.
+func (o *subtreeOverride) relPath(relPath string) string {
+	return strings.TrimPrefix(filepath.ToSlash(relPath), o.prefix)
+}
+
+// params returns the transform parameters of o; nil-safe.
+func (o *subtreeOverride) params() map[string]string {
+	if o == nil {
+		return nil
+	}
+	return o.Params
+}
+
+// acquire waits for a processing slot of o and reports whether one was
+// taken before ctx was done. It always succeeds without a limit.
+func (o *subtreeOverride) acquire(ctx context.Context) bool {
+	if o == nil || o.slots == nil {
+		return true
+	}
+	select {
+	case o.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release returns a slot taken by acquire.
+func (o *subtreeOverride) release() {
+	if o == nil || o.slots == nil {
+		return
+	}
+	<-o.slots
+}
@@ -0,0 +1,166 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestOverrides tests that overrides replace patterns, add exclusions and
+// pass parameters for the files below their subtree.
+func TestOverrides(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{
+		"images/a.jpg",
+		"images/b.png",
+		"videos/c.mp4",
+		"videos/d.jpg",
+		"videos/tmp/e.mp4",
+		"videos/hd/f.mp4",
+		"g.jpg",
+	})
+
+	var mu sync.Mutex
+	params := make(map[string]string)
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		Overrides: []Override{
+			{Dir: "videos", Patterns: []string{"**/*.mp4"}, ExcludePatterns: []string{"tmp"}, Params: map[string]string{"codec": "h264"}},
+			{Dir: "videos/hd/", Patterns: []string{"*.mp4"}, Params: map[string]string{"codec": "hevc"}},
+		},
+		TaskCallback: func(task Task) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			params[filepath.ToSlash(task.RelPath)] = task.Params["codec"]
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"images/a.jpg":    "",
+		"videos/c.mp4":    "h264",
+		"videos/hd/f.mp4": "hevc",
+		"g.jpg":           "",
+	}
+	if len(params) != len(expected) {
+		t.Errorf("Expected %v to be processed, got %v", expected, params)
+	}
+	for relPath, codec := range expected {
+		if got, ok := params[relPath]; !ok || got != codec {
+			t.Errorf("Expected %s to be processed with codec %q, got %q (processed: %v)", relPath, codec, got, ok)
+		}
+	}
+}
+
+// TestOverrideConcurrency tests that an override limits how many files of
+// its subtree are processed at once.
+func TestOverrideConcurrency(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"heavy/1.jpg", "heavy/2.jpg", "heavy/3.jpg", "heavy/4.jpg", "light/5.jpg"})
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	var processed []string
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   filepath.Join(testDir, "output"),
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 4,
+		Overrides:   []Override{{Dir: "heavy", Concurrency: 1}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			heavy := strings.Contains(inputPath, "heavy")
+			mu.Lock()
+			if heavy {
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			if heavy {
+				running--
+			}
+			processed = append(processed, filepath.Base(inputPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	sort.Strings(processed)
+	if strings.Join(processed, ",") != "1.jpg,2.jpg,3.jpg,4.jpg,5.jpg" {
+		t.Errorf("Expected every file to be processed, got %v", processed)
+	}
+	if maxRunning != 1 {
+		t.Errorf("Expected at most one heavy file at a time, got %d", maxRunning)
+	}
+}
+
+// TestValidateOverrides tests override validation.
+func TestValidateOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		overrides []Override
+		wantErr   string
+	}{
+		{name: "valid", overrides: []Override{{Dir: "a"}, {Dir: "a/b", Concurrency: 2}}},
+		{name: "empty dir", overrides: []Override{{}}, wantErr: "subdirectory"},
+		{name: "input dir", overrides: []Override{{Dir: "."}}, wantErr: "subdirectory"},
+		{name: "outside", overrides: []Override{{Dir: "../a"}}, wantErr: "subdirectory"},
+		{name: "duplicate", overrides: []Override{{Dir: "a"}, {Dir: "a/"}}, wantErr: "duplicate"},
+		{name: "negative concurrency", overrides: []Override{{Dir: "a", Concurrency: -1}}, wantErr: "negative"},
+		{name: "bad pattern", overrides: []Override{{Dir: "a", Patterns: []string{"[a"}}}, wantErr: "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateOverrides(tt.overrides)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
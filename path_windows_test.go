@@ -0,0 +1,18 @@
+//go:build windows
+
+package mirrortransform
+
+import "testing"
+
+// TestRelPathLongPrefix tests relative paths between long and short spellings.
+func TestRelPathLongPrefix(t *testing.T) {
+	t.Parallel()
+	mt := &mirrorTransform{config: Config{InputDir: `\\?\c:\data\in`}}
+	rel, err := mt.relPath(`C:\Data\In\photos\a.JPG`)
+	if err != nil {
+		t.Fatalf("relPath failed: %v", err)
+	}
+	if rel != `photos\a.JPG` {
+		t.Errorf("Expected %q, got %q", `photos\a.JPG`, rel)
+	}
+}
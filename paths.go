@@ -0,0 +1,87 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CrawlPaths processes only the given files and directory trees, each
+// relative to InputDir (e.g. "2024/06" or "logo.png"), as CrawlWithResult
+// would process them in a full crawl: the same filters apply, including
+// those on their parent directories, and outputs go to their usual paths.
+// Paths that do not exist are skipped, so a CI job can pass every path a
+// commit touched. "." crawls all of InputDir.
+func (mt *mirrorTransform) CrawlPaths(ctx context.Context, relPaths ...string) (*Result, error) {
+	roots, err := crawlRoots(relPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newRunState(false)
+	run.collectResult = true
+	result, err := mt.runFinite(ctx, "CrawlPaths", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		for _, relPath := range roots {
+			if err := mt.crawlPath(ctx, relPath, taskChan, run); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+	}
+	return result, err
+}
+
+// crawlRoots cleans relPaths, sorts them and drops those inside another, so
+// no file is crawled twice.
+func crawlRoots(relPaths []string) ([]string, error) {
+	cleaned := make([]string, 0, len(relPaths))
+	for _, relPath := range relPaths {
+		clean := filepath.Clean(filepath.FromSlash(relPath))
+		if !filepath.IsLocal(clean) && clean != "." {
+			return nil, fmt.Errorf("path %q must be relative to the input directory", relPath)
+		}
+		cleaned = append(cleaned, clean)
+	}
+	sort.Strings(cleaned)
+
+	var roots []string
+	for _, relPath := range cleaned {
+		covered := false
+		for _, root := range roots {
+			if root == "." || relPath == root || strings.HasPrefix(relPath, root+string(filepath.Separator)) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			roots = append(roots, relPath)
+		}
+	}
+	return roots, nil
+}
+
+// crawlPath scans the file or directory tree at relPath unless it is missing
+// or inside a skipped directory.
+func (mt *mirrorTransform) crawlPath(ctx context.Context, relPath string, taskChan chan<- fileTask, run *runState) error {
+	path := filepath.Join(mt.config.InputDir, relPath)
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			mt.log().Debug("skipped missing path", "path", relPath)
+			return nil
+		}
+		return mt.handlePathError(path, err, "access")
+	}
+
+	// Parent directories are not scanned, so check them here
+	skipped, err := mt.inSkippedDir(relPath)
+	if err != nil || skipped {
+		return err
+	}
+	return mt.scanTree(ctx, path, taskChan, run)
+}
@@ -0,0 +1,65 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCrawlPaths tests that only the given files and trees are processed,
+// each once, with the filters of their parent directories.
+func TestCrawlPaths(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	createTestFiles(t, inputDir, []string{
+		"2024/01/a.jpg",
+		"2024/02/b.jpg",
+		"2024/02/c.jpg",
+		"2025/d.jpg",
+		"tmp/e.jpg",
+		"f.jpg",
+	})
+
+	var mu sync.Mutex
+	var outputs []string
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"tmp"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			rel, _ := filepath.Rel(outputDir, outputPath)
+			mu.Lock()
+			outputs = append(outputs, filepath.ToSlash(rel))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlPaths(context.Background(), "2024/02", "2024/02/b.jpg", "f.jpg", "tmp/e.jpg", "deleted.jpg")
+	if err != nil {
+		t.Fatalf("CrawlPaths failed: %v", err)
+	}
+	sort.Strings(outputs)
+	if got := strings.Join(outputs, ","); got != "2024/02/b.jpg,2024/02/c.jpg,f.jpg" {
+		t.Errorf("Unexpected outputs: %s", got)
+	}
+	if result.Processed != 3 {
+		t.Errorf("Expected 3 processed files, got %+v", result)
+	}
+
+	if _, err := mt.CrawlPaths(context.Background(), "../input"); err == nil {
+		t.Error("Expected an error for a path outside the input directory")
+	}
+}
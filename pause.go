@@ -0,0 +1,123 @@
+package mirrortransform
+
+import (
+	"context"
+	"sync"
+)
+
+// pauseControl holds the state set by Pause and Resume.
+type pauseControl struct {
+	mu      sync.Mutex
+	paused  bool
+	changed chan struct{}
+}
+
+// newPauseControl returns a control in the running state.
+func newPauseControl() *pauseControl {
+	return &pauseControl{changed: make(chan struct{})}
+}
+
+// set pauses or resumes dispatching.
+func (p *pauseControl) set(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused == paused {
+		return
+	}
+	p.paused = paused
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// state reports whether dispatching is paused, and a channel closed on the
+// next change.
+func (p *pauseControl) state() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused, p.changed
+}
+
+// Pause stops dispatching tasks until Resume is called.
+func (mt *mirrorTransform) Pause() {
+	mt.pause.set(true)
+	mt.log().Info("paused")
+}
+
+// Resume dispatches the tasks queued while paused and continues normally.
+func (mt *mirrorTransform) Resume() {
+	mt.pause.set(false)
+	mt.log().Info("resumed")
+}
+
+// maxQueuedTasks is how many tasks the pause buffer holds while running,
+// and maxPausedTasks how many distinct tasks it holds while paused. Once
+// full, it stops reading and the queue ahead of it fills up.
+const (
+	maxQueuedTasks = 1000
+	maxPausedTasks = 10000
+)
+
+// runPauseBuffer forwards tasks from in to out in order. It holds up to
+// runningLimit tasks while control is running, and up to pausedLimit while
+// paused so the watcher is not blocked, then stops reading from in. Tasks
+// for a file that is already buffered while paused replace the earlier task.
+// The tasks entering the buffer, except those replacing another, are
+// counted as queued in run. out is closed when in is closed and drained, or
+// when ctx is done.
+func runPauseBuffer(ctx context.Context, in <-chan fileTask, out chan<- fileTask, control *pauseControl, run *runState, runningLimit, pausedLimit int) {
+	defer close(out)
+
+	// Positions in index count from the first task ever buffered
+	var pending []fileTask
+	var sent int
+	index := make(map[string]int)
+	inputOpen := true
+
+	for inputOpen || len(pending) > 0 {
+		paused, changed := control.state()
+
+		// Only offer a task while running
+		var sendChan chan<- fileTask
+		var next fileTask
+		if !paused && len(pending) > 0 {
+			sendChan = out
+			next = pending[0]
+		}
+
+		// Stop receiving once the input is closed or the buffer is full
+		limit := runningLimit
+		if paused {
+			limit = pausedLimit
+		}
+		recvChan := in
+		if !inputOpen || len(pending) >= limit {
+			recvChan = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			// Re-evaluate the pause state
+		case task, ok := <-recvChan:
+			if !ok {
+				inputOpen = false
+				continue
+			}
+			if pos, ok := index[task.RelPath]; ok && paused {
+				pending[pos-sent] = task
+				continue
+			}
+			index[task.RelPath] = sent + len(pending)
+			pending = append(pending, task)
+			run.enqueued(task.RelPath)
+		case sendChan <- next:
+			if index[next.RelPath] == sent {
+				delete(index, next.RelPath)
+			}
+			pending[0] = fileTask{}
+			pending = pending[1:]
+			sent++
+		}
+	}
+}
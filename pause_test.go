@@ -0,0 +1,169 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPauseResumeWatch tests that a paused Watch keeps queueing changed
+// files and processes each of them once after Resume.
+func TestPauseResumeWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			processed[filepath.Base(inputPath)]++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx)
+	}()
+
+	// Give watcher time to start
+	time.Sleep(200 * time.Millisecond)
+
+	mt.Pause()
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(filepath.Join(inputDir, "a.jpg"), []byte("version"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	createTestFiles(t, inputDir, []string{"b.jpg"})
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	pausedCount := len(processed)
+	mu.Unlock()
+	if pausedCount != 0 {
+		t.Errorf("Expected nothing processed while paused, got %v", processed)
+	}
+
+	mt.Resume()
+	time.Sleep(200 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	if processed["a.jpg"] != 1 || processed["b.jpg"] != 1 {
+		t.Errorf("Expected a.jpg and b.jpg processed once each, got %v", processed)
+	}
+}
+
+// TestRunPauseBuffer tests that buffered tasks keep their order and that
+// only tasks buffered while paused are collapsed.
+func TestRunPauseBuffer(t *testing.T) {
+	t.Parallel()
+
+	control := newPauseControl()
+	in := make(chan fileTask)
+	out := make(chan fileTask)
+	go runPauseBuffer(context.Background(), in, out, control, newRunState(false), maxQueuedTasks, maxPausedTasks)
+
+	task := func(relPath string, size int64) fileTask {
+		return fileTask{FileTask: FileTask{RelPath: relPath, Size: size}}
+	}
+
+	control.set(true)
+	in <- task("a", 1)
+	in <- task("b", 1)
+	in <- task("a", 2)
+	close(in)
+
+	select {
+	case got := <-out:
+		t.Fatalf("Expected no task while paused, got %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	control.set(false)
+	var got []fileTask
+	for task := range out {
+		got = append(got, task)
+	}
+	if len(got) != 2 || got[0].RelPath != "a" || got[0].Size != 2 || got[1].RelPath != "b" {
+		t.Errorf("Expected the latest a then b, got %+v", got)
+	}
+}
+
+// TestRunPauseBufferBound tests that the buffer holds at most its running
+// limit while running and its paused limit while paused.
+func TestRunPauseBufferBound(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	control := newPauseControl()
+	in := make(chan fileTask)
+	out := make(chan fileTask)
+	go runPauseBuffer(ctx, in, out, control, newRunState(false), 2, 3)
+
+	// accepted counts the tasks the buffer takes from in within a moment
+	next := 0
+	accepted := func() int {
+		count := 0
+		for {
+			select {
+			case in <- fileTask{FileTask: FileTask{RelPath: fmt.Sprint(next)}}:
+				next++
+				count++
+			case <-time.After(50 * time.Millisecond):
+				return count
+			}
+		}
+	}
+
+	// Nobody reads out, so the buffer fills up to its limits
+	if n := accepted(); n != 2 {
+		t.Errorf("Expected 2 tasks taken while running, got %d", n)
+	}
+	control.set(true)
+	if n := accepted(); n != 1 {
+		t.Errorf("Expected 1 more task taken while paused, got %d", n)
+	}
+
+	control.set(false)
+	for i := 0; i < 3; i++ {
+		select {
+		case task := <-out:
+			if task.RelPath != fmt.Sprint(i) {
+				t.Errorf("Expected task %d, got %s", i, task.RelPath)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for task %d", i)
+		}
+	}
+}
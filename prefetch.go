@@ -0,0 +1,31 @@
+package mirrortransform
+
+import "context"
+
+// runPrefetcher forwards tasks from in to out, hinting the operating system to
+// load each input into the page cache before it is handed to a worker.
+// out should be buffered; its capacity bounds how far reads run ahead.
+// out is closed when in is closed or ctx is done.
+func runPrefetcher(ctx context.Context, in <-chan fileTask, out chan<- fileTask) {
+	defer close(out)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok := <-in:
+			if !ok {
+				return
+			}
+
+			// Prefetching is only a hint; failures surface when the file is processed
+			prefetchFile(task.InputPath)
+
+			select {
+			case out <- task:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package mirrortransform
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// prefetchFile asks the kernel to read path into the page cache asynchronously.
+func prefetchFile(path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	_ = unix.Fadvise(int(file.Fd()), 0, 0, unix.FADV_WILLNEED)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package mirrortransform
+
+// prefetchFile does nothing where there is no read-ahead hint. Reading the
+// whole file instead would hold up every task queued behind it.
+func prefetchFile(path string) {}
@@ -0,0 +1,73 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCrawlPrefetch tests that prefetching does not change which files are processed.
+func TestCrawlPrefetch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	var files []string
+	for i := 0; i < 20; i++ {
+		files = append(files, fmt.Sprintf("dir%d/file%d.jpg", i%3, i))
+	}
+	createTestFiles(t, inputDir, files)
+
+	var processedCount int32
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   filepath.Join(testDir, "output"),
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 2,
+		Prefetch:    4,
+		TaskSorter:  SmallestFirst,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&processedCount, 1)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if processedCount != int32(len(files)) {
+		t.Errorf("Expected %d files to be processed, got %d", len(files), processedCount)
+	}
+}
+
+// TestRunPrefetcher tests that the prefetcher forwards tasks in order and
+// tolerates missing files.
+func TestRunPrefetcher(t *testing.T) {
+	t.Parallel()
+	in := make(chan fileTask, 3)
+	out := make(chan fileTask, 2)
+
+	for i := 0; i < 3; i++ {
+		in <- fileTask{FileTask: FileTask{InputPath: filepath.Join(t.TempDir(), fmt.Sprintf("missing%d", i)), RelPath: fmt.Sprint(i)}}
+	}
+	close(in)
+
+	go runPrefetcher(context.Background(), in, out)
+
+	var order []string
+	for task := range out {
+		order = append(order, task.RelPath)
+	}
+	if fmt.Sprint(order) != "[0 1 2]" {
+		t.Errorf("Expected tasks in order [0 1 2], got %v", order)
+	}
+}
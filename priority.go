@@ -0,0 +1,79 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PriorityHints move marked files to the front of the queue, so urgent
+// assets are processed before a large backlog. Files with a higher priority
+// are dispatched first; unmarked files have priority 0. Among files of equal
+// priority, TaskSorter or arrival order applies.
+type PriorityHints struct {
+	// SidecarExt is the extension of sidecar files that mark their input,
+	// e.g. ".priority" for "photo.jpg.priority" next to "photo.jpg".
+	// The sidecar may contain an integer priority; any other content means 1.
+	// Sidecar files are never processed themselves. Empty disables sidecars.
+	SidecarExt string `json:"sidecarExt,omitempty" yaml:"sidecarExt,omitempty"`
+
+	// Prefixes maps file name prefixes to priorities, e.g. {"rush-": 10}.
+	// The highest matching prefix wins.
+	Prefixes map[string]int `json:"prefixes,omitempty" yaml:"prefixes,omitempty"`
+}
+
+// validatePriorityHints checks the priority hint settings.
+func validatePriorityHints(hints *PriorityHints) error {
+	if hints == nil {
+		return nil
+	}
+	if hints.SidecarExt != "" && !strings.HasPrefix(hints.SidecarExt, ".") {
+		return fmt.Errorf("priority sidecar extension %q must start with a dot", hints.SidecarExt)
+	}
+	for prefix := range hints.Prefixes {
+		if prefix == "" || strings.ContainsAny(prefix, `/\`) {
+			return fmt.Errorf("invalid priority prefix %q", prefix)
+		}
+	}
+	return nil
+}
+
+// priorityOf returns the priority of the task from the configured hints.
+// The highest of the sidecar and prefix priorities applies.
+func (mt *mirrorTransform) priorityOf(task fileTask) int {
+	hints := mt.config.PriorityHints
+	if hints == nil {
+		return 0
+	}
+
+	priority, marked := 0, false
+	name := filepath.Base(task.RelPath)
+	for prefix, p := range hints.Prefixes {
+		if strings.HasPrefix(name, prefix) && (!marked || p > priority) {
+			priority, marked = p, true
+		}
+	}
+
+	if hints.SidecarExt != "" {
+		if data, err := os.ReadFile(task.InputPath + hints.SidecarExt); err == nil {
+			p := 1
+			if text := strings.TrimSpace(string(data)); text != "" {
+				if n, err := strconv.Atoi(text); err == nil {
+					p = n
+				}
+			}
+			if !marked || p > priority {
+				priority = p
+			}
+		}
+	}
+	return priority
+}
+
+// isPrioritySidecar reports whether relPath is a priority sidecar file.
+func (mt *mirrorTransform) isPrioritySidecar(relPath string) bool {
+	hints := mt.config.PriorityHints
+	return hints != nil && hints.SidecarExt != "" && strings.HasSuffix(relPath, hints.SidecarExt)
+}
@@ -0,0 +1,155 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestPriorityOf tests priorities derived from prefixes and sidecars.
+func TestPriorityOf(t *testing.T) {
+	t.Parallel()
+	inputDir := t.TempDir()
+	createTestFiles(t, inputDir, []string{"plain.jpg", "rush-a.jpg", "rush-b.jpg", "side.jpg", "side.jpg.priority", "empty.jpg"})
+	if err := os.WriteFile(filepath.Join(inputDir, "rush-b.jpg.priority"), []byte("20\n"), 0644); err != nil {
+		t.Fatalf("Failed to create sidecar: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDir, "empty.jpg.priority"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create sidecar: %v", err)
+	}
+
+	mt := &mirrorTransform{config: Config{
+		InputDir: inputDir,
+		PriorityHints: &PriorityHints{
+			SidecarExt: ".priority",
+			Prefixes:   map[string]int{"rush-": 10, "rush-a": 5},
+		},
+	}}
+
+	tests := []struct {
+		relPath  string
+		expected int
+	}{
+		{"plain.jpg", 0},
+		{"rush-a.jpg", 10},
+		{"rush-b.jpg", 20},
+		{"side.jpg", 1},
+		{"empty.jpg", 1},
+		{"sub/rush-c.jpg", 10},
+	}
+
+	for _, tt := range tests {
+		task := fileTask{FileTask: FileTask{InputPath: filepath.Join(inputDir, tt.relPath), RelPath: tt.relPath}}
+		if got := mt.priorityOf(task); got != tt.expected {
+			t.Errorf("Expected priority %d for %q, got %d", tt.expected, tt.relPath, got)
+		}
+	}
+}
+
+// TestRunTaskQueuePriority tests that higher priorities are dispatched first
+// and equal priorities keep their arrival order.
+func TestRunTaskQueuePriority(t *testing.T) {
+	t.Parallel()
+	in := make(chan fileTask, 5)
+	out := make(chan fileTask)
+
+	for _, rel := range []string{"c", "rush-b", "a", "rush-a", "b"} {
+		in <- fileTask{FileTask: FileTask{RelPath: rel}}
+	}
+	close(in)
+
+	priority := func(task fileTask) int {
+		if strings.HasPrefix(task.RelPath, "rush-") {
+			return 1
+		}
+		return 0
+	}
+	go runTaskQueue(context.Background(), in, out, nil, priority, 0, true, maxSortedTasks)
+
+	var order []string
+	for task := range out {
+		order = append(order, task.RelPath)
+	}
+
+	if strings.Join(order, ",") != "rush-b,rush-a,c,a,b" {
+		t.Errorf("Expected order [rush-b rush-a c a b], got %v", order)
+	}
+}
+
+// TestCrawlPriorityHints tests that prioritized files are processed first and
+// sidecar files are not processed.
+func TestCrawlPriorityHints(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "dir/c.jpg", "dir/c.jpg.priority", "rush-d.jpg"})
+
+	var order []string
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*"},
+		Concurrency: 1,
+		TaskSorter: func(a, b FileTask) bool {
+			return a.RelPath < b.RelPath
+		},
+		PriorityHints: &PriorityHints{
+			SidecarExt: ".priority",
+			Prefixes:   map[string]int{"rush-": 2},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			order = append(order, filepath.Base(inputPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := []string{"rush-d.jpg", "c.jpg", "a.jpg", "b.jpg"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+}
+
+// TestValidatePriorityHints tests priority hint validation.
+func TestValidatePriorityHints(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		hints   *PriorityHints
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"valid", &PriorityHints{SidecarExt: ".priority", Prefixes: map[string]int{"rush-": 1}}, false},
+		{"sidecar without dot", &PriorityHints{SidecarExt: "priority"}, true},
+		{"empty prefix", &PriorityHints{Prefixes: map[string]int{"": 1}}, true},
+		{"prefix with separator", &PriorityHints{Prefixes: map[string]int{"rush/": 1}}, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validatePriorityHints(tt.hints)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error: %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotMatched is returned by ProcessFile for a file that the patterns do
+// not match or that the filters skip.
+var ErrNotMatched = errors.New("file is not matched by the configured patterns")
+
+// ProcessFile runs the pipeline of a crawl for the single file inputPath,
+// which must be inside InputDir, and returns once its callback has finished.
+// Filters, patterns, output mapping and options apply as in a crawl, so an
+// upload handler can transform a file immediately instead of waiting for the
+// watcher to notice it. It returns an error matching ErrNotMatched if the
+// file is filtered out, and the callback's error if it fails.
+func (mt *mirrorTransform) ProcessFile(ctx context.Context, inputPath string) error {
+	relPath, err := mt.relPath(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path for %q: %w", inputPath, err)
+	}
+	if !filepath.IsLocal(relPath) {
+		return fmt.Errorf("path %q is not inside the input directory", inputPath)
+	}
+	inputPath = filepath.Join(mt.config.InputDir, relPath)
+
+	info, err := os.Lstat(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", inputPath, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("path %q is not a regular file", inputPath)
+	}
+
+	// Check the directories above the file as a crawl would have
+	skipped, err := mt.inSkippedDir(relPath)
+	if err != nil {
+		return err
+	}
+	pattern := ""
+	if !skipped {
+		if pattern, err = mt.entryPattern(inputPath, relPath, newFileEntry(inputPath, info)); err != nil {
+			return err
+		}
+	}
+	if pattern == "" {
+		return fmt.Errorf("cannot process %q: %w", relPath, ErrNotMatched)
+	}
+
+	outputPath, err := mt.outputPath(relPath)
+	if err != nil {
+		return err
+	}
+	return mt.processNow(ctx, "ProcessFile", newFileTask(inputPath, outputPath, relPath, info, TaskEventManual, pattern))
+}
+
+// processNow processes task in the calling goroutine as a worker of a run
+// would, and returns the error that would end that run. A callback asking to
+// stop is not an error for a single file. operation names the caller in
+// webhooks.
+func (mt *mirrorTransform) processNow(ctx context.Context, operation string, task fileTask) error {
+	run := newRunState(false)
+	run.collectFailures = true
+	run.operation = operation
+	run.shutdown = mt.newShutdown(ctx)
+	defer run.shutdown.stop()
+
+	// Count the file as in flight in QueueStats
+	worker := mt.workers.add(run)
+	defer mt.workers.remove(worker)
+
+	errChan := make(chan error, 1)
+	mt.processAs(ctx, run, worker, task, errChan)
+	select {
+	case err := <-errChan:
+		if run.processed.Load() == 0 {
+			return err
+		}
+	default:
+	}
+	return mt.withFailures(run, nil)
+}
@@ -0,0 +1,70 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestProcessFile tests that a single file is processed synchronously with
+// the filters of a crawl.
+func TestProcessFile(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	createTestFiles(t, inputDir, []string{"uploads/a.jpg", "uploads/bad.jpg", "uploads/b.txt", "tmp/c.jpg"})
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"tmp"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Base(inputPath) == "bad.jpg" {
+				return false, errors.New("corrupt")
+			}
+			data, err := os.ReadFile(inputPath)
+			if err != nil {
+				return false, err
+			}
+			return true, os.WriteFile(outputPath, data, 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := mt.ProcessFile(ctx, filepath.Join(inputDir, "uploads", "a.jpg")); err != nil {
+		t.Fatalf("ProcessFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "uploads", "a.jpg")); err != nil {
+		t.Errorf("Expected the output to be written when ProcessFile returns: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		check func(err error) bool
+	}{
+		{"unmatched file", filepath.Join(inputDir, "uploads", "b.txt"), func(err error) bool { return errors.Is(err, ErrNotMatched) }},
+		{"excluded directory", filepath.Join(inputDir, "tmp", "c.jpg"), func(err error) bool { return errors.Is(err, ErrNotMatched) }},
+		{"missing file", filepath.Join(inputDir, "uploads", "missing.jpg"), func(err error) bool { return errors.Is(err, fs.ErrNotExist) }},
+		{"outside input", filepath.Join(testDir, "other.jpg"), func(err error) bool { return err != nil }},
+		{"failing callback", filepath.Join(inputDir, "uploads", "bad.jpg"), func(err error) bool { return err != nil && !errors.Is(err, ErrNotMatched) }},
+	}
+	for _, tt := range tests {
+		if err := mt.ProcessFile(ctx, tt.path); !tt.check(err) {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "tmp")); !os.IsNotExist(err) {
+		t.Errorf("Expected no output for the excluded file")
+	}
+}
@@ -0,0 +1,149 @@
+package mirrortransform
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrProtocolClosed is returned by a Protocol callback when the peer closed
+// its input before answering.
+var ErrProtocolClosed = errors.New("protocol input closed")
+
+// ProtocolMessage is a task sent to the peer of a Protocol, one JSON object
+// per line.
+type ProtocolMessage struct {
+	// Type is "task".
+	Type string `json:"type"`
+
+	// ID identifies the task; the peer echoes it in its ProtocolResult.
+	ID uint64 `json:"id"`
+
+	// Task describes the file to process.
+	Task Task `json:"task"`
+}
+
+// ProtocolResult is the peer's answer to a ProtocolMessage, one JSON object
+// per line. Results may arrive in any order.
+type ProtocolResult struct {
+	// ID is the ID of the task being answered.
+	ID uint64 `json:"id"`
+
+	// Error, if not empty, marks the task as failed.
+	Error string `json:"error,omitempty"`
+
+	// Stop stops processing after this task, like returning false from a
+	// FileCallback.
+	Stop bool `json:"stop,omitempty"`
+}
+
+// Protocol lets a process written in any language perform the work of a
+// TaskCallback: each task is written to w as a ProtocolMessage, and the
+// callback returns when the matching ProtocolResult is read from r. Typically
+// w and r are the stdin and stdout of a subprocess, or the stdout and stdin of
+// the current process when it is driven by an orchestrator.
+// Several tasks may be in flight at once, up to the configured concurrency.
+type Protocol struct {
+	r io.Reader
+
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	mu       sync.Mutex
+	nextID   uint64
+	pending  map[uint64]chan ProtocolResult
+	readErr  error
+	readOnce sync.Once
+}
+
+// NewProtocol returns a Protocol writing tasks to w and reading results from r.
+func NewProtocol(r io.Reader, w io.Writer) *Protocol {
+	return &Protocol{
+		r:       r,
+		encoder: json.NewEncoder(w),
+		pending: make(map[uint64]chan ProtocolResult),
+	}
+}
+
+// TaskCallback sends task to the peer and waits for its result. Use it as
+// Config.TaskCallback.
+func (p *Protocol) TaskCallback(task Task) (bool, error) {
+	// Register before reading and writing so a fast answer is not lost
+	done := make(chan ProtocolResult, 1)
+	p.mu.Lock()
+	if p.readErr != nil {
+		err := p.readErr
+		p.mu.Unlock()
+		return false, err
+	}
+	p.nextID++
+	id := p.nextID
+	p.pending[id] = done
+	p.mu.Unlock()
+	p.readOnce.Do(func() { go p.readResults() })
+
+	p.writeMu.Lock()
+	err := p.encoder.Encode(ProtocolMessage{Type: "task", ID: id, Task: task})
+	p.writeMu.Unlock()
+	if err != nil {
+		p.mu.Lock()
+		delete(p.pending, id)
+		p.mu.Unlock()
+		return false, fmt.Errorf("failed to send task %q: %w", task.InputPath, err)
+	}
+
+	result, ok := <-done
+	if !ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return false, p.readErr
+	}
+	if result.Error != "" {
+		return !result.Stop, errors.New(result.Error)
+	}
+	return !result.Stop, nil
+}
+
+// readResults delivers results to the waiting callbacks until r is exhausted,
+// then fails the remaining ones.
+func (p *Protocol) readResults() {
+	scanner := bufio.NewScanner(p.r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	err := ErrProtocolClosed
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var result ProtocolResult
+		if jsonErr := json.Unmarshal(scanner.Bytes(), &result); jsonErr != nil {
+			err = fmt.Errorf("invalid protocol result %q: %w", scanner.Text(), jsonErr)
+			break
+		}
+
+		p.mu.Lock()
+		done, ok := p.pending[result.ID]
+		delete(p.pending, result.ID)
+		p.mu.Unlock()
+
+		// Unknown IDs are ignored; the peer may answer a task twice
+		if ok {
+			done <- result
+		}
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		err = fmt.Errorf("failed to read protocol results: %w", scanErr)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readErr = err
+	for id, done := range p.pending {
+		close(done)
+		delete(p.pending, id)
+	}
+}
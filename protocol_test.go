@@ -0,0 +1,104 @@
+package mirrortransform
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProtocol tests that tasks are sent to the peer and its results are
+// applied, including failures reported by the peer.
+func TestProtocol(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "dir/c.jpg", "bad.jpg"})
+
+	tasksR, tasksW := io.Pipe()
+	resultsR, resultsW := io.Pipe()
+	protocol := NewProtocol(resultsR, tasksW)
+
+	// Play the peer: copy every input, fail bad.jpg
+	peerErr := make(chan error, 1)
+	go func() {
+		defer resultsW.Close()
+		scanner := bufio.NewScanner(tasksR)
+		encoder := json.NewEncoder(resultsW)
+		for scanner.Scan() {
+			var msg ProtocolMessage
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				peerErr <- err
+				return
+			}
+			result := ProtocolResult{ID: msg.ID}
+			if msg.Type != "task" {
+				result.Error = "unexpected message type " + msg.Type
+			} else if filepath.Base(msg.Task.RelPath) == "bad.jpg" {
+				result.Error = "cannot convert"
+			} else if data, err := os.ReadFile(msg.Task.InputPath); err != nil {
+				result.Error = err.Error()
+			} else if err := os.WriteFile(msg.Task.OutputPath, data, 0644); err != nil {
+				result.Error = err.Error()
+			}
+			if err := encoder.Encode(result); err != nil {
+				peerErr <- err
+				return
+			}
+		}
+		peerErr <- scanner.Err()
+	}()
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		MaxConcurrency:  2,
+		ContinueOnError: true,
+		TaskCallback:    protocol.TaskCallback,
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	crawlErr := mt.Crawl(context.Background())
+	if crawlErr == nil || !strings.Contains(crawlErr.Error(), "cannot convert") {
+		t.Errorf("Expected the peer's failure to be reported, got %v", crawlErr)
+	}
+
+	for _, relPath := range []string{"a.jpg", "b.jpg", "dir/c.jpg"} {
+		if _, err := os.Stat(filepath.Join(outputDir, relPath)); err != nil {
+			t.Errorf("Expected output for %s: %v", relPath, err)
+		}
+	}
+
+	tasksW.Close()
+	if err := <-peerErr; err != nil {
+		t.Errorf("Peer failed: %v", err)
+	}
+}
+
+// TestProtocolClosed tests that callbacks fail once the peer closes its output.
+func TestProtocolClosed(t *testing.T) {
+	t.Parallel()
+
+	protocol := NewProtocol(strings.NewReader(`{"id":1,"stop":true}`+"\n"), io.Discard)
+
+	next, err := protocol.TaskCallback(Task{})
+	if err != nil || next {
+		t.Errorf("Expected the first task to stop processing, got %v, %v", next, err)
+	}
+
+	if _, err := protocol.TaskCallback(Task{}); !errors.Is(err, ErrProtocolClosed) {
+		t.Errorf("Expected ErrProtocolClosed, got %v", err)
+	}
+}
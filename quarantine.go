@@ -0,0 +1,63 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// QuarantineErrorSuffix is appended to the name of a quarantined input for
+// the file recording why its callback failed.
+const QuarantineErrorSuffix = ".error"
+
+// quarantinePath returns where the input of task is quarantined: its
+// relative path in QuarantineDir, below the archive's name for an archive
+// entry, such as "assets.zip/images/a.jpg".
+func (mt *mirrorTransform) quarantinePath(task fileTask) string {
+	if task.archive != "" {
+		return filepath.Join(mt.config.QuarantineDir, filepath.Base(task.archive), task.RelPath)
+	}
+	return filepath.Join(mt.config.QuarantineDir, task.RelPath)
+}
+
+// quarantine copies the input of task into QuarantineDir, keeping its
+// relative path, and records failure in a file beside it, together with
+// the archive an archive entry came from.
+func (mt *mirrorTransform) quarantine(task fileTask, failure error) error {
+	if mt.config.QuarantineDir == "" {
+		return nil
+	}
+	path := mt.quarantinePath(task)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory for %q: %w", task.InputPath, err)
+	}
+	if err := CopyFile(task.InputPath, path, CopyOptions{}); err != nil {
+		return fmt.Errorf("failed to quarantine %q: %w", task.InputPath, err)
+	}
+	record := failure.Error() + "\n"
+	if task.archive != "" {
+		record += fmt.Sprintf("archive: %s\nentry: %s\n", task.archive, filepath.ToSlash(task.RelPath))
+	}
+	if err := os.WriteFile(path+QuarantineErrorSuffix, []byte(record), 0644); err != nil {
+		return fmt.Errorf("failed to record error of quarantined %q: %w", task.InputPath, err)
+	}
+	mt.log().Info("quarantined file", "path", task.RelPath, "quarantine", path)
+	return nil
+}
+
+// unquarantine removes the quarantined copy of the input of task, if any,
+// once its callback has succeeded.
+func (mt *mirrorTransform) unquarantine(task fileTask) error {
+	if mt.config.QuarantineDir == "" {
+		return nil
+	}
+	path := mt.quarantinePath(task)
+	for _, p := range []string{path, path + QuarantineErrorSuffix} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to release quarantined %q: %w", task.InputPath, err)
+		}
+	}
+	return nil
+}
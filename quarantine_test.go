@@ -0,0 +1,110 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestQuarantineDir tests that inputs failing for good are copied into
+// QuarantineDir with their error, and removed again once they succeed.
+func TestQuarantineDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		backoff  *FailureBackoff
+		attempts int
+	}{
+		{name: "without backoff", attempts: 1},
+		{name: "after parking", backoff: &FailureBackoff{MaxFailures: 2}, attempts: 2},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			quarantineDir := filepath.Join(testDir, "quarantine")
+			createTestFiles(t, inputDir, []string{"ok.jpg", "sub/bad.jpg"})
+
+			config := Config{
+				InputDir:        inputDir,
+				OutputDir:       filepath.Join(testDir, "output"),
+				Patterns:        []string{"**/*.jpg"},
+				QuarantineDir:   quarantineDir,
+				FailureBackoff:  tt.backoff,
+				ContinueOnError: true,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					data, err := os.ReadFile(inputPath)
+					if err != nil {
+						return false, err
+					}
+					if string(data) == "test content" && filepath.Base(inputPath) == "bad.jpg" {
+						return false, errors.New("corrupt image")
+					}
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			quarantined := filepath.Join(quarantineDir, "sub", "bad.jpg")
+			for i := 1; i <= tt.attempts; i++ {
+				if _, err := os.Stat(quarantined); !os.IsNotExist(err) {
+					t.Fatalf("Expected no quarantine before attempt %d", i)
+				}
+				if err := mt.Crawl(context.Background(), WithForce()); err == nil {
+					t.Fatalf("Expected the failure to be reported")
+				}
+			}
+
+			data, err := os.ReadFile(quarantined)
+			if err != nil || string(data) != "test content" {
+				t.Fatalf("Expected a copy of the input in quarantine, got %q, %v", data, err)
+			}
+			record, err := os.ReadFile(quarantined + QuarantineErrorSuffix)
+			if err != nil || !strings.Contains(string(record), "corrupt image") {
+				t.Errorf("Expected the error beside the copy, got %q, %v", record, err)
+			}
+			if _, err := os.Stat(filepath.Join(quarantineDir, "ok.jpg")); !os.IsNotExist(err) {
+				t.Errorf("Expected successful files not to be quarantined")
+			}
+
+			// A fixed input leaves quarantine
+			if err := os.WriteFile(filepath.Join(inputDir, "sub", "bad.jpg"), []byte("fixed"), 0644); err != nil {
+				t.Fatalf("Failed to fix input: %v", err)
+			}
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+			for _, path := range []string{quarantined, quarantined + QuarantineErrorSuffix} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed", path)
+				}
+			}
+		})
+	}
+}
+
+// TestQuarantineDirInsideInput tests that a quarantine directory inside
+// InputDir is rejected.
+func TestQuarantineDirInsideInput(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:      "input",
+		OutputDir:     "output",
+		Patterns:      []string{"**/*"},
+		QuarantineDir: filepath.Join("input", "quarantine"),
+		FileCallback:  func(inputPath, outputPath string) (bool, error) { return true, nil },
+	}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "quarantine") {
+		t.Errorf("Expected a quarantine error, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package mirrortransform
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// SmallestFirst is a TaskSorter that processes smaller files first.
+func SmallestFirst(a, b FileTask) bool {
+	if a.Size != b.Size {
+		return a.Size < b.Size
+	}
+	return a.RelPath < b.RelPath
+}
+
+// NewestFirst is a TaskSorter that processes recently modified files first.
+func NewestFirst(a, b FileTask) bool {
+	if !a.ModTime.Equal(b.ModTime) {
+		return a.ModTime.After(b.ModTime)
+	}
+	return a.RelPath < b.RelPath
+}
+
+// ByPath is a TaskSorter that processes files in lexicographic order of
+// their relative paths.
+func ByPath(a, b FileTask) bool {
+	return a.RelPath < b.RelPath
+}
+
+// taskSorter returns the configured TaskSorter, ByPath in Ordered mode if
+// none is set, or nil.
+func (mt *mirrorTransform) taskSorter() TaskSorter {
+	if mt.config.TaskSorter == nil && mt.config.Ordered {
+		return ByPath
+	}
+	return mt.config.TaskSorter
+}
+
+// queuedTask is a task waiting in a taskHeap.
+type queuedTask struct {
+	task     fileTask
+	priority int
+	seq      uint64
+
+	// urgent is set once the task has waited too long for its latency objective.
+	urgent bool
+}
+
+// taskHeap is a priority queue of file tasks. Urgent tasks come first in
+// arrival order, then tasks are ordered by priority hint, then by a
+// TaskSorter if set, then by arrival.
+type taskHeap struct {
+	tasks []queuedTask
+	less  TaskSorter
+}
+
+func (h *taskHeap) Len() int { return len(h.tasks) }
+
+func (h *taskHeap) Less(i, j int) bool {
+	a, b := h.tasks[i], h.tasks[j]
+	if a.urgent != b.urgent {
+		return a.urgent
+	}
+	if a.urgent {
+		return a.seq < b.seq
+	}
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if h.less != nil {
+		ai, bi := a.task.FileTask, b.task.FileTask
+		if h.less(ai, bi) {
+			return true
+		}
+		if h.less(bi, ai) {
+			return false
+		}
+	}
+	return a.seq < b.seq
+}
+
+func (h *taskHeap) Swap(i, j int) { h.tasks[i], h.tasks[j] = h.tasks[j], h.tasks[i] }
+
+func (h *taskHeap) Push(x any) { h.tasks = append(h.tasks, x.(queuedTask)) }
+
+func (h *taskHeap) Pop() any {
+	n := len(h.tasks)
+	task := h.tasks[n-1]
+	h.tasks[n-1] = queuedTask{}
+	h.tasks = h.tasks[:n-1]
+	return task
+}
+
+// dispatchChannel returns the channel file processors should read from.
+// When a TaskSorter (or Ordered), PriorityHints or a LatencyObjective are configured, a
+// queue goroutine is placed between taskChan and the processors to reorder
+// pending tasks.
+// If holdUntilClosed is true and a TaskSorter is set, nothing is dispatched
+// until taskChan is closed.
+// When Prefetch is set, a read-ahead stage follows the queue.
+// Tasks always pass a buffer first that holds them while paused, and count
+// as queued in run until a worker picks them up.
+func (mt *mirrorTransform) dispatchChannel(ctx context.Context, run *runState, taskChan <-chan fileTask, holdUntilClosed bool, wg *sync.WaitGroup) <-chan fileTask {
+	// Hold files reported by the watcher until they stop changing
+	if mt.config.StabilityWindow > 0 {
+		stable := make(chan fileTask)
+		in := taskChan
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runStabilizer(ctx, in, stable, mt.config.StabilityWindow)
+		}()
+		taskChan = stable
+	}
+
+	buffered := make(chan fileTask)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runPauseBuffer(ctx, taskChan, buffered, mt.pause, run, maxQueuedTasks, maxPausedTasks)
+	}()
+	dispatchChan := (<-chan fileTask)(buffered)
+
+	sorter := mt.taskSorter()
+	if sorter != nil || mt.config.PriorityHints != nil || mt.config.LatencyObjective != nil {
+		sorted := make(chan fileTask)
+		in := dispatchChan
+		hold := holdUntilClosed && sorter != nil
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runTaskQueue(ctx, in, sorted, sorter, mt.priorityOf, mt.urgentAfter(), hold, maxSortedTasks)
+		}()
+		dispatchChan = sorted
+	}
+
+	if mt.config.Prefetch > 0 {
+		prefetched := make(chan fileTask, mt.config.Prefetch)
+		in := dispatchChan
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runPrefetcher(ctx, in, prefetched)
+		}()
+		dispatchChan = prefetched
+	}
+
+	return dispatchChan
+}
+
+// maxSortedTasks is how many pending tasks a task queue reorders at a time
+// unless it holds them until its input is closed. Once full, it stops
+// reading and the queue ahead of it fills up.
+const maxSortedTasks = 10000
+
+// runTaskQueue forwards tasks from in to out, always sending the pending task
+// that sorts first. less and priority may be nil. Tasks that have been
+// queued for urgentAfter are marked urgent; zero disables this.
+// Unless holdUntilClosed, at most limit tasks are pending at a time.
+// out is closed when in is closed and drained, or when ctx is done.
+func runTaskQueue(ctx context.Context, in <-chan fileTask, out chan<- fileTask, less TaskSorter, priority func(fileTask) int, urgentAfter time.Duration, holdUntilClosed bool, limit int) {
+	defer close(out)
+
+	h := &taskHeap{less: less}
+	inputOpen := true
+	var seq uint64
+
+	// Periodically promote tasks that are running out of time
+	var urgentTick <-chan time.Time
+	if urgentAfter > 0 {
+		ticker := time.NewTicker(urgentCheckInterval(urgentAfter))
+		defer ticker.Stop()
+		urgentTick = ticker.C
+	}
+
+	for inputOpen || h.Len() > 0 {
+		// Only offer a task to the processors when one is ready to go
+		var sendChan chan<- fileTask
+		var next fileTask
+		if h.Len() > 0 && (!inputOpen || !holdUntilClosed) {
+			sendChan = out
+			next = h.tasks[0].task
+		}
+
+		// Stop receiving once the input is closed or the queue is full
+		recvChan := in
+		if !inputOpen || (!holdUntilClosed && h.Len() >= limit) {
+			recvChan = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok := <-recvChan:
+			if !ok {
+				inputOpen = false
+				continue
+			}
+			queued := queuedTask{task: task, seq: seq}
+			if priority != nil {
+				queued.priority = priority(task)
+			}
+			seq++
+			heap.Push(h, queued)
+		case sendChan <- next:
+			heap.Pop(h)
+		case now := <-urgentTick:
+			if h.markUrgent(now, urgentAfter) {
+				heap.Init(h)
+			}
+		}
+	}
+}
+
+// markUrgent marks the tasks queued at least urgentAfter before now as urgent
+// and reports whether any task changed. The heap must be re-initialized then.
+func (h *taskHeap) markUrgent(now time.Time, urgentAfter time.Duration) bool {
+	changed := false
+	for i := range h.tasks {
+		queued := &h.tasks[i]
+		if !queued.urgent && !queued.task.queuedAt.IsZero() && now.Sub(queued.task.queuedAt) >= urgentAfter {
+			queued.urgent = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+// urgentCheckInterval returns how often queued tasks are checked for urgency.
+func urgentCheckInterval(urgentAfter time.Duration) time.Duration {
+	interval := urgentAfter / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
@@ -0,0 +1,222 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCrawlTaskSorter tests that sorted crawls process files in sorter order.
+func TestCrawlTaskSorter(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	// Create files whose walk order differs from size and mtime order
+	files := map[string]int{
+		"a.jpg":     300,
+		"b.jpg":     100,
+		"dir/c.jpg": 200,
+	}
+	now := time.Now()
+	for name, size := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", path, err)
+		}
+		// Larger files are newer
+		mtime := now.Add(time.Duration(size) * time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatalf("Failed to set mtime for %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		sorter   TaskSorter
+		expected []string
+	}{
+		{
+			name:     "SmallestFirst",
+			sorter:   SmallestFirst,
+			expected: []string{"b.jpg", "c.jpg", "a.jpg"},
+		},
+		{
+			name:     "NewestFirst",
+			sorter:   NewestFirst,
+			expected: []string{"a.jpg", "c.jpg", "b.jpg"},
+		},
+		{
+			name: "Custom",
+			sorter: func(a, b FileTask) bool {
+				return a.RelPath > b.RelPath
+			},
+			expected: []string{"c.jpg", "b.jpg", "a.jpg"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var order []string
+			var mu sync.Mutex
+
+			config := Config{
+				InputDir:    inputDir,
+				OutputDir:   filepath.Join(outputDir, tt.name),
+				Patterns:    []string{"**/*.jpg"},
+				Concurrency: 1,
+				TaskSorter:  tt.sorter,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					mu.Lock()
+					order = append(order, filepath.Base(inputPath))
+					mu.Unlock()
+					return true, nil
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			if strings.Join(order, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected order %v, got %v", tt.expected, order)
+			}
+		})
+	}
+}
+
+// TestCrawlOrdered tests that Ordered crawls process files one at a time in
+// path order regardless of the configured concurrency.
+func TestCrawlOrdered(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	files := []string{"b/2.jpg", "a/1.jpg", "c.jpg", "a/b/3.jpg", "a.jpg", "b/1.jpg"}
+	createTestFiles(t, inputDir, files)
+
+	var mu sync.Mutex
+	var order []string
+	var running, overlapped int
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		Concurrency:     4,
+		ScanConcurrency: 4,
+		Ordered:         true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			running++
+			if running > 1 {
+				overlapped++
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			order = append(order, filepath.ToSlash(relPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := []string{"a.jpg", "a/1.jpg", "a/b/3.jpg", "b/1.jpg", "b/2.jpg", "c.jpg"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected order %v, got %v", expected, order)
+	}
+	if overlapped != 0 {
+		t.Errorf("Expected callbacks not to overlap, got %d overlaps", overlapped)
+	}
+
+	// Ordering by waiting time is not deterministic
+	config.LatencyObjective = &LatencyObjective{Target: time.Minute}
+	if _, err := NewMirrorTransform(&config); err == nil || !strings.Contains(err.Error(), "ordered") {
+		t.Errorf("Expected ordered mode to conflict with a latency objective, got %v", err)
+	}
+}
+
+// TestRunTaskQueue tests that pending tasks are reordered before dispatch.
+func TestRunTaskQueue(t *testing.T) {
+	t.Parallel()
+	in := make(chan fileTask, 3)
+	out := make(chan fileTask)
+
+	for _, rel := range []string{"b", "c", "a"} {
+		in <- fileTask{FileTask: FileTask{RelPath: rel}}
+	}
+	close(in)
+
+	less := func(a, b FileTask) bool { return a.RelPath < b.RelPath }
+	go runTaskQueue(context.Background(), in, out, less, nil, 0, true, maxSortedTasks)
+
+	var order []string
+	for task := range out {
+		order = append(order, task.RelPath)
+	}
+
+	if strings.Join(order, ",") != "a,b,c" {
+		t.Errorf("Expected order [a b c], got %v", order)
+	}
+}
+
+// TestRunTaskQueueBound tests that a queue that does not hold its tasks
+// takes at most its limit, and still sorts the tasks it has taken.
+func TestRunTaskQueueBound(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan fileTask)
+	out := make(chan fileTask)
+	go runTaskQueue(ctx, in, out, ByPath, nil, 0, false, 3)
+
+	// Nobody reads out, so the queue fills up to its limit
+	accepted := 0
+	for _, rel := range []string{"d", "c", "b", "a"} {
+		select {
+		case in <- fileTask{FileTask: FileTask{RelPath: rel}}:
+			accepted++
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if accepted != 3 {
+		t.Errorf("Expected 3 tasks taken, got %d", accepted)
+	}
+
+	select {
+	case task := <-out:
+		if task.RelPath != "b" {
+			t.Errorf("Expected task b first, got %s", task.RelPath)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a task")
+	}
+}
@@ -0,0 +1,98 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"os"
+)
+
+// QuotaExceededError is returned by Crawl and Watch when MaxOutputBytes is reached.
+// Use errors.As to retrieve it.
+type QuotaExceededError struct {
+	// Limit is the configured MaxOutputBytes.
+	Limit int64
+
+	// Written is the number of output bytes written during the run,
+	// including tasks that were in flight when the quota was reached.
+	Written int64
+
+	// Unprocessed lists the input paths that matched but were not processed
+	// because the quota was reached. Crawl records every remaining match so a
+	// follow-up run can resume with exactly these files.
+	Unprocessed []string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("output quota of %d bytes reached after writing %d bytes; %d files left unprocessed", e.Limit, e.Written, len(e.Unprocessed))
+}
+
+// quotaReached reports whether the run has stopped dispatching because of the quota.
+func (mt *mirrorTransform) quotaReached(run *runState) bool {
+	return mt.config.MaxOutputBytes > 0 && run.quotaReached.Load()
+}
+
+// recordOutputBytes adds the size of the outputs to the run total when a
+// quota is set, a result is collected or ResultCallback reports it.
+// It returns the size and whether this call made the run reach the quota.
+func (mt *mirrorTransform) recordOutputBytes(run *runState, outputs []taskOutput) (int64, bool) {
+	if mt.config.MaxOutputBytes <= 0 && !run.collectResult && mt.config.ResultCallback == nil {
+		return 0, false
+	}
+
+	var size int64
+	for _, output := range outputs {
+		// Callbacks may decide not to write an output
+		if info, err := os.Stat(output.path); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+	}
+
+	written := run.bytesWritten.Add(size)
+	if mt.config.MaxOutputBytes <= 0 || written < mt.config.MaxOutputBytes {
+		return size, false
+	}
+	return size, run.quotaReached.CompareAndSwap(false, true)
+}
+
+// reserveFile counts a file whose callback is about to start and reports
+// whether MaxFiles allows it. Only finite runs have a limit. Once it is
+// reached, no more tasks are produced.
+func (mt *mirrorTransform) reserveFile(run *runState) bool {
+	if run.fileLimit <= 0 {
+		return true
+	}
+	for {
+		started := run.filesStarted.Load()
+		if started >= run.fileLimit {
+			break
+		}
+		if run.filesStarted.CompareAndSwap(started, started+1) {
+			return true
+		}
+	}
+	if run.fileLimitReached.CompareAndSwap(false, true) {
+		mt.log().Info("file limit reached, leaving the remaining files", "limit", run.fileLimit)
+		if run.stopProducing != nil {
+			run.stopProducing()
+		}
+	}
+	return false
+}
+
+// unreserveFile gives back the slot reserveFile counted for a task that is
+// held or dropped before its callback runs, so a retry is not counted twice.
+func (mt *mirrorTransform) unreserveFile(run *runState) {
+	if run.fileLimit > 0 {
+		run.filesStarted.Add(-1)
+	}
+}
+
+// quotaError returns the error describing the reached quota.
+func (mt *mirrorTransform) quotaError(run *runState) error {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return &QuotaExceededError{
+		Limit:       mt.config.MaxOutputBytes,
+		Written:     run.bytesWritten.Load(),
+		Unprocessed: append([]string(nil), run.unprocessed...),
+	}
+}
@@ -0,0 +1,164 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCrawlMaxOutputBytes tests that Crawl stops dispatching once the quota is reached.
+func TestCrawlMaxOutputBytes(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	var files []string
+	for i := 0; i < 10; i++ {
+		files = append(files, fmt.Sprintf("file%d.jpg", i))
+	}
+	createTestFiles(t, inputDir, files)
+
+	var processedCount int32
+
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      filepath.Join(testDir, "output"),
+		Patterns:       []string{"**/*.jpg"},
+		Concurrency:    1,
+		MaxOutputBytes: 250,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			atomic.AddInt32(&processedCount, 1)
+			return true, os.WriteFile(outputPath, []byte(strings.Repeat("x", 100)), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	err = mt.Crawl(context.Background())
+	var quotaErr *QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("Expected QuotaExceededError, got %v", err)
+	}
+
+	if processedCount != 3 {
+		t.Errorf("Expected 3 files to be processed, got %d", processedCount)
+	}
+	if quotaErr.Written != 300 || quotaErr.Limit != 250 {
+		t.Errorf("Unexpected quota error %+v", quotaErr)
+	}
+	if len(quotaErr.Unprocessed) != 7 {
+		t.Errorf("Expected 7 unprocessed files, got %d", len(quotaErr.Unprocessed))
+	}
+	for _, path := range quotaErr.Unprocessed {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Unprocessed entry %s is not an input path: %v", path, err)
+		}
+	}
+}
+
+// TestCrawlMaxFiles tests that Crawl ends without an error once MaxFiles
+// callbacks started, and that OnlyIfStale continues with the rest.
+func TestCrawlMaxFiles(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"1.jpg", "2.jpg", "3.jpg", "4.jpg", "5.jpg"})
+
+	var calls atomic.Int32
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		MaxFiles:    2,
+		OnlyIfStale: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			calls.Add(1)
+			return true, os.WriteFile(outputPath, []byte("output"), 0o644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Each batch processes at most two files
+	for i, want := range []int{2, 2, 1} {
+		result, err := mt.CrawlWithResult(context.Background())
+		if err != nil {
+			t.Fatalf("Crawl %d failed: %v", i, err)
+		}
+		if result.Processed != want {
+			t.Errorf("Crawl %d: expected %d files processed, got %d", i, want, result.Processed)
+		}
+		// The scan stops at the limit, so only queued files are listed
+		remaining := 5 - 2*i - want
+		if result.LimitReached != (remaining > 0) || len(result.Unprocessed) > remaining || (remaining > 0) != (len(result.Unprocessed) > 0) {
+			t.Errorf("Crawl %d: expected %d files left, got %v (limit reached: %v)", i, remaining, result.Unprocessed, result.LimitReached)
+		}
+	}
+	if calls.Load() != 5 {
+		t.Errorf("Expected 5 callbacks, got %d", calls.Load())
+	}
+
+	// A crawl with nothing left to do does not reach the limit
+	result, err := mt.CrawlWithResult(context.Background())
+	if err != nil || result.Processed != 0 || result.LimitReached {
+		t.Errorf("Expected an empty crawl, got %+v: %v", result, err)
+	}
+}
+
+// TestMaxFilesStopsProducer tests that reaching MaxFiles stops the producer
+// of a finite run instead of letting it queue every remaining file.
+func TestMaxFilesStopsProducer(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+	inputPath := filepath.Join(inputDir, "a.jpg")
+
+	var calls atomic.Int32
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		MaxFiles:  2,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			calls.Add(1)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// A producer without end only returns once it is stopped
+	run := newRunState(false)
+	result, err := mt.(*mirrorTransform).runFinite(context.Background(), "Crawl", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		for {
+			if err := mt.(*mirrorTransform).sendTask(ctx, inputPath, "a.jpg", nil, TaskEventScan, "**/*.jpg", taskChan, run); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.LimitReached || result.Processed != 2 || calls.Load() != 2 {
+		t.Errorf("Expected 2 files processed before the limit, got %+v after %d callbacks", result, calls.Load())
+	}
+}
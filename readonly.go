@@ -0,0 +1,296 @@
+package mirrortransform
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWritableCheckInterval is how often a read-only output is probed
+// when ReadOnlyOutput.CheckInterval is not set.
+const defaultWritableCheckInterval = 10 * time.Second
+
+// ReadOnlyOutput pauses processing when the output file system becomes
+// read-only, for example after an error remount, instead of failing every
+// task. Tasks are held while paused and processed once writes succeed again.
+type ReadOnlyOutput struct {
+	// CheckInterval is how often the output is probed for writability while
+	// paused. Defaults to 10 seconds.
+	CheckInterval time.Duration
+
+	// PendingFile, if set, records the inputs held while paused, one relative
+	// path per line, so they are processed by the next Watch if the process
+	// stops before the output recovers.
+	PendingFile string
+
+	// HealthCallback is called when processing pauses and when it resumes.
+	HealthCallback OutputHealthCallback
+}
+
+// OutputHealthCallback is called when the writability of the output changes.
+type OutputHealthCallback func(event OutputHealthEvent)
+
+// OutputHealthEvent describes a change in the writability of the output.
+type OutputHealthEvent struct {
+	// Writable is false when processing paused and true when it resumed.
+	Writable bool
+
+	// Err is the error that paused processing; nil on resume.
+	Err error
+
+	// Held is the number of tasks held while paused; zero on pause.
+	Held int
+
+	// Time is when the change was detected.
+	Time time.Time
+}
+
+// validateReadOnlyOutput checks the read-only output settings.
+func validateReadOnlyOutput(readOnly *ReadOnlyOutput) error {
+	if readOnly == nil {
+		return nil
+	}
+	if readOnly.CheckInterval < 0 {
+		return fmt.Errorf("read-only output check interval must not be negative")
+	}
+	return nil
+}
+
+// outputGate holds tasks while the output is read-only.
+type outputGate struct {
+	config    ReadOnlyOutput
+	outputDir string
+	logger    *slog.Logger
+
+	mu        sync.Mutex
+	paused    bool
+	resumedCh chan struct{}
+	held      []fileTask
+}
+
+// newOutputGate returns a gate if ReadOnlyOutput is configured, or nil.
+func newOutputGate(config *Config) *outputGate {
+	if config.ReadOnlyOutput == nil {
+		return nil
+	}
+	gate := &outputGate{config: *config.ReadOnlyOutput, outputDir: config.OutputDir, logger: configLogger(config)}
+	if gate.config.CheckInterval <= 0 {
+		gate.config.CheckInterval = defaultWritableCheckInterval
+	}
+	return gate
+}
+
+// reset forgets the state of a previous run. Held tasks remain in PendingFile.
+func (g *outputGate) reset() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = false
+	g.held = nil
+}
+
+// pauseOn pauses processing and holds task if err shows that the output is
+// read-only. It reports whether the task was held.
+func (g *outputGate) pauseOn(ctx context.Context, err error, task fileTask) bool {
+	if g == nil || !isReadOnlyError(err) {
+		return false
+	}
+
+	g.mu.Lock()
+	started := !g.paused
+	if started {
+		g.paused = true
+		g.resumedCh = make(chan struct{})
+	}
+	g.mu.Unlock()
+
+	if started {
+		g.notify(OutputHealthEvent{Writable: false, Err: err, Time: time.Now()})
+		go g.probe(ctx)
+	}
+	return g.hold(task)
+}
+
+// hold keeps task for later if processing is paused and reports whether it did.
+func (g *outputGate) hold(task fileTask) bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	g.held = append(g.held, task)
+	g.appendPending(task)
+	g.logger.Debug("holding file until the output is writable", "path", task.RelPath)
+	return true
+}
+
+// take returns a held task once processing has resumed.
+func (g *outputGate) take() (fileTask, bool) {
+	if g == nil {
+		return fileTask{}, false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused || len(g.held) == 0 {
+		return fileTask{}, false
+	}
+	task := g.held[0]
+	g.held[0] = fileTask{}
+	g.held = g.held[1:]
+	if len(g.held) == 0 && g.config.PendingFile != "" {
+		os.Remove(g.config.PendingFile)
+	}
+	return task, true
+}
+
+// resumed returns a channel closed when a pause ends, or nil if not paused.
+func (g *outputGate) resumed() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return nil
+	}
+	return g.resumedCh
+}
+
+// waitHeld waits until held tasks can be processed. It returns false if no
+// tasks are held or ctx is done first.
+func (g *outputGate) waitHeld(ctx context.Context) bool {
+	if g == nil {
+		return false
+	}
+
+	g.mu.Lock()
+	held := len(g.held) > 0
+	g.mu.Unlock()
+	if !held {
+		return false
+	}
+
+	if resumed := g.resumed(); resumed != nil {
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// probe checks the output for writability until it recovers or ctx is done.
+func (g *outputGate) probe(ctx context.Context) {
+	ticker := time.NewTicker(g.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !isWritable(g.outputDir) {
+				continue
+			}
+
+			g.mu.Lock()
+			g.paused = false
+			close(g.resumedCh)
+			held := len(g.held)
+			g.mu.Unlock()
+
+			g.notify(OutputHealthEvent{Writable: true, Held: held, Time: time.Now()})
+			return
+		}
+	}
+}
+
+// notify logs event and calls HealthCallback if set.
+func (g *outputGate) notify(event OutputHealthEvent) {
+	if event.Writable {
+		g.logger.Info("output is writable again, resuming", "dir", g.outputDir, "held", event.Held)
+	} else {
+		g.logger.Warn("output is read-only, holding files", "dir", g.outputDir, "error", event.Err)
+	}
+	if g.config.HealthCallback != nil {
+		g.config.HealthCallback(event)
+	}
+}
+
+// appendPending records a held task in PendingFile. The caller must hold g.mu.
+// Failures are ignored: the task is still held in memory.
+func (g *outputGate) appendPending(task fileTask) {
+	if g.config.PendingFile == "" {
+		return
+	}
+	file, err := os.OpenFile(g.config.PendingFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, filepath.ToSlash(task.RelPath))
+}
+
+// isWritable reports whether a file can be created in dir.
+func isWritable(dir string) bool {
+	file, err := os.CreateTemp(dir, ".mirror-writable-")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return true
+}
+
+// loadPendingTasks returns the inputs recorded in PendingFile by a previous
+// run and removes the file.
+func (mt *mirrorTransform) loadPendingTasks() ([]string, error) {
+	if mt.config.ReadOnlyOutput == nil || mt.config.ReadOnlyOutput.PendingFile == "" {
+		return nil, nil
+	}
+	path := mt.config.ReadOnlyOutput.PendingFile
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open pending file %q: %w", path, err)
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	var relPaths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		relPath := strings.TrimSpace(scanner.Text())
+		if relPath == "" || seen[relPath] {
+			continue
+		}
+		seen[relPath] = true
+		relPaths = append(relPaths, filepath.FromSlash(relPath))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending file %q: %w", path, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return nil, fmt.Errorf("failed to remove pending file %q: %w", path, err)
+	}
+	return relPaths, nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package mirrortransform
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isReadOnlyError reports whether err was caused by a read-only file system.
+func isReadOnlyError(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
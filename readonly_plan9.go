@@ -0,0 +1,7 @@
+package mirrortransform
+
+// isReadOnlyError returns false: Plan 9 reports read-only file systems with
+// plain error strings that cannot be told apart reliably.
+func isReadOnlyError(err error) bool {
+	return false
+}
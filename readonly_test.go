@@ -0,0 +1,149 @@
+//go:build !plan9
+
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestReadOnlyOutputPauseResume tests that tasks failing on a read-only output
+// are held and processed once the output is writable again.
+func TestReadOnlyOutputPauseResume(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	pendingFile := filepath.Join(testDir, "pending.txt")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "c.jpg"})
+
+	var readOnly atomic.Bool
+	readOnly.Store(true)
+
+	var mu sync.Mutex
+	var events []OutputHealthEvent
+	var processed []string
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		ReadOnlyOutput: &ReadOnlyOutput{
+			CheckInterval: 20 * time.Millisecond,
+			PendingFile:   pendingFile,
+			HealthCallback: func(event OutputHealthEvent) {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+
+				// The remount is fixed after the first alert
+				if !event.Writable {
+					readOnly.Store(false)
+				}
+			},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if readOnly.Load() {
+				return false, fmt.Errorf("failed to write %q: %w", outputPath, &os.PathError{Op: "open", Path: outputPath, Err: syscall.EROFS})
+			}
+			mu.Lock()
+			processed = append(processed, filepath.Base(inputPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := mt.Crawl(ctx); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(processed) != 3 {
+		t.Errorf("Expected 3 processed files, got %v", processed)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 health events, got %d", len(events))
+	}
+	if events[0].Writable || events[0].Err == nil {
+		t.Errorf("Expected a pause event with an error, got %+v", events[0])
+	}
+	if !events[1].Writable || events[1].Held == 0 {
+		t.Errorf("Expected a resume event with held tasks, got %+v", events[1])
+	}
+	if _, err := os.Stat(pendingFile); !os.IsNotExist(err) {
+		t.Errorf("Expected pending file to be removed after resuming, got %v", err)
+	}
+}
+
+// TestReadOnlyOutputDisabled tests that read-only errors fail tasks when
+// ReadOnlyOutput is not set.
+func TestReadOnlyOutputDisabled(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return false, &os.PathError{Op: "open", Path: outputPath, Err: syscall.EROFS}
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Error("Expected Crawl to fail on a read-only output")
+	}
+}
+
+// TestLoadPendingTasks tests that held inputs recorded by a previous run are
+// read back once.
+func TestLoadPendingTasks(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	pendingFile := filepath.Join(testDir, "pending.txt")
+
+	if err := os.WriteFile(pendingFile, []byte("a.jpg\nsub/b.jpg\na.jpg\n\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write pending file: %v", err)
+	}
+
+	mt := &mirrorTransform{config: Config{ReadOnlyOutput: &ReadOnlyOutput{PendingFile: pendingFile}}}
+
+	relPaths, err := mt.loadPendingTasks()
+	if err != nil {
+		t.Fatalf("loadPendingTasks failed: %v", err)
+	}
+	expected := []string{"a.jpg", filepath.Join("sub", "b.jpg")}
+	if len(relPaths) != len(expected) || relPaths[0] != expected[0] || relPaths[1] != expected[1] {
+		t.Errorf("Expected %v, got %v", expected, relPaths)
+	}
+	if _, err := os.Stat(pendingFile); !os.IsNotExist(err) {
+		t.Errorf("Expected pending file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package mirrortransform
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorWriteProtect is ERROR_WRITE_PROTECT, returned for write-protected media.
+const errorWriteProtect syscall.Errno = 19
+
+// isReadOnlyError reports whether err was caused by a read-only file system.
+func isReadOnlyError(err error) bool {
+	return errors.Is(err, syscall.EROFS) || errors.Is(err, errorWriteProtect)
+}
@@ -0,0 +1,154 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// reconfigurableFields are the Config fields Reconfigure can change.
+var reconfigurableFields = map[string]bool{
+	"Patterns":        true,
+	"ExcludePatterns": true,
+	"Concurrency":     true,
+	"MaxConcurrency":  true,
+}
+
+// Reconfigure swaps in a new configuration while runs are in progress.
+// newConfig is validated as a whole, then its patterns, exclusions and
+// concurrency replace the current ones at once, as with UpdateConfig.
+// Running watchers start watching the directories no longer excluded and
+// process the existing files that newly match, so coverage never lapses.
+// Other fields must be unchanged; changing them requires a new instance.
+// Callbacks are kept as they are.
+func (mt *mirrorTransform) Reconfigure(newConfig Config) error {
+	if err := newConfig.Validate(); err != nil {
+		return err
+	}
+	newConfig.InputDir = filepath.Clean(newConfig.InputDir)
+	newConfig.OutputDir = filepath.Clean(newConfig.OutputDir)
+
+	// Check that only reconfigurable fields change
+	if changed := changedConfigFields(&mt.config, &newConfig); len(changed) > 0 {
+		return fmt.Errorf("reconfigure cannot change %s; create a new instance instead", strings.Join(changed, ", "))
+	}
+
+	excludePatterns := newConfig.ExcludePatterns
+	if excludePatterns == nil {
+		excludePatterns = []string{}
+	}
+	return mt.applyUpdate(ConfigUpdate{
+		Patterns:        newConfig.Patterns,
+		ExcludePatterns: excludePatterns,
+		Concurrency:     &newConfig.Concurrency,
+		MaxConcurrency:  &newConfig.MaxConcurrency,
+	}, true)
+}
+
+// changedConfigFields returns the names of the fields that differ between a
+// and b, except reconfigurable fields and callbacks.
+func changedConfigFields(a, b *Config) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var changed []string
+	for i := 0; i < va.NumField(); i++ {
+		field := va.Type().Field(i)
+		if !field.IsExported() || reconfigurableFields[field.Name] || field.Type.Kind() == reflect.Func {
+			continue
+		}
+		fa, fb := va.Field(i), vb.Field(i)
+
+		// Config files leave unset lists nil, Go code often empty
+		if kind := field.Type.Kind(); (kind == reflect.Slice || kind == reflect.Map) && fa.Len() == 0 && fb.Len() == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			changed = append(changed, field.Name)
+		}
+	}
+	return changed
+}
+
+// reconcileIncluded sends a task for every existing file below root that
+// matches the current settings but was excluded or unmatched under previous.
+// Files are selected as a scan of run would select them, asking DirCallback
+// about directories it has not asked about yet.
+func (mt *mirrorTransform) reconcileIncluded(ctx context.Context, root string, previous *runtimeSettings, taskChan chan<- fileTask, run *runState) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return mt.handlePathError(path, err, "access")
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		relPath, err := mt.relPath(path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path for %q: %w", path, err)
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		// Skip what a scan skips now, with everything below it
+		pattern, err := mt.entryPattern(path, relPath, d)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if run.options.dryRun {
+				return nil
+			}
+
+			// Let DirCallback skip the whole subtree
+			enter, err := mt.enterRunDir(run, path, relPath)
+			if err != nil {
+				return err
+			}
+			if !enter {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if pattern == "" {
+			return nil
+		}
+
+		// Files included before are up to date or left to the watcher
+		wasExcluded, err := mt.excludedIn(previous, relPath)
+		if err != nil {
+			return err
+		}
+		if !wasExcluded {
+			previousPattern, err := mt.matchPatternIn(previous, relPath, d)
+			if err != nil {
+				return err
+			}
+			if previousPattern != "" {
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return mt.handlePathError(path, err, "stat")
+		}
+		outputPath, err := mt.outputPath(relPath)
+		if err != nil {
+			return err
+		}
+		mt.log().Debug("reconciling newly included file", "path", relPath)
+
+		select {
+		case taskChan <- newFileTask(path, outputPath, relPath, info, TaskEventScan, pattern):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestReconfigureWatch tests that a running watcher processes the existing
+// files a new configuration includes, and watches them afterwards.
+func TestReconfigureWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.png", "skip/c.jpg", "other/d.jpg"})
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"skip"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)]++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	newConfig := config
+	newConfig.Patterns = []string{"**/*.jpg", "**/*.png"}
+	newConfig.ExcludePatterns = []string{"other"}
+	if err := mt.Reconfigure(newConfig); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+
+	// Give watcher time to reconcile and add the included directories
+	time.Sleep(300 * time.Millisecond)
+
+	createTestFiles(t, inputDir, []string{"skip/e.jpg"})
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, relPath := range []string{"b.png", "skip/c.jpg"} {
+		if processed[relPath] != 1 {
+			t.Errorf("Expected %s to be processed once, got %v", relPath, processed)
+		}
+	}
+	if processed["skip/e.jpg"] == 0 {
+		t.Errorf("Expected skip/e.jpg to be watched once skip is no longer excluded, got %v", processed)
+	}
+	for _, relPath := range []string{"a.jpg", "other/d.jpg"} {
+		if processed[relPath] != 0 {
+			t.Errorf("Expected %s not to be processed, got %v", relPath, processed)
+		}
+	}
+}
+
+// TestReconfigureDirCallback tests that reconciling a new configuration
+// leaves out the files in directories DirCallback skipped, as a crawl does.
+func TestReconfigureDirCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.png", "veto/b.png", "skip/c.png", "skip/veto/d.png"})
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"skip"},
+		DirCallback: func(inputDir, outputDir string) (bool, error) {
+			return filepath.Base(inputDir) != "veto", nil
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)]++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	newConfig := config
+	newConfig.Patterns = []string{"**/*.png"}
+	newConfig.ExcludePatterns = nil
+	if err := mt.Reconfigure(newConfig); err != nil {
+		t.Fatalf("Reconfigure failed: %v", err)
+	}
+
+	// Give watcher time to reconcile
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, relPath := range []string{"a.png", "skip/c.png"} {
+		if processed[relPath] != 1 {
+			t.Errorf("Expected %s to be processed once, got %v", relPath, processed)
+		}
+	}
+	for _, relPath := range []string{"veto/b.png", "skip/veto/d.png"} {
+		if processed[relPath] != 0 {
+			t.Errorf("Expected %s not to be processed, got %v", relPath, processed)
+		}
+	}
+}
+
+// TestReconfigureRejectsFixedFields tests that fields other than patterns,
+// exclusions and concurrency cannot be reconfigured.
+func TestReconfigureRejectsFixedFields(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	config := Config{
+		InputDir:  filepath.Join(testDir, "input"),
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, nil, 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	newConfig := config
+	newConfig.OutputDir = filepath.Join(testDir, "elsewhere")
+	newConfig.Concurrency = 4
+	err = mt.Reconfigure(newConfig)
+	if err == nil || !strings.Contains(err.Error(), "OutputDir") {
+		t.Errorf("Expected OutputDir change to be rejected, got %v", err)
+	}
+
+	newConfig = config
+	newConfig.Patterns = nil
+	if err := mt.Reconfigure(newConfig); err == nil {
+		t.Error("Expected invalid config to be rejected")
+	}
+
+	newConfig = config
+	newConfig.Concurrency = 4
+	if err := mt.Reconfigure(newConfig); err != nil {
+		t.Errorf("Expected concurrency change to be accepted, got %v", err)
+	}
+}
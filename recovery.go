@@ -0,0 +1,178 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PartialMarkerSuffix is appended to an output path to name the marker file
+// that exists while the output is being produced.
+const PartialMarkerSuffix = ".partially-processed"
+
+// writePartialMarkers creates a marker next to every output of the task.
+// Each marker holds the slash-separated input path relative to InputDir.
+func (mt *mirrorTransform) writePartialMarkers(task fileTask, outputs []taskOutput) error {
+	if !mt.config.RecoverPartialOutputs {
+		return nil
+	}
+	for _, output := range outputs {
+		marker := output.path + PartialMarkerSuffix
+		if err := os.WriteFile(marker, []byte(filepath.ToSlash(task.RelPath)), 0o644); err != nil {
+			return fmt.Errorf("failed to write partial marker %q: %w", marker, err)
+		}
+	}
+	return nil
+}
+
+// removePartialMarkers removes the markers written by writePartialMarkers.
+func (mt *mirrorTransform) removePartialMarkers(outputs []taskOutput) error {
+	if !mt.config.RecoverPartialOutputs {
+		return nil
+	}
+	for _, output := range outputs {
+		marker := output.path + PartialMarkerSuffix
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove partial marker %q: %w", marker, err)
+		}
+	}
+	return nil
+}
+
+// staleTempFileAge is how long a temporary file must have been left
+// unchanged before CleanStaleTempFiles removes it, so files still being
+// written by another process are kept.
+const staleTempFileAge = time.Minute
+
+// recoverPartialOutputs finds markers left behind by an interrupted run,
+// removes them together with their half-written outputs, and returns the
+// relative paths of the inputs that need to be processed again. With
+// CleanStaleTempFiles, it also removes the temporary files of interrupted
+// writes.
+func (mt *mirrorTransform) recoverPartialOutputs() (relPaths []string, err error) {
+	// Starting a generation also clears one left writing by a crash
+	if err := mt.generation.acquire(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if releaseErr := mt.generation.release(); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+	}()
+
+	if !mt.config.RecoverPartialOutputs && !mt.config.CleanStaleTempFiles {
+		return nil, nil
+	}
+
+	roots := []string{mt.config.OutputDir}
+	for _, v := range mt.config.Variants {
+		if v.Dir != "" {
+			roots = append(roots, mt.variantDir(v))
+		}
+	}
+
+	seen := make(map[string]bool)
+	now := time.Now()
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return fmt.Errorf("failed to scan output directory for partial markers: %w", err)
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			// Remove temporary files whose write was interrupted
+			if mt.config.CleanStaleTempFiles && strings.HasPrefix(info.Name(), tempFilePrefix) {
+				return mt.removeStaleTempFile(path, info, now)
+			}
+
+			if !mt.config.RecoverPartialOutputs || !strings.HasSuffix(path, PartialMarkerSuffix) {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read partial marker %q: %w", path, err)
+			}
+
+			// Remove the half-written output before the marker
+			output := strings.TrimSuffix(path, PartialMarkerSuffix)
+			if err := os.Remove(output); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove partial output %q: %w", output, err)
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove partial marker %q: %w", path, err)
+			}
+
+			relPath := filepath.FromSlash(strings.TrimSpace(string(data)))
+			if relPath != "" && !seen[relPath] {
+				seen[relPath] = true
+				relPaths = append(relPaths, relPath)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return relPaths, nil
+}
+
+// removeStaleTempFile removes the temporary file at path unless it changed
+// within staleTempFileAge of now, and reports it to StaleTempFileCallback.
+func (mt *mirrorTransform) removeStaleTempFile(path string, info os.FileInfo, now time.Time) error {
+	if now.Sub(info.ModTime()) < staleTempFileAge {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove stale temporary file %q: %w", path, err)
+	}
+	mt.log().Info("removed stale temporary file", "path", path, "size", info.Size())
+	if mt.config.StaleTempFileCallback != nil {
+		mt.config.StaleTempFileCallback(path)
+	}
+	return nil
+}
+
+// enqueueRecovered sends a task for every recovered input that still exists.
+func (mt *mirrorTransform) enqueueRecovered(ctx context.Context, relPaths []string, taskChan chan<- fileTask) error {
+	for _, relPath := range relPaths {
+		inputPath := filepath.Join(mt.config.InputDir, relPath)
+		info, err := os.Stat(inputPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return mt.handlePathError(inputPath, err, "stat")
+		}
+
+		pattern, err := mt.matchPattern(relPath, newFileEntry(inputPath, info))
+		if err != nil {
+			return err
+		}
+
+		outputPath, err := mt.outputPath(relPath)
+		if err != nil {
+			return err
+		}
+
+		mt.log().Info("retrying file", "path", relPath)
+		select {
+		case taskChan <- newFileTask(inputPath, outputPath, relPath, info, TaskEventRecovered, pattern):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRecoverPartialOutputsCrawl tests that outputs left by an interrupted run
+// are removed and regenerated.
+func TestRecoverPartialOutputsCrawl(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"dir/a.txt", "b.txt"})
+
+	// Simulate a crash while a.txt was processed and a stray output of a removed input
+	createTestFiles(t, outputDir, []string{"dir/a.txt", "gone.txt"})
+	writeMarker(t, filepath.Join(outputDir, "dir", "a.txt"), "dir/a.txt")
+	writeMarker(t, filepath.Join(outputDir, "gone.txt"), "gone.txt")
+
+	config := Config{
+		InputDir:              inputDir,
+		OutputDir:             outputDir,
+		Patterns:              []string{"**/*.txt"},
+		RecoverPartialOutputs: true,
+		AllowDestructive:      true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			// Markers exist while the callback runs
+			if _, err := os.Stat(outputPath + PartialMarkerSuffix); err != nil {
+				t.Errorf("Expected marker for %s: %v", outputPath, err)
+			}
+			return true, os.WriteFile(outputPath, []byte("complete"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "dir", "a.txt"))
+	if err != nil || string(data) != "complete" {
+		t.Errorf("Expected a.txt to be regenerated, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "gone.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected partial output of removed input to be deleted")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(outputDir, "*", "*"+PartialMarkerSuffix))
+	rootMatches, _ := filepath.Glob(filepath.Join(outputDir, "*"+PartialMarkerSuffix))
+	if len(matches)+len(rootMatches) != 0 {
+		t.Errorf("Expected no markers after a successful crawl, got %v %v", matches, rootMatches)
+	}
+}
+
+// TestRecoverPartialOutputsWatch tests that Watch reprocesses recovered inputs on start.
+func TestRecoverPartialOutputsWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt", "b.txt"})
+	createTestFiles(t, outputDir, []string{"a.txt"})
+	writeMarker(t, filepath.Join(outputDir, "a.txt"), "a.txt")
+
+	var mu sync.Mutex
+	var processed []string
+
+	config := Config{
+		InputDir:              inputDir,
+		OutputDir:             outputDir,
+		Patterns:              []string{"**/*.txt"},
+		RecoverPartialOutputs: true,
+		AllowDestructive:      true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			processed = append(processed, filepath.Base(inputPath))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := mt.Watch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) != 1 || processed[0] != "a.txt" {
+		t.Errorf("Expected only a.txt to be reprocessed, got %v", processed)
+	}
+}
+
+// TestPartialMarkerKeptOnFailure tests that a failed output keeps its marker.
+func TestPartialMarkerKeptOnFailure(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt"})
+
+	config := Config{
+		InputDir:              inputDir,
+		OutputDir:             outputDir,
+		Patterns:              []string{"**/*.txt"},
+		RecoverPartialOutputs: true,
+		AllowDestructive:      true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			os.WriteFile(outputPath, []byte("half"), 0644)
+			return false, errors.New("transform failed")
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatalf("Expected crawl to fail")
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "a.txt"+PartialMarkerSuffix)); err != nil {
+		t.Errorf("Expected marker to be kept after failure: %v", err)
+	}
+}
+
+// writeMarker creates a partial marker for output referring to relPath.
+func writeMarker(t *testing.T, output, relPath string) {
+	t.Helper()
+	if err := os.WriteFile(output+PartialMarkerSuffix, []byte(relPath), 0644); err != nil {
+		t.Fatalf("Failed to write marker: %v", err)
+	}
+}
+
+// TestCleanStaleTempFiles tests that temporary files left by interrupted
+// writes are removed on start, while recent ones and other files are kept.
+func TestCleanStaleTempFiles(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt"})
+	createTestFiles(t, outputDir, []string{"dir/" + tempFilePrefix + "123", tempFilePrefix + "456", "keep.txt"})
+	stale := time.Now().Add(-2 * staleTempFileAge)
+	for _, name := range []string{"dir/" + tempFilePrefix + "123", "keep.txt"} {
+		if err := os.Chtimes(filepath.Join(outputDir, name), stale, stale); err != nil {
+			t.Fatalf("Failed to set file times: %v", err)
+		}
+	}
+
+	var removed []string
+	config := Config{
+		InputDir:            inputDir,
+		OutputDir:           outputDir,
+		Patterns:            []string{"**/*.txt"},
+		CleanStaleTempFiles: true,
+		StaleTempFileCallback: func(path string) {
+			removed = append(removed, path)
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(removed) != 1 || removed[0] != filepath.Join(outputDir, "dir", tempFilePrefix+"123") {
+		t.Errorf("Expected the stale temporary file to be reported, got %v", removed)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "dir", tempFilePrefix+"123")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale temporary file to be removed")
+	}
+	for _, name := range []string{tempFilePrefix + "456", "keep.txt"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+}
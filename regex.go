@@ -0,0 +1,83 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// regexRules are the compiled RegexPatterns and RegexExcludes.
+type regexRules struct {
+	patterns []compiledRegex
+	excludes []compiledRegex
+}
+
+// compiledRegex is a compiled expression of RegexPatterns or RegexExcludes.
+type compiledRegex struct {
+	expr string
+	re   *regexp.Regexp
+}
+
+// compileRegexRules compiles RegexPatterns and RegexExcludes, without regard
+// to case if CaseInsensitivePatterns is set. It returns nil if neither is
+// configured.
+func compileRegexRules(config *Config) (*regexRules, error) {
+	if len(config.RegexPatterns) == 0 && len(config.RegexExcludes) == 0 {
+		return nil, nil
+	}
+
+	compile := func(exprs []string, kind string) ([]compiledRegex, error) {
+		compiled := make([]compiledRegex, 0, len(exprs))
+		for _, expr := range exprs {
+			source := expr
+			if config.CaseInsensitivePatterns {
+				source = "(?i)" + expr
+			}
+			re, err := regexp.Compile(source)
+			if err != nil {
+				return nil, markError(ErrInvalidPattern, fmt.Errorf("invalid %s %q: %w", kind, expr, err))
+			}
+			compiled = append(compiled, compiledRegex{expr: expr, re: re})
+		}
+		return compiled, nil
+	}
+
+	patterns, err := compile(config.RegexPatterns, "regex pattern")
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compile(config.RegexExcludes, "regex exclude")
+	if err != nil {
+		return nil, err
+	}
+	return &regexRules{patterns: patterns, excludes: excludes}, nil
+}
+
+// match returns the first of RegexPatterns matching relPath, or an empty
+// string if none matches.
+func (r *regexRules) match(relPath string) string {
+	if r == nil {
+		return ""
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, rule := range r.patterns {
+		if rule.re.MatchString(relPath) {
+			return rule.expr
+		}
+	}
+	return ""
+}
+
+// excluded reports whether relPath matches any of RegexExcludes.
+func (r *regexRules) excluded(relPath string) bool {
+	if r == nil {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, rule := range r.excludes {
+		if rule.re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestRegexPatterns tests that regular expressions select and exclude files
+// alongside glob patterns.
+func TestRegexPatterns(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{
+		"2023/01/a.jpg",
+		"2024/12/B.JPG",
+		"2024/12/c.png",
+		"2024/drafts/d.jpg",
+		"misc/e.jpg",
+		"2024/12/private/f.jpg",
+		"logo.svg",
+	})
+
+	var mu sync.Mutex
+	processed := make(map[string]string)
+	config := Config{
+		InputDir:                inputDir,
+		OutputDir:               filepath.Join(testDir, "output"),
+		Patterns:                []string{"*.svg"},
+		RegexPatterns:           []string{`^20\d{2}/\d{2}/[^/]+\.jpg$`, `^20\d{2}/\d{2}/.*\.png$`},
+		RegexExcludes:           []string{`/private$`},
+		CaseInsensitivePatterns: true,
+		TaskCallback: func(task Task) (bool, error) {
+			mu.Lock()
+			processed[filepath.ToSlash(task.RelPath)] = task.Pattern
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	var relPaths []string
+	for relPath := range processed {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+	expected := []string{"2023/01/a.jpg", "2024/12/B.JPG", "2024/12/c.png", "logo.svg"}
+	if strings.Join(relPaths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, relPaths)
+	}
+	if pattern := processed["2024/12/c.png"]; pattern != config.RegexPatterns[1] {
+		t.Errorf("Expected the matching expression as the pattern, got %q", pattern)
+	}
+}
+
+// TestRegexPatternsValidation tests that invalid expressions are rejected and
+// that RegexPatterns alone are enough.
+func TestRegexPatternsValidation(t *testing.T) {
+	t.Parallel()
+	config := Config{
+		InputDir:      "input",
+		OutputDir:     "output",
+		RegexPatterns: []string{`\.jpg$`},
+		RegexExcludes: []string{`(unclosed`},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), `invalid regex exclude "(unclosed"`) {
+		t.Errorf("Expected the invalid exclude to be reported, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "at least one pattern") {
+		t.Errorf("Expected RegexPatterns to satisfy the pattern requirement, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// renameWindow is how soon after a rename the new name must be reported for
+// the two events to be treated as one move.
+const renameWindow = time.Second
+
+// RenameCallback is called when an input moved within InputDir, for each
+// output of the input, instead of moving the output itself.
+// The directory for newOutputPath is guaranteed to exist.
+type RenameCallback func(oldOutputPath, newOutputPath string) error
+
+// renameTracker pairs the rename event of a file's old name with the create
+// event of its new name.
+type renameTracker struct {
+	mu      sync.Mutex
+	relPath string
+	at      time.Time
+	pending bool
+}
+
+// newRenameTracker returns a tracker if rename tracking is enabled, or nil.
+func newRenameTracker(config *Config) *renameTracker {
+	if !config.TrackRenames && config.RenameCallback == nil {
+		return nil
+	}
+	return &renameTracker{}
+}
+
+// remember records relPath as the old name of a file being moved.
+func (r *renameTracker) remember(relPath string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.relPath, r.at, r.pending = relPath, at, true
+}
+
+// take returns the remembered old name if it was recorded within
+// renameWindow before now, and forgets it either way.
+func (r *renameTracker) take(now time.Time) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.pending {
+		return "", false
+	}
+	r.pending = false
+	if now.Sub(r.at) > renameWindow {
+		return "", false
+	}
+	return r.relPath, true
+}
+
+// followRename moves the outputs of the file renamed to task, if oldRelPath
+// had been mirrored. It reports whether the outputs were moved, in which case
+// the file needs no processing.
+func (mt *mirrorTransform) followRename(oldRelPath string, task fileTask) (moved bool, err error) {
+	oldOutputPath, err := mt.outputPath(oldRelPath)
+	if err != nil {
+		return false, err
+	}
+	oldTask := task
+	oldTask.RelPath = oldRelPath
+	oldTask.OutputPath = oldOutputPath
+
+	oldOutputs, err := mt.taskOutputs(oldTask)
+	if err != nil {
+		return false, err
+	}
+	newOutputs, err := mt.taskOutputs(task)
+	if err != nil {
+		return false, err
+	}
+
+	// Inputs that were never mirrored are processed as new files
+	for _, output := range oldOutputs {
+		if _, err := os.Stat(output.path); err != nil {
+			return false, nil
+		}
+	}
+
+	if err := ensureOutputDirs(newOutputs); err != nil {
+		return false, err
+	}
+	if err := mt.generation.acquire(); err != nil {
+		return false, err
+	}
+	defer func() {
+		if releaseErr := mt.generation.release(); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+	}()
+	for i, output := range oldOutputs {
+		newPath := newOutputs[i].path
+		if mt.config.RenameCallback != nil {
+			if err := mt.config.RenameCallback(output.path, newPath); err != nil {
+				return false, fmt.Errorf("rename callback failed for %q: %w", task.InputPath, err)
+			}
+			continue
+		}
+		if err := os.Rename(output.path, newPath); err != nil {
+			return false, fmt.Errorf("failed to move output %q to %q: %w", output.path, newPath, err)
+		}
+	}
+	return true, nil
+}
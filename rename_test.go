@@ -0,0 +1,184 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWatchTrackRenames tests that outputs follow inputs moved within InputDir.
+func TestWatchTrackRenames(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		withCallback bool
+	}{
+		{"Move", false},
+		{"Callback", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			outputDir := filepath.Join(testDir, "output")
+
+			createTestFiles(t, inputDir, []string{"dir/keep.txt"})
+
+			var calls int32
+			var renames [][2]string
+			var mu sync.Mutex
+
+			config := Config{
+				InputDir:         inputDir,
+				OutputDir:        outputDir,
+				Patterns:         []string{"**/*.jpg"},
+				TrackRenames:     true,
+				AllowDestructive: true,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					atomic.AddInt32(&calls, 1)
+					return true, os.WriteFile(outputPath, []byte("out"), 0644)
+				},
+			}
+			if tt.withCallback {
+				config.RenameCallback = func(oldOutputPath, newOutputPath string) error {
+					mu.Lock()
+					renames = append(renames, [2]string{oldOutputPath, newOutputPath})
+					mu.Unlock()
+					return nil
+				}
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			watchErr := make(chan error, 1)
+			go func() {
+				watchErr <- mt.Watch(ctx)
+			}()
+			time.Sleep(200 * time.Millisecond)
+
+			// Write the file outside the tree and move it in, so it is processed once
+			tmp := filepath.Join(testDir, "a.jpg")
+			if err := os.WriteFile(tmp, []byte("test content"), 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+			if err := os.Rename(tmp, filepath.Join(inputDir, "a.jpg")); err != nil {
+				t.Fatalf("Failed to move file in: %v", err)
+			}
+			waitForFile(t, filepath.Join(outputDir, "a.jpg"))
+
+			// Move the file within the tree
+			if err := os.Rename(filepath.Join(inputDir, "a.jpg"), filepath.Join(inputDir, "dir", "b.jpg")); err != nil {
+				t.Fatalf("Failed to rename file: %v", err)
+			}
+			time.Sleep(300 * time.Millisecond)
+
+			cancel()
+			<-watchErr
+
+			if calls != 1 {
+				t.Errorf("Expected the file to be processed once, got %d", calls)
+			}
+
+			oldOutput := filepath.Join(outputDir, "a.jpg")
+			newOutput := filepath.Join(outputDir, "dir", "b.jpg")
+			if tt.withCallback {
+				if len(renames) != 1 || renames[0] != [2]string{oldOutput, newOutput} {
+					t.Errorf("Expected rename %s -> %s, got %v", oldOutput, newOutput, renames)
+				}
+				return
+			}
+			if _, err := os.Stat(oldOutput); !os.IsNotExist(err) {
+				t.Errorf("Expected old output to be gone, got %v", err)
+			}
+			if _, err := os.Stat(newOutput); err != nil {
+				t.Errorf("Expected output at the new name: %v", err)
+			}
+		})
+	}
+}
+
+// waitForFile waits up to two seconds for path to exist.
+func waitForFile(t *testing.T, path string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %s", path)
+}
+
+// TestWatchTrackRenamesDryRun tests that dry runs leave the outputs of
+// renamed files where they are.
+func TestWatchTrackRenamesDryRun(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+	createTestFiles(t, outputDir, []string{"a.jpg"})
+
+	var renames int32
+	config := Config{
+		InputDir:         inputDir,
+		OutputDir:        outputDir,
+		Patterns:         []string{"**/*.jpg"},
+		TrackRenames:     true,
+		AllowDestructive: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, []byte("out"), 0644)
+		},
+		RenameCallback: func(oldOutputPath, newOutputPath string) error {
+			atomic.AddInt32(&renames, 1)
+			return nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithDryRun())
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := os.Rename(filepath.Join(inputDir, "a.jpg"), filepath.Join(inputDir, "b.jpg")); err != nil {
+		t.Fatalf("Failed to rename file: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	if renames != 0 {
+		t.Errorf("Expected no renames in a dry run, got %d", renames)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "a.jpg")); err != nil {
+		t.Errorf("Expected the old output to stay: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "b.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected no output at the new name, got %v", err)
+	}
+}
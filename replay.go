@@ -0,0 +1,234 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RecordedEvent is a watch event as written to Config.EventLog, one JSON
+// object per line.
+type RecordedEvent struct {
+	// Time is when the event was received.
+	Time time.Time `json:"time"`
+
+	// Op is the fsnotify operation, e.g. "CREATE" or "WRITE|CHMOD".
+	Op string `json:"op"`
+
+	// Path is the slash-separated path relative to InputDir.
+	Path string `json:"path"`
+
+	// Labels are the Config.RunLabels of the recording instance.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// ReplayOptions controls Replay.
+type ReplayOptions struct {
+	// Realtime waits between events as long as the recording did.
+	// By default events are replayed as fast as they can be processed.
+	Realtime bool
+}
+
+// eventRecorder writes watch events to Config.EventLog.
+type eventRecorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// newEventRecorder returns a recorder for the configured event log, or nil if disabled.
+func newEventRecorder(config *Config) *eventRecorder {
+	if config.EventLog == nil {
+		return nil
+	}
+	return &eventRecorder{encoder: json.NewEncoder(config.EventLog)}
+}
+
+// recordEvent appends event to the event log, if one is configured.
+func (mt *mirrorTransform) recordEvent(event fsnotify.Event) error {
+	if mt.recorder == nil {
+		return nil
+	}
+
+	relPath, err := filepath.Rel(mt.config.InputDir, event.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path for %q: %w", event.Name, err)
+	}
+
+	mt.recorder.mu.Lock()
+	defer mt.recorder.mu.Unlock()
+	record := RecordedEvent{
+		Time:   time.Now(),
+		Op:     event.Op.String(),
+		Path:   filepath.ToSlash(relPath),
+		Labels: mt.config.RunLabels,
+	}
+	if err := mt.recorder.encoder.Encode(record); err != nil {
+		return fmt.Errorf("failed to record watch event: %w", err)
+	}
+	return nil
+}
+
+// Replay processes the events of a log recorded with Config.EventLog as if
+// they were reported by the watcher. Paths are resolved against this
+// instance's InputDir, so a recording can be replayed against a different
+// configuration or a copy of the input tree.
+// It returns when the log is exhausted and all resulting tasks are processed.
+func (mt *mirrorTransform) Replay(ctx context.Context, r io.Reader, opts ReplayOptions) (err error) {
+	// Report throttled errors when the run ends
+	defer mt.flushErrorSummaries()
+
+	// Check for circular references
+	if err := mt.checkCircularReference(); err != nil {
+		return err
+	}
+
+	// Keep other processes from writing the same outputs
+	if err := mt.lock.acquire(); err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := mt.lock.release(); releaseErr != nil {
+			err = errors.Join(err, releaseErr)
+		}
+	}()
+
+	// Re-read ignore files on every run
+	if mt.ignore != nil {
+		mt.ignore.reset()
+	}
+	if mt.sampler != nil {
+		mt.sampler.reset()
+	}
+
+	// Create channels for communication
+	taskChan := make(chan fileTask, 1000)
+	errChan := make(chan error, 1)
+
+	// WaitGroup to track all goroutines
+	var wg sync.WaitGroup
+
+	// Start file processors
+	processorCtx, cancelProcessors := context.WithCancel(ctx)
+	defer cancelProcessors()
+
+	run := newRunState(false)
+	run.collectFailures = true
+	run.operation = "Replay"
+
+	dispatchChan := mt.dispatchChannel(processorCtx, run, taskChan, false, &wg)
+
+	// Callbacks may outlive a cancellation by the shutdown grace period
+	run.shutdown = mt.newShutdown(ctx)
+	defer run.shutdown.stop()
+	mt.startWorkers(processorCtx, run, dispatchChan, errChan, &wg)
+
+	// Start event reader
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(taskChan)
+
+		if err := mt.replayEvents(processorCtx, r, opts, taskChan); err != nil {
+			sendError(processorCtx, errChan, err)
+		}
+	}()
+
+	// Wait for completion or error
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Context cancelled, let in-flight callbacks finish
+		cancelProcessors()
+		run.shutdown.drain(done)
+		return mt.withFailures(run, ctx.Err())
+	case err := <-errChan:
+		// Error occurred, cancel and wait for shutdown
+		cancelProcessors()
+		<-done
+		return mt.withFailures(run, err)
+	case <-done:
+		// All events replayed, possibly cut short by the output quota
+		if mt.quotaReached(run) {
+			return mt.withFailures(run, mt.quotaError(run))
+		}
+		return mt.withFailures(run, nil)
+	}
+}
+
+// replayEvents reads recorded events from r and handles each like a watch event.
+func (mt *mirrorTransform) replayEvents(ctx context.Context, r io.Reader, opts ReplayOptions, taskChan chan<- fileTask) error {
+	decoder := json.NewDecoder(r)
+	var previous time.Time
+
+	for line := 1; ; line++ {
+		var record RecordedEvent
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read event %d: %w", line, err)
+		}
+
+		// Keep the recorded pace
+		if opts.Realtime && !previous.IsZero() {
+			if delay := record.Time.Sub(previous); delay > 0 {
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		previous = record.Time
+
+		op, err := parseEventOp(record.Op)
+		if err != nil {
+			return fmt.Errorf("invalid event %d: %w", line, err)
+		}
+
+		event := fsnotify.Event{
+			Name: filepath.Join(mt.config.InputDir, filepath.FromSlash(record.Path)),
+			Op:   op,
+		}
+		if err := mt.processWatchEvent(ctx, nil, nil, event, taskChan); err != nil {
+			return err
+		}
+	}
+}
+
+// eventOps maps the names produced by fsnotify.Op.String to operations.
+var eventOps = map[string]fsnotify.Op{
+	"CREATE": fsnotify.Create,
+	"WRITE":  fsnotify.Write,
+	"REMOVE": fsnotify.Remove,
+	"RENAME": fsnotify.Rename,
+	"CHMOD":  fsnotify.Chmod,
+}
+
+// parseEventOp parses the output of fsnotify.Op.String, e.g. "CREATE|WRITE".
+func parseEventOp(s string) (fsnotify.Op, error) {
+	var op fsnotify.Op
+	for _, name := range strings.Split(s, "|") {
+		bit, ok := eventOps[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown event operation %q", name)
+		}
+		op |= bit
+	}
+	return op, nil
+}
@@ -0,0 +1,141 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRecordAndReplay tests that recorded watch events can be replayed
+// against a different configuration.
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	replayDir := filepath.Join(testDir, "replay")
+
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	var log bytes.Buffer
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		EventLog:  &log,
+		RunLabels: []string{"backfill"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.txt"})
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	<-watchErr
+
+	if !strings.Contains(log.String(), `"labels":["backfill"]`) {
+		t.Errorf("Expected run labels in event log, got:\n%s", log.String())
+	}
+	if !strings.Contains(log.String(), `"path":"a.jpg"`) {
+		t.Fatalf("Expected a.jpg in event log, got:\n%s", log.String())
+	}
+
+	// Replay with a different pattern and output directory
+	var mu sync.Mutex
+	var processed []string
+	replayConfig := Config{
+		InputDir:  inputDir,
+		OutputDir: replayDir,
+		Patterns:  []string{"**/*.txt"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			processed = append(processed, outputPath)
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	replayer, err := NewMirrorTransform(&replayConfig)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := replayer.Replay(context.Background(), &log, ReplayOptions{}); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(processed) == 0 {
+		t.Fatalf("Expected replayed events to be processed")
+	}
+	for _, path := range processed {
+		if path != filepath.Join(replayDir, "b.txt") {
+			t.Errorf("Unexpected output path: %s", path)
+		}
+	}
+}
+
+// TestReplayInvalidLog tests that malformed logs are rejected.
+func TestReplayInvalidLog(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	config := Config{
+		InputDir:  filepath.Join(testDir, "input"),
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	logs := []string{
+		`{"time":"2024-01-01T00:00:00Z","op":"EXPLODE","path":"a"}`,
+		`not json`,
+	}
+	for _, log := range logs {
+		if err := mt.Replay(context.Background(), strings.NewReader(log), ReplayOptions{}); err == nil {
+			t.Errorf("Expected error for log %q", log)
+		}
+	}
+}
+
+// TestParseEventOp tests that operations round-trip through their string form.
+func TestParseEventOp(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"CREATE", "WRITE|CHMOD", "REMOVE|RENAME"} {
+		op, err := parseEventOp(s)
+		if err != nil {
+			t.Errorf("parseEventOp(%q) failed: %v", s, err)
+			continue
+		}
+		if op.String() != s {
+			t.Errorf("parseEventOp(%q).String() = %q", s, op.String())
+		}
+	}
+}
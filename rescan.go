@@ -0,0 +1,136 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotWatching is returned by Rescan when no Watch or Run is in progress.
+var ErrNotWatching = errors.New("no watch in progress")
+
+// rescanSub receives the Rescan requests for one Watch or Run.
+type rescanSub struct {
+	requests chan *rescanRequest
+
+	// stopped is closed when the watch no longer takes requests.
+	stopped chan struct{}
+}
+
+// rescanRequest asks a watch to scan its tree again.
+type rescanRequest struct {
+	// ctx is the context of the Rescan call.
+	ctx context.Context
+
+	// done receives the result of the scan.
+	done chan error
+}
+
+// subscribeRescans returns the subscription a watch takes Rescan requests
+// from.
+func (mt *mirrorTransform) subscribeRescans() *rescanSub {
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+	sub := &rescanSub{requests: make(chan *rescanRequest), stopped: make(chan struct{})}
+	mt.rescanSubs[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribeRescans stops sending requests to sub and releases the Rescan
+// calls waiting for it.
+func (mt *mirrorTransform) unsubscribeRescans(sub *rescanSub) {
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+	delete(mt.rescanSubs, sub)
+	close(sub.stopped)
+}
+
+// Rescan makes every Watch and Run in progress scan its tree again, as Crawl
+// does, while it keeps watching, so changes the watcher missed, e.g. after
+// an event queue overflow, are picked up without a restart. Files queued or
+// being processed by the watch are not queued again, and Run still skips
+// the versions it has processed. Rescan returns once the scans have queued
+// their files, or ErrNotWatching if no watch is in progress.
+func (mt *mirrorTransform) Rescan(ctx context.Context) error {
+	mt.updateMu.Lock()
+	subs := make([]*rescanSub, 0, len(mt.rescanSubs))
+	for sub := range mt.rescanSubs {
+		subs = append(subs, sub)
+	}
+	mt.updateMu.Unlock()
+	if len(subs) == 0 {
+		return ErrNotWatching
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		req := &rescanRequest{ctx: ctx, done: make(chan error, 1)}
+		select {
+		case sub.requests <- req:
+		case <-sub.stopped:
+			continue
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case err := <-req.done:
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case <-sub.stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// serve scans the tree at root for the request, stopping early when ctx or
+// the context of the Rescan call is done, and reports the result to it.
+func (req *rescanRequest) serve(ctx context.Context, mt *mirrorTransform, run *runState, root string, taskChan chan<- fileTask) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(req.ctx, cancel)
+	defer stop()
+
+	req.done <- mt.rescan(ctx, run, root, TaskEventRescan, taskChan)
+}
+
+// rescan scans the tree at root and sends a task with event for every
+// matching file of run that is neither queued nor being processed.
+func (mt *mirrorTransform) rescan(ctx context.Context, run *runState, root string, event TaskEvent, taskChan chan<- fileTask) error {
+	mt.log().Info("scanning existing files", "dir", root)
+
+	// Scan with a run of its own so the watch only counts what is queued
+	scanRun := newRunState(false)
+	scanRun.options = run.options
+	scanRun.statScanned = run.statScanned
+	scanRun.entered = run.entered
+	found := make(chan fileTask)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(found)
+		scanErr <- mt.scanTree(ctx, root, found, scanRun)
+	}()
+
+	var queued, active int
+	for task := range found {
+		if run.isActive(task.RelPath) {
+			active++
+			continue
+		}
+		task.Event = event
+		select {
+		case taskChan <- task:
+			run.matched.Add(1)
+			queued++
+		case <-ctx.Done():
+		}
+	}
+	if err := <-scanErr; err != nil {
+		return fmt.Errorf("failed to scan %q: %w", root, err)
+	}
+	mt.log().Info("scanned existing files", "dir", root, "queued", queued, "active", active)
+	return nil
+}
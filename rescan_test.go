@@ -0,0 +1,124 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRescan tests that Rescan queues the existing files of a running watch,
+// except those already queued or being processed.
+func TestRescan(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "sub/c.jpg", "d.png"})
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      filepath.Join(testDir, "output"),
+		Patterns:       []string{"**/*.jpg"},
+		Concurrency:    1,
+		MaxConcurrency: 1,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)]++
+			first := processed["a.jpg"] == 1 && relPath == "a.jpg"
+			mu.Unlock()
+
+			// Hold the first file so the others stay queued
+			if first {
+				close(started)
+				<-release
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Rescan(context.Background()); !errors.Is(err, ErrNotWatching) {
+		t.Fatalf("Expected ErrNotWatching without a watch, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	if err := mt.Rescan(ctx); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	<-started
+
+	// Everything is queued or in flight, so nothing is queued again
+	if err := mt.Rescan(ctx); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	close(release)
+
+	waitProcessed := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			mu.Lock()
+			done := processed["a.jpg"] >= want && processed["b.jpg"] >= want && processed["sub/c.jpg"] >= want
+			mu.Unlock()
+			if done || time.Now().After(deadline) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitProcessed(1)
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	for _, relPath := range []string{"a.jpg", "b.jpg", "sub/c.jpg"} {
+		if processed[relPath] != 1 {
+			t.Errorf("Expected %s to be processed once, got %v", relPath, processed)
+		}
+	}
+	mu.Unlock()
+
+	// Once idle, a rescan processes every file again
+	if err := mt.Rescan(ctx); err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	waitProcessed(2)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, relPath := range []string{"a.jpg", "b.jpg", "sub/c.jpg"} {
+		if processed[relPath] != 2 {
+			t.Errorf("Expected %s to be processed twice, got %v", relPath, processed)
+		}
+	}
+	if processed["d.png"] != 0 {
+		t.Errorf("Expected d.png not to be processed, got %v", processed)
+	}
+
+	// The watch has stopped, so there is nothing to rescan
+	if err := mt.Rescan(context.Background()); !errors.Is(err, ErrNotWatching) {
+		t.Errorf("Expected ErrNotWatching after the watch, got %v", err)
+	}
+}
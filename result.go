@@ -0,0 +1,167 @@
+package mirrortransform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// Result summarizes a crawl.
+type Result struct {
+	// Matched is the number of files that matched the patterns.
+	Matched int
+
+	// Processed is the number of files whose callback succeeded.
+	Processed int
+
+	// Skipped is the number of matched files that were not processed because
+	// of FailureBackoff, ContentTypeFilter, OnlyIfStale, Schedule,
+	// MaxOutputBytes or MaxFiles.
+	Skipped int
+
+	// Failed is the number of files whose callback failed.
+	Failed int
+
+	// Bytes is the total size of the outputs written.
+	Bytes int64
+
+	// Started is when the crawl started.
+	Started time.Time
+
+	// Duration is the wall time of the crawl.
+	Duration time.Duration
+
+	// Files lists the outcome of every matched file that was processed,
+	// skipped or failed, sorted by path.
+	Files []FileOutcome
+
+	// Errors describes every failed file.
+	Errors []FileError
+
+	// Unprocessed lists the full paths of the matched files that were neither
+	// processed nor deliberately skipped, sorted: files left over when the run
+	// was cancelled or stopped by an error, and files skipped because of
+	// MaxOutputBytes or MaxFiles. Listing them relative to InputDir for ImportList
+	// resumes the run without crawling again.
+	Unprocessed []string
+
+	// Labels are the Config.RunLabels of the run.
+	Labels []string
+
+	// LimitReached is true when the run stopped processing files because
+	// MaxFiles was reached. The files it had queued are in Unprocessed;
+	// those the scan had not reached yet are not listed.
+	LimitReached bool
+}
+
+// FileError is a failure for one file or directory: a callback failure for
+// an input file, or an operation such as reading a directory that failed
+// without an ErrorCallback to handle it. Use errors.As to retrieve it.
+type FileError struct {
+	// Path is the full path of the file or directory.
+	Path string
+
+	// Op is the operation that failed, such as "access", or "process" for
+	// callback failures.
+	Op string
+
+	// Err is the error returned by the callback or the operation.
+	Err error
+}
+
+func (e FileError) Error() string {
+	if e.Op != "" {
+		return fmt.Sprintf("failed to %s %q: %v", e.Op, e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the callback or operation error.
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// resultReport is the JSON form of a Result written by WriteJSON.
+type resultReport struct {
+	Labels       []string      `json:"labels,omitempty"`
+	Started      time.Time     `json:"started"`
+	Duration     string        `json:"duration"`
+	Matched      int           `json:"matched"`
+	Processed    int           `json:"processed"`
+	Skipped      int           `json:"skipped"`
+	Failed       int           `json:"failed"`
+	Bytes        int64         `json:"bytes"`
+	Files        []fileReport  `json:"files"`
+	Errors       []errorReport `json:"errors"`
+	Unprocessed  []string      `json:"unprocessed"`
+	LimitReached bool          `json:"limitReached,omitempty"`
+}
+
+// fileReport is the JSON form of a FileOutcome.
+type fileReport struct {
+	RelPath      string     `json:"relPath"`
+	Status       TaskStatus `json:"status"`
+	Reason       string     `json:"reason,omitempty"`
+	Duration     string     `json:"duration,omitempty"`
+	BytesRead    int64      `json:"bytesRead,omitempty"`
+	BytesWritten int64      `json:"bytesWritten,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// errorReport is the JSON form of a FileError.
+type errorReport struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// WriteJSON writes r to w as an indented JSON report of the totals, the
+// outcome of every file with its timing and error message, and the
+// unprocessed files, for pipelines to archive and diff successive runs.
+// Durations are written as strings such as "1.5s".
+func (r *Result) WriteJSON(w io.Writer) error {
+	report := resultReport{
+		Labels:       r.Labels,
+		Started:      r.Started,
+		Duration:     r.Duration.String(),
+		Matched:      r.Matched,
+		Processed:    r.Processed,
+		Skipped:      r.Skipped,
+		Failed:       r.Failed,
+		Bytes:        r.Bytes,
+		Files:        make([]fileReport, 0, len(r.Files)),
+		Errors:       make([]errorReport, 0, len(r.Errors)),
+		Unprocessed:  r.Unprocessed,
+		LimitReached: r.LimitReached,
+	}
+	if report.Unprocessed == nil {
+		report.Unprocessed = []string{}
+	}
+	for _, file := range r.Files {
+		entry := fileReport{
+			RelPath:      filepath.ToSlash(file.RelPath),
+			Status:       file.Status,
+			Reason:       file.Reason,
+			BytesRead:    file.BytesRead,
+			BytesWritten: file.BytesWritten,
+		}
+		if file.Duration > 0 {
+			entry.Duration = file.Duration.String()
+		}
+		if file.Err != nil {
+			entry.Error = file.Err.Error()
+		}
+		report.Files = append(report.Files, entry)
+	}
+	for _, fileErr := range r.Errors {
+		report.Errors = append(report.Errors, errorReport{Path: fileErr.Path, Error: fileErr.Err.Error()})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write result report: %w", err)
+	}
+	return nil
+}
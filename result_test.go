@@ -0,0 +1,225 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCrawlWithResult tests the counters of a successful crawl.
+func TestCrawlWithResult(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt", "dir/b.txt", "c.jpg", "d.md"})
+
+	config := Config{
+		InputDir:          inputDir,
+		OutputDir:         outputDir,
+		Patterns:          []string{"**/*.txt", "**/*.jpg"},
+		ContentTypeFilter: []string{"text/*"},
+		RunLabels:         []string{"nightly"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, []byte("12345"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// c.jpg is plain text, so make it look like a JPEG to be skipped
+	if err := os.WriteFile(filepath.Join(inputDir, "c.jpg"), []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background())
+	if err != nil {
+		t.Fatalf("CrawlWithResult failed: %v", err)
+	}
+
+	if result.Matched != 3 || result.Processed != 2 || result.Skipped != 1 || result.Failed != 0 {
+		t.Errorf("Unexpected counts: %+v", result)
+	}
+	if result.Bytes != 10 {
+		t.Errorf("Expected 10 output bytes, got %d", result.Bytes)
+	}
+	if len(result.Labels) != 1 || result.Labels[0] != "nightly" {
+		t.Errorf("Expected run labels in result, got %v", result.Labels)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Expected positive duration, got %v", result.Duration)
+	}
+	if len(result.Unprocessed) != 0 {
+		t.Errorf("Expected no unprocessed files, got %v", result.Unprocessed)
+	}
+}
+
+// TestCrawlWithResultFailure tests that failures are detailed in the result.
+func TestCrawlWithResultFailure(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"bad.txt"})
+
+	errCorrupt := errors.New("corrupt")
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.txt"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return false, errCorrupt
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background())
+	if !errors.Is(err, errCorrupt) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+	if result == nil {
+		t.Fatalf("Expected a result for a failed crawl")
+	}
+	if result.Failed != 1 || len(result.Errors) != 1 {
+		t.Fatalf("Expected one failure, got %+v", result)
+	}
+	if result.Errors[0].Path != filepath.Join(inputDir, "bad.txt") || !errors.Is(result.Errors[0], errCorrupt) {
+		t.Errorf("Unexpected failure detail: %v", result.Errors[0])
+	}
+}
+
+// TestCrawlWithResultUnprocessed tests that the files left over by an aborted
+// crawl are listed in the result.
+func TestCrawlWithResultUnprocessed(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"})
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.txt"},
+		Concurrency: 1,
+		Ordered:     true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Base(inputPath) == "c.txt" {
+				return false, errors.New("corrupt")
+			}
+			return true, os.WriteFile(outputPath, []byte("ok"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	result, err := mt.CrawlWithResult(context.Background())
+	if err == nil {
+		t.Fatal("Expected crawl to fail")
+	}
+
+	expected := []string{filepath.Join(inputDir, "d.txt"), filepath.Join(inputDir, "e.txt")}
+	if len(result.Unprocessed) != len(expected) {
+		t.Fatalf("Expected unprocessed %v, got %v", expected, result.Unprocessed)
+	}
+	for i, path := range expected {
+		if result.Unprocessed[i] != path {
+			t.Errorf("Expected unprocessed %v, got %v", expected, result.Unprocessed)
+			break
+		}
+	}
+	if result.Processed != 2 || result.Failed != 1 {
+		t.Errorf("Unexpected counts: %+v", result)
+	}
+}
+
+// TestResultWriteJSON tests the JSON report of a crawl with processed,
+// failed and skipped files.
+func TestResultWriteJSON(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"b/ok.txt", "a.txt", "bad.txt"})
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.txt"},
+		ContinueOnError: true,
+		RunLabels:       []string{"nightly"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Base(inputPath) == "bad.txt" {
+				return false, errors.New("broken input")
+			}
+			return true, os.WriteFile(outputPath, []byte("out"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	result, _ := mt.CrawlWithResult(context.Background())
+
+	var buf bytes.Buffer
+	if err := result.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var report struct {
+		Labels    []string `json:"labels"`
+		Duration  string   `json:"duration"`
+		Processed int      `json:"processed"`
+		Failed    int      `json:"failed"`
+		Bytes     int64    `json:"bytes"`
+		Files     []struct {
+			RelPath      string `json:"relPath"`
+			Status       string `json:"status"`
+			BytesWritten int64  `json:"bytesWritten"`
+			Error        string `json:"error"`
+		} `json:"files"`
+		Errors []struct {
+			Path  string `json:"path"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse report: %v\n%s", err, buf.String())
+	}
+
+	if report.Processed != 2 || report.Failed != 1 || report.Bytes != 6 || len(report.Labels) != 1 || report.Duration == "" {
+		t.Errorf("Unexpected totals: %s", buf.String())
+	}
+	if len(report.Files) != 3 {
+		t.Fatalf("Expected 3 files, got %s", buf.String())
+	}
+	expected := []struct{ relPath, status string }{{"a.txt", "processed"}, {"b/ok.txt", "processed"}, {"bad.txt", "failed"}}
+	for i, file := range report.Files {
+		if file.RelPath != expected[i].relPath || file.Status != expected[i].status {
+			t.Errorf("Expected file %d to be %s %s, got %s %s", i, expected[i].relPath, expected[i].status, file.RelPath, file.Status)
+		}
+	}
+	if report.Files[0].BytesWritten != 3 || report.Files[2].Error != "broken input" {
+		t.Errorf("Unexpected file details: %s", buf.String())
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Error != "broken input" {
+		t.Errorf("Expected the failure in errors, got %s", buf.String())
+	}
+}
@@ -0,0 +1,123 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// RetryFailed processes the files in the retry queue kept with RetryQueue,
+// those whose callback failed and those a run deferred, as a crawl
+// would and past any FailureBackoff, e.g. once a downstream service is back.
+// Files that succeed leave the queue; files that no longer exist or match
+// are dropped from it. Entries of archives are left to CrawlArchive.
+func (mt *mirrorTransform) RetryFailed(ctx context.Context, opts ...RunOption) (*Result, error) {
+	if mt.failures == nil || !mt.failures.retry {
+		return nil, errors.New("RetryFailed requires RetryQueue")
+	}
+
+	run := newRunState(false, append(opts, WithForce())...)
+	run.collectResult = true
+	result, err := mt.runFinite(ctx, "RetryFailed", run, func(ctx context.Context, taskChan chan<- fileTask) error {
+		return mt.sendRetries(ctx, taskChan, run)
+	})
+	if result != nil {
+		result.Labels = append([]string(nil), mt.config.RunLabels...)
+	}
+	return result, err
+}
+
+// sendRetries sends a task for every queued retry below the walk root of
+// run that still exists and matches.
+func (mt *mirrorTransform) sendRetries(ctx context.Context, taskChan chan<- fileTask, run *runState) error {
+	relPaths, err := mt.failures.queued()
+	if err != nil {
+		return err
+	}
+
+	root := mt.walkRoot(run)
+	for _, relPath := range relPaths {
+		inputPath := filepath.Join(mt.config.InputDir, relPath)
+		if rel, err := filepath.Rel(root, inputPath); err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+
+		info, err := os.Lstat(inputPath)
+		if err != nil && !os.IsNotExist(err) {
+			if err := mt.handlePathError(inputPath, err, "stat"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Drop what is gone or no longer matches
+		pattern := ""
+		if err == nil && info.Mode().IsRegular() {
+			skipped, err := mt.inSkippedDir(relPath)
+			if err != nil {
+				return err
+			}
+			if !skipped {
+				if pattern, err = mt.entryPattern(inputPath, relPath, newFileEntry(inputPath, info)); err != nil {
+					return err
+				}
+			}
+		}
+		if pattern == "" {
+			mt.log().Debug("dropping retry", "path", relPath)
+			if err := mt.failures.forget(relPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		mt.log().Info("retrying file", "path", relPath)
+		if err := mt.sendTask(ctx, inputPath, relPath, info, TaskEventRetry, pattern, taskChan, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deferTask records a task the run matched but does not process, queueing
+// it for RetryFailed.
+func (mt *mirrorTransform) deferTask(run *runState, task fileTask) error {
+	run.addUnprocessed(task.InputPath)
+	if mt.failures == nil {
+		return nil
+	}
+	return mt.failures.recordDeferred(task)
+}
+
+// queued returns the relative paths in the retry queue in order, leaving
+// out archive entries.
+func (ft *failureTracker) queued() ([]string, error) {
+	lister, ok := ft.store.(StateLister)
+	if !ok {
+		return nil, errors.New("state store cannot list its states, which RetryQueue requires")
+	}
+	states, err := lister.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list states: %w", err)
+	}
+
+	var relPaths []string
+	for key, state := range states {
+		if state.Retry && state.Archive == "" {
+			relPaths = append(relPaths, filepath.FromSlash(key))
+		}
+	}
+	sort.Strings(relPaths)
+	return relPaths, nil
+}
+
+// forget removes the state of relPath, and with it its retry.
+func (ft *failureTracker) forget(relPath string) error {
+	if err := ft.store.Delete(filepath.ToSlash(relPath)); err != nil {
+		return fmt.Errorf("failed to clear state for %q: %w", relPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRetryFailed tests that failed files are retried by a later instance
+// sharing the state store, and leave the queue once they succeed.
+func TestRetryFailed(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	statePath := filepath.Join(testDir, "state.json")
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "sub/c.jpg", "d.jpg"})
+
+	var outage atomic.Bool
+	outage.Store(true)
+	var mu sync.Mutex
+	var processed []string
+
+	newInstance := func() MirrorTransform {
+		store, err := NewFileStateStore(statePath)
+		if err != nil {
+			t.Fatalf("Failed to create state store: %v", err)
+		}
+		config := Config{
+			InputDir:        inputDir,
+			OutputDir:       filepath.Join(testDir, "output"),
+			Patterns:        []string{"**/*.jpg"},
+			RetryQueue:      true,
+			StateStore:      store,
+			ContinueOnError: true,
+			FileCallback: func(inputPath, outputPath string) (bool, error) {
+				rel, _ := filepath.Rel(inputDir, inputPath)
+				if outage.Load() && filepath.Base(rel) != "b.jpg" {
+					return false, errors.New("converter unavailable")
+				}
+				mu.Lock()
+				processed = append(processed, filepath.ToSlash(rel))
+				mu.Unlock()
+				return true, nil
+			},
+		}
+		mt, err := NewMirrorTransform(&config)
+		if err != nil {
+			t.Fatalf("Failed to create MirrorTransform: %v", err)
+		}
+		return mt
+	}
+
+	if err := newInstance().Crawl(context.Background()); err == nil {
+		t.Fatalf("Expected the outage to fail the crawl")
+	}
+
+	// After a restart, only the failed files that still exist are retried
+	outage.Store(false)
+	processed = nil
+	if err := os.Remove(filepath.Join(inputDir, "d.jpg")); err != nil {
+		t.Fatalf("Failed to remove input: %v", err)
+	}
+	mt := newInstance()
+	result, err := mt.RetryFailed(context.Background())
+	if err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	sort.Strings(processed)
+	if got := strings.Join(processed, ","); got != "a.jpg,sub/c.jpg" {
+		t.Errorf("Unexpected retried files: %s", got)
+	}
+	if result.Processed != 2 {
+		t.Errorf("Expected 2 processed files, got %+v", result)
+	}
+
+	// The queue is empty once everything succeeded
+	result, err = newInstance().RetryFailed(context.Background())
+	if err != nil {
+		t.Fatalf("RetryFailed failed: %v", err)
+	}
+	if result.Matched != 0 {
+		t.Errorf("Expected an empty retry queue, got %+v", result)
+	}
+}
+
+// TestRetryFailedRequiresRetryQueue tests that RetryFailed is rejected
+// without RetryQueue.
+func TestRetryFailedRequiresRetryQueue(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	config := Config{
+		InputDir:     filepath.Join(testDir, "input"),
+		OutputDir:    filepath.Join(testDir, "output"),
+		Patterns:     []string{"**/*"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) { return true, nil },
+	}
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if _, err := mt.RetryFailed(context.Background()); err == nil {
+		t.Errorf("Expected an error without RetryQueue")
+	}
+}
@@ -0,0 +1,499 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runState holds the state of a single Crawl, Watch or Run.
+type runState struct {
+	// stopOnQuota makes the run fail as soon as MaxOutputBytes is reached
+	// instead of draining the remaining tasks.
+	stopOnQuota bool
+
+	// collectResult measures the output size of every task for the Result.
+	collectResult bool
+
+	// collectFailures keeps every callback failure so finite runs can report
+	// them. Continuous runs leave it off to keep memory bounded.
+	collectFailures bool
+
+	// options are the RunOptions passed to the call.
+	options runOptions
+
+	// statScanned makes scans stat every matching file so that Run can skip
+	// versions it has already processed.
+	statScanned bool
+
+	// stamps remembers the files processed by earlier crawls of Schedule;
+	// nil in other runs.
+	stamps *stampMemory
+
+	// archive is the archive CrawlArchive reads the inputs from; empty in
+	// other runs.
+	archive string
+
+	// shutdown tracks in-flight callbacks and their context.
+	shutdown *shutdown
+
+	// operation names the method of the run in webhooks, e.g. "Crawl".
+	operation string
+
+	started      time.Time
+	matched      atomic.Int64
+	processed    atomic.Int64
+	skipped      atomic.Int64
+	failed       atomic.Int64
+	bytesWritten atomic.Int64
+
+	// queued counts the tasks waiting to be dispatched to a worker.
+	queued atomic.Int64
+
+	// busy counts the tasks being processed by a worker.
+	busy atomic.Int64
+
+	// lastActivity is when the run last received a watch event or queued
+	// or finished a task, in Unix nanoseconds, for IdleCallback.
+	lastActivity atomic.Int64
+
+	// trackActive keeps active, for Rescan to skip the files of a watch
+	// that are queued or being processed.
+	trackActive bool
+
+	// callbacks counts the callbacks that returned, and callbackTime their
+	// total duration in nanoseconds, for AutoConcurrency.
+	callbacks    atomic.Int64
+	callbackTime atomic.Int64
+
+	quotaReached atomic.Bool
+
+	// fileLimit is the MaxFiles of a finite run, zero in other runs.
+	// filesStarted counts the callbacks it allowed to start, and
+	// stopProducing ends the producer of tasks once it is reached.
+	fileLimit        int64
+	filesStarted     atomic.Int64
+	fileLimitReached atomic.Bool
+	stopProducing    context.CancelFunc
+
+	mu          sync.Mutex
+	unprocessed []string
+	failures    []FileError
+
+	// outcomes lists what became of every file. It is only kept when
+	// collectResult is set.
+	outcomes []FileOutcome
+
+	// pending counts the tasks sent for each input that have not been
+	// finished yet. It is only kept when collectResult is set.
+	pending map[string]int
+
+	// active counts the queued and in-flight tasks of each relative path.
+	// It is only kept when trackActive is set.
+	active map[string]int
+
+	// entered remembers what DirCallback answered for the directories of
+	// the run, so a directory both watched and scanned is asked about once.
+	entered *dirAnswers
+}
+
+// newRunState returns the state for a new run with the given options.
+func newRunState(stopOnQuota bool, opts ...RunOption) *runState {
+	run := &runState{stopOnQuota: stopOnQuota, started: time.Now(), entered: &dirAnswers{}}
+	for _, opt := range opts {
+		opt(&run.options)
+	}
+	return run
+}
+
+// addUnprocessed records an input that was matched but not processed.
+func (r *runState) addUnprocessed(inputPath string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unprocessed = append(r.unprocessed, inputPath)
+}
+
+// enqueued records that a task for relPath is waiting for a worker.
+func (r *runState) enqueued(relPath string) {
+	r.queued.Add(1)
+	r.touch()
+	if !r.trackActive {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active == nil {
+		r.active = make(map[string]int)
+	}
+	r.active[relPath]++
+}
+
+// finishActive records that a worker is done with a task for relPath.
+func (r *runState) finishActive(relPath string) {
+	if !r.trackActive {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active[relPath] <= 1 {
+		delete(r.active, relPath)
+		return
+	}
+	r.active[relPath]--
+}
+
+// isActive reports whether a task for relPath is queued or being processed.
+func (r *runState) isActive(relPath string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active[relPath] > 0
+}
+
+// addPending records that a task was sent for inputPath.
+func (r *runState) addPending(inputPath string) {
+	if !r.collectResult {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[string]int)
+	}
+	r.pending[inputPath]++
+}
+
+// finishPending records that a task for inputPath was skipped on purpose or
+// had its callback run, so it no longer counts as unprocessed.
+func (r *runState) finishPending(inputPath string) {
+	if !r.collectResult {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[inputPath] <= 1 {
+		delete(r.pending, inputPath)
+		return
+	}
+	r.pending[inputPath]--
+}
+
+// unprocessedPaths returns the sorted inputs that were matched but not
+// processed, either skipped for the quota or left over when the run stopped.
+// r.mu must be held.
+func (r *runState) unprocessedPaths() []string {
+	seen := make(map[string]bool, len(r.unprocessed)+len(r.pending))
+	for _, inputPath := range r.unprocessed {
+		seen[inputPath] = true
+	}
+	for inputPath := range r.pending {
+		seen[inputPath] = true
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(seen))
+	for inputPath := range seen {
+		paths = append(paths, inputPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// sortedOutcomes returns the outcomes of the run sorted by path, in the
+// order they happened for the same path. r.mu must be held.
+func (r *runState) sortedOutcomes() []FileOutcome {
+	if len(r.outcomes) == 0 {
+		return nil
+	}
+	outcomes := append([]FileOutcome(nil), r.outcomes...)
+	sort.SliceStable(outcomes, func(i, j int) bool { return outcomes[i].RelPath < outcomes[j].RelPath })
+	return outcomes
+}
+
+// addFailure counts an input whose callback failed and records it, if
+// failures are collected.
+func (r *runState) addFailure(inputPath string, err error) {
+	r.failed.Add(1)
+	if !r.collectFailures {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, FileError{Path: inputPath, Op: "process", Err: err})
+}
+
+// result summarizes the run so far.
+func (r *runState) result() *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Result{
+		Matched:      int(r.matched.Load()),
+		Processed:    int(r.processed.Load()),
+		Skipped:      int(r.skipped.Load()),
+		Failed:       len(r.failures),
+		Bytes:        r.bytesWritten.Load(),
+		Started:      r.started,
+		Duration:     time.Since(r.started),
+		Files:        r.sortedOutcomes(),
+		Errors:       append([]FileError(nil), r.failures...),
+		Unprocessed:  r.unprocessedPaths(),
+		LimitReached: r.fileLimitReached.Load(),
+	}
+}
+
+// withFailures joins err with the collected failures when ContinueOnError
+// kept the run going past them. It returns err unchanged otherwise.
+func (mt *mirrorTransform) withFailures(run *runState, err error) error {
+	if !mt.config.ContinueOnError {
+		return err
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if len(run.failures) == 0 {
+		return err
+	}
+	errs := make([]error, 0, len(run.failures)+1)
+	errs = append(errs, err)
+	for _, failure := range run.failures {
+		errs = append(errs, failure)
+	}
+	return errors.Join(errs...)
+}
+
+// Run crawls the existing files and then keeps watching for changes, like
+// Crawl followed by Watch but without a gap between them: the watcher starts
+// before the crawl, so files created while crawling are picked up, and a file
+// seen by both the crawl and the watcher is processed only once.
+// Run remembers the size and modification time of every file it processes and
+// skips events that leave them unchanged.
+// It blocks until the context is cancelled.
+func (mt *mirrorTransform) Run(ctx context.Context, opts ...RunOption) (err error) {
+	// Report throttled errors when the run ends
+	defer mt.flushErrorSummaries()
+
+	run := newRunState(true, opts...)
+	run.operation = "Run"
+	defer func() { mt.webhooks.stopped(ctx, run, err) }()
+	run.statScanned = true
+	if err := mt.checkRunOptions(run); err != nil {
+		return err
+	}
+
+	// Check for circular references
+	if err := mt.checkCircularReference(); err != nil {
+		return err
+	}
+
+	if !run.options.dryRun {
+		// Keep other processes from writing the same outputs
+		if err := mt.lock.acquire(); err != nil {
+			return err
+		}
+		defer func() {
+			if releaseErr := mt.lock.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+
+		// Clean up outputs left half-written by an interrupted run
+		if _, err := mt.recoverPartialOutputs(); err != nil {
+			return err
+		}
+
+		// Drop the output directories of inputs removed while not running
+		if err := mt.removeOrphanedDirs(); err != nil {
+			return err
+		}
+	}
+
+	// Create watcher
+	watcher, err := mt.newWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Watch directories that UpdateConfig stops excluding
+	updates := mt.subscribeUpdates()
+	defer mt.unsubscribeUpdates(updates)
+
+	// Scan again on Rescan, skipping the files already queued
+	rescans := mt.subscribeRescans()
+	defer mt.unsubscribeRescans(rescans)
+	run.trackActive = true
+
+	// Re-read ignore files on every run
+	if mt.ignore != nil {
+		mt.ignore.reset()
+	}
+	if mt.sampler != nil {
+		mt.sampler.reset()
+	}
+	mt.outputGate.reset()
+
+	// Create channels for communication
+	scanChan := make(chan fileTask, 1000)
+	watchChan := make(chan fileTask, 1000)
+	taskChan := make(chan fileTask)
+	errChan := make(chan error, 1)
+
+	// WaitGroup to track all goroutines
+	var wg sync.WaitGroup
+
+	// Start file processors
+	processorCtx, cancelProcessors := context.WithCancel(ctx)
+	defer cancelProcessors()
+
+	// Callbacks may outlive a cancellation by the shutdown grace period
+	run.shutdown = mt.newShutdown(ctx)
+	defer run.shutdown.stop()
+
+	dispatchChan := mt.dispatchChannel(processorCtx, run, taskChan, false, &wg)
+
+	// Report when the run falls idle
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mt.watchIdle(processorCtx, run)
+	}()
+
+	mt.startWorkers(processorCtx, run, dispatchChan, errChan, &wg)
+
+	// Watch before scanning so nothing created during the crawl is missed
+	if err := mt.addWatchDirs(watcher, mt.walkRoot(run), run); err != nil {
+		return fmt.Errorf("failed to add watch directories: %w", err)
+	}
+	mt.log().Info("watch started", "dir", mt.walkRoot(run))
+	run.signalReady()
+
+	// Merge both sources, dropping tasks for unchanged files
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mergeTasks(processorCtx, scanChan, watchChan, taskChan, mergeWindow)
+	}()
+
+	// Start event handler
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mt.handleWatchEvents(processorCtx, run, watcher, mt.walkRoot(run), updates, rescans, watchChan, errChan)
+	}()
+
+	// Start directory scanner
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(scanChan)
+
+		if err := mt.scanDirectory(processorCtx, scanChan, run); err != nil {
+			sendError(processorCtx, errChan, err)
+		}
+	}()
+
+	// Wait for completion or error
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Context cancelled, let in-flight callbacks finish
+		cancelProcessors()
+		run.shutdown.drain(done)
+		return ctx.Err()
+	case err := <-errChan:
+		// Error occurred, cancel and wait for shutdown
+		cancelProcessors()
+		<-done
+		return err
+	case <-done:
+		// Should not happen as watch runs indefinitely
+		return nil
+	}
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// mergeWindow is how long after the scan has finished mergeTasks still
+// remembers a version, for watch events queued before the scan reached
+// the file.
+const mergeWindow = time.Minute
+
+// mergedVersion is the last version of a file mergeTasks forwarded.
+type mergedVersion struct {
+	stamp    fileStamp
+	fromScan bool
+	at       time.Time
+}
+
+// mergeTasks forwards tasks from the scanner and the watcher to out, skipping
+// a task when the last task forwarded for the same path had the same size and
+// modification time. A version is forgotten once both inputs delivered it,
+// or window after it was forwarded once the scan has finished, so memory
+// does not grow with the tree. out is closed once both inputs are closed or
+// ctx is done.
+func mergeTasks(ctx context.Context, scanChan, watchChan <-chan fileTask, out chan<- fileTask, window time.Duration) {
+	defer close(out)
+
+	seen := make(map[string]mergedVersion)
+	var expire <-chan time.Time
+	for scanChan != nil || watchChan != nil {
+		var task fileTask
+		var ok bool
+		fromScan := false
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-expire:
+			for relPath, version := range seen {
+				if now.Sub(version.at) >= window {
+					delete(seen, relPath)
+				}
+			}
+			continue
+		case task, ok = <-scanChan:
+			if !ok {
+				scanChan = nil
+				ticker := time.NewTicker(window)
+				defer ticker.Stop()
+				expire = ticker.C
+				continue
+			}
+			fromScan = true
+		case task, ok = <-watchChan:
+			if !ok {
+				watchChan = nil
+				continue
+			}
+		}
+
+		if task.info != nil {
+			stamp := fileStamp{size: task.info.Size(), modTime: task.info.ModTime()}
+			if last, ok := seen[task.RelPath]; ok && last.stamp.size == stamp.size && last.stamp.modTime.Equal(stamp.modTime) {
+				if last.fromScan != fromScan {
+					delete(seen, task.RelPath)
+				}
+				continue
+			}
+			seen[task.RelPath] = mergedVersion{stamp: stamp, fromScan: fromScan, at: time.Now()}
+		}
+
+		select {
+		case out <- task:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
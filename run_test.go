@@ -0,0 +1,187 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRunCrawlThenWatch tests that Run processes existing files and then new
+// and modified files, each version once.
+func TestRunCrawlThenWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"existing.jpg", "dir/existing.jpg"})
+
+	counts := make(map[string]int)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		Concurrency: 2,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			rel, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			counts[filepath.ToSlash(rel)]++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- mt.Run(ctx)
+	}()
+
+	// Give the crawl time to finish
+	time.Sleep(300 * time.Millisecond)
+
+	// Touching a file without changing it must not reprocess it
+	existing := filepath.Join(inputDir, "existing.jpg")
+	info, err := os.Stat(existing)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if err := os.Chtimes(existing, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to touch file: %v", err)
+	}
+
+	// New file, moved in so it produces a single event
+	tmpFile := filepath.Join(testDir, "new.jpg")
+	if err := os.WriteFile(tmpFile, []byte("new"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Rename(tmpFile, filepath.Join(inputDir, "dir", "new.jpg")); err != nil {
+		t.Fatalf("Failed to move file: %v", err)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	if err := <-runErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"existing.jpg", "dir/existing.jpg", "dir/new.jpg"} {
+		if counts[path] != 1 {
+			t.Errorf("Expected %s to be processed once, got %d", path, counts[path])
+		}
+	}
+}
+
+// TestMergeTasksDeduplicates tests that unchanged files are forwarded once.
+func TestMergeTasksDeduplicates(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	createTestFiles(t, testDir, []string{"a.txt"})
+
+	path := filepath.Join(testDir, "a.txt")
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("changed content"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		scan  os.FileInfo
+		watch os.FileInfo
+		want  int
+	}{
+		{name: "unchanged", scan: before, watch: before, want: 1},
+		{name: "changed", scan: before, watch: after, want: 2},
+	}
+
+	for _, tt := range tests {
+		scanChan := make(chan fileTask, 1)
+		watchChan := make(chan fileTask, 1)
+		out := make(chan fileTask, 2)
+
+		scanChan <- fileTask{FileTask: FileTask{RelPath: "a.txt"}, info: tt.scan}
+		watchChan <- fileTask{FileTask: FileTask{RelPath: "a.txt"}, info: tt.watch}
+		close(scanChan)
+		close(watchChan)
+
+		mergeTasks(context.Background(), scanChan, watchChan, out, mergeWindow)
+
+		count := 0
+		for range out {
+			count++
+		}
+		if count != tt.want {
+			t.Errorf("%s: expected %d forwarded tasks, got %d", tt.name, tt.want, count)
+		}
+	}
+}
+
+// TestMergeTasksForgets tests that versions are forgotten once both inputs
+// delivered them, and after the window once the scan has finished.
+func TestMergeTasksForgets(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	createTestFiles(t, testDir, []string{"a.txt", "b.txt"})
+
+	infoA, err := os.Stat(filepath.Join(testDir, "a.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	infoB, err := os.Stat(filepath.Join(testDir, "b.txt"))
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+
+	scanChan := make(chan fileTask)
+	watchChan := make(chan fileTask)
+	out := make(chan fileTask, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mergeTasks(context.Background(), scanChan, watchChan, out, 50*time.Millisecond)
+	}()
+
+	// a.txt is delivered by both inputs, so a later duplicate is forwarded again
+	scanChan <- fileTask{FileTask: FileTask{RelPath: "a.txt"}, info: infoA}
+	watchChan <- fileTask{FileTask: FileTask{RelPath: "a.txt"}, info: infoA}
+	watchChan <- fileTask{FileTask: FileTask{RelPath: "a.txt"}, info: infoA}
+
+	// b.txt is only scanned, and forgotten after the window
+	scanChan <- fileTask{FileTask: FileTask{RelPath: "b.txt"}, info: infoB}
+	close(scanChan)
+	time.Sleep(150 * time.Millisecond)
+	watchChan <- fileTask{FileTask: FileTask{RelPath: "b.txt"}, info: infoB}
+	close(watchChan)
+	<-done
+
+	counts := make(map[string]int)
+	for task := range out {
+		counts[task.RelPath]++
+	}
+	if counts["a.txt"] != 2 || counts["b.txt"] != 2 {
+		t.Errorf("Expected a.txt and b.txt to be forwarded twice each, got %v", counts)
+	}
+}
@@ -0,0 +1,100 @@
+package mirrortransform
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// Sample restricts processing to a representative subset of the matching
+// files, so a new transform can be validated before a full run.
+// When both fields are set, a file must pass both to be processed.
+type Sample struct {
+	// Fraction is the share of matching files to process, between 0 and 1
+	// (e.g. 0.01 for 1%). Selection is derived from a hash of the relative
+	// path, so repeated runs pick the same files. Zero disables it.
+	Fraction float64 `json:"fraction,omitempty" yaml:"fraction,omitempty"`
+
+	// PerDirectory processes at most this many matching files in each
+	// directory, taking the first ones in walk order. Zero disables it.
+	PerDirectory int `json:"perDirectory,omitempty" yaml:"perDirectory,omitempty"`
+}
+
+// validateSample checks that the sample settings are in range.
+func validateSample(sample *Sample) error {
+	if sample == nil {
+		return nil
+	}
+	if sample.Fraction < 0 || sample.Fraction > 1 {
+		return fmt.Errorf("sample fraction must be between 0 and 1, got %v", sample.Fraction)
+	}
+	if sample.PerDirectory < 0 {
+		return fmt.Errorf("sample per-directory limit must not be negative, got %d", sample.PerDirectory)
+	}
+	return nil
+}
+
+// sampler decides which matching files belong to the sample.
+type sampler struct {
+	sample Sample
+
+	mu       sync.Mutex
+	selected map[string]map[string]bool
+}
+
+// newSampler returns a sampler for the configured sample, or nil if disabled.
+func newSampler(config *Config) *sampler {
+	if config.Sample == nil || (config.Sample.Fraction == 0 && config.Sample.PerDirectory == 0) {
+		return nil
+	}
+	return &sampler{sample: *config.Sample, selected: make(map[string]map[string]bool)}
+}
+
+// reset forgets the files selected per directory so a new run starts over.
+func (s *sampler) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selected = make(map[string]map[string]bool)
+}
+
+// keep reports whether relPath is part of the sample.
+func (s *sampler) keep(relPath string) bool {
+	if s.sample.Fraction > 0 && sampleHash(relPath) >= s.sample.Fraction {
+		return false
+	}
+	if s.sample.PerDirectory == 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A file selected earlier stays selected when it changes again
+	dir, name := filepath.Split(relPath)
+	files := s.selected[dir]
+	if files[name] {
+		return true
+	}
+	if len(files) >= s.sample.PerDirectory {
+		return false
+	}
+	if files == nil {
+		files = make(map[string]bool)
+		s.selected[dir] = files
+	}
+	files[name] = true
+	return true
+}
+
+// sampleHash maps relPath to a stable value in [0, 1).
+func sampleHash(relPath string) float64 {
+	sum := sha256.Sum256([]byte(filepath.ToSlash(relPath)))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
+
+// inSample reports whether relPath should be processed under Config.Sample.
+func (mt *mirrorTransform) inSample(relPath string) bool {
+	return mt.sampler == nil || mt.sampler.keep(relPath)
+}
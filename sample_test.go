@@ -0,0 +1,132 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestCrawlSamplePerDirectory tests that at most N files per directory are processed.
+func TestCrawlSamplePerDirectory(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{
+		"a/1.jpg", "a/2.jpg", "a/3.jpg",
+		"b/1.jpg", "b/2.jpg",
+		"c/1.jpg",
+	})
+
+	processed := make(map[string]bool)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		Sample:    &Sample{PerDirectory: 2},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			rel, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(rel)] = true
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Repeated runs select the same files
+	for i := 0; i < 2; i++ {
+		if err := mt.Crawl(context.Background()); err != nil {
+			t.Fatalf("Crawl failed: %v", err)
+		}
+	}
+
+	expected := []string{"a/1.jpg", "a/2.jpg", "b/1.jpg", "b/2.jpg", "c/1.jpg"}
+	if len(processed) != len(expected) {
+		t.Errorf("Expected %d files, got %v", len(expected), processed)
+	}
+	for _, path := range expected {
+		if !processed[path] {
+			t.Errorf("Expected %s to be processed", path)
+		}
+	}
+}
+
+// TestCrawlSampleFraction tests that roughly the requested share of files is processed.
+func TestCrawlSampleFraction(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	var files []string
+	for i := 0; i < 200; i++ {
+		files = append(files, fmt.Sprintf("dir%d/file%d.txt", i%10, i))
+	}
+	createTestFiles(t, inputDir, files)
+
+	count := 0
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.txt"},
+		Sample:    &Sample{Fraction: 0.25},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			count++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if count < 25 || count > 75 {
+		t.Errorf("Expected about 50 of 200 files, got %d", count)
+	}
+}
+
+// TestSampleValidation tests that out-of-range sample settings are rejected.
+func TestSampleValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sample  *Sample
+		wantErr bool
+	}{
+		{name: "nil", sample: nil},
+		{name: "valid", sample: &Sample{Fraction: 0.01, PerDirectory: 3}},
+		{name: "fraction too large", sample: &Sample{Fraction: 1.5}, wantErr: true},
+		{name: "negative fraction", sample: &Sample{Fraction: -0.1}, wantErr: true},
+		{name: "negative per directory", sample: &Sample{PerDirectory: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateSample(tt.sample)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSample() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
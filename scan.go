@@ -0,0 +1,145 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// dirQueue is the queue of directories waiting to be read by a parallel scan.
+type dirQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	dirs   []string
+	active int
+	err    error
+}
+
+// newDirQueue returns a queue holding root.
+func newDirQueue(root string) *dirQueue {
+	q := &dirQueue{dirs: []string{root}}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// next waits for a directory to read. It returns false once the queue is
+// drained with no directory being read, or the scan failed.
+func (q *dirQueue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.dirs) == 0 && q.active > 0 && q.err == nil {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 || q.err != nil {
+		return "", false
+	}
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	q.active++
+	return dir, true
+}
+
+// push queues subdirectories found while reading a directory.
+func (q *dirQueue) push(dirs []string) {
+	if len(dirs) == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dirs = append(q.dirs, dirs...)
+	q.cond.Broadcast()
+}
+
+// done marks a directory as read, recording err if it is the first failure.
+func (q *dirQueue) done(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.active--
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+	q.cond.Broadcast()
+}
+
+// scanParallel is scanTree reading up to workers directories at a time.
+// Entries of one directory are checked in name order, but directories are
+// visited in no particular order.
+func (mt *mirrorTransform) scanParallel(ctx context.Context, root string, taskChan chan<- fileTask, run *runState, workers int) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return mt.handlePathError(root, err, "access")
+	}
+	if err := mt.scanEntry(ctx, root, fs.FileInfoToDirEntry(info), taskChan, run); err != nil || !info.IsDir() {
+		if errors.Is(err, filepath.SkipDir) {
+			return nil
+		}
+		return err
+	}
+
+	queue := newDirQueue(root)
+
+	// Wake idle workers if the scan is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		queue.mu.Lock()
+		defer queue.mu.Unlock()
+		if queue.err == nil {
+			queue.err = ctx.Err()
+		}
+		queue.cond.Broadcast()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := queue.next()
+				if !ok {
+					return
+				}
+				subdirs, err := mt.scanDir(ctx, dir, taskChan, run)
+				queue.push(subdirs)
+				queue.done(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return queue.err
+}
+
+// scanDir checks the entries of dir and returns the subdirectories to scan.
+func (mt *mirrorTransform) scanDir(ctx context.Context, dir string, taskChan chan<- fileTask, run *runState) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, mt.handlePathError(dir, err, "access")
+	}
+
+	var subdirs []string
+	for _, entry := range entries {
+		// Check context cancellation
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		err = mt.scanEntry(ctx, path, entry, taskChan, run)
+		if errors.Is(err, filepath.SkipDir) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, path)
+		}
+	}
+	return subdirs, nil
+}
@@ -0,0 +1,177 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestScanConcurrency tests that a parallel scan finds the same files as a
+// sequential one.
+func TestScanConcurrency(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{
+		"a.jpg",
+		"one/b.jpg",
+		"one/two/c.jpg",
+		"one/two/three/d.jpg",
+		"other/e.jpg",
+		"other/notes.txt",
+		"vendor/f.jpg",
+		".hidden/g.jpg",
+	})
+
+	scan := func(scanConcurrency int) []string {
+		var mu sync.Mutex
+		var processed []string
+		config := Config{
+			InputDir:        inputDir,
+			OutputDir:       filepath.Join(testDir, "output"),
+			Patterns:        []string{"**/*.jpg"},
+			ExcludePatterns: []string{"vendor"},
+			ScanConcurrency: scanConcurrency,
+			FileCallback: func(inputPath, outputPath string) (bool, error) {
+				relPath, _ := filepath.Rel(inputDir, inputPath)
+				mu.Lock()
+				processed = append(processed, filepath.ToSlash(relPath))
+				mu.Unlock()
+				return true, nil
+			},
+		}
+
+		mt, err := NewMirrorTransform(&config)
+		if err != nil {
+			t.Fatalf("Failed to create MirrorTransform: %v", err)
+		}
+		if err := mt.Crawl(context.Background()); err != nil {
+			t.Fatalf("Crawl failed: %v", err)
+		}
+		sort.Strings(processed)
+		return processed
+	}
+
+	sequential := scan(0)
+	parallel := scan(4)
+
+	expected := "a.jpg,one/b.jpg,one/two/c.jpg,one/two/three/d.jpg,other/e.jpg"
+	if got := strings.Join(sequential, ","); got != expected {
+		t.Errorf("Sequential scan: expected %s, got %s", expected, got)
+	}
+	if got := strings.Join(parallel, ","); got != expected {
+		t.Errorf("Parallel scan: expected %s, got %s", expected, got)
+	}
+}
+
+// TestScanConcurrencyCancellation tests that a parallel scan stops when the
+// context is cancelled.
+func TestScanConcurrencyCancellation(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	var files []string
+	for _, dir := range []string{"a", "b", "c", "d"} {
+		for _, name := range []string{"1.jpg", "2.jpg", "3.jpg"} {
+			files = append(files, dir+"/"+name)
+		}
+	}
+	createTestFiles(t, inputDir, files)
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ScanConcurrency: 2,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	// Nobody reads the tasks, so the scan blocks until cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	taskChan := make(chan fileTask)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- mt.scanDirectory(ctx, taskChan, nil)
+	}()
+
+	<-taskChan
+	cancel()
+
+	if err := <-errChan; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+// TestScanNeedsInfo tests that scanned files are only stat'ed when their size
+// or modification time is used.
+func TestScanNeedsInfo(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/b.jpg"})
+
+	tests := []struct {
+		name     string
+		sorter   TaskSorter
+		wantInfo bool
+	}{
+		{"Plain", nil, false},
+		{"Sorted", SmallestFirst, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := Config{
+				InputDir:   inputDir,
+				OutputDir:  filepath.Join(testDir, "output"),
+				Patterns:   []string{"**/*.jpg"},
+				TaskSorter: tt.sorter,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					return true, nil
+				},
+			}
+
+			instance, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+			mt := instance.(*mirrorTransform)
+
+			taskChan := make(chan fileTask, 10)
+			if err := mt.scanDirectory(context.Background(), taskChan, nil); err != nil {
+				t.Fatalf("scanDirectory failed: %v", err)
+			}
+			close(taskChan)
+
+			count := 0
+			for task := range taskChan {
+				count++
+				if (task.info != nil) != tt.wantInfo {
+					t.Errorf("Expected info present=%v for %s, got %v", tt.wantInfo, task.RelPath, task.info)
+				}
+				if tt.wantInfo && task.Size != int64(len("test content")) {
+					t.Errorf("Expected size %d for %s, got %d", len("test content"), task.RelPath, task.Size)
+				}
+			}
+			if count != 2 {
+				t.Errorf("Expected 2 tasks, got %d", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// nextCrawl returns when the crawl after one that finished at now starts.
+type nextCrawl func(now time.Time) time.Time
+
+// parseSchedule parses the spec of Schedule: a Go duration such as "5m",
+// or a cron expression in the standard five-field format or one of its
+// descriptors such as "@hourly" and "@every 5m".
+func parseSchedule(spec string) (nextCrawl, error) {
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("schedule interval must be positive, got %q", spec)
+		}
+		return func(now time.Time) time.Time { return now.Add(interval) }, nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+	return schedule.Next, nil
+}
+
+// stampMemory remembers the version of every file a scheduled crawl
+// processed, so later crawls skip the files that did not change.
+type stampMemory struct {
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+
+	// seen holds the paths the current crawl checked.
+	seen map[string]bool
+}
+
+// newStampMemory returns an empty memory.
+func newStampMemory() *stampMemory {
+	return &stampMemory{stamps: make(map[string]fileStamp), seen: make(map[string]bool)}
+}
+
+// unchanged reports whether task's file still has the size and modification
+// time it had when it was last processed. Files that were not stat'ed count
+// as changed. It is false on a nil memory.
+func (m *stampMemory) unchanged(task fileTask) bool {
+	if m == nil || task.info == nil {
+		return false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seen[task.RelPath] = true
+	last, ok := m.stamps[task.RelPath]
+	return ok && last.size == task.info.Size() && last.modTime.Equal(task.info.ModTime())
+}
+
+// record remembers the version of task's file once it was processed. It is
+// a no-op on a nil memory.
+func (m *stampMemory) record(task fileTask) {
+	if m == nil || task.info == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stamps[task.RelPath] = fileStamp{size: task.info.Size(), modTime: task.info.ModTime()}
+}
+
+// prune forgets the files the crawl that just completed did not see, such as
+// removed ones, and starts the next crawl.
+func (m *stampMemory) prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for relPath := range m.stamps {
+		if !m.seen[relPath] {
+			delete(m.stamps, relPath)
+		}
+	}
+	clear(m.seen)
+}
+
+// Schedule crawls the input repeatedly until ctx is cancelled, for trees
+// where watching is unreliable, such as network file systems. spec is a Go
+// duration such as "5m", measured from the end of one crawl to the start of
+// the next, or a cron expression such as "*/10 * * * *" or "@hourly". The
+// first crawl starts at once. Crawls after the first skip the files whose
+// size and modification time did not change since they were processed.
+// A crawl that fails ends the schedule with its error; it returns ctx.Err()
+// once ctx is cancelled.
+func (mt *mirrorTransform) Schedule(ctx context.Context, spec string, opts ...RunOption) error {
+	next, err := parseSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	stamps := newStampMemory()
+	for {
+		run := newRunState(false, opts...)
+		run.statScanned = true
+		run.stamps = stamps
+		result, err := mt.crawl(ctx, run)
+		if err != nil {
+			return err
+		}
+		stamps.prune()
+
+		at := next(time.Now())
+		mt.log().Info("next scheduled crawl", "at", at, "processed", result.Processed, "skipped", result.Skipped)
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestParseSchedule tests intervals, cron expressions and invalid specs.
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2024, 5, 1, 10, 3, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{spec: "5m", want: now.Add(5 * time.Minute)},
+		{spec: "*/10 * * * *", want: time.Date(2024, 5, 1, 10, 10, 0, 0, time.UTC)},
+		{spec: "@hourly", want: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)},
+		{spec: "@every 90s", want: time.Date(2024, 5, 1, 10, 5, 0, 0, time.UTC)},
+		{spec: "0s", wantErr: true},
+		{spec: "-1m", wantErr: true},
+		{spec: "every five minutes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		next, err := parseSchedule(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSchedule(%q) expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSchedule(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got := next(now); !got.Equal(tt.want) {
+			t.Errorf("parseSchedule(%q) next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// TestSchedule tests that scheduled crawls only process new and changed
+// files after the first one.
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg"})
+
+	processed := make(chan string, 10)
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			processed <- filepath.Base(inputPath)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Schedule(context.Background(), "soon"); err == nil {
+		t.Error("Expected an error for an invalid schedule")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var scheduleErr error
+	go func() {
+		defer wg.Done()
+		scheduleErr = mt.Schedule(ctx, "100ms")
+	}()
+
+	// receive returns the names processed within the next crawl
+	receive := func(n int) map[string]bool {
+		t.Helper()
+		names := make(map[string]bool)
+		for len(names) < n {
+			select {
+			case name := <-processed:
+				names[name] = true
+			case <-time.After(2 * time.Second):
+				t.Fatalf("Expected %d files to be processed, got %v", n, names)
+			}
+		}
+		return names
+	}
+
+	if names := receive(2); !names["a.jpg"] || !names["b.jpg"] {
+		t.Errorf("Expected the first crawl to process every file, got %v", names)
+	}
+
+	// Change one file and add another
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(inputDir, "b.jpg"), later, later); err != nil {
+		t.Fatalf("Failed to set file times: %v", err)
+	}
+	createTestFiles(t, inputDir, []string{"c.jpg"})
+	if names := receive(2); !names["b.jpg"] || !names["c.jpg"] {
+		t.Errorf("Expected only the changed and new files, got %v", names)
+	}
+
+	// Unchanged files are not processed again
+	select {
+	case name := <-processed:
+		t.Errorf("Expected no file to be processed again, got %s", name)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	cancel()
+	wg.Wait()
+	if !errors.Is(scheduleErr, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", scheduleErr)
+	}
+}
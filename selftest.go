@@ -0,0 +1,140 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// selfTestWatchTimeout is how long SelfTest waits for a watcher event.
+const selfTestWatchTimeout = 5 * time.Second
+
+// SelfTest verifies that the instance can operate: the input directory is
+// readable, the output directory is writable, the FileCallback succeeds on the
+// first matching input, and the file watcher delivers events.
+// The round-trip writes into a temporary directory inside OutputDir and the
+// watcher is probed in a hidden temporary directory inside InputDir, where
+// Watch would see events; both are removed afterwards, so existing inputs
+// and outputs are never touched.
+// It returns nil if all checks pass, or the joined errors of the failed checks.
+func (mt *mirrorTransform) SelfTest(ctx context.Context) error {
+	var errs []error
+
+	// Input must be readable
+	if _, err := os.ReadDir(mt.config.InputDir); err != nil {
+		errs = append(errs, fmt.Errorf("self test: input directory is not readable: %w", err))
+	}
+
+	// Output must be writable
+	if err := os.MkdirAll(mt.config.OutputDir, 0o755); err != nil {
+		return errors.Join(append(errs, fmt.Errorf("self test: failed to create output directory: %w", err))...)
+	}
+	tmpDir, err := os.MkdirTemp(mt.config.OutputDir, ".mirror-selftest-")
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("self test: output directory is not writable: %w", err))...)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if len(errs) == 0 {
+		if err := mt.selfTestRoundTrip(ctx, tmpDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := mt.selfTestWatcher(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// selfTestRoundTrip runs the file callback on the first matching input, writing
+// its output below tmpDir. It succeeds trivially if no input matches.
+func (mt *mirrorTransform) selfTestRoundTrip(ctx context.Context, tmpDir string) error {
+	scanCtx, cancelScan := context.WithCancel(ctx)
+	defer cancelScan()
+
+	taskChan := make(chan fileTask)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(taskChan)
+		scanErr <- mt.scanDirectory(scanCtx, taskChan, nil)
+	}()
+
+	task, ok := <-taskChan
+	cancelScan()
+	for range taskChan {
+		// Drain until the scanner notices the cancellation
+	}
+	if err := <-scanErr; !ok && err != nil {
+		return fmt.Errorf("self test: failed to scan input directory: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	relOutput, err := filepath.Rel(mt.config.OutputDir, task.OutputPath)
+	if err != nil {
+		return fmt.Errorf("self test: failed to map output path for %q: %w", task.InputPath, err)
+	}
+	outputPath := filepath.Join(tmpDir, relOutput)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return fmt.Errorf("self test: failed to create output directory: %w", err)
+	}
+
+	if _, err := mt.callFileCallback(ctx, task, taskOutput{path: outputPath}, time.Now()); err != nil {
+		return fmt.Errorf("self test: file callback failed for %q: %w", task.InputPath, err)
+	}
+	return nil
+}
+
+// selfTestWatcher checks that the watcher backend reports a file creation
+// inside InputDir. If no probe can be written there, e.g. on a read-only
+// mount, it only checks that InputDir can be watched.
+func (mt *mirrorTransform) selfTestWatcher(ctx context.Context) error {
+	watcher, err := mt.newWatcher()
+	if err != nil {
+		return fmt.Errorf("self test: %w", err)
+	}
+	defer watcher.Close()
+
+	dir, err := os.MkdirTemp(mt.config.InputDir, ".mirror-selftest-")
+	if err != nil {
+		if err := watcher.Add(mt.config.InputDir); err != nil {
+			return fmt.Errorf("self test: failed to watch %q: %w", mt.config.InputDir, err)
+		}
+		mt.log().Debug("self test: skipped watcher probe", "error", err)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("self test: failed to watch %q: %w", dir, err)
+	}
+
+	probe := filepath.Join(dir, "probe")
+	if err := os.WriteFile(probe, []byte("probe"), 0o644); err != nil {
+		return fmt.Errorf("self test: failed to write probe file: %w", err)
+	}
+
+	timer := time.NewTimer(selfTestWatchTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return fmt.Errorf("self test: watcher reported no event within %v", selfTestWatchTimeout)
+		case err := <-watcher.Errors():
+			return fmt.Errorf("self test: watcher error: %w", err)
+		case event := <-watcher.Events():
+			if filepath.Clean(event.Name) == probe {
+				return nil
+			}
+		}
+	}
+}
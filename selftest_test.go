@@ -0,0 +1,101 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSelfTest tests the self test checks.
+func TestSelfTest(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"dir/file1.jpg"})
+
+	var callbackOutput string
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			callbackOutput = outputPath
+			return true, os.WriteFile(outputPath, []byte("converted"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.SelfTest(context.Background()); err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+
+	// The round-trip must not write into the real output location
+	if callbackOutput == "" {
+		t.Fatal("File callback was not called")
+	}
+	if callbackOutput == filepath.Join(outputDir, "dir", "file1.jpg") {
+		t.Error("Self test wrote to the real output path")
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Self test left files in output directory: %v", entries)
+	}
+
+	// The watcher probe is removed from the input directory
+	entries, err = os.ReadDir(inputDir)
+	if err != nil {
+		t.Fatalf("Failed to read input directory: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "dir" {
+		t.Errorf("Self test left files in input directory: %v", entries)
+	}
+}
+
+// TestSelfTestFailures tests that failing checks are reported.
+func TestSelfTestFailures(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"file1.jpg"})
+
+	errConvert := errors.New("converter unavailable")
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return false, errConvert
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	err = mt.SelfTest(context.Background())
+	if !errors.Is(err, errConvert) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+
+	// Missing input directory
+	config.InputDir = filepath.Join(testDir, "missing")
+	mt, _ = NewMirrorTransform(&config)
+	err = mt.SelfTest(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "input directory is not readable") {
+		t.Errorf("Expected unreadable input error, got %v", err)
+	}
+}
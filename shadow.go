@@ -0,0 +1,146 @@
+package mirrortransform
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Shadow runs a candidate callback next to the current one so a change to a
+// transform can be compared on real inputs before it is rolled out.
+// Candidate outputs are written below Dir and never affect the run: their
+// errors are only reported.
+type Shadow struct {
+	// Dir is the root directory for candidate outputs, mirrored like OutputDir.
+	Dir string
+
+	// Callback is the candidate transform.
+	Callback FileCallback
+
+	// ReportCallback receives the comparison for every processed file.
+	// It may be called concurrently.
+	ReportCallback ShadowReportCallback
+}
+
+// ShadowReportCallback receives the comparison of one file.
+type ShadowReportCallback func(report ShadowReport)
+
+// ShadowReport compares the current and candidate outputs of one input.
+type ShadowReport struct {
+	// InputPath is the full path of the source file.
+	InputPath string
+
+	// Current describes the output of FileCallback at OutputPath.
+	Current ShadowOutput
+
+	// Candidate describes the output of Shadow.Callback below Shadow.Dir.
+	Candidate ShadowOutput
+}
+
+// ShadowOutput describes the result of one callback.
+type ShadowOutput struct {
+	// Path is the full output path.
+	Path string
+
+	// Missing is true when no file was written at Path.
+	Missing bool
+
+	// Size is the size of the output in bytes.
+	Size int64
+
+	// SHA256 is the hex-encoded SHA-256 of the output.
+	SHA256 string
+
+	// Duration is how long the callback took.
+	Duration time.Duration
+
+	// Err is the error returned by the callback, if any.
+	Err error
+}
+
+// Differs reports whether the candidate behaved differently from the current
+// callback: one failed and the other did not, or the outputs differ.
+func (r ShadowReport) Differs() bool {
+	if (r.Current.Err == nil) != (r.Candidate.Err == nil) {
+		return true
+	}
+	return r.Current.Missing != r.Candidate.Missing || r.Current.SHA256 != r.Candidate.SHA256
+}
+
+// validateShadow checks the shadow settings.
+func validateShadow(shadow *Shadow, config *Config) error {
+	if shadow == nil {
+		return nil
+	}
+	if shadow.Dir == "" {
+		return fmt.Errorf("shadow directory is required")
+	}
+	if shadow.Callback == nil {
+		return fmt.Errorf("shadow callback is required")
+	}
+	if len(config.Variants) > 0 {
+		return fmt.Errorf("shadow mode does not support variants")
+	}
+	if config.OutputDir != "" && filepath.Clean(shadow.Dir) == filepath.Clean(config.OutputDir) {
+		return fmt.Errorf("shadow directory must differ from the output directory")
+	}
+	return nil
+}
+
+// runShadow runs the candidate callback for the task and reports how it
+// compares to the current output. currentDuration and currentErr describe the
+// FileCallback run that just finished.
+func (mt *mirrorTransform) runShadow(task fileTask, currentDuration time.Duration, currentErr error) {
+	shadow := mt.config.Shadow
+
+	report := ShadowReport{
+		InputPath: task.InputPath,
+		Current:   ShadowOutput{Path: task.OutputPath, Duration: currentDuration, Err: currentErr},
+	}
+
+	relOutput, err := filepath.Rel(mt.config.OutputDir, task.OutputPath)
+	if err != nil {
+		report.Candidate.Err = fmt.Errorf("failed to get relative output path for %q: %w", task.OutputPath, err)
+	} else {
+		report.Candidate.Path = filepath.Join(shadow.Dir, relOutput)
+		if err := os.MkdirAll(filepath.Dir(report.Candidate.Path), 0o755); err != nil {
+			report.Candidate.Err = fmt.Errorf("failed to create shadow directory: %w", err)
+		} else {
+			start := time.Now()
+			_, report.Candidate.Err = shadow.Callback(task.InputPath, report.Candidate.Path)
+			report.Candidate.Duration = time.Since(start)
+		}
+	}
+
+	describeShadowOutput(&report.Current)
+	if report.Candidate.Path != "" {
+		describeShadowOutput(&report.Candidate)
+	}
+
+	if shadow.ReportCallback != nil {
+		shadow.ReportCallback(report)
+	}
+}
+
+// describeShadowOutput fills in the size and hash of the file at out.Path.
+func describeShadowOutput(out *ShadowOutput) {
+	f, err := os.Open(out.Path)
+	if err != nil {
+		out.Missing = true
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		out.Missing = true
+		return
+	}
+	out.Size = n
+	out.SHA256 = hex.EncodeToString(h.Sum(nil))
+}
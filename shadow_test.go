@@ -0,0 +1,133 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestCrawlShadow tests that candidate outputs are written to the shadow
+// directory and differences are reported.
+func TestCrawlShadow(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	shadowDir := filepath.Join(testDir, "shadow")
+
+	createTestFiles(t, inputDir, []string{"same.txt", "dir/changed.txt", "broken.txt"})
+
+	reports := make(map[string]ShadowReport)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.txt"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, []byte("v1"), 0644)
+		},
+		Shadow: &Shadow{
+			Dir: shadowDir,
+			Callback: func(inputPath, outputPath string) (bool, error) {
+				switch filepath.Base(inputPath) {
+				case "changed.txt":
+					return true, os.WriteFile(outputPath, []byte("v2 output"), 0644)
+				case "broken.txt":
+					return false, errors.New("candidate failed")
+				}
+				return true, os.WriteFile(outputPath, []byte("v1"), 0644)
+			},
+			ReportCallback: func(report ShadowReport) {
+				mu.Lock()
+				defer mu.Unlock()
+				reports[filepath.Base(report.InputPath)] = report
+			},
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Candidate failures must not fail the run
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(reports) != 3 {
+		t.Fatalf("Expected 3 reports, got %d", len(reports))
+	}
+
+	same := reports["same.txt"]
+	if same.Differs() {
+		t.Errorf("Expected identical outputs for same.txt: %+v", same)
+	}
+	if same.Candidate.Path != filepath.Join(shadowDir, "same.txt") {
+		t.Errorf("Unexpected shadow path: %s", same.Candidate.Path)
+	}
+
+	changed := reports["changed.txt"]
+	if !changed.Differs() {
+		t.Errorf("Expected changed.txt to differ")
+	}
+	if changed.Current.Size != 2 || changed.Candidate.Size != 9 {
+		t.Errorf("Unexpected sizes: current %d, candidate %d", changed.Current.Size, changed.Candidate.Size)
+	}
+	if _, err := os.Stat(filepath.Join(shadowDir, "dir", "changed.txt")); err != nil {
+		t.Errorf("Expected candidate output in shadow directory: %v", err)
+	}
+
+	broken := reports["broken.txt"]
+	if !broken.Differs() || broken.Candidate.Err == nil || !broken.Candidate.Missing {
+		t.Errorf("Expected candidate failure to be reported: %+v", broken)
+	}
+
+	// Current outputs are untouched by the candidate
+	data, err := os.ReadFile(filepath.Join(outputDir, "dir", "changed.txt"))
+	if err != nil || string(data) != "v1" {
+		t.Errorf("Expected current output to be kept, got %q (%v)", data, err)
+	}
+}
+
+// TestShadowValidation tests shadow configuration errors.
+func TestShadowValidation(t *testing.T) {
+	t.Parallel()
+	callback := func(inputPath, outputPath string) (bool, error) { return true, nil }
+
+	tests := []struct {
+		name     string
+		shadow   *Shadow
+		variants []Variant
+		wantErr  bool
+	}{
+		{name: "valid", shadow: &Shadow{Dir: "/tmp/shadow", Callback: callback}},
+		{name: "missing dir", shadow: &Shadow{Callback: callback}, wantErr: true},
+		{name: "missing callback", shadow: &Shadow{Dir: "/tmp/shadow"}, wantErr: true},
+		{name: "same as output", shadow: &Shadow{Dir: "/tmp/out/", Callback: callback}, wantErr: true},
+		{name: "with variants", shadow: &Shadow{Dir: "/tmp/shadow", Callback: callback}, variants: []Variant{{Name: "a"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			config := Config{
+				InputDir:     "/tmp/in",
+				OutputDir:    "/tmp/out",
+				Patterns:     []string{"*.jpg"},
+				FileCallback: callback,
+				Variants:     tt.variants,
+				Shadow:       tt.shadow,
+			}
+			_, err := NewMirrorTransform(&config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewMirrorTransform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
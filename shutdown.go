@@ -0,0 +1,160 @@
+package mirrortransform
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ShutdownProgress reports the callbacks still running after a run's context
+// was cancelled.
+type ShutdownProgress struct {
+	// InFlight are the input paths whose callbacks are still running, sorted.
+	InFlight []string
+
+	// Deadline is when the contexts of the remaining callbacks are cancelled.
+	Deadline time.Time
+
+	// Expired is set once the grace period has passed. The contexts of the
+	// remaining callbacks are cancelled right after this report.
+	Expired bool
+}
+
+// ShutdownCallback is called while a cancelled run waits for its in-flight
+// callbacks: when the grace period starts, each time a callback finishes,
+// and when the grace period expires. Calls are not concurrent.
+type ShutdownCallback func(progress ShutdownProgress)
+
+// shutdown tracks the in-flight callbacks of a run and the context handed to
+// them through Task.Context, which outlives the run's context by the grace
+// period.
+type shutdown struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	grace    time.Duration
+	callback ShutdownCallback
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	inFlight map[string]int
+	draining bool
+
+	// finished is signalled when a callback finishes while draining.
+	finished chan struct{}
+}
+
+// newShutdown returns the shutdown state for a run with context ctx. The
+// callback context keeps the values of ctx but not its cancellation; call
+// stop when the run ends.
+func (mt *mirrorTransform) newShutdown(ctx context.Context) *shutdown {
+	taskCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	return &shutdown{
+		ctx:      taskCtx,
+		cancel:   cancel,
+		grace:    mt.config.ShutdownGracePeriod,
+		callback: mt.config.ShutdownCallback,
+		logger:   mt.log(),
+		inFlight: make(map[string]int),
+		finished: make(chan struct{}, 1),
+	}
+}
+
+// stop cancels the callback context.
+func (s *shutdown) stop() {
+	s.cancel()
+}
+
+// begin records that the callback for inputPath started.
+func (s *shutdown) begin(inputPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight[inputPath]++
+}
+
+// end records that the callback for inputPath returned.
+func (s *shutdown) end(inputPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inFlight[inputPath]--; s.inFlight[inputPath] <= 0 {
+		delete(s.inFlight, inputPath)
+	}
+	if s.draining {
+		select {
+		case s.finished <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drain waits until done is closed after the run's context was cancelled.
+// In-flight callbacks keep their context for the grace period, then it is
+// cancelled and drain keeps waiting for them to return.
+func (s *shutdown) drain(done <-chan struct{}) {
+	s.logger.Info("shutting down", "in_flight", s.inFlightCount(), "grace_period", s.grace)
+	if s.grace <= 0 {
+		s.cancel()
+		<-done
+		return
+	}
+
+	deadline := time.Now().Add(s.grace)
+	timer := time.NewTimer(s.grace)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+	s.report(deadline, false)
+
+	for {
+		select {
+		case <-done:
+			// Report the last callback to finish
+			select {
+			case <-s.finished:
+				s.report(deadline, false)
+			default:
+			}
+			return
+		case <-s.finished:
+			s.report(deadline, false)
+		case <-timer.C:
+			s.logger.Warn("shutdown grace period expired, cancelling callbacks", "in_flight", s.inFlightCount())
+			s.report(deadline, true)
+			s.cancel()
+			<-done
+			return
+		}
+	}
+}
+
+// inFlightCount returns the number of callbacks still running.
+func (s *shutdown) inFlightCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, n := range s.inFlight {
+		count += n
+	}
+	return count
+}
+
+// report passes the in-flight callbacks to the ShutdownCallback, if set.
+func (s *shutdown) report(deadline time.Time, expired bool) {
+	if s.callback == nil {
+		return
+	}
+
+	s.mu.Lock()
+	inFlight := make([]string, 0, len(s.inFlight))
+	for inputPath := range s.inFlight {
+		inFlight = append(inFlight, inputPath)
+	}
+	s.mu.Unlock()
+	sort.Strings(inFlight)
+
+	s.callback(ShutdownProgress{InFlight: inFlight, Deadline: deadline, Expired: expired})
+}
@@ -0,0 +1,136 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestShutdownGracePeriod tests that in-flight callbacks may finish after
+// cancellation while their progress is reported.
+func TestShutdownGracePeriod(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"video.mp4"})
+
+	started := make(chan struct{})
+	var mu sync.Mutex
+	var progress []ShutdownProgress
+	var taskErr error
+
+	config := Config{
+		InputDir:            inputDir,
+		OutputDir:           outputDir,
+		Patterns:            []string{"**/*.mp4"},
+		MaxConcurrency:      1,
+		ShutdownGracePeriod: time.Minute,
+		ShutdownCallback: func(p ShutdownProgress) {
+			mu.Lock()
+			progress = append(progress, p)
+			mu.Unlock()
+		},
+		TaskCallback: func(task Task) (bool, error) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			mu.Lock()
+			taskErr = task.Context().Err()
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	result, err := mt.CrawlWithResult(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if result.Processed != 1 {
+		t.Errorf("Expected the in-flight file to finish, got %+v", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if taskErr != nil {
+		t.Errorf("Expected the task context to stay alive, got %v", taskErr)
+	}
+	if len(progress) != 2 {
+		t.Fatalf("Expected 2 progress reports, got %+v", progress)
+	}
+	if len(progress[0].InFlight) != 1 || filepath.Base(progress[0].InFlight[0]) != "video.mp4" {
+		t.Errorf("Expected video.mp4 in flight, got %v", progress[0].InFlight)
+	}
+	if len(progress[1].InFlight) != 0 || progress[1].Expired {
+		t.Errorf("Expected nothing in flight after the callback, got %+v", progress[1])
+	}
+}
+
+// TestShutdownGracePeriodExpired tests that task contexts are cancelled once
+// the grace period passes.
+func TestShutdownGracePeriodExpired(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"video.mp4"})
+
+	started := make(chan struct{})
+	expired := make(chan ShutdownProgress, 1)
+
+	config := Config{
+		InputDir:            inputDir,
+		OutputDir:           outputDir,
+		Patterns:            []string{"**/*.mp4"},
+		ShutdownGracePeriod: 50 * time.Millisecond,
+		ShutdownCallback: func(p ShutdownProgress) {
+			if p.Expired {
+				expired <- p
+			}
+		},
+		TaskCallback: func(task Task) (bool, error) {
+			close(started)
+			<-task.Context().Done()
+			return false, task.Context().Err()
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	if err := mt.Crawl(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	select {
+	case p := <-expired:
+		if len(p.InFlight) != 1 {
+			t.Errorf("Expected 1 file in flight at expiry, got %v", p.InFlight)
+		}
+	default:
+		t.Errorf("Expected an expiry report")
+	}
+}
@@ -0,0 +1,129 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+)
+
+// minStabilityPoll bounds how often held files are stat'ed.
+const minStabilityPoll = 10 * time.Millisecond
+
+// heldTask is the task of a file waiting for its size and modification time
+// to settle.
+type heldTask struct {
+	task    fileTask
+	size    int64
+	modTime time.Time
+
+	// changed is when the size or modification time last changed.
+	changed time.Time
+}
+
+// waitsForStability reports whether tasks with event are held until the
+// file stops changing: those of files reported by the watcher.
+func waitsForStability(event TaskEvent) bool {
+	return event == TaskEventCreate || event == TaskEventWrite || event == TaskEventChmod
+}
+
+// runStabilizer forwards tasks from in to out, holding those of files
+// reported by the watcher until their size and modification time have not
+// changed for window, so files still being uploaded are not processed
+// truncated. Later events for a held file replace its task, and files
+// removed while held are dropped. out is closed when in is closed and every
+// held task is forwarded, or when ctx is done.
+func runStabilizer(ctx context.Context, in <-chan fileTask, out chan<- fileTask, window time.Duration) {
+	defer close(out)
+
+	ticker := time.NewTicker(max(window/4, minStabilityPoll))
+	defer ticker.Stop()
+
+	held := make(map[string]*heldTask)
+	var ready []fileTask
+	inputOpen := true
+
+	for inputOpen || len(held) > 0 || len(ready) > 0 {
+		// Only offer a task once one is stable
+		var sendChan chan<- fileTask
+		var next fileTask
+		if len(ready) > 0 {
+			sendChan = out
+			next = ready[0]
+		}
+
+		// Stop receiving once the input is closed
+		recvChan := in
+		if !inputOpen {
+			recvChan = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok := <-recvChan:
+			if !ok {
+				inputOpen = false
+				continue
+			}
+			if !waitsForStability(task.Event) {
+				ready = append(ready, task)
+				continue
+			}
+			h := &heldTask{task: task, size: task.Size, modTime: task.ModTime, changed: time.Now()}
+			if task.info == nil {
+				if info, err := os.Stat(task.InputPath); err == nil {
+					h.size, h.modTime = info.Size(), info.ModTime()
+				}
+			}
+			held[task.RelPath] = h
+		case now := <-ticker.C:
+			ready = append(ready, releaseStable(held, now, window)...)
+		case sendChan <- next:
+			ready[0] = fileTask{}
+			ready = ready[1:]
+		}
+	}
+}
+
+// releaseStable removes the tasks from held whose files have not changed
+// for window at now and returns them in the order they settled. Files that
+// no longer exist are dropped.
+func releaseStable(held map[string]*heldTask, now time.Time, window time.Duration) []fileTask {
+	var stable []*heldTask
+	for relPath, h := range held {
+		info, err := os.Stat(h.task.InputPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				delete(held, relPath)
+				continue
+			}
+
+			// Let the callback report files that cannot be stat'ed
+			stable = append(stable, h)
+			delete(held, relPath)
+			continue
+		}
+		if info.Size() != h.size || !info.ModTime().Equal(h.modTime) {
+			h.size, h.modTime, h.changed = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(h.changed) < window {
+			continue
+		}
+
+		// Queue the file as it is now
+		h.task.info = info
+		h.task.Size, h.task.ModTime = info.Size(), info.ModTime()
+		h.task.queuedAt = now
+		stable = append(stable, h)
+		delete(held, relPath)
+	}
+
+	sort.Slice(stable, func(i, j int) bool { return stable[i].changed.Before(stable[j].changed) })
+	tasks := make([]fileTask, len(stable))
+	for i, h := range stable {
+		tasks[i] = h.task
+	}
+	return tasks
+}
@@ -0,0 +1,157 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRunStabilizer tests that watched files are held until they stop
+// changing, that other tasks pass at once, and that removed files are dropped.
+func TestRunStabilizer(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	growing := filepath.Join(dir, "growing.jpg")
+	removed := filepath.Join(dir, "removed.jpg")
+	for _, path := range []string{growing, removed} {
+		if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	task := func(path string, event TaskEvent) fileTask {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat file: %v", err)
+		}
+		return newFileTask(path, path+".out", filepath.Base(path), info, event, "**/*.jpg")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan fileTask)
+	out := make(chan fileTask)
+	go runStabilizer(ctx, in, out, 100*time.Millisecond)
+
+	in <- task(growing, TaskEventCreate)
+	in <- task(removed, TaskEventWrite)
+	in <- task(growing, TaskEventScan)
+
+	// Tasks not reported by the watcher pass at once
+	select {
+	case got := <-out:
+		if got.Event != TaskEventScan {
+			t.Errorf("Expected the scan task first, got %q", got.Event)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("Expected the scan task to pass at once")
+	}
+
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	// Keep the file growing for longer than the window
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		time.Sleep(40 * time.Millisecond)
+		f, err := os.OpenFile(growing, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("Failed to open file: %v", err)
+		}
+		f.WriteString("b")
+		f.Close()
+
+		select {
+		case got := <-out:
+			t.Fatalf("Expected the growing file to be held, got %s", got.RelPath)
+		default:
+		}
+	}
+
+	select {
+	case got := <-out:
+		if got.RelPath != "growing.jpg" || got.Size != 7 || got.Event != TaskEventCreate {
+			t.Errorf("Expected the settled growing.jpg of 7 bytes, got %s of %d bytes (%s)", got.RelPath, got.Size, got.Event)
+		}
+		if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+			t.Errorf("Expected the file to be held while it grew, released after %v", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the growing file to be released once stable")
+	}
+
+	close(in)
+	if got, ok := <-out; ok {
+		t.Errorf("Expected the removed file to be dropped, got %s", got.RelPath)
+	}
+}
+
+// TestWatchStabilityWindow tests that Watch processes a file written in
+// several steps once, after it is complete.
+func TestWatchStabilityWindow(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	var mu sync.Mutex
+	var contents []string
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		StabilityWindow: 200 * time.Millisecond,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			data, err := os.ReadFile(inputPath)
+			if err != nil {
+				return false, err
+			}
+			mu.Lock()
+			contents = append(contents, string(data))
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	// Upload the file in chunks
+	f, err := os.Create(filepath.Join(inputDir, "upload.jpg"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		f.WriteString("chunk")
+		time.Sleep(50 * time.Millisecond)
+	}
+	f.Close()
+
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(contents) != 1 || contents[0] != strings.Repeat("chunk", 5) {
+		t.Errorf("Expected the complete file to be processed once, got %q", contents)
+	}
+}
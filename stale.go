@@ -0,0 +1,41 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"os"
+)
+
+// upToDate reports whether every output of task exists and is at least as
+// new as its input, so that OnlyIfStale can skip it.
+func (mt *mirrorTransform) upToDate(task fileTask) (bool, error) {
+	if !mt.config.OnlyIfStale {
+		return false, nil
+	}
+
+	modTime := task.ModTime
+	if task.info == nil {
+		info, err := os.Stat(task.InputPath)
+		if err != nil {
+			return false, fmt.Errorf("failed to stat %q: %w", task.InputPath, err)
+		}
+		modTime = info.ModTime()
+	}
+
+	outputs, err := mt.taskOutputs(task)
+	if err != nil {
+		return false, err
+	}
+	for _, output := range outputs {
+		info, err := os.Stat(output.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to stat output %q: %w", output.path, err)
+		}
+		if info.ModTime().Before(modTime) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOnlyIfStale tests that files are only processed again once the input
+// is newer than the output mapped by OutputPathFunc.
+func TestOnlyIfStale(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/b.jpg"})
+
+	config := Config{
+		InputDir:    inputDir,
+		OutputDir:   outputDir,
+		Patterns:    []string{"**/*.jpg"},
+		OnlyIfStale: true,
+		OutputPathFunc: func(relPath string) string {
+			return strings.TrimSuffix(relPath, ".jpg") + ".webp"
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, []byte("webp"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	crawl := func() *Result {
+		t.Helper()
+		result, err := mt.CrawlWithResult(context.Background())
+		if err != nil {
+			t.Fatalf("Crawl failed: %v", err)
+		}
+		return result
+	}
+
+	if result := crawl(); result.Processed != 2 {
+		t.Fatalf("Expected 2 processed files, got %+v", result)
+	}
+	for _, name := range []string{"a.webp", "dir/b.webp"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+			t.Errorf("Expected mapped output %s: %v", name, err)
+		}
+	}
+
+	// Outputs newer than their inputs are skipped
+	if result := crawl(); result.Processed != 0 || result.Skipped != 2 {
+		t.Errorf("Expected every file to be skipped, got %+v", result)
+	}
+
+	// Inputs newer than their outputs are processed again
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(outputDir, "dir", "b.webp"), past, past); err != nil {
+		t.Fatalf("Failed to age output: %v", err)
+	}
+	if result := crawl(); result.Processed != 1 || result.Skipped != 1 {
+		t.Errorf("Expected only the stale file to be processed, got %+v", result)
+	}
+
+	// Removed outputs are produced again
+	if err := os.Remove(filepath.Join(outputDir, "a.webp")); err != nil {
+		t.Fatalf("Failed to remove output: %v", err)
+	}
+	if result := crawl(); result.Processed != 1 {
+		t.Errorf("Expected the missing output to be produced, got %+v", result)
+	}
+}
+
+// TestOutputPathFuncOutside tests that outputs mapped outside OutputDir are
+// rejected.
+func TestOutputPathFuncOutside(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg"})
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.jpg"},
+		OutputPathFunc: func(relPath string) string {
+			return filepath.Join("..", relPath)
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err == nil || !strings.Contains(err.Error(), "outside the output directory") {
+		t.Errorf("Expected an error for the escaping output, got %v", err)
+	}
+}
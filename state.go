@@ -0,0 +1,28 @@
+package mirrortransform
+
+import "github.com/ideamans/go-mirror-transform/store"
+
+// PathState is the processing state recorded for an input path.
+type PathState = store.PathState
+
+// StateStore persists per-path processing state.
+// Keys are slash-separated paths relative to InputDir, or the archive path
+// joined with the entry's path for files read by CrawlArchive.
+// Implementations must be safe for concurrent use.
+type StateStore = store.Store
+
+// StateLister is implemented by state stores that can enumerate their
+// states, as RetryQueue requires. The built-in stores implement it.
+type StateLister = store.Lister
+
+// NewMemoryStateStore returns a StateStore that keeps state in memory.
+// State survives across runs of the same instance but not process restarts.
+func NewMemoryStateStore() StateStore {
+	return store.NewMemory()
+}
+
+// NewFileStateStore returns a StateStore persisted as JSON at path.
+// Existing state is loaded immediately; every change rewrites the file atomically.
+func NewFileStateStore(path string) (StateStore, error) {
+	return store.NewFile(path)
+}
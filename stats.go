@@ -0,0 +1,131 @@
+package mirrortransform
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueueStats is a snapshot of the work of the runs in progress, as returned
+// by QueueStats. Comparing Pending over time shows whether the workers keep
+// up with the rate at which files change.
+type QueueStats struct {
+	// Pending counts the tasks waiting for a worker, including those held
+	// while paused.
+	Pending int `json:"pending"`
+
+	// InFlight counts the files being processed, including those of
+	// Handler and ProcessFile.
+	InFlight int `json:"inFlight"`
+
+	// InFlightBytes is the input size of the files counted against
+	// MaxInFlightBytes, zero without the limit.
+	InFlightBytes int64 `json:"inFlightBytes"`
+
+	// Workers describes every worker, ordered by ID.
+	Workers []WorkerState `json:"workers"`
+}
+
+// WorkerState describes a worker in QueueStats.
+type WorkerState struct {
+	// ID identifies the worker within the instance.
+	ID int `json:"id"`
+
+	// Operation is the method of the run the worker belongs to, e.g. "Watch".
+	Operation string `json:"operation"`
+
+	// Path is the relative path of the file being processed, empty if the
+	// worker is idle.
+	Path string `json:"path,omitempty"`
+
+	// Since is when the worker started processing Path.
+	Since time.Time `json:"since"`
+}
+
+// activeWorker is the state of a running worker.
+type activeWorker struct {
+	id  int
+	run *runState
+
+	mu    sync.Mutex
+	path  string
+	since time.Time
+}
+
+// workerRegistry tracks the workers of all runs for QueueStats.
+type workerRegistry struct {
+	mu      sync.Mutex
+	nextID  int
+	workers map[*activeWorker]struct{}
+}
+
+// newWorkerRegistry returns an empty registry.
+func newWorkerRegistry() *workerRegistry {
+	return &workerRegistry{workers: make(map[*activeWorker]struct{})}
+}
+
+// add registers a worker of run.
+func (r *workerRegistry) add(run *runState) *activeWorker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	w := &activeWorker{id: r.nextID, run: run}
+	r.workers[w] = struct{}{}
+	return w
+}
+
+// remove unregisters a worker that exited.
+func (r *workerRegistry) remove(w *activeWorker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.workers, w)
+}
+
+// processAs processes task as processTask does, reporting it as the current
+// file of worker meanwhile.
+func (mt *mirrorTransform) processAs(ctx context.Context, run *runState, worker *activeWorker, task fileTask, errChan chan<- error) bool {
+	run.busy.Add(1)
+	worker.mu.Lock()
+	worker.path, worker.since = task.RelPath, time.Now()
+	worker.mu.Unlock()
+
+	defer func() {
+		worker.mu.Lock()
+		worker.path, worker.since = "", time.Time{}
+		worker.mu.Unlock()
+		run.finishActive(task.RelPath)
+		run.touch()
+		run.busy.Add(-1)
+	}()
+	return mt.processTask(ctx, run, task, errChan)
+}
+
+// QueueStats returns the number of pending and in-flight tasks and the
+// state of every worker of the runs in progress.
+func (mt *mirrorTransform) QueueStats() QueueStats {
+	r := mt.workers
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Runs own their queues, so count each once
+	stats := QueueStats{Workers: []WorkerState{}}
+	runs := make(map[*runState]struct{})
+	for w := range r.workers {
+		if _, ok := runs[w.run]; !ok {
+			runs[w.run] = struct{}{}
+			stats.Pending += int(w.run.queued.Load())
+		}
+
+		w.mu.Lock()
+		state := WorkerState{ID: w.id, Operation: w.run.operation, Path: w.path, Since: w.since}
+		w.mu.Unlock()
+		if state.Path != "" {
+			stats.InFlight++
+		}
+		stats.Workers = append(stats.Workers, state)
+	}
+	sort.Slice(stats.Workers, func(i, j int) bool { return stats.Workers[i].ID < stats.Workers[j].ID })
+	stats.InFlightBytes = mt.budget.inFlight()
+	return stats
+}
@@ -0,0 +1,68 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestQueueStats tests that pending and in-flight tasks and the workers of
+// a run in progress are reported, and nothing once it has finished.
+func TestQueueStats(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"})
+
+	release := make(chan struct{})
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      filepath.Join(testDir, "output"),
+		Patterns:       []string{"**/*.jpg"},
+		Concurrency:    2,
+		MaxConcurrency: 2,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			<-release
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- mt.Crawl(context.Background()) }()
+
+	// Two files are processed while the others wait
+	var stats QueueStats
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats = mt.QueueStats(); stats.InFlight == 2 && stats.Pending == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats.InFlight != 2 || stats.Pending != 3 {
+		close(release)
+		t.Fatalf("Expected 2 in-flight and 3 pending tasks, got %+v", stats)
+	}
+	if len(stats.Workers) != 2 {
+		t.Errorf("Expected 2 workers, got %+v", stats.Workers)
+	}
+	for _, w := range stats.Workers {
+		if w.Operation != "Crawl" || w.Path == "" || w.Since.IsZero() {
+			t.Errorf("Unexpected worker state %+v", w)
+		}
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if stats := mt.QueueStats(); stats.Pending != 0 || stats.InFlight != 0 || len(stats.Workers) != 0 {
+		t.Errorf("Expected no work after the crawl, got %+v", stats)
+	}
+}
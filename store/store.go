@@ -0,0 +1,175 @@
+// Package store provides the storage adapters that persist per-path
+// processing state, such as failure history, across runs.
+// It does not depend on the mirroring engine.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// PathState is the processing state recorded for an input path.
+type PathState struct {
+	// Failures is the number of consecutive failures.
+	Failures int `json:"failures,omitempty"`
+
+	// LastError is the message of the most recent failure.
+	LastError string `json:"lastError,omitempty"`
+
+	// LastFailure is the time of the most recent failure.
+	LastFailure time.Time `json:"lastFailure,omitempty"`
+
+	// NextAttempt is the earliest time the path will be processed again.
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+
+	// Parked is true when the path is no longer retried.
+	Parked bool `json:"parked,omitempty"`
+
+	// Retry is true while the path waits in the retry queue, after it
+	// failed or was left unprocessed.
+	Retry bool `json:"retry,omitempty"`
+
+	// Archive is the archive the path is an entry of, for files read by
+	// CrawlArchive. Their keys are the archive path joined with the entry's
+	// path, and they are retried by crawling the archive again.
+	Archive string `json:"archive,omitempty"`
+
+	// Size and ModTime describe the input when the state was recorded.
+	// A changed input resets the failure history.
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime,omitempty"`
+}
+
+// Store persists per-path processing state.
+// Keys are slash-separated paths relative to the input directory, or
+// archive paths joined with entry paths (see PathState.Archive).
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Load returns the state for relPath. ok is false if no state is recorded.
+	Load(relPath string) (state PathState, ok bool, err error)
+
+	// Save records the state for relPath.
+	Save(relPath string, state PathState) error
+
+	// Delete removes the state for relPath.
+	Delete(relPath string) error
+}
+
+// Lister is implemented by stores that can enumerate their states.
+type Lister interface {
+	// List returns a copy of every recorded state by path.
+	List() (map[string]PathState, error)
+}
+
+// memoryStore is a Store kept in memory.
+type memoryStore struct {
+	mu     sync.Mutex
+	states map[string]PathState
+}
+
+// NewMemory returns a Store that keeps state in memory.
+// State survives across runs of the same instance but not process restarts.
+func NewMemory() Store {
+	return &memoryStore{states: make(map[string]PathState)}
+}
+
+func (s *memoryStore) Load(relPath string) (PathState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[relPath]
+	return state, ok, nil
+}
+
+func (s *memoryStore) Save(relPath string, state PathState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[relPath] = state
+	return nil
+}
+
+func (s *memoryStore) Delete(relPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, relPath)
+	return nil
+}
+
+func (s *memoryStore) List() (map[string]PathState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make(map[string]PathState, len(s.states))
+	for relPath, state := range s.states {
+		states[relPath] = state
+	}
+	return states, nil
+}
+
+// fileStore is a Store persisted to a JSON file.
+type fileStore struct {
+	memoryStore
+	path string
+}
+
+// NewFile returns a Store persisted as JSON at path.
+// Existing state is loaded immediately; every change rewrites the file atomically.
+func NewFile(path string) (Store, error) {
+	s := &fileStore{
+		memoryStore: memoryStore{states: make(map[string]PathState)},
+		path:        path,
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read state file %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.states); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %q: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *fileStore) Save(relPath string, state PathState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[relPath] = state
+	return s.flush()
+}
+
+func (s *fileStore) Delete(relPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[relPath]; !ok {
+		return nil
+	}
+	delete(s.states, relPath)
+	return s.flush()
+}
+
+// flush writes all states to the file. The caller must hold s.mu.
+func (s *fileStore) flush() error {
+	data, err := json.MarshalIndent(s.states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write to a temporary file and rename so readers never see partial state
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state file %q: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file %q: %w", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestFileStore tests that state persists across store instances.
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+
+	store, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to create state store: %v", err)
+	}
+
+	state := PathState{Failures: 2, LastError: "corrupt", ModTime: time.Unix(1700000000, 123)}
+	if err := store.Save("dir/a.jpg", state); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save("b.jpg", PathState{Failures: 1}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Delete("b.jpg"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// Reopen the store from disk
+	store, err = NewFile(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen state store: %v", err)
+	}
+
+	loaded, ok, err := store.Load("dir/a.jpg")
+	if err != nil || !ok {
+		t.Fatalf("Load failed: ok=%v err=%v", ok, err)
+	}
+	if loaded.Failures != 2 || loaded.LastError != "corrupt" || !loaded.ModTime.Equal(state.ModTime) {
+		t.Errorf("Loaded state %+v does not match saved state %+v", loaded, state)
+	}
+
+	if _, ok, _ := store.Load("b.jpg"); ok {
+		t.Error("Deleted state was persisted")
+	}
+
+	states, err := store.(Lister).List()
+	if err != nil || len(states) != 1 || states["dir/a.jpg"].Failures != 2 {
+		t.Errorf("Unexpected listed states %+v, err=%v", states, err)
+	}
+}
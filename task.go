@@ -0,0 +1,219 @@
+package mirrortransform
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TaskEvent describes what caused a file to be queued.
+type TaskEvent string
+
+const (
+	// TaskEventScan marks a file found by scanning InputDir.
+	TaskEventScan TaskEvent = "scan"
+
+	// TaskEventCreate marks a file reported as created by the watcher.
+	TaskEventCreate TaskEvent = "create"
+
+	// TaskEventWrite marks a file reported as written by the watcher.
+	TaskEventWrite TaskEvent = "write"
+
+	// TaskEventChmod marks a file whose attributes changed.
+	TaskEventChmod TaskEvent = "chmod"
+
+	// TaskEventRecovered marks a file queued again because its output was
+	// left partially written (see RecoverPartialOutputs).
+	TaskEventRecovered TaskEvent = "recovered"
+
+	// TaskEventImport marks a file queued by ImportList, ImportTar or
+	// CrawlArchive.
+	TaskEventImport TaskEvent = "import"
+
+	// TaskEventRequest marks a file transformed on demand by Handler.
+	TaskEventRequest TaskEvent = "request"
+
+	// TaskEventManual marks a file passed to ProcessFile.
+	TaskEventManual TaskEvent = "manual"
+
+	// TaskEventRetry marks a file queued again from the retry queue (see
+	// RetryQueue).
+	TaskEventRetry TaskEvent = "retry"
+
+	// TaskEventRescan marks a file found by Rescan during a watch.
+	TaskEventRescan TaskEvent = "rescan"
+)
+
+// FileTask describes a matched file to be processed. It is shared by the
+// APIs that expose queued or processed files and can be encoded as JSON.
+// To save a stat call per file, scans leave Size and ModTime zero unless the
+// configuration uses them (TaskSorter, TaskCallback, ResultCallback,
+// FailureBackoff, NoCacheThreshold, MaxInFlightBytes) or the file is
+// processed by Run.
+type FileTask struct {
+	// InputPath is the full path of the source file.
+	InputPath string `json:"inputPath"`
+
+	// OutputPath is the full path where the output should be written.
+	OutputPath string `json:"outputPath"`
+
+	// RelPath is the path of the source file relative to InputDir.
+	RelPath string `json:"relPath"`
+
+	// Size is the size of the source file in bytes.
+	Size int64 `json:"size"`
+
+	// ModTime is the modification time of the source file.
+	ModTime time.Time `json:"modTime"`
+
+	// Event is what caused the file to be queued.
+	Event TaskEvent `json:"event,omitempty"`
+
+	// Pattern is the entry of Patterns or RegexPatterns that matched the
+	// file, or "(matcher)" if Config.Matcher selected it.
+	Pattern string `json:"pattern,omitempty"`
+
+	// ContentHash is the hex-encoded SHA-256 of the source file, set once
+	// processing starts if Config.IdempotencyKeys is enabled.
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// IdempotencyKey identifies the input path, content and
+	// Config.TransformVersion (see IdempotencyKey), set along with ContentHash.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// Task describes a file passed to TaskCallback.
+type Task struct {
+	FileTask
+
+	// Info is the stat result of the input when it was queued, so callbacks
+	// need not stat it again. It may be nil for inputs queued without one.
+	Info fs.FileInfo `json:"-"`
+
+	// Variant is the name of the variant being produced; empty without Variants.
+	// OutputPath is the variant's output path.
+	Variant string `json:"variant,omitempty"`
+
+	// QueuedAt is when the file was queued.
+	QueuedAt time.Time `json:"queuedAt"`
+
+	// StartedAt is when processing of the file started.
+	StartedAt time.Time `json:"startedAt"`
+
+	// Params are the transform parameters of the Override for the file's
+	// subtree; nil outside overridden subtrees.
+	Params map[string]string `json:"params,omitempty"`
+
+	// Metadata holds the values attached to the run with WithMetadata; nil
+	// without any. It is shared by the tasks of the run and must not be
+	// modified.
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// ctx is returned by Context.
+	ctx context.Context
+}
+
+// Context returns the context of the task. It carries the values of the
+// context passed to the run, such as a request-scoped logger, and the
+// run's metadata (see MetadataValue). It is cancelled once the run's
+// context is cancelled and Config.ShutdownGracePeriod has passed, or when
+// the run ends. It is never nil.
+func (t Task) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// TaskCallback is called for each file that matches the pattern, like
+// FileCallback but with a Task describing the file.
+// The directory for task.OutputPath is guaranteed to exist, unless
+// LazyOutputDirs leaves creating it to the callback.
+// If continueProcessing is false, the crawl will stop.
+type TaskCallback func(task Task) (continueProcessing bool, err error)
+
+// fileTask represents a file to be processed.
+type fileTask struct {
+	FileTask
+
+	// info is the stat result of the source file when it was queued.
+	info os.FileInfo
+
+	// queuedAt is when the task was created.
+	queuedAt time.Time
+
+	// archive is the archive the input was extracted from by CrawlArchive,
+	// or empty.
+	archive string
+}
+
+// stateKey returns the key of the task in the StateStore: its relative
+// path, or for an archive entry the archive path joined with it, so
+// failures point at something that still exists after the run.
+func (task fileTask) stateKey() string {
+	if task.archive != "" {
+		return filepath.ToSlash(filepath.Join(task.archive, task.RelPath))
+	}
+	return filepath.ToSlash(task.RelPath)
+}
+
+// newFileTask returns the task for the input described by info.
+func newFileTask(inputPath, outputPath, relPath string, info os.FileInfo, event TaskEvent, pattern string) fileTask {
+	task := fileTask{
+		FileTask: FileTask{
+			InputPath:  inputPath,
+			OutputPath: outputPath,
+			RelPath:    relPath,
+			Event:      event,
+			Pattern:    pattern,
+		},
+		info:     info,
+		queuedAt: time.Now(),
+	}
+	if info != nil {
+		task.Size = info.Size()
+		task.ModTime = info.ModTime()
+	}
+	return task
+}
+
+// watchTaskEvent returns the task event for a watcher operation.
+func watchTaskEvent(op fsnotify.Op) TaskEvent {
+	switch {
+	case op.Has(fsnotify.Create):
+		return TaskEventCreate
+	case op.Has(fsnotify.Write):
+		return TaskEventWrite
+	default:
+		return TaskEventChmod
+	}
+}
+
+// callFileCallback calls TaskCallback, or FileCallback if it is not set,
+// for one output of the task. ctx becomes the Task's context.
+func (mt *mirrorTransform) callFileCallback(ctx context.Context, task fileTask, output taskOutput, started time.Time) (bool, error) {
+	if mt.config.TaskCallback == nil {
+		return mt.config.FileCallback(task.InputPath, output.path)
+	}
+
+	t := mt.newTask(task, started, contextMetadata(ctx))
+	t.Variant, t.OutputPath, t.ctx = output.variant, output.path, ctx
+	return mt.config.TaskCallback(t)
+}
+
+// newTask returns the Task describing task, started at started, in a run
+// with metadata.
+func (mt *mirrorTransform) newTask(task fileTask, started time.Time, metadata map[string]any) Task {
+	return Task{
+		FileTask:  task.FileTask,
+		Info:      task.info,
+		QueuedAt:  task.queuedAt,
+		StartedAt: started,
+		Params:    mt.overrides.find(task.RelPath).params(),
+		Metadata:  metadata,
+	}
+}
@@ -0,0 +1,176 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestFileTaskJSON tests the JSON encoding of FileTask.
+func TestFileTaskJSON(t *testing.T) {
+	t.Parallel()
+	task := FileTask{
+		InputPath:  "/in/a.jpg",
+		OutputPath: "/out/a.jpg",
+		RelPath:    "a.jpg",
+		Size:       42,
+		ModTime:    time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		Event:      TaskEventWrite,
+		Pattern:    "**/*.jpg",
+	}
+
+	data, err := json.Marshal(task)
+	if err != nil {
+		t.Fatalf("Failed to marshal task: %v", err)
+	}
+	expected := `{"inputPath":"/in/a.jpg","outputPath":"/out/a.jpg","relPath":"a.jpg","size":42,"modTime":"2024-06-01T12:00:00Z","event":"write","pattern":"**/*.jpg"}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	var decoded FileTask
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal task: %v", err)
+	}
+	if decoded != task {
+		t.Errorf("Expected %+v, got %+v", task, decoded)
+	}
+}
+
+// TestFileTaskFromScan tests that scanned tasks carry their event and pattern.
+func TestFileTaskFromScan(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.png"})
+
+	var tasks []FileTask
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg", "**/*.png"},
+		TaskSorter: func(a, b FileTask) bool {
+			mu.Lock()
+			tasks = append(tasks, a, b)
+			mu.Unlock()
+			return a.RelPath < b.RelPath
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(tasks) == 0 {
+		t.Fatal("Expected the sorter to be called")
+	}
+	for _, task := range tasks {
+		if task.Event != TaskEventScan {
+			t.Errorf("Expected event %q for %s, got %q", TaskEventScan, task.RelPath, task.Event)
+		}
+		expected := map[string]string{"a.jpg": "**/*.jpg", "b.png": "**/*.png"}[task.RelPath]
+		if task.Pattern != expected {
+			t.Errorf("Expected pattern %q for %s, got %q", expected, task.RelPath, task.Pattern)
+		}
+		if task.Size != int64(len("test content")) {
+			t.Errorf("Expected size %d for %s, got %d", len("test content"), task.RelPath, task.Size)
+		}
+	}
+}
+
+// TestWatchTaskEvent tests the mapping of watcher operations to task events.
+func TestWatchTaskEvent(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		op       fsnotify.Op
+		expected TaskEvent
+	}{
+		{fsnotify.Create, TaskEventCreate},
+		{fsnotify.Write, TaskEventWrite},
+		{fsnotify.Create | fsnotify.Write, TaskEventCreate},
+		{fsnotify.Chmod, TaskEventChmod},
+	}
+
+	for _, tt := range tests {
+		if got := watchTaskEvent(tt.op); got != tt.expected {
+			t.Errorf("watchTaskEvent(%v) = %q, want %q", tt.op, got, tt.expected)
+		}
+	}
+}
+
+// TestTaskCallback tests that TaskCallback receives the file details.
+func TestTaskCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"dir/a.jpg"})
+
+	var tasks []Task
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		Variants: []Variant{
+			{Name: "thumb", Dir: filepath.Join(outputDir, "thumb")},
+			{Name: "webp", Dir: filepath.Join(outputDir, "webp"), Ext: ".webp"},
+		},
+		TaskCallback: func(task Task) (bool, error) {
+			mu.Lock()
+			tasks = append(tasks, task)
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(tasks) != 2 {
+		t.Fatalf("Expected 2 calls, got %d", len(tasks))
+	}
+	expectedOutputs := map[string]string{
+		"thumb": filepath.Join(outputDir, "thumb", "dir", "a.jpg"),
+		"webp":  filepath.Join(outputDir, "webp", "dir", "a.webp"),
+	}
+	for _, task := range tasks {
+		if task.RelPath != filepath.Join("dir", "a.jpg") || task.Event != TaskEventScan {
+			t.Errorf("Unexpected task %+v", task.FileTask)
+		}
+		if task.Info == nil || task.Info.Size() != int64(len("test content")) {
+			t.Errorf("Expected FileInfo of the input, got %v", task.Info)
+		}
+		if task.OutputPath != expectedOutputs[task.Variant] {
+			t.Errorf("Expected output %q for variant %q, got %q", expectedOutputs[task.Variant], task.Variant, task.OutputPath)
+		}
+		if task.QueuedAt.IsZero() || task.StartedAt.Before(task.QueuedAt) {
+			t.Errorf("Unexpected timestamps: queued %v, started %v", task.QueuedAt, task.StartedAt)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package mirrortransform
+
+import (
+	"time"
+)
+
+// TaskStatus is how a file fared, reported in TaskResult.
+type TaskStatus string
+
+const (
+	// TaskProcessed means the callback succeeded.
+	TaskProcessed TaskStatus = "processed"
+
+	// TaskSkipped means the file matched but was not passed to the callback,
+	// e.g. because its outputs are up to date.
+	TaskSkipped TaskStatus = "skipped"
+
+	// TaskFailed means the callback failed.
+	TaskFailed TaskStatus = "failed"
+)
+
+// TaskResult describes the outcome of one file, passed to ResultCallback.
+type TaskResult struct {
+	// Status is whether the file was processed, skipped or failed.
+	Status TaskStatus `json:"status"`
+
+	// Reason says why a skipped file was skipped, e.g. "output up to date".
+	Reason string `json:"reason,omitempty"`
+
+	// Duration is the wall time of the callback; zero for skipped files.
+	Duration time.Duration `json:"duration"`
+
+	// BytesRead is the size of the input as queued; zero for skipped files.
+	BytesRead int64 `json:"bytesRead"`
+
+	// BytesWritten is the total size of the outputs of a processed file.
+	BytesWritten int64 `json:"bytesWritten"`
+
+	// Err is the error of a failed file.
+	Err error `json:"-"`
+}
+
+// ResultCallback is called after each matched file with its outcome, so
+// per-file accounting need not live in the file callback. It is called
+// from several goroutines at once.
+type ResultCallback func(task Task, result TaskResult)
+
+// FileOutcome is the outcome of one file of a run, listed in Result.Files.
+type FileOutcome struct {
+	// RelPath is the path of the input relative to InputDir.
+	RelPath string
+
+	TaskResult
+}
+
+// skipTask logs that task was skipped and why, and reports it to
+// ResultCallback and the result of run.
+func (mt *mirrorTransform) skipTask(run *runState, task fileTask, reason string) {
+	mt.log().Debug("skipped file", "path", task.RelPath, "reason", reason)
+	mt.reportResult(run, task, time.Time{}, TaskResult{Status: TaskSkipped, Reason: reason})
+}
+
+// reportResult passes the outcome of task, started at started, to
+// ResultCallback if set, and records it for the result of run if collected.
+func (mt *mirrorTransform) reportResult(run *runState, task fileTask, started time.Time, result TaskResult) {
+	if run.collectResult {
+		run.mu.Lock()
+		run.outcomes = append(run.outcomes, FileOutcome{RelPath: task.RelPath, TaskResult: result})
+		run.mu.Unlock()
+	}
+	if mt.config.ResultCallback != nil {
+		mt.config.ResultCallback(mt.newTask(task, started, run.options.metadata), result)
+	}
+}
@@ -0,0 +1,64 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestResultCallback tests that every matched file is reported with its
+// outcome, timing and sizes.
+func TestResultCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"ok.jpg", "bad.jpg"})
+
+	errBad := errors.New("bad file")
+	var mu sync.Mutex
+	results := make(map[string]TaskResult)
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ContinueOnError: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if filepath.Base(inputPath) == "bad.jpg" {
+				return false, errBad
+			}
+			return true, os.WriteFile(outputPath, []byte("output"), 0644)
+		},
+		ResultCallback: func(task Task, result TaskResult) {
+			mu.Lock()
+			results[task.RelPath] = result
+			mu.Unlock()
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt.Crawl(context.Background())
+
+	ok := results["ok.jpg"]
+	if ok.Status != TaskProcessed || ok.BytesRead != 12 || ok.BytesWritten != 6 || ok.Duration <= 0 || ok.Err != nil {
+		t.Errorf("Unexpected result for ok.jpg: %+v", ok)
+	}
+	bad := results["bad.jpg"]
+	if bad.Status != TaskFailed || !errors.Is(bad.Err, errBad) || bad.BytesWritten != 0 {
+		t.Errorf("Unexpected result for bad.jpg: %+v", bad)
+	}
+
+	// Skipped files are reported with the reason
+	clear(results)
+	if err := mt.Crawl(context.Background(), WithDryRun()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if len(results) != 2 || results["ok.jpg"].Status != TaskSkipped || results["ok.jpg"].Reason != "dry run" {
+		t.Errorf("Expected both files to be skipped by the dry run, got %+v", results)
+	}
+}
@@ -0,0 +1,47 @@
+package mirrortransform
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultTempFilePatterns match the temporary files of transfers, editors
+// and office suites that Watch and Run ignore unless TempFilePatterns
+// replaces them or WatchTempFiles is set.
+var DefaultTempFilePatterns = []string{
+	// Partial transfers and downloads
+	"*.tmp", "*.temp", "*.part", "*.partial", "*.filepart", "*.crdownload", "*.download",
+
+	// Editor swap and backup files
+	"*.swp", "*.swx", "*~",
+
+	// Office lock files
+	"~$*", ".~lock.*#",
+
+	// Desktop metadata
+	".DS_Store", "Thumbs.db",
+}
+
+// isTempFile reports whether watch events for the file at relPath are
+// ignored as those of a temporary file. Patterns match the base name
+// without regard to case.
+func (mt *mirrorTransform) isTempFile(relPath string) bool {
+	if mt.config.WatchTempFiles {
+		return false
+	}
+	patterns := mt.config.TempFilePatterns
+	if len(patterns) == 0 {
+		patterns = DefaultTempFilePatterns
+	}
+
+	name := strings.ToLower(filepath.Base(relPath))
+	for _, pattern := range patterns {
+		// Patterns are validated with the configuration
+		if match, _ := doublestar.Match(strings.ToLower(pattern), name); match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package mirrortransform
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestIsTempFile tests the default temporary file patterns and how they are
+// replaced and disabled.
+func TestIsTempFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		config   Config
+		relPath  string
+		expected bool
+	}{
+		{"partial upload", Config{}, "photos/a.jpg.part", true},
+		{"browser download", Config{}, "a.jpg.crdownload", true},
+		{"editor backup", Config{}, "notes.txt~", true},
+		{"office lock", Config{}, "docs/~$report.docx", true},
+		{"libreoffice lock", Config{}, ".~lock.report.odt#", true},
+		{"desktop metadata", Config{}, "photos/.DS_Store", true},
+		{"case ignored", Config{}, "A.JPG.TMP", true},
+		{"regular file", Config{}, "photos/a.jpg", false},
+		{"temp directory name", Config{}, "a.tmp/b.jpg", false},
+		{"replaced", Config{TempFilePatterns: []string{"*.uploading"}}, "a.jpg.uploading", true},
+		{"replaced default", Config{TempFilePatterns: []string{"*.uploading"}}, "a.jpg.part", false},
+		{"disabled", Config{WatchTempFiles: true}, "a.jpg.part", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mt := &mirrorTransform{config: tt.config}
+			if got := mt.isTempFile(filepath.FromSlash(tt.relPath)); got != tt.expected {
+				t.Errorf("isTempFile(%q) = %v, expected %v", tt.relPath, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestWatchIgnoresTempFiles tests that watch events for temporary files are
+// dropped even when the patterns match them.
+func TestWatchIgnoresTempFiles(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "a.jpg.part", "~$b.docx"})
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	taskChan := make(chan fileTask, 10)
+	for _, name := range []string{"a.jpg.part", "~$b.docx", "a.jpg"} {
+		event := fsnotify.Event{Name: filepath.Join(inputDir, name), Op: fsnotify.Create}
+		if err := mt.processWatchEvent(context.Background(), nil, nil, event, taskChan); err != nil {
+			t.Fatalf("processWatchEvent failed for %q: %v", name, err)
+		}
+	}
+	close(taskChan)
+
+	var relPaths []string
+	for task := range taskChan {
+		relPaths = append(relPaths, task.RelPath)
+	}
+	if len(relPaths) != 1 || relPaths[0] != "a.jpg" {
+		t.Errorf("Expected only a.jpg to be queued, got %v", relPaths)
+	}
+}
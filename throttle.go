@@ -0,0 +1,165 @@
+package mirrortransform
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultThrottleInterval is used when ErrorThrottle.Interval is not set.
+const defaultThrottleInterval = time.Minute
+
+// ErrorThrottle collapses repeated identical errors so ErrorCallback is not
+// flooded when many files fail for the same reason (e.g. a read-only output
+// volume). Within each interval, only the first occurrence of an error is
+// passed to ErrorCallback; repeats reuse its decision and are counted.
+// Errors are identical when their messages match after removing the path.
+type ErrorThrottle struct {
+	// Interval is the length of a throttling window.
+	// Defaults to one minute if not set.
+	Interval time.Duration
+
+	// SummaryCallback receives the counts of suppressed errors when an
+	// interval has passed and at the end of each run. It is not called when
+	// nothing was suppressed.
+	SummaryCallback func(summaries []ErrorSummary)
+}
+
+// ErrorSummary counts the occurrences of one error that were not passed to
+// ErrorCallback.
+type ErrorSummary struct {
+	// Message is the error message with the path replaced by "<path>".
+	Message string
+
+	// Count is the number of suppressed occurrences.
+	Count int
+
+	// LastPath is the path of the most recent occurrence.
+	LastPath string
+}
+
+// throttleEntry is the state of one distinct error within a window.
+type throttleEntry struct {
+	stop     bool
+	count    int
+	lastPath string
+}
+
+// errorThrottler applies ErrorThrottle to ErrorCallback.
+type errorThrottler struct {
+	throttle ErrorThrottle
+	now      func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	entries     map[string]*throttleEntry
+}
+
+// newErrorThrottler returns a throttler for the configured throttle, or nil if disabled.
+func newErrorThrottler(config *Config) *errorThrottler {
+	if config.ErrorThrottle == nil {
+		return nil
+	}
+
+	throttle := *config.ErrorThrottle
+	if throttle.Interval <= 0 {
+		throttle.Interval = defaultThrottleInterval
+	}
+	return &errorThrottler{
+		throttle: throttle,
+		now:      time.Now,
+		entries:  make(map[string]*throttleEntry),
+	}
+}
+
+// call passes the error to callback unless an identical error was already
+// passed in the current window, in which case that decision is reused.
+func (t *errorThrottler) call(callback ErrorCallback, path string, err error) (bool, error) {
+	key := strings.ReplaceAll(err.Error(), path, "<path>")
+
+	t.mu.Lock()
+	summaries := t.rotateLocked(false)
+	entry, seen := t.entries[key]
+	if seen {
+		entry.count++
+		entry.lastPath = path
+	}
+	t.mu.Unlock()
+	t.report(summaries)
+
+	if seen {
+		return entry.stop, nil
+	}
+
+	stop, retErr := callback(path, err)
+	if retErr == nil {
+		t.mu.Lock()
+		if _, ok := t.entries[key]; !ok {
+			t.entries[key] = &throttleEntry{stop: stop}
+		}
+		t.mu.Unlock()
+	}
+	return stop, retErr
+}
+
+// flush reports the suppressed errors and starts a new window.
+func (t *errorThrottler) flush() {
+	t.mu.Lock()
+	summaries := t.rotateLocked(true)
+	t.mu.Unlock()
+	t.report(summaries)
+}
+
+// rotateLocked starts a new window if the current one has expired or force
+// is set, returning the summaries of the finished window.
+// The caller must hold t.mu.
+func (t *errorThrottler) rotateLocked(force bool) []ErrorSummary {
+	now := t.now()
+	if t.windowStart.IsZero() {
+		t.windowStart = now
+	}
+	if !force && now.Sub(t.windowStart) < t.throttle.Interval {
+		return nil
+	}
+
+	var summaries []ErrorSummary
+	for message, entry := range t.entries {
+		if entry.count > 0 {
+			summaries = append(summaries, ErrorSummary{Message: message, Count: entry.count, LastPath: entry.lastPath})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Message < summaries[j].Message
+	})
+
+	t.entries = make(map[string]*throttleEntry)
+	t.windowStart = now
+	return summaries
+}
+
+// report passes non-empty summaries to SummaryCallback.
+func (t *errorThrottler) report(summaries []ErrorSummary) {
+	if len(summaries) > 0 && t.throttle.SummaryCallback != nil {
+		t.throttle.SummaryCallback(summaries)
+	}
+}
+
+// callErrorCallback calls ErrorCallback, which must be set, through the
+// throttler if one is configured.
+func (mt *mirrorTransform) callErrorCallback(path string, err error) (bool, error) {
+	if mt.throttler == nil {
+		return mt.config.ErrorCallback(path, err)
+	}
+	return mt.throttler.call(mt.config.ErrorCallback, path, err)
+}
+
+// flushErrorSummaries reports errors suppressed during the run that just ended.
+func (mt *mirrorTransform) flushErrorSummaries() {
+	if mt.throttler != nil {
+		mt.throttler.flush()
+	}
+}
@@ -0,0 +1,94 @@
+package mirrortransform
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestErrorThrottle tests that identical errors reach ErrorCallback once per
+// window and are summarized afterwards.
+func TestErrorThrottle(t *testing.T) {
+	t.Parallel()
+
+	var summaries []ErrorSummary
+	config := Config{
+		ErrorThrottle: &ErrorThrottle{
+			Interval: time.Minute,
+			SummaryCallback: func(s []ErrorSummary) {
+				summaries = append(summaries, s...)
+			},
+		},
+	}
+	throttler := newErrorThrottler(&config)
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	throttler.now = func() time.Time { return now }
+
+	calls := 0
+	callback := func(path string, err error) (bool, error) {
+		calls++
+		return false, nil
+	}
+
+	// The path is not part of the identity of an error
+	for i := 0; i < 100; i++ {
+		path := fmt.Sprintf("/out/file%d.jpg", i)
+		stop, err := throttler.call(callback, path, fmt.Errorf("open %s: read-only file system", path))
+		if stop || err != nil {
+			t.Fatalf("Unexpected decision: stop=%v err=%v", stop, err)
+		}
+	}
+	throttler.call(callback, "/in/a", errors.New("permission denied"))
+
+	if calls != 2 {
+		t.Errorf("Expected 2 callback calls, got %d", calls)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("Expected no summary within the window, got %v", summaries)
+	}
+
+	// The next error after the interval emits the summary and is passed on again
+	now = now.Add(2 * time.Minute)
+	throttler.call(callback, "/out/x.jpg", errors.New("open /out/x.jpg: read-only file system"))
+
+	if calls != 3 {
+		t.Errorf("Expected error to reach the callback in the new window, got %d calls", calls)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("Expected 1 summary, got %v", summaries)
+	}
+	if summaries[0].Count != 99 || summaries[0].Message != "open <path>: read-only file system" || summaries[0].LastPath != "/out/file99.jpg" {
+		t.Errorf("Unexpected summary: %+v", summaries[0])
+	}
+
+	// Flushing with nothing suppressed reports nothing
+	throttler.flush()
+	if len(summaries) != 1 {
+		t.Errorf("Expected no additional summary, got %v", summaries)
+	}
+}
+
+// TestErrorThrottleReusesStop tests that repeats reuse the callback's stop decision.
+func TestErrorThrottleReusesStop(t *testing.T) {
+	t.Parallel()
+
+	config := Config{ErrorThrottle: &ErrorThrottle{}}
+	throttler := newErrorThrottler(&config)
+
+	calls := 0
+	callback := func(path string, err error) (bool, error) {
+		calls++
+		return true, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		stop, _ := throttler.call(callback, "/a", errors.New("disk full"))
+		if !stop {
+			t.Errorf("Expected repeated error to stop as the first did")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 callback call, got %d", calls)
+	}
+}
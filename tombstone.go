@@ -0,0 +1,114 @@
+package mirrortransform
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Tombstone records an input that disappeared from InputDir, as written to
+// Config.TombstoneLog, one JSON object per line.
+type Tombstone struct {
+	// Time is when the removal was reported.
+	Time time.Time `json:"time"`
+
+	// Op is "REMOVE" for a deleted input and "RENAME" for one moved away.
+	Op string `json:"op"`
+
+	// Path is the slash-separated path of the input relative to InputDir.
+	Path string `json:"path"`
+
+	// Outputs are the output paths that were produced for the input.
+	Outputs []string `json:"outputs"`
+
+	// Labels are the Config.RunLabels of the recording instance.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// tombstoneLog appends tombstones to Config.TombstoneLog.
+type tombstoneLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// newTombstoneLog returns a log for the configured writer, or nil if disabled.
+func newTombstoneLog(config *Config) *tombstoneLog {
+	if config.TombstoneLog == nil {
+		return nil
+	}
+	return &tombstoneLog{encoder: json.NewEncoder(config.TombstoneLog)}
+}
+
+// recordTombstone appends a tombstone for a remove or rename event if the
+// removed path would have been mirrored.
+func (mt *mirrorTransform) recordTombstone(event fsnotify.Event) error {
+	if mt.tombstones == nil {
+		return nil
+	}
+
+	relPath, err := mt.relPath(event.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path for %q: %w", event.Name, err)
+	}
+
+	// Only paths that would have been mirrored leave anything behind
+	if !mt.config.IncludeHidden && isHidden(event.Name, relPath) {
+		return nil
+	}
+	ignored, err := mt.isIgnored(relPath, false)
+	if err != nil {
+		return err
+	}
+	if ignored {
+		return nil
+	}
+	excluded, err := mt.isExcluded(relPath)
+	if err != nil {
+		return err
+	}
+	if excluded {
+		return nil
+	}
+	pattern, err := mt.matchPattern(relPath, newFileEntry(event.Name, nil))
+	if err != nil {
+		return err
+	}
+	if pattern == "" || mt.isPrioritySidecar(relPath) {
+		return nil
+	}
+
+	outputPath, err := mt.outputPath(relPath)
+	if err != nil {
+		return err
+	}
+	outputs, err := mt.taskOutputs(fileTask{FileTask: FileTask{InputPath: event.Name, OutputPath: outputPath, RelPath: relPath}})
+	if err != nil {
+		return err
+	}
+
+	op := "REMOVE"
+	if event.Op.Has(fsnotify.Rename) {
+		op = "RENAME"
+	}
+	tombstone := Tombstone{
+		Time:    time.Now(),
+		Op:      op,
+		Path:    filepath.ToSlash(relPath),
+		Outputs: make([]string, 0, len(outputs)),
+		Labels:  mt.config.RunLabels,
+	}
+	for _, output := range outputs {
+		tombstone.Outputs = append(tombstone.Outputs, output.path)
+	}
+
+	mt.tombstones.mu.Lock()
+	defer mt.tombstones.mu.Unlock()
+	if err := mt.tombstones.encoder.Encode(tombstone); err != nil {
+		return fmt.Errorf("failed to record tombstone: %w", err)
+	}
+	return nil
+}
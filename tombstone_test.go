@@ -0,0 +1,78 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestRecordTombstone tests that removals of matching inputs are logged with
+// their outputs.
+func TestRecordTombstone(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	var log bytes.Buffer
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"tmp/**"},
+		RunLabels:       []string{"nightly"},
+		TombstoneLog:    &log,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	events := []fsnotify.Event{
+		{Name: filepath.Join(inputDir, "a", "photo.jpg"), Op: fsnotify.Remove},
+		{Name: filepath.Join(inputDir, "b.jpg"), Op: fsnotify.Rename},
+		{Name: filepath.Join(inputDir, "notes.txt"), Op: fsnotify.Remove},
+		{Name: filepath.Join(inputDir, "tmp", "c.jpg"), Op: fsnotify.Remove},
+	}
+	for _, event := range events {
+		if err := mt.processWatchEvent(context.Background(), nil, nil, event, nil); err != nil {
+			t.Fatalf("processWatchEvent failed for %q: %v", event.Name, err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 tombstones, got %d: %q", len(lines), log.String())
+	}
+
+	var first, second Tombstone
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Failed to parse tombstone: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Failed to parse tombstone: %v", err)
+	}
+
+	if first.Op != "REMOVE" || first.Path != "a/photo.jpg" {
+		t.Errorf("Unexpected first tombstone: %+v", first)
+	}
+	if len(first.Outputs) != 1 || first.Outputs[0] != filepath.Join(outputDir, "a", "photo.jpg") {
+		t.Errorf("Expected output %q, got %v", filepath.Join(outputDir, "a", "photo.jpg"), first.Outputs)
+	}
+	if len(first.Labels) != 1 || first.Labels[0] != "nightly" {
+		t.Errorf("Expected labels [nightly], got %v", first.Labels)
+	}
+	if second.Op != "RENAME" || second.Path != "b.jpg" {
+		t.Errorf("Unexpected second tombstone: %+v", second)
+	}
+}
@@ -0,0 +1,99 @@
+package mirrortransform
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans this package creates.
+const tracerName = "github.com/ideamans/go-mirror-transform"
+
+// Outcomes recorded on file spans.
+const (
+	spanOutcomeSuccess = "success"
+	spanOutcomeError   = "error"
+	spanOutcomeStopped = "stopped"
+)
+
+// tracing creates the spans of runs and processed files.
+// A nil *tracing creates none.
+type tracing struct {
+	tracer trace.Tracer
+}
+
+// newTracing returns the tracing for the configured TracerProvider, or nil
+// if none is set.
+func newTracing(config *Config) *tracing {
+	if config.TracerProvider == nil {
+		return nil
+	}
+	return &tracing{tracer: config.TracerProvider.Tracer(tracerName)}
+}
+
+// startRun starts the parent span of a finite run such as a crawl. The
+// returned function ends it with the run's result and error.
+func (t *tracing) startRun(ctx context.Context, operation string) (context.Context, func(result *Result, err error)) {
+	if t == nil {
+		return ctx, func(*Result, error) {}
+	}
+
+	ctx, span := t.tracer.Start(ctx, "mirrortransform."+operation)
+	return ctx, func(result *Result, err error) {
+		if result != nil {
+			span.SetAttributes(
+				attribute.Int("mirrortransform.matched", result.Matched),
+				attribute.Int("mirrortransform.processed", result.Processed),
+				attribute.Int("mirrortransform.skipped", result.Skipped),
+				attribute.Int("mirrortransform.failed", result.Failed),
+				attribute.Int64("mirrortransform.bytes", result.Bytes),
+			)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// startFile starts the span of a file whose callback is about to run, as a
+// child of the span in ctx if any, and returns ctx with the new span.
+func (t *tracing) startFile(ctx context.Context, task fileTask) (context.Context, trace.Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	return t.tracer.Start(ctx, "mirrortransform.file", trace.WithAttributes(
+		attribute.String("mirrortransform.rel_path", task.RelPath),
+		attribute.Int64("mirrortransform.size", task.Size),
+		attribute.String("mirrortransform.event", string(task.Event)),
+		attribute.String("mirrortransform.pattern", task.Pattern),
+	))
+}
+
+// endFile ends the span of a file with the callback's duration and outcome.
+// span may be nil.
+func (t *tracing) endFile(span trace.Span, duration time.Duration, continueProcessing bool, err error) {
+	if span == nil {
+		return
+	}
+
+	outcome := spanOutcomeSuccess
+	switch {
+	case err != nil:
+		outcome = spanOutcomeError
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case !continueProcessing:
+		outcome = spanOutcomeStopped
+	}
+	span.SetAttributes(
+		attribute.Float64("mirrortransform.duration_ms", float64(duration)/float64(time.Millisecond)),
+		attribute.String("mirrortransform.outcome", outcome),
+	)
+	span.End()
+}
@@ -0,0 +1,109 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanAttr returns the value of the attribute key of span.
+func spanAttr(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// TestTracing tests that crawls get a run span with a child span per
+// processed file, and that callbacks see the file span in their context.
+func TestTracing(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "dir/bad.jpg", "skip.txt"})
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var traced atomic.Int32
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ContinueOnError: true,
+		TracerProvider:  provider,
+		TaskCallback: func(task Task) (bool, error) {
+			if trace.SpanFromContext(task.Context()).SpanContext().IsValid() {
+				traced.Add(1)
+			}
+			if strings.Contains(task.RelPath, "bad") {
+				return true, errors.New("broken")
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if _, err := mt.CrawlWithResult(context.Background()); err == nil {
+		t.Fatal("Expected the failure to be reported")
+	}
+	if traced.Load() != 2 {
+		t.Errorf("Expected both callbacks to see a span, got %d", traced.Load())
+	}
+
+	var runSpan sdktrace.ReadOnlySpan
+	fileSpans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "mirrortransform.Crawl":
+			runSpan = span
+		case "mirrortransform.file":
+			fileSpans[spanAttr(span, "mirrortransform.rel_path").AsString()] = span
+		}
+	}
+	if runSpan == nil {
+		t.Fatal("Expected a span for the crawl")
+	}
+	if spanAttr(runSpan, "mirrortransform.processed").AsInt64() != 1 || spanAttr(runSpan, "mirrortransform.failed").AsInt64() != 1 {
+		t.Errorf("Unexpected run span attributes: %v", runSpan.Attributes())
+	}
+	if runSpan.Status().Code != codes.Error {
+		t.Errorf("Expected the run span to record the failure, got %v", runSpan.Status())
+	}
+
+	if len(fileSpans) != 2 {
+		t.Fatalf("Expected 2 file spans, got %d", len(fileSpans))
+	}
+	for relPath, outcome := range map[string]string{"a.jpg": "success", filepath.Join("dir", "bad.jpg"): "error"} {
+		span, ok := fileSpans[relPath]
+		if !ok {
+			t.Errorf("Expected a span for %s", relPath)
+			continue
+		}
+		if span.Parent().SpanID() != runSpan.SpanContext().SpanID() {
+			t.Errorf("Expected the span for %s to be a child of the run span", relPath)
+		}
+		if got := spanAttr(span, "mirrortransform.outcome").AsString(); got != outcome {
+			t.Errorf("Expected outcome %q for %s, got %q", outcome, relPath, got)
+		}
+		if spanAttr(span, "mirrortransform.event").AsString() != string(TaskEventScan) {
+			t.Errorf("Expected the scan event for %s, got %v", relPath, span.Attributes())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package transform provides ready-made transformers that can be used as the
+// FileCallback of a mirror, such as plain copies that preserve metadata.
+// It does not depend on the mirroring engine.
+package transform
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TempFilePrefix is the name prefix of temporary files written by the copy
+// helpers before they are renamed into place.
+const TempFilePrefix = ".mirrortmp-"
+
+// CopyOptions controls CopyFile and CopyCallback.
+// Mode bits and modification times are always preserved.
+type CopyOptions struct {
+	// PreserveOwner copies the owning user and group, which usually requires
+	// root privileges. Only supported on Linux and macOS.
+	PreserveOwner bool
+
+	// PreserveXattrs copies extended attributes.
+	// Only supported on Linux and macOS.
+	PreserveXattrs bool
+
+	// RateLimit, if set, caps the throughput of the copy. Share one limiter
+	// between the copies of every worker to cap their total.
+	RateLimit *RateLimiter
+}
+
+// Func transforms the file at inputPath into outputPath. It has the
+// signature of mirrortransform.FileCallback, so transformers can be used as
+// callbacks directly.
+type Func func(inputPath, outputPath string) (continueProcessing bool, err error)
+
+// CopyCallback returns a Func that copies each input to its output path
+// with CopyFile, for plain mirrors that need no transformation.
+func CopyCallback(opts CopyOptions) Func {
+	return func(inputPath, outputPath string) (bool, error) {
+		if err := CopyFile(inputPath, outputPath, opts); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// CopyFile copies inputPath to outputPath, preserving mode bits and
+// modification time, and ownership and extended attributes as requested.
+// The copy is written to a temporary file next to outputPath and renamed
+// into place, so readers never see a partial output.
+func CopyFile(inputPath, outputPath string, opts CopyOptions) error {
+	return CopyFileContext(context.Background(), inputPath, outputPath, opts)
+}
+
+// CopyFileContext is CopyFile ending early with the error of ctx once it is
+// done while waiting for opts.RateLimit, e.g. with the context of a task.
+func CopyFileContext(ctx context.Context, inputPath, outputPath string, opts CopyOptions) error {
+	src, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", inputPath, err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %q: %w", inputPath, err)
+	}
+
+	// The output directory may be left to the first write
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory for %q: %w", outputPath, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), TempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %q: %w", outputPath, err)
+	}
+	tmpPath := tmp.Name()
+
+	// Remove the temporary file on any failure
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, opts.RateLimit.reader(ctx, src)); err != nil {
+		return fmt.Errorf("failed to copy %q: %w", inputPath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %q: %w", tmpPath, err)
+	}
+
+	if err := copyMetadata(inputPath, tmpPath, info, opts); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("failed to rename %q to %q: %w", tmpPath, outputPath, err)
+	}
+	committed = true
+	return nil
+}
+
+// copyMetadata applies the metadata of the input described by info to path.
+func copyMetadata(inputPath, path string, info os.FileInfo, opts CopyOptions) error {
+	// Ownership first, as changing it may clear setuid bits
+	if opts.PreserveOwner {
+		if err := copyOwner(path, info); err != nil {
+			return fmt.Errorf("failed to preserve owner of %q: %w", inputPath, err)
+		}
+	}
+	if opts.PreserveXattrs {
+		if err := copyXattrs(inputPath, path); err != nil {
+			return fmt.Errorf("failed to preserve extended attributes of %q: %w", inputPath, err)
+		}
+	}
+
+	if err := os.Chmod(path, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+		return fmt.Errorf("failed to preserve mode of %q: %w", inputPath, err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve modification time of %q: %w", inputPath, err)
+	}
+	return nil
+}
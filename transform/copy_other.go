@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package transform
+
+import (
+	"errors"
+	"os"
+)
+
+// copyOwner is not supported on this platform.
+func copyOwner(path string, info os.FileInfo) error {
+	return errors.New("preserving ownership is not supported on this platform")
+}
+
+// copyXattrs is not supported on this platform.
+func copyXattrs(src, dst string) error {
+	return errors.New("preserving extended attributes is not supported on this platform")
+}
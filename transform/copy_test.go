@@ -0,0 +1,23 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCopyFileMissingInput tests that a failed copy leaves nothing behind.
+func TestCopyFileMissingInput(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	err := CopyFile(filepath.Join(testDir, "missing"), filepath.Join(testDir, "out"), CopyOptions{})
+	if err == nil {
+		t.Fatalf("Expected error for missing input")
+	}
+
+	entries, _ := os.ReadDir(testDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left behind, got %v", entries)
+	}
+}
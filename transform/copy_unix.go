@@ -0,0 +1,77 @@
+//go:build linux || darwin
+
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyOwner sets the owner of path to the owner recorded in info.
+func copyOwner(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("ownership is not available")
+	}
+	return os.Lchown(path, int(stat.Uid), int(stat.Gid))
+}
+
+// copyXattrs copies the extended attributes of src to dst.
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		size, err := unix.Getxattr(src, name, nil)
+		if err != nil {
+			return fmt.Errorf("failed to read attribute %q: %w", name, err)
+		}
+		value := make([]byte, size)
+		if size > 0 {
+			if size, err = unix.Getxattr(src, name, value); err != nil {
+				return fmt.Errorf("failed to read attribute %q: %w", name, err)
+			}
+			value = value[:size]
+		}
+		if err := unix.Setxattr(dst, name, value, 0); err != nil {
+			return fmt.Errorf("failed to write attribute %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// listXattrs returns the names of the extended attributes of path.
+func listXattrs(path string) ([]string, error) {
+	size, err := unix.Listxattr(path, nil)
+	if err != nil {
+		// File systems without xattr support have nothing to copy
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list attributes: %w", err)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, size)
+	size, err = unix.Listxattr(path, buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attributes: %w", err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(buf[:size]), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestCopyFileXattrs tests that extended attributes are copied when requested.
+func TestCopyFileXattrs(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	input := filepath.Join(testDir, "in")
+	output := filepath.Join(testDir, "out")
+
+	if err := os.WriteFile(input, []byte("data"), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if err := unix.Setxattr(input, "user.mirror-test", []byte("value"), 0); err != nil {
+		t.Skipf("Extended attributes not supported here: %v", err)
+	}
+
+	if err := CopyFile(input, output, CopyOptions{PreserveXattrs: true}); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	buf := make([]byte, 64)
+	n, err := unix.Getxattr(output, "user.mirror-test", buf)
+	if err != nil {
+		t.Fatalf("Expected attribute on output: %v", err)
+	}
+	if string(buf[:n]) != "value" {
+		t.Errorf("Unexpected attribute value %q", buf[:n])
+	}
+}
@@ -0,0 +1,96 @@
+package transform
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LinkOptions controls LinkFile and LinkCallback.
+type LinkOptions struct {
+	// Hardlink allows hard links when the file system cannot clone files.
+	// A hard-linked output shares its data, mode and modification time with
+	// the input, so changing one in place changes the other.
+	Hardlink bool
+
+	// Copy controls the copy made when the output can be neither cloned nor
+	// linked, e.g. because it is on another file system.
+	Copy CopyOptions
+}
+
+// LinkCallback returns a Func that mirrors each input to its output path
+// with LinkFile, for identity transforms that should not duplicate data.
+func LinkCallback(opts LinkOptions) Func {
+	return func(inputPath, outputPath string) (bool, error) {
+		if err := LinkFile(inputPath, outputPath, opts); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+}
+
+// LinkFile makes outputPath a copy of inputPath that shares its data where
+// the file system allows: a reflink (FICLONE on Linux, clonefile on macOS)
+// if supported, then a hard link if opts.Hardlink is set, and otherwise a
+// regular copy with CopyFile. Clones and copies preserve mode bits and
+// modification time. The output is created under a temporary name next to
+// outputPath and renamed into place, so readers never see a partial output.
+func LinkFile(inputPath, outputPath string, opts LinkOptions) error {
+	// The output directory may be left to the first write
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create output directory for %q: %w", outputPath, err)
+	}
+
+	tmpPath, err := tempPath(filepath.Dir(outputPath))
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for %q: %w", outputPath, err)
+	}
+
+	// Clones are independent of the input, so try them first
+	if err := cloneFile(inputPath, tmpPath); err == nil {
+		info, err := os.Stat(inputPath)
+		if err == nil {
+			err = copyMetadata(inputPath, tmpPath, info, opts.Copy)
+		}
+		if err == nil {
+			err = rename(tmpPath, outputPath)
+		}
+		if err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		return nil
+	}
+
+	if opts.Hardlink {
+		if err := os.Link(inputPath, tmpPath); err == nil {
+			if err := rename(tmpPath, outputPath); err != nil {
+				os.Remove(tmpPath)
+				return err
+			}
+			return nil
+		}
+	}
+
+	// Different file systems and platforms without links get a copy
+	return CopyFile(inputPath, outputPath, opts.Copy)
+}
+
+// tempPath returns an unused temporary file name in dir.
+func tempPath(dir string) (string, error) {
+	var random [8]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, TempFilePrefix+hex.EncodeToString(random[:])), nil
+}
+
+// rename moves the temporary file tmpPath to outputPath.
+func rename(tmpPath, outputPath string) error {
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("failed to rename %q to %q: %w", tmpPath, outputPath, err)
+	}
+	return nil
+}
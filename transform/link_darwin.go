@@ -0,0 +1,9 @@
+package transform
+
+import "golang.org/x/sys/unix"
+
+// cloneFile creates dst as a clone of src with clonefile.
+// dst must not exist.
+func cloneFile(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
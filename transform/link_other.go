@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package transform
+
+import "errors"
+
+// cloneFile is not supported on this platform.
+func cloneFile(src, dst string) error {
+	return errors.ErrUnsupported
+}
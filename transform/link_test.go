@@ -0,0 +1,84 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestLinkFile tests that linked outputs have the content and metadata of
+// their inputs, replace existing outputs, and share data only with Hardlink.
+func TestLinkFile(t *testing.T) {
+	t.Parallel()
+
+	for _, hardlink := range []bool{false, true} {
+		hardlink := hardlink // capture range variable
+		name := "clone or copy"
+		if hardlink {
+			name = "hardlink"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			input := filepath.Join(testDir, "input.bin")
+			output := filepath.Join(testDir, "output.bin")
+
+			modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+			if err := os.WriteFile(input, []byte("content"), 0640); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+			if err := os.Chtimes(input, modTime, modTime); err != nil {
+				t.Fatalf("Failed to set times: %v", err)
+			}
+			if err := os.WriteFile(output, []byte("stale"), 0644); err != nil {
+				t.Fatalf("Failed to write output: %v", err)
+			}
+
+			if err := LinkFile(input, output, LinkOptions{Hardlink: hardlink}); err != nil {
+				t.Fatalf("LinkFile failed: %v", err)
+			}
+
+			data, err := os.ReadFile(output)
+			if err != nil || string(data) != "content" {
+				t.Fatalf("Unexpected output %q (%v)", data, err)
+			}
+			inputInfo, _ := os.Stat(input)
+			outputInfo, err := os.Stat(output)
+			if err != nil {
+				t.Fatalf("Failed to stat output: %v", err)
+			}
+			if runtime.GOOS != "windows" && outputInfo.Mode().Perm() != 0640 {
+				t.Errorf("Expected mode 0640, got %v", outputInfo.Mode().Perm())
+			}
+			if !outputInfo.ModTime().Equal(modTime) {
+				t.Errorf("Expected modification time %v, got %v", modTime, outputInfo.ModTime())
+			}
+			if !hardlink && os.SameFile(inputInfo, outputInfo) {
+				t.Errorf("Expected an independent output without Hardlink")
+			}
+
+			entries, _ := os.ReadDir(testDir)
+			if len(entries) != 2 {
+				t.Errorf("Expected no temporary files to be left behind, got %v", entries)
+			}
+		})
+	}
+}
+
+// TestLinkFileMissingInput tests that a failed link leaves nothing behind.
+func TestLinkFileMissingInput(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	err := LinkFile(filepath.Join(testDir, "missing"), filepath.Join(testDir, "out"), LinkOptions{Hardlink: true})
+	if err == nil {
+		t.Fatalf("Expected error for missing input")
+	}
+
+	entries, _ := os.ReadDir(testDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be left behind, got %v", entries)
+	}
+}
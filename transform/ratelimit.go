@@ -0,0 +1,111 @@
+package transform
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateBurst is how much unused throughput a RateLimiter carries over, so
+// short pauses between files do not waste the cap while long idle periods
+// do not allow a burst.
+const rateBurst = 100 * time.Millisecond
+
+// rateChunk bounds the bytes copied between two waits, so the throughput
+// stays smooth instead of arriving in large bursts.
+const rateChunk = 32 * 1024
+
+// RateLimiter caps the throughput of the copies it is passed to in bytes
+// per second. A single limiter shared by every worker caps their total, so
+// mirroring to a network file system does not saturate the link. It is safe
+// for concurrent use.
+type RateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+
+	// next is when the bytes reserved so far will have been transferred
+	// at the current rate.
+	next time.Time
+}
+
+// NewRateLimiter returns a limiter allowing bytesPerSecond. Zero or less
+// means no limit.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{rate: bytesPerSecond}
+}
+
+// SetRate changes the cap, e.g. to lift it outside business hours. It
+// applies to copies in progress from their next chunk. Zero or less means
+// no limit.
+func (l *RateLimiter) SetRate(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = bytesPerSecond
+}
+
+// Rate returns the cap in bytes per second, zero or less if unlimited.
+func (l *RateLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// wait blocks until n more bytes may be transferred, or ctx is done.
+func (l *RateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	// Reserve n bytes after those reserved before
+	now := time.Now()
+	if earliest := now.Add(-rateBurst); l.next.Before(earliest) {
+		l.next = earliest
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reader returns r limited by l, or r itself if l is nil. Reads fail with
+// the error of ctx once it is done.
+func (l *RateLimiter) reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, limiter: l}
+}
+
+// limitedReader is an io.Reader whose reads wait for a RateLimiter.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *RateLimiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateChunk {
+		p = p[:rateChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
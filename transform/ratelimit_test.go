@@ -0,0 +1,87 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestCopyFileRateLimit tests that a shared limiter caps the total
+// throughput of concurrent copies and can be lifted.
+func TestCopyFileRateLimit(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	data := bytes.Repeat([]byte("x"), 64*1024)
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(testDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+	}
+
+	// 128 KiB at 512 KiB/s take 250ms, less the carried-over burst
+	limiter := NewRateLimiter(512 * 1024)
+	opts := CopyOptions{RateLimit: limiter}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := CopyFile(filepath.Join(testDir, name), filepath.Join(testDir, name+".out"), opts); err != nil {
+				t.Errorf("CopyFile failed: %v", err)
+			}
+		}(name)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("Expected the copies to be throttled, took %v", elapsed)
+	}
+	for _, name := range []string{"a.out", "b.out"} {
+		if out, err := os.ReadFile(filepath.Join(testDir, name)); err != nil || !bytes.Equal(out, data) {
+			t.Errorf("Expected %s to be a complete copy: %v", name, err)
+		}
+	}
+
+	// Lifting the cap lets copies run at full speed
+	limiter.SetRate(0)
+	start = time.Now()
+	if err := CopyFile(filepath.Join(testDir, "a"), filepath.Join(testDir, "c.out"), opts); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected an unthrottled copy, took %v", elapsed)
+	}
+}
+
+// TestCopyFileContextRateLimitCancel tests that a cancelled copy stops
+// waiting for the limiter and leaves no output.
+func TestCopyFileContextRateLimitCancel(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputPath := filepath.Join(testDir, "a")
+	outputPath := filepath.Join(testDir, "a.out")
+	if err := os.WriteFile(inputPath, bytes.Repeat([]byte("x"), 256*1024), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	// 256 KiB at 1 KiB/s would take minutes
+	opts := CopyOptions{RateLimit: NewRateLimiter(1024)}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := CopyFileContext(ctx, inputPath, outputPath, opts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the copy, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the copy to stop promptly, took %v", elapsed)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no output, got %v", err)
+	}
+}
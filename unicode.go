@@ -0,0 +1,46 @@
+package mirrortransform
+
+import (
+	"fmt"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// UnicodeNormalization is a Unicode normalization form relative paths are
+// converted to before matching and mapping to outputs, set with
+// Config.UnicodeNormalization.
+type UnicodeNormalization string
+
+const (
+	// UnicodeNormalizationNone keeps paths as the file system reports them.
+	UnicodeNormalizationNone UnicodeNormalization = ""
+
+	// UnicodeNormalizationNFC composes characters, as most Linux and Windows
+	// tools write names, e.g. "e" followed by a combining acute accent
+	// becomes "é".
+	UnicodeNormalizationNFC UnicodeNormalization = "nfc"
+
+	// UnicodeNormalizationNFD decomposes characters, as HFS+ on macOS stores
+	// names.
+	UnicodeNormalizationNFD UnicodeNormalization = "nfd"
+)
+
+// validateUnicodeNormalization checks the normalization form.
+func validateUnicodeNormalization(form UnicodeNormalization) error {
+	switch form {
+	case UnicodeNormalizationNone, UnicodeNormalizationNFC, UnicodeNormalizationNFD:
+		return nil
+	}
+	return fmt.Errorf("unknown unicode normalization %q: use %q or %q", form, UnicodeNormalizationNFC, UnicodeNormalizationNFD)
+}
+
+// normalizeUnicode returns relPath in the form of UnicodeNormalization.
+func (mt *mirrorTransform) normalizeUnicode(relPath string) string {
+	switch mt.config.UnicodeNormalization {
+	case UnicodeNormalizationNFC:
+		return norm.NFC.String(relPath)
+	case UnicodeNormalizationNFD:
+		return norm.NFD.String(relPath)
+	}
+	return relPath
+}
@@ -0,0 +1,74 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUnicodeNormalization tests that names in NFD match NFC patterns and
+// map to NFC outputs while inputs are read by their names on disk.
+func TestUnicodeNormalization(t *testing.T) {
+	t.Parallel()
+	nfd := "cafe\u0301"
+	nfc := "caf\u00e9"
+
+	tests := []struct {
+		name     string
+		form     UnicodeNormalization
+		expected []string
+	}{
+		{"None", UnicodeNormalizationNone, nil},
+		{"NFC", UnicodeNormalizationNFC, []string{nfc + "/" + nfc + ".jpg"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := t.TempDir()
+			inputDir := filepath.Join(testDir, "input")
+			outputDir := filepath.Join(testDir, "output")
+			createTestFiles(t, inputDir, []string{nfd + "/" + nfd + ".jpg"})
+
+			config := Config{
+				InputDir:             inputDir,
+				OutputDir:            outputDir,
+				Patterns:             []string{nfc + "/*.jpg"},
+				UnicodeNormalization: tt.form,
+				FileCallback: func(inputPath, outputPath string) (bool, error) {
+					if _, err := os.Stat(inputPath); err != nil {
+						t.Errorf("Expected the input to be read by its name on disk: %v", err)
+					}
+					return true, os.WriteFile(outputPath, []byte("out"), 0644)
+				},
+			}
+
+			mt, err := NewMirrorTransform(&config)
+			if err != nil {
+				t.Fatalf("Failed to create MirrorTransform: %v", err)
+			}
+			if err := mt.Crawl(context.Background()); err != nil {
+				t.Fatalf("Crawl failed: %v", err)
+			}
+
+			var outputs []string
+			filepath.WalkDir(outputDir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(outputDir, path)
+					outputs = append(outputs, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			if strings.Join(outputs, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected outputs %q, got %q", tt.expected, outputs)
+			}
+		})
+	}
+
+	config := Config{InputDir: "input", OutputDir: "output", Patterns: []string{"*"}, UnicodeNormalization: "nfkc"}
+	if errs := config.validateSettings(); len(errs) == 0 {
+		t.Error("Expected an unknown normalization form to be rejected")
+	}
+}
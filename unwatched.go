@@ -0,0 +1,110 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UnwatchedDirError is passed to ErrorCallback when Watch or Run cannot
+// watch a directory below the watched root, e.g. for lack of permission or
+// a transient network file system error. The rest of the tree is watched;
+// changes inside the directory are missed until it is watched again, e.g.
+// after it is recreated. Use errors.As to retrieve it.
+type UnwatchedDirError struct {
+	// Path is the directory that could not be watched.
+	Path string
+
+	// Err is the error returned by the watcher.
+	Err error
+}
+
+func (e *UnwatchedDirError) Error() string {
+	return fmt.Sprintf("failed to add watch for %q: %v", e.Path, e.Err)
+}
+
+func (e *UnwatchedDirError) Unwrap() error {
+	return e.Err
+}
+
+// unwatchedDirs remembers the directories that could not be watched.
+type unwatchedDirs struct {
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+// newUnwatchedDirs returns an empty set.
+func newUnwatchedDirs() *unwatchedDirs {
+	return &unwatchedDirs{paths: make(map[string]bool)}
+}
+
+// add remembers path. It is a no-op on a nil set.
+func (u *unwatchedDirs) add(path string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.paths[path] = true
+}
+
+// forget drops path and the directories below it, once it is watched or
+// gone. It is a no-op on a nil set.
+func (u *unwatchedDirs) forget(path string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for p := range u.paths {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(u.paths, p)
+		}
+	}
+}
+
+// list returns the remembered paths, sorted.
+func (u *unwatchedDirs) list() []string {
+	if u == nil {
+		return nil
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	paths := make([]string, 0, len(u.paths))
+	for p := range u.paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// UnwatchedDirs returns the directories Watch and Run failed to watch and
+// have not watched since, sorted.
+func (mt *mirrorTransform) UnwatchedDirs() []string {
+	return mt.unwatched.list()
+}
+
+// reportUnwatchedDir records that the directory at path could not be
+// watched and passes the failure to ErrorCallback, which can stop the run.
+// Without ErrorCallback, or for paths matching IgnoreErrorPatterns, the
+// failure is logged and the run continues.
+func (mt *mirrorTransform) reportUnwatchedDir(path string, err error) error {
+	unwatchedErr := &UnwatchedDirError{Path: path, Err: err}
+	mt.unwatched.add(path)
+	mt.log().Warn("failed to watch directory", "dir", path, "error", err)
+	if mt.config.ErrorCallback == nil || mt.isIgnoredErrorPath(path) {
+		return nil
+	}
+
+	stop, retErr := mt.callErrorCallback(path, unwatchedErr)
+	if retErr != nil {
+		return fmt.Errorf("error callback failed at %q: %w", path, retErr)
+	}
+	if stop {
+		return markError(ErrStoppedByCallback, fmt.Errorf("stopped due to error at %q: %w", path, unwatchedErr))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package mirrortransform
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// failingFakeWatcher is a fileWatcher that fails to add the paths in fail.
+type failingFakeWatcher struct {
+	mu     sync.Mutex
+	fail   map[string]bool
+	added  []string
+	events chan fsnotify.Event
+}
+
+func (w *failingFakeWatcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fail[path] {
+		return errors.New("permission denied")
+	}
+	w.added = append(w.added, path)
+	return nil
+}
+func (w *failingFakeWatcher) Close() error                  { return nil }
+func (w *failingFakeWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *failingFakeWatcher) Errors() <-chan error          { return nil }
+func (w *failingFakeWatcher) Recursive() bool               { return false }
+
+// TestUnwatchedDirs tests that a directory that cannot be watched is
+// reported and skipped while the rest of the tree is watched.
+func TestUnwatchedDirs(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a/1.jpg", "b/deep/2.jpg", "c/3.jpg"})
+
+	var reported []error
+	mt := &mirrorTransform{
+		config: Config{
+			InputDir:  inputDir,
+			OutputDir: filepath.Join(testDir, "output"),
+			ErrorCallback: func(path string, err error) (bool, error) {
+				reported = append(reported, err)
+				return false, nil
+			},
+		},
+		unwatched: newUnwatchedDirs(),
+	}
+	watcher := &failingFakeWatcher{fail: map[string]bool{filepath.Join(inputDir, "b"): true}}
+
+	if err := mt.addWatchDirs(watcher, inputDir, nil); err != nil {
+		t.Fatalf("Expected the walk to continue, got %v", err)
+	}
+	want := []string{inputDir, filepath.Join(inputDir, "a"), filepath.Join(inputDir, "c")}
+	if len(watcher.added) != len(want) {
+		t.Fatalf("Expected watches for %v, got %v", want, watcher.added)
+	}
+	for i := range want {
+		if watcher.added[i] != want[i] {
+			t.Errorf("Expected watch %d to be %s, got %s", i, want[i], watcher.added[i])
+		}
+	}
+
+	var unwatchedErr *UnwatchedDirError
+	if len(reported) != 1 || !errors.As(reported[0], &unwatchedErr) || unwatchedErr.Path != filepath.Join(inputDir, "b") {
+		t.Errorf("Expected one *UnwatchedDirError for b, got %v", reported)
+	}
+	if dirs := mt.UnwatchedDirs(); len(dirs) != 1 || dirs[0] != filepath.Join(inputDir, "b") {
+		t.Errorf("Expected b to be unwatched, got %v", dirs)
+	}
+
+	// Watching the directory later clears it
+	delete(watcher.fail, filepath.Join(inputDir, "b"))
+	if err := mt.addWatchDirs(watcher, filepath.Join(inputDir, "b"), nil); err != nil {
+		t.Fatalf("Failed to add watch: %v", err)
+	}
+	if dirs := mt.UnwatchedDirs(); len(dirs) != 0 {
+		t.Errorf("Expected no unwatched directories, got %v", dirs)
+	}
+
+	// The root must be watched
+	watcher.fail[inputDir] = true
+	if err := mt.addWatchDirs(watcher, inputDir, nil); err == nil {
+		t.Error("Expected an error when the root cannot be watched")
+	}
+}
+
+// TestUnwatchedDirsStop tests that ErrorCallback can stop the walk at a
+// directory that cannot be watched.
+func TestUnwatchedDirsStop(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a/1.jpg"})
+
+	mt := &mirrorTransform{config: Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		ErrorCallback: func(path string, err error) (bool, error) {
+			return true, nil
+		},
+	}}
+	watcher := &failingFakeWatcher{fail: map[string]bool{filepath.Join(inputDir, "a"): true}}
+
+	err := mt.addWatchDirs(watcher, inputDir, nil)
+	var unwatchedErr *UnwatchedDirError
+	if !errors.As(err, &unwatchedErr) {
+		t.Errorf("Expected an *UnwatchedDirError, got %v", err)
+	}
+}
+
+// TestUnwatchedDirsIgnoreErrorPatterns tests that a directory matching
+// IgnoreErrorPatterns that cannot be watched does not reach ErrorCallback.
+func TestUnwatchedDirsIgnoreErrorPatterns(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"lost+found/1.jpg"})
+
+	var called bool
+	mt := &mirrorTransform{
+		config: Config{
+			InputDir:            inputDir,
+			OutputDir:           filepath.Join(testDir, "output"),
+			IgnoreErrorPatterns: []string{"lost+found"},
+			ErrorCallback: func(path string, err error) (bool, error) {
+				called = true
+				return true, nil
+			},
+		},
+		unwatched: newUnwatchedDirs(),
+	}
+	watcher := &failingFakeWatcher{fail: map[string]bool{filepath.Join(inputDir, "lost+found"): true}}
+
+	if err := mt.addWatchDirs(watcher, inputDir, nil); err != nil {
+		t.Errorf("Expected the failure to be ignored, got %v", err)
+	}
+	if called {
+		t.Error("Expected ErrorCallback not to be called")
+	}
+	if dirs := mt.UnwatchedDirs(); len(dirs) != 1 {
+		t.Errorf("Expected lost+found to be listed as unwatched, got %v", dirs)
+	}
+}
@@ -0,0 +1,373 @@
+package mirrortransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ConfigUpdate lists the settings UpdateConfig changes. Nil fields are left
+// unchanged.
+type ConfigUpdate struct {
+	// Patterns replaces Config.Patterns. It must not be empty if non-nil.
+	Patterns []string
+
+	// ExcludePatterns replaces Config.ExcludePatterns. A non-nil empty
+	// slice removes every exclusion.
+	ExcludePatterns []string
+
+	// Concurrency replaces Config.Concurrency.
+	Concurrency *int
+
+	// MaxConcurrency replaces Config.MaxConcurrency.
+	MaxConcurrency *int
+}
+
+// runtimeSettings are the settings UpdateConfig can change while runs are
+// in progress. They are replaced as a whole and never modified.
+type runtimeSettings struct {
+	patterns        []string
+	globs           *GlobMatcher
+	excludePatterns []string
+	concurrency     int
+	maxConcurrency  int
+}
+
+// newRuntimeSettings returns the initial runtime settings of config.
+func newRuntimeSettings(config *Config) *runtimeSettings {
+	return &runtimeSettings{
+		patterns:        config.Patterns,
+		globs:           newGlobMatcher(config.Patterns, config.CaseInsensitivePatterns),
+		excludePatterns: config.ExcludePatterns,
+		concurrency:     config.Concurrency,
+		maxConcurrency:  config.MaxConcurrency,
+	}
+}
+
+// currentSettings returns the runtime settings, which are those of
+// the Config until UpdateConfig is called.
+func (mt *mirrorTransform) currentSettings() *runtimeSettings {
+	if settings := mt.settings.Load(); settings != nil {
+		return settings
+	}
+	return newRuntimeSettings(&mt.config)
+}
+
+// validate checks an update before it is applied.
+func (u ConfigUpdate) validate() error {
+	var errs []error
+	if u.Patterns != nil && len(u.Patterns) == 0 {
+		errs = append(errs, fmt.Errorf("at least one pattern is required"))
+	}
+	for _, pattern := range u.Patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid pattern %q", pattern)))
+		}
+	}
+	for _, pattern := range u.ExcludePatterns {
+		if !doublestar.ValidatePattern(pattern) {
+			errs = append(errs, markError(ErrInvalidPattern, fmt.Errorf("invalid exclude pattern %q", pattern)))
+		}
+	}
+	if u.Concurrency != nil && *u.Concurrency < 0 && *u.Concurrency != AutoConcurrency {
+		errs = append(errs, fmt.Errorf("concurrency must not be negative, got %d", *u.Concurrency))
+	}
+	if u.MaxConcurrency != nil && *u.MaxConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("max concurrency must not be negative, got %d", *u.MaxConcurrency))
+	}
+	return errors.Join(errs...)
+}
+
+// UpdateConfig changes patterns, exclusions and concurrency while runs are
+// in progress, without restarting them.
+func (mt *mirrorTransform) UpdateConfig(update ConfigUpdate) error {
+	return mt.applyUpdate(update, false)
+}
+
+// settingsChange is sent to running watchers when the settings change.
+type settingsChange struct {
+	// previous are the settings replaced by the change.
+	previous *runtimeSettings
+
+	// reconcile asks the watcher to process the existing files that the
+	// change newly includes.
+	reconcile bool
+}
+
+// applyUpdate validates and applies update, then notifies running watchers,
+// asking them to process newly included files if reconcile is set.
+func (mt *mirrorTransform) applyUpdate(update ConfigUpdate, reconcile bool) error {
+	if err := update.validate(); err != nil {
+		return err
+	}
+
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+
+	previous := mt.currentSettings()
+	next := *previous
+	if update.Patterns != nil {
+		next.patterns = append([]string(nil), update.Patterns...)
+		next.globs = newGlobMatcher(next.patterns, mt.config.CaseInsensitivePatterns)
+	}
+	if update.ExcludePatterns != nil {
+		next.excludePatterns = append([]string(nil), update.ExcludePatterns...)
+	}
+	if update.Concurrency != nil {
+		next.concurrency = *update.Concurrency
+	}
+	if update.MaxConcurrency != nil {
+		next.maxConcurrency = *update.MaxConcurrency
+	}
+	mt.settings.Store(&next)
+	mt.log().Info("configuration updated", "patterns", next.patterns, "exclude_patterns", next.excludePatterns, "concurrency", mt.concurrency())
+
+	// Resize the worker pools of running runs; automatic pools keep their
+	// size within the new bounds
+	concurrency := mt.concurrency()
+	low, high := mt.concurrencyBounds()
+	for pool := range mt.pools {
+		if mt.autoConcurrency() {
+			pool.startTuning()
+			pool.resize(min(max(pool.size(), low), high))
+			continue
+		}
+		pool.resize(concurrency)
+	}
+
+	// Let running watchers pick up directories that are no longer excluded
+	if update.ExcludePatterns != nil || reconcile {
+		change := &settingsChange{previous: previous, reconcile: reconcile}
+		for updates := range mt.updateSubs {
+			select {
+			case updates <- change:
+				continue
+			default:
+			}
+
+			// A pending change was made against older settings, which
+			// covers this one too, but may not ask for reconciliation
+			merged := change
+			select {
+			case pending := <-updates:
+				merged = &settingsChange{previous: pending.previous, reconcile: pending.reconcile || reconcile}
+			default:
+			}
+			updates <- merged
+		}
+	}
+	return nil
+}
+
+// subscribeUpdates returns a channel that receives the changes made by each
+// UpdateConfig that changes exclusions, and by each Reconfigure.
+func (mt *mirrorTransform) subscribeUpdates() chan *settingsChange {
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+	updates := make(chan *settingsChange, 1)
+	mt.updateSubs[updates] = struct{}{}
+	return updates
+}
+
+// unsubscribeUpdates stops sending updates to the channel.
+func (mt *mirrorTransform) unsubscribeUpdates(updates chan *settingsChange) {
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+	delete(mt.updateSubs, updates)
+}
+
+// watchIncludedDirs adds to the watcher the directories below root that were
+// excluded under previous but are not anymore. Newly excluded directories
+// stay watched; their events are filtered instead. run is passed on to
+// addWatchDirs.
+func (mt *mirrorTransform) watchIncludedDirs(watcher fileWatcher, root string, previous *runtimeSettings, run *runState) error {
+	if watcher.Recursive() {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return mt.handlePathError(path, err, "access")
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		relPath, err := mt.relPath(path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path for %q: %w", path, err)
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		// Directories skipped now are skipped with everything below them
+		if !mt.config.IncludeHidden && isHidden(path, relPath) {
+			return filepath.SkipDir
+		}
+		if mt.beyondMaxDepth(relPath, true) {
+			return filepath.SkipDir
+		}
+		ignored, err := mt.isIgnored(relPath, true)
+		if err != nil {
+			return err
+		}
+		if ignored {
+			return filepath.SkipDir
+		}
+		excluded, err := mt.isExcluded(relPath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			return filepath.SkipDir
+		}
+
+		// Directories that were not excluded are watched already
+		wasExcluded, err := mt.excludedIn(previous, relPath)
+		if err != nil {
+			return err
+		}
+		if !wasExcluded {
+			return nil
+		}
+		if err := mt.addWatchDirs(watcher, path, run); err != nil {
+			return err
+		}
+		return filepath.SkipDir
+	})
+}
+
+// workerPool tracks the file processors of a run so UpdateConfig can change
+// their number.
+type workerPool struct {
+	start func()
+	wg    *sync.WaitGroup
+	owner *mirrorTransform
+
+	// tune starts resizing the pool for AutoConcurrency, once.
+	tune     func()
+	tuneOnce sync.Once
+
+	mu      sync.Mutex
+	running int
+	excess  int
+	changed chan struct{}
+}
+
+// startWorkers starts the file processors of a run and registers them for
+// resizing. Only the shared processors are resized; those of concurrency
+// groups keep their size.
+func (mt *mirrorTransform) startWorkers(ctx context.Context, run *runState, taskChan <-chan fileTask, errChan chan<- error, wg *sync.WaitGroup) {
+	// Files of concurrency groups go to workers of their own
+	if len(mt.config.ConcurrencyGroups) > 0 {
+		taskChan = mt.startGroupWorkers(ctx, run, taskChan, errChan, wg)
+	}
+
+	pool := &workerPool{wg: wg, owner: mt, changed: make(chan struct{})}
+	pool.start = func() {
+		wg.Add(1)
+		go mt.fileProcessor(ctx, run, taskChan, errChan, pool)
+	}
+
+	mt.updateMu.Lock()
+	defer mt.updateMu.Unlock()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	for i := mt.concurrency(); i > 0; i-- {
+		pool.running++
+		pool.start()
+	}
+	mt.pools[pool] = struct{}{}
+
+	// Automatic pools are resized as long as the run goes on
+	pool.tune = func() { go mt.tuneConcurrency(ctx, run, pool) }
+	if mt.autoConcurrency() {
+		pool.startTuning()
+	}
+}
+
+// startTuning starts resizing the pool for AutoConcurrency unless it does
+// already. Pools of concurrency groups keep their size.
+func (p *workerPool) startTuning() {
+	if p.tune != nil {
+		p.tuneOnce.Do(p.tune)
+	}
+}
+
+// resize starts or retires workers to reach n. Workers retire once idle or
+// after their current task.
+func (p *workerPool) resize(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A pool whose workers have all exited belongs to a finished run
+	if p.running == 0 {
+		return
+	}
+
+	target := p.running - p.excess
+	for ; target < n; target++ {
+		if p.excess > 0 {
+			p.excess--
+			continue
+		}
+		p.running++
+		p.start()
+	}
+	if target > n {
+		p.excess += target - n
+		close(p.changed)
+		p.changed = make(chan struct{})
+	}
+}
+
+// size returns the number of workers the pool is heading for, zero once
+// they have all exited.
+func (p *workerPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running == 0 {
+		return 0
+	}
+	return p.running - p.excess
+}
+
+// resized returns a channel closed when workers are asked to retire.
+func (p *workerPool) resized() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.changed
+}
+
+// retire reports whether the calling worker should exit to shrink the pool.
+func (p *workerPool) retire() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.excess == 0 {
+		return false
+	}
+	p.excess--
+	return true
+}
+
+// done records that a worker exited.
+func (p *workerPool) done() {
+	p.mu.Lock()
+	p.running--
+	last := p.running == 0
+	p.mu.Unlock()
+
+	if last {
+		p.owner.updateMu.Lock()
+		delete(p.owner.pools, p)
+		p.owner.updateMu.Unlock()
+	}
+	p.wg.Done()
+}
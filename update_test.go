@@ -0,0 +1,198 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestUpdateConfigWatch tests that a running Watch follows updated patterns
+// and exclusions, including directories that were not watched before.
+func TestUpdateConfigWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	for _, dir := range []string{"skip/deep", "other"} {
+		if err := os.MkdirAll(filepath.Join(inputDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	processed := make(map[string]bool)
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"skip"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)] = true
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx)
+	}()
+
+	// Give watcher time to start
+	time.Sleep(200 * time.Millisecond)
+
+	err = mt.UpdateConfig(ConfigUpdate{
+		Patterns:        []string{"**/*.jpg", "**/*.png"},
+		ExcludePatterns: []string{"other"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+
+	// Give watcher time to add the included directories
+	time.Sleep(200 * time.Millisecond)
+
+	createTestFiles(t, inputDir, []string{"a.png", "skip/deep/b.jpg", "other/c.jpg"})
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !processed["a.png"] {
+		t.Errorf("Expected a.png to match the new pattern, got %v", processed)
+	}
+	if !processed["skip/deep/b.jpg"] {
+		t.Errorf("Expected skip/deep/b.jpg to be watched once skip is no longer excluded, got %v", processed)
+	}
+	if processed["other/c.jpg"] {
+		t.Errorf("Expected other/c.jpg to be excluded, got %v", processed)
+	}
+}
+
+// TestUpdateConfigConcurrency tests that a running crawl grows and shrinks
+// its worker pool.
+func TestUpdateConfigConcurrency(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	var files []string
+	for i := 0; i < 40; i++ {
+		files = append(files, filepath.Join("dir", string(rune('a'+i%26))+string(rune('a'+i/26))+".jpg"))
+	}
+	createTestFiles(t, inputDir, files)
+
+	var inFlight, peak atomic.Int32
+	release := make(chan struct{})
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      outputDir,
+		Patterns:       []string{"**/*.jpg"},
+		MaxConcurrency: 1,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				current := peak.Load()
+				if n <= current || peak.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			<-release
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	crawlErr := make(chan error, 1)
+	go func() {
+		crawlErr <- mt.Crawl(context.Background())
+	}()
+
+	// Grow to three workers
+	time.Sleep(50 * time.Millisecond)
+	three := 3
+	if err := mt.UpdateConfig(ConfigUpdate{MaxConcurrency: &three}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := inFlight.Load(); got != 3 {
+		t.Errorf("Expected 3 callbacks in flight after growing, got %d", got)
+	}
+
+	// Shrink back to one worker
+	one := 1
+	if err := mt.UpdateConfig(ConfigUpdate{MaxConcurrency: &one}); err != nil {
+		t.Fatalf("UpdateConfig failed: %v", err)
+	}
+	peak.Store(0)
+	for i := 0; i < 3; i++ {
+		release <- struct{}{}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := inFlight.Load(); got != 1 {
+		t.Errorf("Expected 1 callback in flight after shrinking, got %d", got)
+	}
+
+	close(release)
+	if err := <-crawlErr; err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+	if got := peak.Load(); got > 1 {
+		t.Errorf("Expected at most 1 callback in flight after shrinking, got %d", got)
+	}
+}
+
+// TestUpdateConfigValidation tests that invalid updates are rejected.
+func TestUpdateConfigValidation(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+
+	config := Config{
+		InputDir:     filepath.Join(testDir, "input"),
+		OutputDir:    filepath.Join(testDir, "output"),
+		Patterns:     []string{"**/*.jpg"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) { return true, nil },
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	negative := -2
+	updates := []ConfigUpdate{
+		{Patterns: []string{}},
+		{Patterns: []string{"[invalid"}},
+		{ExcludePatterns: []string{"[invalid"}},
+		{Concurrency: &negative},
+	}
+	for _, update := range updates {
+		if err := mt.UpdateConfig(update); err == nil {
+			t.Errorf("Expected an error for %+v", update)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package mirrortransform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Warning describes a suspicious but valid configuration.
+type Warning struct {
+	// Field is the Config field the warning refers to.
+	Field string
+
+	// Message explains the problem.
+	Message string
+}
+
+// String returns the warning as "Field: Message".
+func (w Warning) String() string {
+	return w.Field + ": " + w.Message
+}
+
+// ValidateConfig checks config for setups that are accepted by
+// NewMirrorTransform but are unlikely to do what was intended, such as
+// patterns that can never match or excludes that shadow every include.
+// Rate limits are set in callbacks, e.g. CopyOptions.RateLimit, so whether
+// the output shares a device with a rate-limited input is not checked.
+// It does not modify config.
+func ValidateConfig(config *Config) []Warning {
+	var warnings []Warning
+	warn := func(field, format string, args ...any) {
+		warnings = append(warnings, Warning{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Directories
+	if config.InputDir != "" {
+		info, err := os.Stat(config.InputDir)
+		switch {
+		case err != nil:
+			warn("InputDir", "%q cannot be accessed: %v", config.InputDir, err)
+		case !info.IsDir():
+			warn("InputDir", "%q is not a directory", config.InputDir)
+		}
+	}
+	if config.InputDir != "" && config.OutputDir != "" {
+		if overlap := overlappingDirs(config.InputDir, config.OutputDir); overlap != "" {
+			warn("OutputDir", "%s; Crawl and Watch will refuse to run", overlap)
+		}
+	}
+
+	// Patterns
+	shadowed := 0
+	for _, pattern := range config.Patterns {
+		if reason := unmatchablePattern(pattern, config.IncludeHidden); reason != "" {
+			warn("Patterns", "%q can never match: %s", pattern, reason)
+			continue
+		}
+		for _, exclude := range config.ExcludePatterns {
+			if excludeShadows(exclude, pattern) {
+				warn("ExcludePatterns", "%q excludes every file matched by pattern %q", exclude, pattern)
+				shadowed++
+				break
+			}
+		}
+	}
+	if len(config.Patterns) > 0 && shadowed == len(config.Patterns) {
+		warn("ExcludePatterns", "exclude patterns shadow all include patterns; no file will ever be processed")
+	}
+	for _, pattern := range config.ExcludePatterns {
+		if reason := unmatchablePattern(pattern, true); reason != "" {
+			warn("ExcludePatterns", "%q can never match: %s", pattern, reason)
+		}
+	}
+
+	// Concurrency
+	if config.MaxConcurrency > 0 && config.Concurrency > config.MaxConcurrency {
+		warn("Concurrency", "%d exceeds MaxConcurrency %d and will be capped", config.Concurrency, config.MaxConcurrency)
+	}
+
+	// Ignore files
+	if config.IgnoreFile != "" && !config.NestedIgnoreFiles && config.InputDir != "" {
+		if _, err := os.Stat(filepath.Join(config.InputDir, config.IgnoreFile)); os.IsNotExist(err) {
+			warn("IgnoreFile", "%q does not exist in InputDir and NestedIgnoreFiles is disabled", config.IgnoreFile)
+		}
+	}
+
+	// State
+	if (config.FailureBackoff != nil || config.RetryQueue) && config.StateStore == nil {
+		warn("StateStore", "failure history is kept in memory and lost when the process restarts")
+	}
+
+	return warnings
+}
+
+// ValidateConfigs validates each config like ValidateConfig and additionally
+// reports instances whose directories overlap, which makes them process the
+// same files or feed each other's outputs back as inputs.
+func ValidateConfigs(configs ...*Config) []Warning {
+	var warnings []Warning
+	for i, config := range configs {
+		for _, w := range ValidateConfig(config) {
+			w.Field = fmt.Sprintf("[%d].%s", i, w.Field)
+			warnings = append(warnings, w)
+		}
+	}
+
+	for i := 0; i < len(configs); i++ {
+		for j := i + 1; j < len(configs); j++ {
+			a, b := configs[i], configs[j]
+			pairs := []struct {
+				field string
+				dirA  string
+				dirB  string
+			}{
+				{field: "InputDir", dirA: a.InputDir, dirB: b.InputDir},
+				{field: "OutputDir", dirA: a.OutputDir, dirB: b.OutputDir},
+				{field: "OutputDir", dirA: a.OutputDir, dirB: b.InputDir},
+				{field: "InputDir", dirA: a.InputDir, dirB: b.OutputDir},
+			}
+			for _, pair := range pairs {
+				if pair.dirA == "" || pair.dirB == "" {
+					continue
+				}
+				if overlap := overlappingDirs(pair.dirA, pair.dirB); overlap != "" {
+					warnings = append(warnings, Warning{
+						Field:   fmt.Sprintf("[%d].%s", i, pair.field),
+						Message: fmt.Sprintf("overlaps with config [%d]: %s", j, overlap),
+					})
+				}
+			}
+		}
+	}
+	return warnings
+}
+
+// unmatchablePattern returns why pattern can never match a relative path, or "".
+func unmatchablePattern(pattern string, includeHidden bool) string {
+	switch {
+	case !doublestar.ValidatePattern(pattern):
+		return "invalid glob syntax"
+	case strings.HasPrefix(pattern, "/") || filepath.IsAbs(pattern):
+		return "patterns are matched against paths relative to InputDir"
+	case strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../"):
+		return "relative paths never start with ./ or ../"
+	case strings.HasSuffix(pattern, "/"):
+		return "a trailing / never matches a file"
+	}
+
+	if !includeHidden {
+		for _, part := range strings.Split(pattern, "/") {
+			if strings.HasPrefix(part, ".") {
+				return "hidden files are skipped unless IncludeHidden is set"
+			}
+		}
+	}
+	return ""
+}
+
+// excludeShadows reports whether exclude certainly matches everything pattern matches.
+// It recognizes identical patterns, catch-all excludes, and excluded directories
+// that contain the pattern's literal leading directories.
+func excludeShadows(exclude, pattern string) bool {
+	if exclude == pattern || exclude == "**" || exclude == "**/*" {
+		return true
+	}
+
+	// "dir/**" shadows any pattern rooted inside dir
+	dir, ok := strings.CutSuffix(exclude, "/**")
+	if !ok {
+		return false
+	}
+	literal := literalDirs(pattern)
+	for i := range literal {
+		if match, _ := doublestar.Match(dir, strings.Join(literal[:i+1], "/")); match {
+			return true
+		}
+	}
+	return false
+}
+
+// literalDirs returns the leading directory segments of pattern that contain
+// no glob metacharacters, e.g. ["images", "raw"] for "images/raw/**/*.jpg".
+func literalDirs(pattern string) []string {
+	parts := strings.Split(pattern, "/")
+	var literal []string
+	for _, part := range parts[:len(parts)-1] {
+		if strings.ContainsAny(part, `*?[{\`) {
+			break
+		}
+		literal = append(literal, part)
+	}
+	return literal
+}
+
+// overlappingDirs describes how dirs a and b overlap, or returns "".
+func overlappingDirs(a, b string) string {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return ""
+	}
+
+	switch {
+	case absA == absB:
+		return fmt.Sprintf("%q and %q are the same directory", a, b)
+	case strings.HasPrefix(absB, absA+string(filepath.Separator)):
+		return fmt.Sprintf("%q is inside %q", b, a)
+	case strings.HasPrefix(absA, absB+string(filepath.Separator)):
+		return fmt.Sprintf("%q is inside %q", a, b)
+	}
+	return ""
+}
@@ -0,0 +1,129 @@
+package mirrortransform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestValidateConfig tests detection of suspicious configurations.
+func TestValidateConfig(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	if err := os.MkdirAll(inputDir, 0755); err != nil {
+		t.Fatalf("Failed to create input directory: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{
+			name: "Clean",
+			config: Config{
+				InputDir:        inputDir,
+				OutputDir:       filepath.Join(testDir, "output"),
+				Patterns:        []string{"**/*.jpg"},
+				ExcludePatterns: []string{"cache/**"},
+			},
+			want: nil,
+		},
+		{
+			name: "UnmatchablePatterns",
+			config: Config{
+				InputDir:  inputDir,
+				OutputDir: filepath.Join(testDir, "output"),
+				Patterns:  []string{"/abs/*.jpg", "./rel/*.jpg", "images/", ".well-known/*.json", "[", "**/*.png"},
+			},
+			want: []string{
+				`Patterns: "/abs/*.jpg" can never match`,
+				`Patterns: "./rel/*.jpg" can never match`,
+				`Patterns: "images/" can never match`,
+				`Patterns: ".well-known/*.json" can never match`,
+				`Patterns: "[" can never match`,
+			},
+		},
+		{
+			name: "ShadowedIncludes",
+			config: Config{
+				InputDir:        inputDir,
+				OutputDir:       filepath.Join(testDir, "output"),
+				Patterns:        []string{"images/raw/**/*.jpg", "*.png"},
+				ExcludePatterns: []string{"images/**", "*.png"},
+			},
+			want: []string{
+				`ExcludePatterns: "images/**" excludes every file matched by pattern "images/raw/**/*.jpg"`,
+				`ExcludePatterns: "*.png" excludes every file matched by pattern "*.png"`,
+				"ExcludePatterns: exclude patterns shadow all include patterns",
+			},
+		},
+		{
+			name: "DirectoriesAndConcurrency",
+			config: Config{
+				InputDir:       filepath.Join(testDir, "missing"),
+				OutputDir:      filepath.Join(testDir, "missing", "output"),
+				Patterns:       []string{"**/*.jpg"},
+				Concurrency:    8,
+				MaxConcurrency: 2,
+				IgnoreFile:     DefaultIgnoreFile,
+				FailureBackoff: &FailureBackoff{},
+			},
+			want: []string{
+				"InputDir: ",
+				"OutputDir: ",
+				"Concurrency: 8 exceeds MaxConcurrency 2",
+				"IgnoreFile: ",
+				"StateStore: ",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			warnings := ValidateConfig(&tt.config)
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("Expected %d warnings, got %d: %v", len(tt.want), len(warnings), warnings)
+			}
+			for i, w := range warnings {
+				if !strings.HasPrefix(w.String(), tt.want[i]) {
+					t.Errorf("Warning %d: expected prefix %q, got %q", i, tt.want[i], w.String())
+				}
+			}
+		})
+	}
+}
+
+// TestValidateConfigsOverlap tests detection of overlapping instances.
+func TestValidateConfigsOverlap(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(testDir, dir), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	first := &Config{
+		InputDir:  filepath.Join(testDir, "a"),
+		OutputDir: filepath.Join(testDir, "out-a"),
+		Patterns:  []string{"**/*.jpg"},
+	}
+	second := &Config{
+		InputDir:  filepath.Join(testDir, "b"),
+		OutputDir: filepath.Join(testDir, "a", "derived"),
+		Patterns:  []string{"**/*.jpg"},
+	}
+
+	warnings := ValidateConfigs(first, second)
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.HasPrefix(warnings[0].String(), "[0].InputDir: overlaps with config [1]") {
+		t.Errorf("Unexpected warning %q", warnings[0].String())
+	}
+}
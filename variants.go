@@ -0,0 +1,120 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Variant describes one of several outputs produced from each input file.
+type Variant struct {
+	// Name identifies the variant (e.g. "thumb" or "webp").
+	Name string `json:"name" yaml:"name"`
+
+	// Dir is the output root of the variant.
+	// Defaults to OutputDir/Name if not set.
+	Dir string `json:"dir,omitempty" yaml:"dir,omitempty"`
+
+	// Ext replaces the extension of the output file name (e.g. ".webp").
+	// The input extension is kept if not set.
+	Ext string `json:"ext,omitempty" yaml:"ext,omitempty"`
+}
+
+// VariantCallback is called once per input with the output path of every variant,
+// keyed by Variant.Name. All output directories are guaranteed to exist,
+// unless LazyOutputDirs leaves creating them to the callback.
+// If continueProcessing is false, the crawl will stop.
+type VariantCallback func(inputPath string, outputPaths map[string]string) (continueProcessing bool, err error)
+
+// taskOutput is an output path produced for a task.
+type taskOutput struct {
+	variant string
+	path    string
+}
+
+// validateVariants checks the configured variants.
+func validateVariants(variants []Variant) error {
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		if v.Name == "" {
+			return fmt.Errorf("variant name is required")
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variant name %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+// variantDir returns the output root of v.
+func (mt *mirrorTransform) variantDir(v Variant) string {
+	if v.Dir != "" {
+		return filepath.Clean(v.Dir)
+	}
+	return filepath.Join(mt.config.OutputDir, v.Name)
+}
+
+// taskOutputs returns every output path of the task: the variant outputs when
+// Variants are configured, or the single mirrored output path otherwise.
+func (mt *mirrorTransform) taskOutputs(task fileTask) ([]taskOutput, error) {
+	if len(mt.config.Variants) == 0 {
+		return []taskOutput{{path: task.OutputPath}}, nil
+	}
+
+	relOutput, err := filepath.Rel(mt.config.OutputDir, task.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative output path for %q: %w", task.OutputPath, err)
+	}
+
+	outputs := make([]taskOutput, 0, len(mt.config.Variants))
+	for _, v := range mt.config.Variants {
+		path := filepath.Join(mt.variantDir(v), relOutput)
+		if v.Ext != "" {
+			path = strings.TrimSuffix(path, filepath.Ext(path)) + v.Ext
+		}
+		outputs = append(outputs, taskOutput{variant: v.Name, path: path})
+	}
+	return outputs, nil
+}
+
+// ensureOutputDirs creates the parent directory of every output.
+func ensureOutputDirs(outputs []taskOutput) error {
+	for _, output := range outputs {
+		if err := EnsureOutputDir(output.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCallback invokes the configured callback for the task's outputs.
+// With variants, VariantCallback is called once if set; otherwise
+// TaskCallback or FileCallback is called once per variant until one fails or stops.
+func (mt *mirrorTransform) runCallback(ctx context.Context, task fileTask, outputs []taskOutput) (bool, error) {
+	started := time.Now()
+
+	if len(mt.config.Variants) > 0 && mt.config.VariantCallback != nil {
+		outputPaths := make(map[string]string, len(outputs))
+		for _, output := range outputs {
+			outputPaths[output.variant] = output.path
+		}
+		return mt.config.VariantCallback(task.InputPath, outputPaths)
+	}
+
+	for _, output := range outputs {
+		continueProcessing, err := mt.callFileCallback(ctx, task, output, started)
+		if err != nil {
+			if output.variant != "" {
+				err = fmt.Errorf("variant %q: %w", output.variant, err)
+			}
+			return false, err
+		}
+		if !continueProcessing {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,158 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestCrawlVariantsFileCallback tests that FileCallback is called once per variant.
+func TestCrawlVariantsFileCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+	avifDir := filepath.Join(testDir, "avif")
+
+	createTestFiles(t, inputDir, []string{"dir/photo.jpg"})
+
+	outputs := make(map[string]bool)
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		Variants: []Variant{
+			{Name: "thumb"},
+			{Name: "webp", Ext: ".webp"},
+			{Name: "avif", Dir: avifDir, Ext: ".avif"},
+		},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			mu.Lock()
+			outputs[outputPath] = true
+			mu.Unlock()
+			// Output directory must exist for every variant
+			return true, os.WriteFile(outputPath, []byte("variant"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(outputDir, "thumb", "dir", "photo.jpg"),
+		filepath.Join(outputDir, "webp", "dir", "photo.webp"),
+		filepath.Join(avifDir, "dir", "photo.avif"),
+	}
+	if len(outputs) != len(expected) {
+		t.Errorf("Expected %d outputs, got %v", len(expected), outputs)
+	}
+	for _, path := range expected {
+		if !outputs[path] {
+			t.Errorf("Expected output %s was not produced", path)
+		}
+	}
+
+	// The plain mirrored path is not used
+	if _, err := os.Stat(filepath.Join(outputDir, "dir")); !os.IsNotExist(err) {
+		t.Error("Plain output directory was created alongside variants")
+	}
+}
+
+// TestCrawlVariantCallback tests that VariantCallback receives all variants at once.
+func TestCrawlVariantCallback(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b.jpg"})
+
+	var calls []map[string]string
+	var mu sync.Mutex
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		Variants:  []Variant{{Name: "small"}, {Name: "large"}},
+		VariantCallback: func(inputPath string, outputPaths map[string]string) (bool, error) {
+			mu.Lock()
+			calls = append(calls, outputPaths)
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	if err := mt.Crawl(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 callback calls, got %d", len(calls))
+	}
+	for _, outputPaths := range calls {
+		if len(outputPaths) != 2 || outputPaths["small"] == "" || outputPaths["large"] == "" {
+			t.Errorf("Unexpected variant outputs %v", outputPaths)
+		}
+	}
+}
+
+// TestVariantsValidation tests variant configuration validation.
+func TestVariantsValidation(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	base := Config{
+		InputDir:  filepath.Join(testDir, "input"),
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"*.jpg"},
+		FileCallback: func(in, out string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		variants []Variant
+		wantErr  bool
+	}{
+		{name: "MissingName", variants: []Variant{{Ext: ".webp"}}, wantErr: true},
+		{name: "DuplicateName", variants: []Variant{{Name: "a"}, {Name: "a"}}, wantErr: true},
+		{name: "Valid", variants: []Variant{{Name: "a"}, {Name: "b"}}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		config := base
+		config.Variants = tt.variants
+		_, err := NewMirrorTransform(&config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: NewMirrorTransform() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Variant directories inside the input are circular
+	config := base
+	config.Variants = []Variant{{Name: "inside", Dir: filepath.Join(base.InputDir, "variant")}}
+	createTestFiles(t, base.InputDir, []string{"a.jpg"})
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Errorf("Expected circular reference error for variant dir %s", config.Variants[0].Dir)
+	}
+}
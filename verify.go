@@ -0,0 +1,246 @@
+package mirrortransform
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// VerifyOptions configure Verify.
+type VerifyOptions struct {
+	// Hash, if set, is called for every matching input and each of its
+	// existing outputs. Outputs whose hash differs from that of their input
+	// are reported as mismatched. For a plain copy a content hash will do;
+	// transforms need a hash that survives them, e.g. one read from metadata
+	// embedded in the output.
+	Hash func(path string) (string, error)
+}
+
+// VerifyEntry is an input whose output is missing or differs.
+type VerifyEntry struct {
+	// RelPath is the input path relative to InputDir.
+	RelPath string `json:"relPath"`
+
+	// OutputPath is the full path of the output concerned.
+	OutputPath string `json:"outputPath"`
+}
+
+// VerifyReport lists where the output tree has drifted from the input tree.
+type VerifyReport struct {
+	// Inputs is the number of matching inputs checked.
+	Inputs int `json:"inputs"`
+
+	// Missing lists the outputs of matching inputs that do not exist.
+	Missing []VerifyEntry `json:"missing,omitempty"`
+
+	// Extra lists the full paths of files in the output directories that
+	// no matching input maps to.
+	Extra []string `json:"extra,omitempty"`
+
+	// Mismatched lists the outputs whose hash differs from their input's.
+	// It is only filled when VerifyOptions.Hash is set.
+	Mismatched []VerifyEntry `json:"mismatched,omitempty"`
+}
+
+// OK reports whether the output tree matches the input tree.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Verify walks the input and output trees and reports inputs whose outputs
+// are missing, output files no input maps to and, with opts.Hash, outputs
+// whose content differs from their input. It reads both trees without
+// running callbacks or changing anything.
+func (mt *mirrorTransform) Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	expected := make(map[string]bool)
+	err := mt.eachMatchingInput(ctx, func(task fileTask) error {
+		report.Inputs++
+		return mt.verifyTask(task, opts, report, expected)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Extra, err = mt.extraOutputs(ctx, expected); err != nil {
+		return nil, err
+	}
+
+	sortVerifyEntries(report.Missing)
+	sortVerifyEntries(report.Mismatched)
+	return report, nil
+}
+
+// Orphans returns the sorted paths of the files in the output directories
+// that no matching input maps to, leaving out the library's own files. It
+// is the Extra list of Verify without checking the outputs of the inputs,
+// and deletes nothing.
+func (mt *mirrorTransform) Orphans(ctx context.Context) ([]string, error) {
+	expected := make(map[string]bool)
+	err := mt.eachMatchingInput(ctx, func(task fileTask) error {
+		outputs, err := mt.taskOutputs(task)
+		if err != nil {
+			return err
+		}
+		for _, output := range outputs {
+			expected[output.path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mt.extraOutputs(ctx, expected)
+}
+
+// eachMatchingInput scans the input tree as a crawl would and calls fn for
+// every matching input, stopping at the first error.
+func (mt *mirrorTransform) eachMatchingInput(ctx context.Context, fn func(task fileTask) error) error {
+	scanCtx, cancelScan := context.WithCancel(ctx)
+	defer cancelScan()
+
+	taskChan := make(chan fileTask)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(taskChan)
+		scanErr <- mt.scanDirectory(scanCtx, taskChan, nil)
+	}()
+
+	var err error
+	for task := range taskChan {
+		if err != nil {
+			continue
+		}
+		if err = fn(task); err != nil {
+			cancelScan()
+		}
+	}
+	if scanErr := <-scanErr; err == nil && scanErr != nil {
+		err = fmt.Errorf("failed to scan input directory: %w", scanErr)
+	}
+	return err
+}
+
+// verifyTask checks the outputs of one matching input, adding them to
+// expected and their differences to report.
+func (mt *mirrorTransform) verifyTask(task fileTask, opts VerifyOptions, report *VerifyReport, expected map[string]bool) error {
+	outputs, err := mt.taskOutputs(task)
+	if err != nil {
+		return err
+	}
+
+	var inputHash string
+	hashed := false
+	for _, output := range outputs {
+		expected[output.path] = true
+		entry := VerifyEntry{RelPath: task.RelPath, OutputPath: output.path}
+
+		if _, err := os.Stat(output.path); err != nil {
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("failed to stat output %q: %w", output.path, err)
+			}
+			report.Missing = append(report.Missing, entry)
+			continue
+		}
+		if opts.Hash == nil {
+			continue
+		}
+
+		// Hash the input once for all of its outputs
+		if !hashed {
+			if inputHash, err = opts.Hash(task.InputPath); err != nil {
+				return fmt.Errorf("failed to hash input %q: %w", task.InputPath, err)
+			}
+			hashed = true
+		}
+		outputHash, err := opts.Hash(output.path)
+		if err != nil {
+			return fmt.Errorf("failed to hash output %q: %w", output.path, err)
+		}
+		if outputHash != inputHash {
+			report.Mismatched = append(report.Mismatched, entry)
+		}
+	}
+	return nil
+}
+
+// extraOutputs walks the output directories and returns the sorted paths of
+// the files not in expected, leaving out the library's own files.
+func (mt *mirrorTransform) extraOutputs(ctx context.Context, expected map[string]bool) ([]string, error) {
+	roots := []string{filepath.Clean(mt.config.OutputDir)}
+	for _, v := range mt.config.Variants {
+		if v.Dir != "" {
+			roots = append(roots, mt.variantDir(v))
+		}
+	}
+
+	found := make(map[string]bool)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			// Check context cancellation
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			if err != nil {
+				if os.IsNotExist(err) && path == root {
+					return nil
+				}
+				return fmt.Errorf("failed to scan output directory %q: %w", root, err)
+			}
+			if mt.isOwnOutputFile(path, d) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() && !expected[path] {
+				found[path] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	extra := make([]string, 0, len(found))
+	for path := range found {
+		extra = append(extra, path)
+	}
+	sort.Strings(extra)
+	return extra, nil
+}
+
+// isOwnOutputFile reports whether the file or directory at path in an
+// output directory was written by the library rather than a callback:
+// partial markers, temporary files, the lock and generation files, and the
+// quarantine and self test directories.
+func (mt *mirrorTransform) isOwnOutputFile(path string, d fs.DirEntry) bool {
+	name := d.Name()
+	if d.IsDir() {
+		return (mt.config.QuarantineDir != "" && path == filepath.Clean(mt.config.QuarantineDir)) ||
+			strings.HasPrefix(name, ".mirror-selftest-")
+	}
+	if strings.HasPrefix(name, tempFilePrefix) || strings.HasSuffix(name, PartialMarkerSuffix) {
+		return true
+	}
+	return (mt.lock != nil && path == mt.lock.path) ||
+		(mt.generation != nil && path == mt.generation.path)
+}
+
+// sortVerifyEntries sorts entries by input path, then output path.
+func sortVerifyEntries(entries []VerifyEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].RelPath != entries[j].RelPath {
+			return entries[i].RelPath < entries[j].RelPath
+		}
+		return entries[i].OutputPath < entries[j].OutputPath
+	})
+}
@@ -0,0 +1,112 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestVerify tests that Verify reports missing, extra and mismatched outputs
+// while leaving out the library's own files.
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "sub/b.jpg", "sub/c.jpg", "d.txt"})
+	createTestFiles(t, outputDir, []string{"a.jpg", "sub/b.jpg", "gone.jpg", tempFilePrefix + "1", DefaultLockFile})
+	if err := os.WriteFile(filepath.Join(outputDir, "sub", "b.jpg"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		LockFile:  DefaultLockFile,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			t.Errorf("Expected no callback, got %s", inputPath)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Without a hash only existence is compared
+	report, err := mt.Verify(context.Background(), VerifyOptions{})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.OK() || report.Inputs != 3 {
+		t.Errorf("Expected 3 inputs with differences, got %+v", report)
+	}
+	if len(report.Missing) != 1 || report.Missing[0].RelPath != filepath.Join("sub", "c.jpg") ||
+		report.Missing[0].OutputPath != filepath.Join(outputDir, "sub", "c.jpg") {
+		t.Errorf("Expected sub/c.jpg to be missing, got %v", report.Missing)
+	}
+	if len(report.Extra) != 1 || report.Extra[0] != filepath.Join(outputDir, "gone.jpg") {
+		t.Errorf("Expected gone.jpg to be extra, got %v", report.Extra)
+	}
+	if len(report.Mismatched) != 0 {
+		t.Errorf("Expected no mismatches without a hash, got %v", report.Mismatched)
+	}
+
+	// Content is compared with a hash
+	report, err = mt.Verify(context.Background(), VerifyOptions{Hash: contentHash})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(report.Mismatched) != 1 || report.Mismatched[0].RelPath != filepath.Join("sub", "b.jpg") {
+		t.Errorf("Expected sub/b.jpg to mismatch, got %v", report.Mismatched)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "gone.jpg")); err != nil {
+		t.Errorf("Expected Verify to leave outputs alone: %v", err)
+	}
+}
+
+// TestOrphans tests that Orphans lists the outputs without an input,
+// following the output name mapping, and deletes nothing.
+func TestOrphans(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "sub/b.jpg"})
+	createTestFiles(t, outputDir, []string{"a.webp", "sub/b.webp", "sub/old.webp", "a.jpg", tempFilePrefix + "1"})
+
+	config := Config{
+		InputDir:     inputDir,
+		OutputDir:    outputDir,
+		Patterns:     []string{"**/*.jpg"},
+		RewriteRules: []RewriteRule{{Pattern: `\.jpg$`, Replacement: ".webp"}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			t.Errorf("Expected no callback, got %s", inputPath)
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	orphans, err := mt.Orphans(context.Background())
+	if err != nil {
+		t.Fatalf("Orphans failed: %v", err)
+	}
+	want := []string{filepath.Join(outputDir, "a.jpg"), filepath.Join(outputDir, "sub", "old.webp")}
+	if len(orphans) != len(want) || orphans[0] != want[0] || orphans[1] != want[1] {
+		t.Errorf("Expected orphans %v, got %v", want, orphans)
+	}
+	for _, path := range want {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected Orphans to leave %s alone: %v", path, err)
+		}
+	}
+}
@@ -2,40 +2,84 @@ package mirrortransform
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sync"
+	"time"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fsnotify/fsnotify"
 )
 
 // Watch monitors the input directory for changes and processes new/modified files.
 // This method blocks until the context is cancelled.
-func (mt *mirrorTransform) Watch(ctx context.Context) error {
+func (mt *mirrorTransform) Watch(ctx context.Context, opts ...RunOption) (err error) {
+	// Report throttled errors when the run ends
+	defer mt.flushErrorSummaries()
+
+	run := newRunState(true, opts...)
+	run.operation = "Watch"
+	defer func() { mt.webhooks.stopped(ctx, run, err) }()
+	if err := mt.checkRunOptions(run); err != nil {
+		return err
+	}
+
 	// Check for circular references
 	if err := mt.checkCircularReference(); err != nil {
 		return err
 	}
 
+	var recovered []string
+	if !run.options.dryRun {
+		// Keep other processes from writing the same outputs
+		if err := mt.lock.acquire(); err != nil {
+			return err
+		}
+		defer func() {
+			if releaseErr := mt.lock.release(); releaseErr != nil {
+				err = errors.Join(err, releaseErr)
+			}
+		}()
+
+		// Clean up outputs left half-written by an interrupted run
+		var err error
+		if recovered, err = mt.recoverPartialOutputs(); err != nil {
+			return err
+		}
+
+		// Retry inputs held while the output was read-only
+		pending, err := mt.loadPendingTasks()
+		if err != nil {
+			return err
+		}
+		recovered = append(recovered, pending...)
+	}
+
 	// Create watcher
-	watcher, err := fsnotify.NewWatcher()
+	watcher, err := mt.newWatcher()
 	if err != nil {
-		return fmt.Errorf("failed to create watcher: %w", err)
+		return err
 	}
 	defer watcher.Close()
 
-	// Determine concurrency
-	concurrency := mt.config.Concurrency
-	maxConcurrency := mt.config.MaxConcurrency
-	if maxConcurrency <= 0 {
-		maxConcurrency = runtime.NumCPU()
+	// Watch directories that UpdateConfig stops excluding
+	updates := mt.subscribeUpdates()
+	defer mt.unsubscribeUpdates(updates)
+
+	// Scan again on Rescan, skipping the files already queued
+	rescans := mt.subscribeRescans()
+	defer mt.unsubscribeRescans(rescans)
+	run.trackActive = true
+
+	// Re-read ignore files on every run
+	if mt.ignore != nil {
+		mt.ignore.reset()
 	}
-	if concurrency <= 0 || concurrency > maxConcurrency {
-		concurrency = maxConcurrency
+	if mt.sampler != nil {
+		mt.sampler.reset()
 	}
+	mt.outputGate.reset()
 
 	// Create channels for communication
 	taskChan := make(chan fileTask, 1000)
@@ -48,21 +92,52 @@ func (mt *mirrorTransform) Watch(ctx context.Context) error {
 	processorCtx, cancelProcessors := context.WithCancel(ctx)
 	defer cancelProcessors()
 
-	for i := 0; i < concurrency; i++ {
-		wg.Add(1)
-		go mt.fileProcessor(processorCtx, taskChan, errChan, &wg)
-	}
+	// Callbacks may outlive a cancellation by the shutdown grace period
+	run.shutdown = mt.newShutdown(ctx)
+	defer run.shutdown.stop()
+
+	dispatchChan := mt.dispatchChannel(processorCtx, run, taskChan, false, &wg)
+
+	// Report when the run falls idle
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		mt.watchIdle(processorCtx, run)
+	}()
+
+	mt.startWorkers(processorCtx, run, dispatchChan, errChan, &wg)
 
 	// Add directories to watch
-	if err := mt.addWatchDirs(watcher); err != nil {
+	if err := mt.addWatchDirs(watcher, mt.walkRoot(run), run); err != nil {
 		return fmt.Errorf("failed to add watch directories: %w", err)
 	}
+	mt.log().Info("watch started", "dir", mt.walkRoot(run))
+	run.signalReady()
+
+	// Reprocess inputs whose outputs were recovered
+	if err := mt.enqueueRecovered(processorCtx, recovered, taskChan); err != nil {
+		return err
+	}
+
+	// Retry the files a previous run failed or left unprocessed
+	if mt.failures != nil && mt.failures.retry && !run.options.dryRun {
+		if err := mt.sendRetries(processorCtx, taskChan, run); err != nil {
+			return err
+		}
+	}
+
+	// Catch up with the files that exist already
+	if mt.config.ProcessExisting {
+		if err := mt.rescan(processorCtx, run, mt.walkRoot(run), TaskEventScan, taskChan); err != nil {
+			return err
+		}
+	}
 
 	// Start event handler
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		mt.handleWatchEvents(processorCtx, watcher, taskChan, errChan)
+		mt.handleWatchEvents(processorCtx, run, watcher, mt.walkRoot(run), updates, rescans, taskChan, errChan)
 	}()
 
 	// Wait for completion or error
@@ -74,9 +149,9 @@ func (mt *mirrorTransform) Watch(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		// Context cancelled, wait for graceful shutdown
+		// Context cancelled, let in-flight callbacks finish
 		cancelProcessors()
-		<-done
+		run.shutdown.drain(done)
 		return ctx.Err()
 	case err := <-errChan:
 		// Error occurred, cancel and wait for shutdown
@@ -89,21 +164,24 @@ func (mt *mirrorTransform) Watch(ctx context.Context) error {
 	}
 }
 
-// addWatchDirs recursively adds directories to the watcher.
-func (mt *mirrorTransform) addWatchDirs(watcher *fsnotify.Watcher) error {
-	return filepath.Walk(mt.config.InputDir, func(path string, info os.FileInfo, err error) error {
+// addWatchDirs recursively adds root and the directories below it to the watcher.
+// A recursive watcher only needs root. Unless run is nil or a dry run,
+// DirCallback is asked about each directory and skipped ones are not watched.
+func (mt *mirrorTransform) addWatchDirs(watcher fileWatcher, root string, run *runState) error {
+	askDirs := mt.config.DirCallback != nil && run != nil && !run.options.dryRun
+	if watcher.Recursive() {
+		if err := watcher.Add(root); err != nil {
+			return fmt.Errorf("failed to add watch for %q: %w", root, err)
+		}
+		mt.log().Debug("watching tree", "dir", root)
+		if !askDirs {
+			return nil
+		}
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			if mt.config.ErrorCallback != nil {
-				stop, retErr := mt.config.ErrorCallback(path, err)
-				if retErr != nil {
-					return fmt.Errorf("error callback failed at %q: %w", path, retErr)
-				}
-				if stop {
-					return fmt.Errorf("stopped due to error at %q: %w", path, err)
-				}
-				return nil
-			}
-			return fmt.Errorf("failed to access %q: %w", path, err)
+			return mt.handlePathError(path, err, "access")
 		}
 
 		// Only watch directories
@@ -112,86 +190,159 @@ func (mt *mirrorTransform) addWatchDirs(watcher *fsnotify.Watcher) error {
 		}
 
 		// Get relative path from input directory
-		relPath, err := filepath.Rel(mt.config.InputDir, path)
+		relPath, err := mt.relPath(path)
 		if err != nil {
 			return fmt.Errorf("failed to get relative path for %q: %w", path, err)
 		}
 
+		// Skip hidden directories and those beyond MaxDepth
+		if !mt.config.IncludeHidden && isHidden(path, relPath) {
+			return filepath.SkipDir
+		}
+		if mt.beyondMaxDepth(relPath, true) {
+			return filepath.SkipDir
+		}
+
+		// Skip ignored directories
+		ignored, err := mt.isIgnored(relPath, true)
+		if err != nil {
+			return err
+		}
+		if ignored {
+			return filepath.SkipDir
+		}
+
 		// Check exclude patterns for directories
 		if relPath != "." {
-			for _, pattern := range mt.config.ExcludePatterns {
-				match, err := doublestar.Match(pattern, relPath)
-				if err != nil {
-					return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
-				}
-				if match {
-					return filepath.SkipDir
-				}
+			excluded, err := mt.isExcluded(relPath)
+			if err != nil {
+				return err
+			}
+			if excluded {
+				return filepath.SkipDir
+			}
+		}
+
+		// Let DirCallback skip the whole subtree
+		if askDirs {
+			enter, err := mt.enterRunDir(run, path, relPath)
+			if err != nil {
+				return err
+			}
+			if !enter {
+				return filepath.SkipDir
 			}
 		}
 
-		// Add directory to watcher
+		// The recursive watch covers the directory already
+		if watcher.Recursive() {
+			return nil
+		}
+
+		// Add directory to watcher, or poll it past the watch limit
 		if err := watcher.Add(path); err != nil {
-			return fmt.Errorf("failed to add watch for %q: %w", path, err)
+			var limitErr *WatchLimitError
+			if errors.As(err, &limitErr) && limitErr.Polled {
+				if err := mt.reportWatchLimit(limitErr); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+
+			// The root must be watched; other directories are reported
+			if path == root || errors.As(err, &limitErr) {
+				return fmt.Errorf("failed to add watch for %q: %w", path, err)
+			}
+			if err := mt.reportUnwatchedDir(path, err); err != nil {
+				return err
+			}
+			return filepath.SkipDir
 		}
+		mt.unwatched.forget(path)
+		mt.log().Debug("watching directory", "dir", path)
 
 		return nil
 	})
 }
 
-// handleWatchEvents handles file system events from the watcher.
-func (mt *mirrorTransform) handleWatchEvents(ctx context.Context, watcher *fsnotify.Watcher, taskChan chan<- fileTask, errChan chan<- error) {
+// handleWatchEvents handles file system events from the watcher, and adds
+// watches below root when an update stops excluding directories. After a
+// Reconfigure, it also processes the existing files newly included, and on
+// Rescan, or when the watcher lost events, every existing file.
+func (mt *mirrorTransform) handleWatchEvents(ctx context.Context, run *runState, watcher fileWatcher, root string, updates <-chan *settingsChange, rescans *rescanSub, taskChan chan<- fileTask, errChan chan<- error) {
 	for {
 		select {
 		case <-ctx.Done():
 			close(taskChan)
 			return
 
-		case event, ok := <-watcher.Events:
+		case req := <-rescans.requests:
+			// A failed rescan is reported to its caller; watching goes on
+			req.serve(ctx, mt, run, root, taskChan)
+
+		case change := <-updates:
+			if err := mt.watchIncludedDirs(watcher, root, change.previous, run); err != nil {
+				sendError(ctx, errChan, fmt.Errorf("failed to add watch directories: %w", err))
+				close(taskChan)
+				return
+			}
+			if change.reconcile {
+				if err := mt.reconcileIncluded(ctx, root, change.previous, taskChan, run); err != nil {
+					sendError(ctx, errChan, fmt.Errorf("failed to reconcile newly included files: %w", err))
+					close(taskChan)
+					return
+				}
+			}
+
+		case event, ok := <-watcher.Events():
 			if !ok {
 				close(taskChan)
 				return
 			}
+			run.touch()
 
 			// Handle the event
-			if err := mt.processWatchEvent(ctx, watcher, event, taskChan); err != nil {
-				select {
-				case errChan <- err:
-				case <-ctx.Done():
-				}
+			if err := mt.recordEvent(event); err != nil {
+				sendError(ctx, errChan, err)
+				close(taskChan)
+				return
+			}
+			if err := mt.processWatchEvent(ctx, run, watcher, event, taskChan); err != nil {
+				sendError(ctx, errChan, err)
 				close(taskChan)
 				return
 			}
 
-		case err, ok := <-watcher.Errors:
+		case err, ok := <-watcher.Errors():
 			if !ok {
 				close(taskChan)
 				return
 			}
 
+			// Rescan what the watcher lost instead of missing it for good
+			if overflow := watchOverflow(err, root); overflow != nil {
+				if err := mt.recoverOverflow(ctx, run, overflow, taskChan); err != nil {
+					sendError(ctx, errChan, err)
+					close(taskChan)
+					return
+				}
+				continue
+			}
+
 			if mt.config.ErrorCallback != nil {
-				stop, retErr := mt.config.ErrorCallback("watcher", err)
+				stop, retErr := mt.callErrorCallback("watcher", err)
 				if retErr != nil {
-					select {
-					case errChan <- fmt.Errorf("error callback failed: %w", retErr):
-					case <-ctx.Done():
-					}
+					sendError(ctx, errChan, fmt.Errorf("error callback failed: %w", retErr))
 					close(taskChan)
 					return
 				}
 				if stop {
-					select {
-					case errChan <- fmt.Errorf("stopped due to watcher error: %w", err):
-					case <-ctx.Done():
-					}
+					sendError(ctx, errChan, markError(ErrStoppedByCallback, fmt.Errorf("stopped due to watcher error: %w", err)))
 					close(taskChan)
 					return
 				}
 			} else {
-				select {
-				case errChan <- fmt.Errorf("watcher error: %w", err):
-				case <-ctx.Done():
-				}
+				sendError(ctx, errChan, fmt.Errorf("watcher error: %w", err))
 				close(taskChan)
 				return
 			}
@@ -200,10 +351,31 @@ func (mt *mirrorTransform) handleWatchEvents(ctx context.Context, watcher *fsnot
 }
 
 // processWatchEvent processes a single file system event.
-func (mt *mirrorTransform) processWatchEvent(ctx context.Context, watcher *fsnotify.Watcher, event fsnotify.Event, taskChan chan<- fileTask) error {
-	// Ignore remove and rename events
+// watcher and run are nil when replaying recorded events; new directories are
+// then neither watched nor mirrored.
+func (mt *mirrorTransform) processWatchEvent(ctx context.Context, run *runState, watcher fileWatcher, event fsnotify.Event, taskChan chan<- fileTask) error {
+	// A rename can only be paired with the event right after it
+	var renamedFrom string
+	var renamed bool
+	if mt.renames != nil {
+		now := time.Now()
+		renamedFrom, renamed = mt.renames.take(now)
+		if event.Op.Has(fsnotify.Rename) {
+			if relPath, err := mt.relPath(event.Name); err == nil {
+				mt.renames.remember(relPath, now)
+			}
+		}
+	}
+
+	// Removed and renamed paths need no processing, only a tombstone
 	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
-		return nil
+		mt.unwatched.forget(event.Name)
+		if run != nil && !run.options.dryRun {
+			if err := mt.mirrorDirRemoval(event.Name); err != nil {
+				return err
+			}
+		}
+		return mt.recordTombstone(event)
 	}
 
 	// Get file info
@@ -213,85 +385,177 @@ func (mt *mirrorTransform) processWatchEvent(ctx context.Context, watcher *fsnot
 		if os.IsNotExist(err) {
 			return nil
 		}
-		if mt.config.ErrorCallback != nil {
-			stop, retErr := mt.config.ErrorCallback(event.Name, err)
-			if retErr != nil {
-				return fmt.Errorf("error callback failed at %q: %w", event.Name, retErr)
-			}
-			if stop {
-				return fmt.Errorf("stopped due to error at %q: %w", event.Name, err)
-			}
-			return nil
-		}
-		return fmt.Errorf("failed to stat %q: %w", event.Name, err)
+		return mt.handlePathError(event.Name, err, "stat")
 	}
 
 	// If it's a new directory, add it to the watcher
 	if info.IsDir() {
 		// Check if we need to watch this directory
-		relPath, relErr := filepath.Rel(mt.config.InputDir, event.Name)
+		relPath, relErr := mt.relPath(event.Name)
 		if relErr != nil {
 			return fmt.Errorf("failed to get relative path for %q: %w", event.Name, relErr)
 		}
 
+		// Skip hidden directories and those beyond MaxDepth
+		if !mt.config.IncludeHidden && isHidden(event.Name, relPath) {
+			return nil
+		}
+		if mt.beyondMaxDepth(relPath, true) {
+			return nil
+		}
+
+		// Skip ignored directories
+		ignored, ignoreErr := mt.isIgnored(relPath, true)
+		if ignoreErr != nil {
+			return ignoreErr
+		}
+		if ignored {
+			return nil
+		}
+
 		// Check exclude patterns
-		for _, pattern := range mt.config.ExcludePatterns {
-			match, matchErr := doublestar.Match(pattern, relPath)
-			if matchErr != nil {
-				return fmt.Errorf("invalid exclude pattern %q: %w", pattern, matchErr)
+		excluded, excludeErr := mt.isExcluded(relPath)
+		if excludeErr != nil {
+			return excludeErr
+		}
+		if excluded {
+			return nil
+		}
+
+		// Skip directories below those DirCallback skipped, or ask it
+		if mt.dirVetoes.covers(relPath) {
+			return nil
+		}
+		if run != nil && !run.options.dryRun {
+			// A directory created again may be answered differently
+			run.entered.forget(relPath)
+			enter, err := mt.enterRunDir(run, event.Name, relPath)
+			if err != nil || !enter {
+				return err
 			}
-			if match {
-				return nil
+
+			// Reproduce the directory in the output tree
+			if err := mt.mirrorDir(relPath); err != nil {
+				return err
 			}
 		}
 
-		// Add to watcher
+		// Add to watcher, unless replaying a recording or already covered
+		if watcher == nil || watcher.Recursive() {
+			return nil
+		}
 		if addErr := watcher.Add(event.Name); addErr != nil {
-			return fmt.Errorf("failed to add watch for new directory %q: %w", event.Name, addErr)
+			var limitErr *WatchLimitError
+			if errors.As(addErr, &limitErr) && limitErr.Polled {
+				return mt.reportWatchLimit(limitErr)
+			}
+			if errors.As(addErr, &limitErr) {
+				return fmt.Errorf("failed to add watch for new directory %q: %w", event.Name, addErr)
+			}
+			return mt.reportUnwatchedDir(event.Name, addErr)
 		}
+		mt.unwatched.forget(event.Name)
+		mt.log().Debug("watching new directory", "dir", event.Name)
 		return nil
 	}
 
 	// Process file event
-	relPath, err := filepath.Rel(mt.config.InputDir, event.Name)
+	relPath, err := mt.relPath(event.Name)
 	if err != nil {
 		return fmt.Errorf("failed to get relative path for %q: %w", event.Name, err)
 	}
 
+	// Pick up edits to ignore files for subsequent events
+	if mt.ignore != nil && filepath.Base(relPath) == mt.config.IgnoreFile {
+		mt.ignore.invalidate(filepath.Dir(relPath))
+	}
+
+	// Skip hidden files and those beyond MaxDepth
+	if !mt.config.IncludeHidden && isHidden(event.Name, relPath) {
+		return nil
+	}
+	if mt.beyondMaxDepth(relPath, false) {
+		return nil
+	}
+
+	// Skip partial uploads, editor backups and the like
+	if mt.isTempFile(relPath) {
+		mt.log().Debug("ignoring temporary file", "path", relPath)
+		return nil
+	}
+
+	// Skip files in directories DirCallback skipped
+	if mt.dirVetoes.covers(relPath) {
+		return nil
+	}
+
+	// Skip ignored files
+	ignored, err := mt.isIgnored(relPath, false)
+	if err != nil {
+		return err
+	}
+	if ignored {
+		return nil
+	}
+
 	// Check exclude patterns
-	for _, pattern := range mt.config.ExcludePatterns {
-		match, matchErr := doublestar.Match(pattern, relPath)
-		if matchErr != nil {
-			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, matchErr)
+	excluded, err := mt.isExcluded(relPath)
+	if err != nil {
+		return err
+	}
+	if excluded {
+		return nil
+	}
+
+	// Recursive watchers, and directories excluded by UpdateConfig after
+	// they were watched, report files below skipped directories
+	if watcher != nil {
+		skipped, err := mt.inSkippedDir(relPath)
+		if err != nil {
+			return err
 		}
-		if match {
+		if skipped {
 			return nil
 		}
 	}
 
 	// Check if file matches any pattern
-	matched := false
-	for _, pattern := range mt.config.Patterns {
-		match, matchErr := doublestar.Match(pattern, relPath)
-		if matchErr != nil {
-			return fmt.Errorf("invalid pattern %q: %w", pattern, matchErr)
-		}
-		if match {
-			matched = true
-			break
-		}
+	pattern, err := mt.matchPattern(relPath, newFileEntry(event.Name, info))
+	if err != nil {
+		return err
+	}
+	if pattern == "" {
+		return nil
 	}
 
-	if !matched {
+	// Skip priority sidecars and files outside the sample
+	if mt.isPrioritySidecar(relPath) || !mt.inSample(relPath) {
 		return nil
 	}
 
 	// Create output path
-	outputPath := filepath.Join(mt.config.OutputDir, relPath)
+	outputPath, err := mt.outputPath(relPath)
+	if err != nil {
+		return err
+	}
+
+	task := newFileTask(event.Name, outputPath, relPath, info, watchTaskEvent(event.Op), pattern)
+
+	// Move the outputs of a renamed file instead of processing it again,
+	// except in dry runs, which leave outputs alone
+	if renamed && event.Op.Has(fsnotify.Create) && run != nil && !run.options.dryRun {
+		moved, err := mt.followRename(renamedFrom, task)
+		if err != nil {
+			return err
+		}
+		if moved {
+			return nil
+		}
+	}
 
 	// Send task to channel
 	select {
-	case taskChan <- fileTask{inputPath: event.Name, outputPath: outputPath}:
+	case taskChan <- task:
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
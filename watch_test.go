@@ -289,6 +289,75 @@ func TestWatchExcludePatterns(t *testing.T) {
 	}
 }
 
+// TestWatchMaxDepth tests that files beyond MaxDepth are not watched.
+func TestWatchMaxDepth(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	if err := os.MkdirAll(filepath.Join(inputDir, "b", "deep"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	var mu sync.Mutex
+	processed := make(map[string]bool)
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Patterns:  []string{"**/*.jpg"},
+		MaxDepth:  2,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)] = true
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	// New directories are watched up to MaxDepth too
+	if err := os.MkdirAll(filepath.Join(inputDir, "new", "deeper"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	createTestFiles(t, inputDir, []string{"a.jpg", "b/c.jpg", "b/deep/d.jpg", "new/e.jpg", "new/deeper/f.jpg"})
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, relPath := range []string{"a.jpg", "b/c.jpg", "new/e.jpg"} {
+		if !processed[relPath] {
+			t.Errorf("Expected %s to be processed, got %v", relPath, processed)
+		}
+	}
+	for _, relPath := range []string{"b/deep/d.jpg", "new/deeper/f.jpg"} {
+		if processed[relPath] {
+			t.Errorf("Expected %s beyond MaxDepth to be skipped, got %v", relPath, processed)
+		}
+	}
+}
+
 // TestWatchContextCancellation tests graceful shutdown on context cancellation.
 func TestWatchContextCancellation(t *testing.T) {
 	t.Parallel()
@@ -501,3 +570,64 @@ func TestWatchFileCallbackError(t *testing.T) {
 		t.Error("Watch did not return error within timeout")
 	}
 }
+
+// TestWatchProcessExisting tests that Watch with ProcessExisting processes
+// the existing files once before following changes.
+func TestWatchProcessExisting(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.jpg", "sub/b.jpg", "c.txt"})
+
+	var mu sync.Mutex
+	processed := make(map[string]int)
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.jpg"},
+		ProcessExisting: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			relPath, _ := filepath.Rel(inputDir, inputPath)
+			mu.Lock()
+			processed[filepath.ToSlash(relPath)]++
+			mu.Unlock()
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ready := make(chan struct{})
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx, WithReady(ready))
+	}()
+	<-ready
+
+	time.Sleep(200 * time.Millisecond)
+	createTestFiles(t, inputDir, []string{"d.jpg"})
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-watchErr
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, relPath := range []string{"a.jpg", "sub/b.jpg"} {
+		if processed[relPath] != 1 {
+			t.Errorf("Expected %s to be processed once, got %v", relPath, processed)
+		}
+	}
+	if processed["d.jpg"] == 0 {
+		t.Errorf("Expected the new file d.jpg to be processed, got %v", processed)
+	}
+	if processed["c.txt"] != 0 {
+		t.Errorf("Expected c.txt not to be processed, got %v", processed)
+	}
+}
@@ -0,0 +1,70 @@
+package mirrortransform
+
+import (
+	"path/filepath"
+
+	"github.com/ideamans/go-mirror-transform/watcher"
+)
+
+// fileWatcher delivers file system events for the directories added to it.
+type fileWatcher = watcher.Watcher
+
+// newWatcher creates the watcher used by Watch and Run. With RecursiveWatch,
+// a native recursive backend is used where the platform has one, falling back
+// to watching each directory with fsnotify. Directories beyond the watch
+// limit are polled if PollUnwatched is set.
+// Platforms without a native backend, and builds with the
+// mirrortransform_poll tag, poll the whole tree instead, every PollUnwatched
+// or watcher.DefaultPollInterval.
+func (mt *mirrorTransform) newWatcher() (fileWatcher, error) {
+	if watcher.PollOnly {
+		interval := mt.config.PollUnwatched
+		if interval <= 0 {
+			interval = watcher.DefaultPollInterval
+		}
+		return watcher.NewPoll(interval, mt.skipPolledDir), nil
+	}
+
+	var w fileWatcher
+	if mt.config.RecursiveWatch {
+		if recursive, err := watcher.NewRecursive(); err == nil {
+			w = recursive
+		}
+	}
+
+	if w == nil {
+		fsWatcher, err := watcher.NewFsnotify()
+		if err != nil {
+			return nil, err
+		}
+		w = fsWatcher
+	}
+	return watcher.NewLimit(w, mt.config.PollUnwatched, mt.skipPolledDir), nil
+}
+
+// inSkippedDir reports whether a directory containing relPath is ignored,
+// excluded or skipped by DirCallback. Recursive watchers report events below
+// such directories, which per-directory watching never adds.
+func (mt *mirrorTransform) inSkippedDir(relPath string) (bool, error) {
+	if mt.dirVetoes.covers(relPath) {
+		return true, nil
+	}
+	for dir := filepath.Dir(relPath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		ignored, err := mt.isIgnored(dir, true)
+		if err != nil {
+			return false, err
+		}
+		if ignored {
+			return true, nil
+		}
+
+		excluded, err := mt.isExcluded(dir)
+		if err != nil {
+			return false, err
+		}
+		if excluded {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,254 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ideamans/go-mirror-transform/internal/fspath"
+)
+
+// LimitError is returned by the Add method of a limit watcher when a
+// directory cannot be watched because the platform limit on watches is
+// reached, such as fs.inotify.max_user_watches on Linux.
+type LimitError struct {
+	// Path is the directory that could not be watched.
+	Path string
+
+	// Watched is the number of watches registered before the limit was hit.
+	Watched int
+
+	// Limit is the configured limit, or zero if it cannot be determined.
+	Limit int
+
+	// Polled is true when the directory tree is polled instead.
+	Polled bool
+
+	// Err is the error returned by the watcher.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	limit := "unknown"
+	if e.Limit > 0 {
+		limit = strconv.Itoa(e.Limit)
+	}
+	msg := fmt.Sprintf("watch limit reached at %q after registering %d watches (limit %s): %v", e.Path, e.Watched, limit, e.Err)
+	if e.Polled {
+		return msg + "; polling the directory instead"
+	}
+	return msg + "; raise " + watchLimitSetting + " or set PollUnwatched"
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// limitWatcher wraps a Watcher, counting the registered watches and
+// polling the directory trees that cannot be watched when pollInterval is set.
+type limitWatcher struct {
+	Watcher
+	pollInterval time.Duration
+
+	// skipDir reports whether a polled directory should be left out.
+	skipDir func(path string) bool
+
+	watched atomic.Int64
+	events  chan fsnotify.Event
+	done    chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	polled []string
+	closed bool
+}
+
+// NewLimit wraps watcher so that Add returns a *LimitError when the watch
+// limit is reached, after starting to poll the directory tree every
+// pollInterval if it is positive. skipDir, if set, reports which polled
+// directories to leave out.
+func NewLimit(watcher Watcher, pollInterval time.Duration, skipDir func(path string) bool) Watcher {
+	w := &limitWatcher{
+		Watcher:      watcher,
+		pollInterval: pollInterval,
+		skipDir:      skipDir,
+		events:       make(chan fsnotify.Event),
+		done:         make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.forward()
+	return w
+}
+
+// Events returns the watcher events merged with the polled changes.
+func (w *limitWatcher) Events() <-chan fsnotify.Event {
+	return w.events
+}
+
+// Add watches path. When the watch limit is reached it returns a
+// *LimitError, after starting to poll path if polling is enabled.
+func (w *limitWatcher) Add(path string) error {
+	// Directories below a polled tree are covered already
+	if w.isPolled(path) {
+		return nil
+	}
+
+	err := w.Watcher.Add(path)
+	if err == nil {
+		w.watched.Add(1)
+		return nil
+	}
+	if !isWatchLimitError(err) {
+		return err
+	}
+
+	limitErr := &LimitError{Path: path, Watched: int(w.watched.Load()), Limit: watchLimit(), Err: err}
+	if w.pollInterval <= 0 {
+		return limitErr
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return limitErr
+	}
+	limitErr.Polled = true
+	w.polled = append(w.polled, path)
+	w.wg.Add(1)
+	go w.poll(path, w.snapshot(path))
+	return limitErr
+}
+
+// isPolled reports whether path is inside a polled tree.
+func (w *limitWatcher) isPolled(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, root := range w.polled {
+		if fspath.Same(path, root) || fspath.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops polling and closes the wrapped watcher.
+func (w *limitWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	err := w.Watcher.Close()
+	w.wg.Wait()
+	close(w.events)
+	return err
+}
+
+// forward passes the events of the wrapped watcher on.
+func (w *limitWatcher) forward() {
+	defer w.wg.Done()
+	for event := range w.Watcher.Events() {
+		if !w.send(event) {
+			return
+		}
+	}
+}
+
+// send delivers event unless the watcher is closed first.
+func (w *limitWatcher) send(event fsnotify.Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// poll reports the files created, written or removed below root since the
+// snapshot seen until the watcher is closed.
+func (w *limitWatcher) poll(root string, seen map[string]fileStamp) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot(root)
+		for _, event := range snapshotChanges(seen, current) {
+			if !w.send(event) {
+				return
+			}
+		}
+		seen = current
+	}
+}
+
+// snapshot returns the size and modification time of every file below root.
+func (w *limitWatcher) snapshot(root string) map[string]fileStamp {
+	return snapshotTree(root, w.skipDir)
+}
+
+// fileStamp identifies a version of a polled file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotTree returns the size and modification time of every file below
+// root, leaving out directories for which skipDir, if set, returns true.
+// Unreadable entries are left out; they are retried on the next poll.
+func snapshotTree(root string, skipDir func(path string) bool) map[string]fileStamp {
+	files := make(map[string]fileStamp)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skipDir != nil && skipDir(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files
+}
+
+// snapshotChanges returns the events turning the snapshot seen into current:
+// Create for new files, Write for changed ones and Remove for missing ones.
+func snapshotChanges(seen, current map[string]fileStamp) []fsnotify.Event {
+	var events []fsnotify.Event
+	for path, stamp := range current {
+		if last, ok := seen[path]; !ok {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		} else if last.size != stamp.size || !last.modTime.Equal(stamp.modTime) {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for path := range seen {
+		if _, ok := current[path]; !ok {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		}
+	}
+	return events
+}
@@ -0,0 +1,32 @@
+//go:build linux
+
+package watcher
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// watchLimitSetting names the setting that raises the watch limit.
+const watchLimitSetting = "fs.inotify.max_user_watches"
+
+// isWatchLimitError reports whether err means no more inotify watches can be added.
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// watchLimit returns the inotify watch limit, or zero if it cannot be read.
+func watchLimit() int {
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return limit
+}
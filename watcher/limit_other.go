@@ -0,0 +1,22 @@
+//go:build !linux && !plan9
+
+package watcher
+
+import (
+	"errors"
+	"syscall"
+)
+
+// watchLimitSetting names the setting that raises the watch limit. kqueue
+// holds a file descriptor per watched directory.
+const watchLimitSetting = "the open file limit"
+
+// isWatchLimitError reports whether err means no more watches can be added.
+func isWatchLimitError(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENOSPC)
+}
+
+// watchLimit returns zero: the limit cannot be determined portably.
+func watchLimit() int {
+	return 0
+}
@@ -0,0 +1,15 @@
+package watcher
+
+// watchLimitSetting names the setting that raises the watch limit. Plan 9
+// has no native watcher, so the limit is never reached.
+const watchLimitSetting = "the open file limit"
+
+// isWatchLimitError returns false: the polling watcher has no limit.
+func isWatchLimitError(err error) bool {
+	return false
+}
+
+// watchLimit returns zero: the limit cannot be determined.
+func watchLimit() int {
+	return 0
+}
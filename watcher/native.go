@@ -0,0 +1,6 @@
+//go:build !mirrortransform_poll && (linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || illumos || windows)
+
+package watcher
+
+// PollOnly is false where fsnotify has a native backend.
+const PollOnly = false
@@ -0,0 +1,22 @@
+package watcher
+
+import (
+	"fmt"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// OverflowError is sent on the error channel of a Watcher that lost events
+// below Dir. It matches fsnotify.ErrEventOverflow with errors.Is.
+type OverflowError struct {
+	// Dir is the directory tree whose events were lost.
+	Dir string
+}
+
+func (e *OverflowError) Error() string {
+	return fmt.Sprintf("watcher lost events below %q", e.Dir)
+}
+
+func (e *OverflowError) Unwrap() error {
+	return fsnotify.ErrEventOverflow
+}
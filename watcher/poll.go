@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ideamans/go-mirror-transform/internal/fspath"
+)
+
+// DefaultPollInterval is a reasonable interval for NewPoll.
+const DefaultPollInterval = 2 * time.Second
+
+// pollWatcher is a pure Go Watcher that finds changes by scanning the
+// added directory trees periodically. It serves platforms without a native
+// fsnotify backend, such as Plan 9 and WebAssembly.
+type pollWatcher struct {
+	interval time.Duration
+
+	// skipDir reports whether a polled directory should be left out.
+	skipDir func(path string) bool
+
+	events chan fsnotify.Event
+	errors chan error
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	roots  []string
+	closed bool
+}
+
+// NewPoll returns a recursive Watcher scanning the added trees every
+// interval. skipDir, if set, reports which directories to leave out.
+func NewPoll(interval time.Duration, skipDir func(path string) bool) Watcher {
+	return &pollWatcher{
+		interval: interval,
+		skipDir:  skipDir,
+		events:   make(chan fsnotify.Event),
+		errors:   make(chan error),
+		done:     make(chan struct{}),
+	}
+}
+
+// Add starts polling the tree at path. Paths inside a polled tree are
+// covered already.
+func (w *pollWatcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fsnotify.ErrClosed
+	}
+	for _, root := range w.roots {
+		if fspath.Same(path, root) || fspath.HasPrefix(path, root+string(filepath.Separator)) {
+			return nil
+		}
+	}
+
+	w.roots = append(w.roots, path)
+	w.wg.Add(1)
+	go w.poll(path, snapshotTree(path, w.skipDir))
+	return nil
+}
+
+// Close stops polling and closes the event and error channels.
+func (w *pollWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	close(w.events)
+	close(w.errors)
+	return nil
+}
+
+func (w *pollWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *pollWatcher) Errors() <-chan error          { return w.errors }
+func (w *pollWatcher) Recursive() bool               { return true }
+
+// poll reports the files created, written or removed below root since the
+// snapshot seen until the watcher is closed.
+func (w *pollWatcher) poll(root string, seen map[string]fileStamp) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		current := snapshotTree(root, w.skipDir)
+		for _, event := range snapshotChanges(seen, current) {
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+		seen = current
+	}
+}
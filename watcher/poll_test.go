@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestPollWatcher tests that the polling watcher reports created, written and
+// removed files in the whole tree, leaving out skipped directories.
+func TestPollWatcher(t *testing.T) {
+	t.Parallel()
+	inputDir := t.TempDir()
+
+	createFiles(t, inputDir, "a/1.jpg", "skip/2.jpg")
+
+	skipDir := func(path string) bool { return filepath.Base(path) == "skip" }
+	watcher := NewPoll(20*time.Millisecond, skipDir)
+	defer watcher.Close()
+
+	if !watcher.Recursive() {
+		t.Error("Expected the polling watcher to be recursive")
+	}
+	if err := watcher.Add(inputDir); err != nil {
+		t.Fatalf("Failed to add watch: %v", err)
+	}
+	if err := watcher.Add(filepath.Join(inputDir, "a")); err != nil {
+		t.Fatalf("Failed to add watch below a polled tree: %v", err)
+	}
+
+	// Let the first poll pass before changing the tree
+	time.Sleep(50 * time.Millisecond)
+	createFiles(t, inputDir, "b/new.jpg", "skip/3.jpg")
+	if err := os.WriteFile(filepath.Join(inputDir, "a/1.jpg"), []byte("changed content"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Remove(filepath.Join(inputDir, "skip/2.jpg")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+
+	expected := map[string]fsnotify.Op{
+		filepath.Join(inputDir, "b/new.jpg"): fsnotify.Create,
+		filepath.Join(inputDir, "a/1.jpg"):   fsnotify.Write,
+	}
+	timeout := time.After(2 * time.Second)
+	for len(expected) > 0 {
+		select {
+		case event := <-watcher.Events():
+			if strings.Contains(event.Name, "skip") {
+				t.Errorf("Expected no events in skipped directories, got %v", event)
+				continue
+			}
+			if op, ok := expected[event.Name]; ok && event.Op == op {
+				delete(expected, event.Name)
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for events: %v", expected)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(inputDir, "b/new.jpg")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	select {
+	case event := <-watcher.Events():
+		if event.Name != filepath.Join(inputDir, "b/new.jpg") || event.Op != fsnotify.Remove {
+			t.Errorf("Expected a remove event, got %v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the remove event")
+	}
+
+	if err := watcher.Close(); err != nil {
+		t.Fatalf("Failed to close watcher: %v", err)
+	}
+	if _, ok := <-watcher.Events(); ok {
+		t.Error("Expected the event channel to be closed")
+	}
+	if err := watcher.Add(inputDir); err == nil {
+		t.Error("Expected Add to fail after Close")
+	}
+}
+
+// createFiles creates the files at the relative paths below dir.
+func createFiles(t *testing.T, dir string, relPaths ...string) {
+	t.Helper()
+	for _, relPath := range relPaths {
+		path := filepath.Join(dir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("test content"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+}
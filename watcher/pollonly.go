@@ -0,0 +1,8 @@
+//go:build mirrortransform_poll || !(linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris || illumos || windows)
+
+package watcher
+
+// PollOnly is true where fsnotify has no native backend, or when built with
+// the mirrortransform_poll tag: every watch should then be served by a
+// polling watcher.
+const PollOnly = true
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package watcher
+
+// NewRecursive returns ErrRecursiveUnsupported: FSEvents needs cgo and
+// fanotify needs CAP_SYS_ADMIN, so these platforms watch each directory.
+func NewRecursive() (Watcher, error) {
+	return nil, ErrRecursiveUnsupported
+}
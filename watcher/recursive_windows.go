@@ -0,0 +1,173 @@
+//go:build windows
+
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/windows"
+)
+
+// recursiveChangeMask selects the changes reported by ReadDirectoryChangesW.
+const recursiveChangeMask = windows.FILE_NOTIFY_CHANGE_FILE_NAME |
+	windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_ATTRIBUTES |
+	windows.FILE_NOTIFY_CHANGE_SIZE |
+	windows.FILE_NOTIFY_CHANGE_LAST_WRITE |
+	windows.FILE_NOTIFY_CHANGE_CREATION
+
+// recursiveBufferSize is the size of the buffer ReadDirectoryChangesW fills.
+// 64 KiB is the largest size supported on network shares.
+const recursiveBufferSize = 64 * 1024
+
+// recursiveWatcher watches whole directory trees with one
+// ReadDirectoryChangesW handle per added directory.
+type recursiveWatcher struct {
+	events chan fsnotify.Event
+	errors chan error
+	done   chan struct{}
+
+	mu      sync.Mutex
+	handles []windows.Handle
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewRecursive returns a recursive Watcher backed by ReadDirectoryChangesW.
+func NewRecursive() (Watcher, error) {
+	return &recursiveWatcher{
+		events: make(chan fsnotify.Event),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+func (w *recursiveWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *recursiveWatcher) Errors() <-chan error          { return w.errors }
+func (w *recursiveWatcher) Recursive() bool               { return true }
+
+// Add watches the directory tree rooted at path.
+func (w *recursiveWatcher) Add(path string) error {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return fmt.Errorf("failed to watch %q: %w", path, err)
+	}
+	handle, err := windows.CreateFile(name,
+		windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil, windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %q for watching: %w", path, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		windows.CloseHandle(handle)
+		return fmt.Errorf("failed to watch %q: watcher is closed", path)
+	}
+	w.handles = append(w.handles, handle)
+	w.wg.Add(1)
+	go w.read(handle, path)
+	return nil
+}
+
+// Close stops all reads and closes the channels.
+func (w *recursiveWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	for _, handle := range w.handles {
+		// Unblock the pending ReadDirectoryChangesW call
+		windows.CancelIoEx(handle, nil)
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	for _, handle := range w.handles {
+		windows.CloseHandle(handle)
+	}
+	close(w.events)
+	close(w.errors)
+	return nil
+}
+
+// read delivers the changes below root until the watcher is closed.
+func (w *recursiveWatcher) read(handle windows.Handle, root string) {
+	defer w.wg.Done()
+
+	buf := make([]byte, recursiveBufferSize)
+	for {
+		var n uint32
+		err := windows.ReadDirectoryChanges(handle, &buf[0], uint32(len(buf)), true, recursiveChangeMask, &n, nil, 0)
+		if err != nil {
+			w.sendError(fmt.Errorf("failed to read changes below %q: %w", root, err))
+			return
+		}
+
+		// The system dropped changes that did not fit in the buffer
+		if n == 0 {
+			if !w.sendError(&OverflowError{Dir: root}) {
+				return
+			}
+			continue
+		}
+
+		for offset := uint32(0); ; {
+			info := (*windows.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := windows.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+			event := fsnotify.Event{Name: filepath.Join(root, name), Op: recursiveOp(info.Action)}
+			if event.Op != 0 && !w.send(event) {
+				return
+			}
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+	}
+}
+
+// send delivers event unless the watcher is closed first.
+func (w *recursiveWatcher) send(event fsnotify.Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// sendError delivers err unless the watcher is closed first.
+func (w *recursiveWatcher) sendError(err error) bool {
+	select {
+	case w.errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// recursiveOp maps a FILE_ACTION value to the matching fsnotify operation.
+// The new name of a renamed file is reported as a creation, as fsnotify does.
+func recursiveOp(action uint32) fsnotify.Op {
+	switch action {
+	case windows.FILE_ACTION_ADDED, windows.FILE_ACTION_RENAMED_NEW_NAME:
+		return fsnotify.Create
+	case windows.FILE_ACTION_REMOVED:
+		return fsnotify.Remove
+	case windows.FILE_ACTION_MODIFIED:
+		return fsnotify.Write
+	case windows.FILE_ACTION_RENAMED_OLD_NAME:
+		return fsnotify.Rename
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+// Package watcher provides the file system watch backends used by Watch and
+// Run: per-directory fsnotify, a native recursive backend where the
+// platform has one, a pure Go polling watcher, and a wrapper that polls the
+// directories beyond the platform's watch limit.
+package watcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrRecursiveUnsupported is returned by NewRecursive on platforms without
+// a native recursive backend.
+var ErrRecursiveUnsupported = errors.New("recursive watching is not supported on this platform")
+
+// Watcher delivers file system events for the directories added to it.
+type Watcher interface {
+	// Add starts watching the directory at path.
+	Add(path string) error
+
+	// Close stops watching and closes the event and error channels.
+	Close() error
+
+	// Events returns the channel file system events are delivered on.
+	Events() <-chan fsnotify.Event
+
+	// Errors returns the channel watcher errors are delivered on.
+	Errors() <-chan error
+
+	// Recursive reports whether adding a directory also watches every
+	// directory below it, including ones created later.
+	Recursive() bool
+}
+
+// fsnotifyWatcher is a Watcher watching each directory separately.
+type fsnotifyWatcher struct {
+	watcher *fsnotify.Watcher
+}
+
+// NewFsnotify returns a Watcher watching each added directory separately
+// with fsnotify.
+func NewFsnotify() (Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+	return fsnotifyWatcher{watcher: watcher}, nil
+}
+
+func (w fsnotifyWatcher) Add(path string) error         { return w.watcher.Add(path) }
+func (w fsnotifyWatcher) Close() error                  { return w.watcher.Close() }
+func (w fsnotifyWatcher) Events() <-chan fsnotify.Event { return w.watcher.Events }
+func (w fsnotifyWatcher) Errors() <-chan error          { return w.watcher.Errors }
+func (w fsnotifyWatcher) Recursive() bool               { return false }
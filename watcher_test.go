@@ -0,0 +1,132 @@
+package mirrortransform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeRecursiveWatcher is a fileWatcher that claims to watch whole trees.
+type fakeRecursiveWatcher struct {
+	added []string
+}
+
+func (w *fakeRecursiveWatcher) Add(path string) error         { w.added = append(w.added, path); return nil }
+func (w *fakeRecursiveWatcher) Close() error                  { return nil }
+func (w *fakeRecursiveWatcher) Events() <-chan fsnotify.Event { return nil }
+func (w *fakeRecursiveWatcher) Errors() <-chan error          { return nil }
+func (w *fakeRecursiveWatcher) Recursive() bool               { return true }
+
+// TestRecursiveWatcherEvents tests that a recursive watcher is added once and
+// that events below excluded directories are dropped.
+func TestRecursiveWatcherEvents(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"a/b/keep.jpg", "vendor/lib/skip.jpg"})
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Patterns:        []string{"**/*.jpg"},
+		ExcludePatterns: []string{"vendor"},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	instance, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	mt := instance.(*mirrorTransform)
+
+	watcher := &fakeRecursiveWatcher{}
+	if err := mt.addWatchDirs(watcher, inputDir, nil); err != nil {
+		t.Fatalf("addWatchDirs failed: %v", err)
+	}
+	if len(watcher.added) != 1 || watcher.added[0] != inputDir {
+		t.Errorf("Expected only the root to be added, got %v", watcher.added)
+	}
+
+	taskChan := make(chan fileTask, 10)
+	for _, rel := range []string{"a/b/keep.jpg", "vendor/lib/skip.jpg", "a/b"} {
+		event := fsnotify.Event{Name: filepath.Join(inputDir, filepath.FromSlash(rel)), Op: fsnotify.Create}
+		if err := mt.processWatchEvent(context.Background(), nil, watcher, event, taskChan); err != nil {
+			t.Fatalf("processWatchEvent failed for %q: %v", rel, err)
+		}
+	}
+	close(taskChan)
+
+	var relPaths []string
+	for task := range taskChan {
+		relPaths = append(relPaths, filepath.ToSlash(task.RelPath))
+	}
+	if len(relPaths) != 1 || relPaths[0] != "a/b/keep.jpg" {
+		t.Errorf("Expected only a/b/keep.jpg to be queued, got %v", relPaths)
+	}
+	if len(watcher.added) != 1 {
+		t.Errorf("Expected new directories not to be added, got %v", watcher.added)
+	}
+}
+
+// TestWatchRecursiveWatch tests that Watch picks up files in new
+// subdirectories with RecursiveWatch, falling back where unsupported.
+func TestWatchRecursiveWatch(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	outputDir := filepath.Join(testDir, "output")
+
+	createTestFiles(t, inputDir, []string{"keep.txt"})
+
+	config := Config{
+		InputDir:       inputDir,
+		OutputDir:      outputDir,
+		Patterns:       []string{"**/*.jpg"},
+		RecursiveWatch: true,
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, os.WriteFile(outputPath, []byte("out"), 0644)
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- mt.Watch(ctx)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := os.MkdirAll(filepath.Join(inputDir, "sub"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Write the file outside the tree and move it in, so it is processed once
+	tmp := filepath.Join(testDir, "a.jpg")
+	if err := os.WriteFile(tmp, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(inputDir, "sub", "a.jpg")); err != nil {
+		t.Fatalf("Failed to move file in: %v", err)
+	}
+	waitForFile(t, filepath.Join(outputDir, "sub", "a.jpg"))
+
+	cancel()
+	if err := <-watchErr; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
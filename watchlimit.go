@@ -0,0 +1,52 @@
+package mirrortransform
+
+import (
+	"fmt"
+
+	"github.com/ideamans/go-mirror-transform/watcher"
+)
+
+// WatchLimitError is returned by Watch and Run when a directory cannot be
+// watched because the platform limit on watches is reached, such as
+// fs.inotify.max_user_watches on Linux. Use errors.As to retrieve it.
+// With Config.PollUnwatched set it is passed to ErrorCallback instead and the
+// directory tree is polled.
+type WatchLimitError = watcher.LimitError
+
+// skipPolledDir reports whether a directory found while polling would not
+// have been watched: hidden, ignored or excluded.
+func (mt *mirrorTransform) skipPolledDir(path string) bool {
+	relPath, err := mt.relPath(path)
+	if err != nil {
+		return true
+	}
+	if !mt.config.IncludeHidden && isHidden(path, relPath) {
+		return true
+	}
+	if mt.beyondMaxDepth(relPath, true) {
+		return true
+	}
+	if ignored, err := mt.isIgnored(relPath, true); err != nil || ignored {
+		return true
+	}
+	excluded, err := mt.isExcluded(relPath)
+	return err != nil || excluded
+}
+
+// reportWatchLimit passes a limit error for a polled directory to
+// ErrorCallback, if set, and returns an error if the run should stop.
+func (mt *mirrorTransform) reportWatchLimit(limitErr *WatchLimitError) error {
+	mt.log().Warn("watch limit reached, polling directory", "dir", limitErr.Path, "watched", limitErr.Watched, "limit", limitErr.Limit)
+	if mt.config.ErrorCallback == nil {
+		return nil
+	}
+
+	stop, retErr := mt.callErrorCallback(limitErr.Path, limitErr)
+	if retErr != nil {
+		return fmt.Errorf("error callback failed at %q: %w", limitErr.Path, retErr)
+	}
+	if stop {
+		return markError(ErrStoppedByCallback, fmt.Errorf("stopped due to error at %q: %w", limitErr.Path, limitErr))
+	}
+	return nil
+}
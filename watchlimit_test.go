@@ -0,0 +1,119 @@
+//go:build !plan9
+
+package mirrortransform
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ideamans/go-mirror-transform/watcher"
+)
+
+// limitedFakeWatcher is a fileWatcher that fails with ENOSPC after max adds.
+type limitedFakeWatcher struct {
+	max    int
+	added  []string
+	events chan fsnotify.Event
+}
+
+func newLimitedFakeWatcher(max int) *limitedFakeWatcher {
+	return &limitedFakeWatcher{max: max, events: make(chan fsnotify.Event)}
+}
+
+func (w *limitedFakeWatcher) Add(path string) error {
+	if len(w.added) >= w.max {
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: syscall.ENOSPC}
+	}
+	w.added = append(w.added, path)
+	return nil
+}
+func (w *limitedFakeWatcher) Close() error                  { close(w.events); return nil }
+func (w *limitedFakeWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *limitedFakeWatcher) Errors() <-chan error          { return nil }
+func (w *limitedFakeWatcher) Recursive() bool               { return false }
+
+// TestWatchLimitError tests that reaching the watch limit returns a typed
+// error with the number of registered watches.
+func TestWatchLimitError(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a/1.jpg", "b/2.jpg", "c/3.jpg"})
+
+	mt := &mirrorTransform{config: Config{InputDir: inputDir, OutputDir: filepath.Join(testDir, "output")}}
+	watcher := watcher.NewLimit(newLimitedFakeWatcher(2), 0, nil)
+	defer watcher.Close()
+
+	err := mt.addWatchDirs(watcher, inputDir, nil)
+	var limitErr *WatchLimitError
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("Expected a *WatchLimitError, got %v", err)
+	}
+	if limitErr.Watched != 2 || limitErr.Polled {
+		t.Errorf("Expected 2 watches and no polling, got %+v", limitErr)
+	}
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("Expected the error to wrap ENOSPC, got %v", err)
+	}
+}
+
+// TestWatchLimitPolling tests that trees beyond the watch limit are polled
+// and the limit is reported to ErrorCallback.
+func TestWatchLimitPolling(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+
+	createTestFiles(t, inputDir, []string{"a/1.jpg", "b/deep/2.jpg"})
+
+	var mu sync.Mutex
+	var reported []error
+	mt := &mirrorTransform{config: Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		ErrorCallback: func(path string, err error) (bool, error) {
+			mu.Lock()
+			reported = append(reported, err)
+			mu.Unlock()
+			return false, nil
+		},
+	}}
+	watcher := watcher.NewLimit(newLimitedFakeWatcher(2), 20*time.Millisecond, mt.skipPolledDir)
+	defer watcher.Close()
+
+	// The root and "a" are watched, "b" is polled with everything below it
+	if err := mt.addWatchDirs(watcher, inputDir, nil); err != nil {
+		t.Fatalf("addWatchDirs failed: %v", err)
+	}
+
+	mu.Lock()
+	if len(reported) != 1 {
+		t.Fatalf("Expected 1 reported limit, got %v", reported)
+	}
+	var limitErr *WatchLimitError
+	if !errors.As(reported[0], &limitErr) || !limitErr.Polled || limitErr.Path != filepath.Join(inputDir, "b") {
+		t.Errorf("Expected a polled limit error for %q, got %v", filepath.Join(inputDir, "b"), reported[0])
+	}
+	mu.Unlock()
+
+	newFile := filepath.Join(inputDir, "b", "deep", "new.jpg")
+	if err := os.WriteFile(newFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	select {
+	case event := <-watcher.Events():
+		if event.Name != newFile || !event.Has(fsnotify.Create) {
+			t.Errorf("Expected create event for %q, got %v", newFile, event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a polled event")
+	}
+}
@@ -0,0 +1,289 @@
+package mirrortransform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultWebhookTimeout bounds a webhook request without a Timeout.
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookEvent names a situation that fires a Webhook.
+type WebhookEvent string
+
+const (
+	// WebhookFinished fires when Crawl, CrawlWithResult, CrawlPaths,
+	// RetryFailed, ImportList, ImportTar or CrawlArchive returns,
+	// successfully or not.
+	WebhookFinished WebhookEvent = "finished"
+
+	// WebhookErrors fires once per run when the number of files whose
+	// callback failed reaches Webhook.ErrorThreshold.
+	WebhookErrors WebhookEvent = "errors"
+
+	// WebhookStopped fires when Watch or Run returns although its context
+	// was not cancelled.
+	WebhookStopped WebhookEvent = "stopped"
+)
+
+// Webhook sends an HTTP POST request to URL when one of its events occurs,
+// so unattended mirrors can page someone without wrapper code.
+type Webhook struct {
+	// URL is the http or https endpoint to notify.
+	URL string `json:"url" yaml:"url"`
+
+	// Events are the events that fire the webhook. Empty means all events.
+	Events []WebhookEvent `json:"events" yaml:"events"`
+
+	// Template is a text/template rendering the request body from a
+	// WebhookPayload, e.g. `{"text": "{{.Operation}} {{.Event}}: {{.Error}}"}`.
+	// Empty sends the payload as JSON.
+	Template string `json:"template" yaml:"template"`
+
+	// ContentType is the Content-Type of the request, "application/json"
+	// if empty.
+	ContentType string `json:"contentType" yaml:"contentType"`
+
+	// Headers are added to the request, e.g. an Authorization header.
+	Headers map[string]string `json:"headers" yaml:"headers"`
+
+	// ErrorThreshold is the number of failed files in one run that fires
+	// WebhookErrors. Zero fires it on the first failure.
+	ErrorThreshold int `json:"errorThreshold" yaml:"errorThreshold"`
+
+	// Timeout bounds each request, ten seconds if zero.
+	Timeout time.Duration `json:"-" yaml:"-"`
+}
+
+// WebhookPayload describes the event a Webhook reports. It is the data of
+// Webhook.Template and the default JSON body.
+type WebhookPayload struct {
+	// Event is what fired the webhook.
+	Event WebhookEvent `json:"event"`
+
+	// Operation is the method of the run, e.g. "Crawl" or "Watch".
+	Operation string `json:"operation"`
+
+	// Labels are the Config.RunLabels of the instance.
+	Labels []string `json:"labels,omitempty"`
+
+	// Time is when the event occurred.
+	Time time.Time `json:"time"`
+
+	// Matched, Processed, Skipped and Failed count the files of the run so
+	// far, as in Result.
+	Matched   int `json:"matched"`
+	Processed int `json:"processed"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+
+	// Duration is how long the run has been going, e.g. "1m30s".
+	Duration string `json:"duration"`
+
+	// Error is the error the run ended with, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// validateWebhooks checks the URL, events and template of every webhook.
+func validateWebhooks(webhooks []Webhook) error {
+	for _, w := range webhooks {
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q: expected an http or https URL", w.URL)
+		}
+		for _, event := range w.Events {
+			switch event {
+			case WebhookFinished, WebhookErrors, WebhookStopped:
+			default:
+				return fmt.Errorf("unknown webhook event %q: expected finished, errors or stopped", event)
+			}
+		}
+		if _, err := template.New("webhook").Parse(w.Template); err != nil {
+			return fmt.Errorf("invalid template of webhook %q: %w", w.URL, err)
+		}
+		if w.ErrorThreshold < 0 {
+			return fmt.Errorf("error threshold of webhook %q must not be negative, got %d", w.URL, w.ErrorThreshold)
+		}
+		if w.Timeout < 0 {
+			return fmt.Errorf("timeout of webhook %q must not be negative, got %v", w.URL, w.Timeout)
+		}
+	}
+	return nil
+}
+
+// webhookNotifier sends the configured webhooks.
+type webhookNotifier struct {
+	webhooks  []Webhook
+	templates []*template.Template
+	labels    []string
+	logger    *slog.Logger
+	client    *http.Client
+
+	// pending tracks requests sent in the background.
+	pending sync.WaitGroup
+}
+
+// newWebhookNotifier returns a notifier for the configured webhooks, or nil
+// if there are none.
+func newWebhookNotifier(config *Config) (*webhookNotifier, error) {
+	if len(config.Webhooks) == 0 {
+		return nil, nil
+	}
+	n := &webhookNotifier{
+		webhooks: config.Webhooks,
+		labels:   config.RunLabels,
+		logger:   configLogger(config),
+		client:   &http.Client{},
+	}
+	for _, w := range config.Webhooks {
+		var tmpl *template.Template
+		if w.Template != "" {
+			var err error
+			if tmpl, err = template.New("webhook").Parse(w.Template); err != nil {
+				return nil, fmt.Errorf("invalid template of webhook %q: %w", w.URL, err)
+			}
+		}
+		n.templates = append(n.templates, tmpl)
+	}
+	return n, nil
+}
+
+// failed fires WebhookErrors in the background when the failed files of
+// run reach a threshold. It is called after each failure.
+func (n *webhookNotifier) failed(ctx context.Context, run *runState) {
+	if n == nil {
+		return
+	}
+	failed := run.failed.Load()
+	for i, w := range n.webhooks {
+		if int64(max(w.ErrorThreshold, 1)) != failed || !w.fires(WebhookErrors) {
+			continue
+		}
+		payload := n.payload(WebhookErrors, run, nil)
+		n.pending.Add(1)
+		go func() {
+			defer n.pending.Done()
+			n.send(context.WithoutCancel(ctx), i, payload)
+		}()
+	}
+}
+
+// finished fires WebhookFinished for a finite run that returned err, after
+// the requests still pending for it.
+func (n *webhookNotifier) finished(ctx context.Context, run *runState, err error) {
+	n.notify(ctx, WebhookFinished, run, err)
+}
+
+// stopped fires WebhookStopped for a Watch or Run that returned err, unless
+// ctx was cancelled.
+func (n *webhookNotifier) stopped(ctx context.Context, run *runState, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	n.notify(ctx, WebhookStopped, run, err)
+}
+
+// notify sends the webhooks for event and waits for them.
+func (n *webhookNotifier) notify(ctx context.Context, event WebhookEvent, run *runState, err error) {
+	if n == nil {
+		return
+	}
+	n.pending.Wait()
+
+	payload := n.payload(event, run, err)
+	for i, w := range n.webhooks {
+		if w.fires(event) {
+			n.send(context.WithoutCancel(ctx), i, payload)
+		}
+	}
+}
+
+// fires reports whether event fires w.
+func (w Webhook) fires(event WebhookEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// payload describes event for run, which ended with err.
+func (n *webhookNotifier) payload(event WebhookEvent, run *runState, err error) WebhookPayload {
+	payload := WebhookPayload{
+		Event:     event,
+		Operation: run.operation,
+		Labels:    n.labels,
+		Time:      time.Now(),
+		Matched:   int(run.matched.Load()),
+		Processed: int(run.processed.Load()),
+		Skipped:   int(run.skipped.Load()),
+		Failed:    int(run.failed.Load()),
+		Duration:  time.Since(run.started).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		payload.Error = err.Error()
+	}
+	return payload
+}
+
+// send posts payload to the i-th webhook. Failures are logged, never
+// returned, so notifications cannot break a run.
+func (n *webhookNotifier) send(ctx context.Context, i int, payload WebhookPayload) {
+	w := n.webhooks[i]
+
+	var body bytes.Buffer
+	if tmpl := n.templates[i]; tmpl != nil {
+		if err := tmpl.Execute(&body, payload); err != nil {
+			n.logger.Warn("failed to render webhook", "url", w.URL, "event", payload.Event, "error", err)
+			return
+		}
+	} else if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		n.logger.Warn("failed to encode webhook", "url", w.URL, "event", payload.Event, "error", err)
+		return
+	}
+
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, &body)
+	if err != nil {
+		n.logger.Warn("failed to create webhook request", "url", w.URL, "event", payload.Event, "error", err)
+		return
+	}
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		n.logger.Warn("webhook failed", "url", w.URL, "event", payload.Event, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		n.logger.Warn("webhook failed", "url", w.URL, "event", payload.Event, "status", resp.Status)
+		return
+	}
+	n.logger.Debug("sent webhook", "url", w.URL, "event", payload.Event)
+}
@@ -0,0 +1,222 @@
+package mirrortransform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRecorder is a server collecting the bodies of webhook requests.
+type webhookRecorder struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	bodies []string
+}
+
+// newWebhookRecorder starts a webhookRecorder closed with the test.
+func newWebhookRecorder(t *testing.T) *webhookRecorder {
+	r := &webhookRecorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.bodies = append(r.bodies, string(body))
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// payloads decodes the bodies received so far.
+func (r *webhookRecorder) payloads(t *testing.T) []WebhookPayload {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var payloads []WebhookPayload
+	for _, body := range r.bodies {
+		var payload WebhookPayload
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatalf("Failed to decode webhook %q: %v", body, err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+// TestWebhookCrawl tests that a crawl reports reaching the error threshold
+// and finishing, in that order.
+func TestWebhookCrawl(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.txt", "b.txt", "bad1.txt", "bad2.txt"})
+	recorder := newWebhookRecorder(t)
+
+	config := Config{
+		InputDir:        inputDir,
+		OutputDir:       filepath.Join(testDir, "output"),
+		Patterns:        []string{"**/*.txt"},
+		ContinueOnError: true,
+		RunLabels:       []string{"nightly"},
+		Webhooks:        []Webhook{{URL: recorder.URL, ErrorThreshold: 2}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			if strings.HasPrefix(filepath.Base(inputPath), "bad") {
+				return false, errors.New("corrupt")
+			}
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if err := mt.Crawl(context.Background()); err == nil {
+		t.Fatal("Expected the failures to be reported")
+	}
+
+	payloads := recorder.payloads(t)
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 webhooks, got %+v", payloads)
+	}
+	if p := payloads[0]; p.Event != WebhookErrors || p.Failed != 2 || p.Operation != "Crawl" {
+		t.Errorf("Unexpected errors payload: %+v", p)
+	}
+	p := payloads[1]
+	if p.Event != WebhookFinished || p.Processed != 2 || p.Failed != 2 || p.Error == "" {
+		t.Errorf("Unexpected finished payload: %+v", p)
+	}
+	if len(p.Labels) != 1 || p.Labels[0] != "nightly" {
+		t.Errorf("Expected the run labels, got %v", p.Labels)
+	}
+}
+
+// TestWebhookTemplate tests that templates render the body and that events
+// filter the notifications.
+func TestWebhookTemplate(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"a.txt"})
+	recorder := newWebhookRecorder(t)
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.txt"},
+		Webhooks: []Webhook{{
+			URL:      recorder.URL,
+			Events:   []WebhookEvent{WebhookFinished},
+			Template: `{"text": "{{.Operation}} {{.Event}}: {{.Processed}} processed"}`,
+		}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return true, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+	if _, err := mt.CrawlWithResult(context.Background()); err != nil {
+		t.Fatalf("Crawl failed: %v", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.bodies) != 1 || recorder.bodies[0] != `{"text": "Crawl finished: 1 processed"}` {
+		t.Errorf("Unexpected webhook bodies: %q", recorder.bodies)
+	}
+}
+
+// TestWebhookWatchStopped tests that a Watch ending on its own is reported,
+// and one ending because its context was cancelled is not.
+func TestWebhookWatchStopped(t *testing.T) {
+	t.Parallel()
+	testDir := t.TempDir()
+	inputDir := filepath.Join(testDir, "input")
+	createTestFiles(t, inputDir, []string{"existing.txt"})
+	recorder := newWebhookRecorder(t)
+
+	config := Config{
+		InputDir:  inputDir,
+		OutputDir: filepath.Join(testDir, "output"),
+		Patterns:  []string{"**/*.txt"},
+		Webhooks:  []Webhook{{URL: recorder.URL, Events: []WebhookEvent{WebhookStopped}}},
+		FileCallback: func(inputPath, outputPath string) (bool, error) {
+			return false, nil
+		},
+	}
+
+	mt, err := NewMirrorTransform(&config)
+	if err != nil {
+		t.Fatalf("Failed to create MirrorTransform: %v", err)
+	}
+
+	// Cancelled watches are not reported
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := mt.Watch(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if payloads := recorder.payloads(t); len(payloads) != 0 {
+		t.Fatalf("Expected no webhook, got %+v", payloads)
+	}
+
+	// A callback stopping the watch is
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- mt.Watch(ctx) }()
+	time.Sleep(200 * time.Millisecond)
+	createTestFiles(t, inputDir, []string{"new.txt"})
+
+	if err := <-done; err == nil || ctx.Err() != nil {
+		t.Fatalf("Expected the callback to stop the watch, got %v", err)
+	}
+	payloads := recorder.payloads(t)
+	if len(payloads) != 1 || payloads[0].Event != WebhookStopped || payloads[0].Operation != "Watch" || payloads[0].Error == "" {
+		t.Errorf("Unexpected webhooks: %+v", payloads)
+	}
+}
+
+// TestValidateWebhooks tests that invalid webhooks are rejected.
+func TestValidateWebhooks(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		webhook Webhook
+		wantErr string
+	}{
+		{"valid", Webhook{URL: "https://example.com/hook", Events: []WebhookEvent{WebhookErrors}}, ""},
+		{"missing scheme", Webhook{URL: "example.com/hook"}, "invalid webhook URL"},
+		{"unknown event", Webhook{URL: "https://example.com/hook", Events: []WebhookEvent{"done"}}, "unknown webhook event"},
+		{"invalid template", Webhook{URL: "https://example.com/hook", Template: "{{.Event"}, "invalid template"},
+		{"negative threshold", Webhook{URL: "https://example.com/hook", ErrorThreshold: -1}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		tt := tt // capture range variable
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateWebhooks([]Webhook{tt.webhook})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}